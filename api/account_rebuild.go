@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"go_email/pkg/utils"
+	"log"
+	"sync"
+	"time"
+)
+
+// accountRebuildTimeout 单次按账号后台重建的最长运行时间
+const accountRebuildTimeout = 2 * time.Hour
+
+// accountRebuilder 按账号在后台分批处理历史邮件的任务（会话重建、邮件类型补充等），同一账号同时只运行一个
+type accountRebuilder struct {
+	name string                                                // 协程名前缀，如 thread-rebuild
+	tag  string                                                // 日志标签，如 [会话]
+	task string                                                // 任务名称，用于日志和接口返回的消息
+	run  func(ctx context.Context, accountID int) (int, error) // 返回处理的邮件数
+
+	mu      sync.Mutex
+	running map[int]bool
+}
+
+func newAccountRebuilder(name, tag, task string, run func(ctx context.Context, accountID int) (int, error)) *accountRebuilder {
+	return &accountRebuilder{name: name, tag: tag, task: task, run: run, running: make(map[int]bool)}
+}
+
+// start 在后台为账号启动任务，账号已有执行中的同类任务时返回错误
+func (r *accountRebuilder) start(accountID int) error {
+	r.mu.Lock()
+	if r.running[accountID] {
+		r.mu.Unlock()
+		return fmt.Errorf("账号 %d 的%s正在执行中", accountID, r.task)
+	}
+	r.running[accountID] = true
+	r.mu.Unlock()
+
+	err := utils.GlobalSafeGoroutineManager.StartSafeGoroutineWithTimeout(
+		context.Background(),
+		fmt.Sprintf("%s-%d", r.name, accountID),
+		accountRebuildTimeout,
+		func(ctx context.Context) {
+			defer r.finish(accountID)
+			count, err := r.run(ctx, accountID)
+			log.Printf("%s 账号 %d %s结束，处理邮件: %d，错误: %v", r.tag, accountID, r.task, count, err)
+		},
+	)
+	if err != nil {
+		r.finish(accountID)
+		return fmt.Errorf("启动%s失败: %v", r.task, err)
+	}
+	return nil
+}
+
+// finish 清除账号的执行中标记
+func (r *accountRebuilder) finish(accountID int) {
+	r.mu.Lock()
+	delete(r.running, accountID)
+	r.mu.Unlock()
+}
@@ -0,0 +1,412 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_email/db"
+	"go_email/model"
+	"go_email/pkg/utils"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// backfillSessionTimeout 单个补录协程的运行时长，到期后开启新的协程从断点继续
+	backfillSessionTimeout = 60 * time.Minute
+	// backfillDefaultPageSize 每页获取的邮件数
+	backfillDefaultPageSize = 200
+	// backfillMaxPageSize 每页最多获取的邮件数
+	backfillMaxPageSize = 1000
+	// backfillDateLayout 接口中日期参数的格式
+	backfillDateLayout = "2006-01-02"
+)
+
+// 执行中的补录任务
+var (
+	backfillRunnersMutex sync.Mutex
+	backfillRunners      = make(map[uint]context.CancelFunc) // 任务ID → 取消函数
+)
+
+// BackfillRequest 创建补录任务的请求参数
+type BackfillRequest struct {
+	AccountId int    `json:"account_id" binding:"required"`
+	Folder    string `json:"folder"`    // 为空时补录INBOX
+	Since     string `json:"since"`     // 起始日期（含），格式 2006-01-02，为空表示不限
+	Before    string `json:"before"`    // 截止日期（不含），格式 2006-01-02，为空表示不限
+	PageSize  int    `json:"page_size"` // 每页获取的邮件数，默认200
+}
+
+// BackfillJobRequest 按任务ID操作补录任务的请求参数
+type BackfillJobRequest struct {
+	ID uint `json:"id" binding:"required"`
+}
+
+// parseBackfillDate 解析日期参数，空字符串返回nil
+func parseBackfillDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(backfillDateLayout, value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("日期格式错误(%s)，应为 %s", value, backfillDateLayout)
+	}
+	return &t, nil
+}
+
+// startBackfillRunner 启动补录任务的执行协程
+func startBackfillRunner(job model.PrimeEmailBackfill) error {
+	backfillRunnersMutex.Lock()
+	if _, ok := backfillRunners[job.ID]; ok {
+		backfillRunnersMutex.Unlock()
+		return fmt.Errorf("补录任务 %d 已在执行中", job.ID)
+	}
+	active, err := model.HasActiveBackfillJob(job.AccountId, job.Folder, job.ID)
+	if err != nil {
+		backfillRunnersMutex.Unlock()
+		return fmt.Errorf("检查补录任务失败: %v", err)
+	}
+	if active {
+		backfillRunnersMutex.Unlock()
+		return fmt.Errorf("账号 %d 文件夹 %s 已有执行中的补录任务", job.AccountId, job.Folder)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	backfillRunners[job.ID] = cancel
+	backfillRunnersMutex.Unlock()
+
+	claimed, err := model.ClaimBackfillJob(job.ID, instanceID())
+	if err != nil {
+		finishBackfillRunner(job.ID)
+		return fmt.Errorf("更新补录任务状态失败: %v", err)
+	}
+	if !claimed {
+		finishBackfillRunner(job.ID)
+		return fmt.Errorf("补录任务 %d 正在执行或已完成", job.ID)
+	}
+
+	if err := startBackfillSession(ctx, job.ID); err != nil {
+		finishBackfillRunner(job.ID)
+		_ = model.UpdateBackfillJob(job.ID, map[string]interface{}{"status": model.BackfillStatusFailed, "error_message": err.Error()})
+		return fmt.Errorf("启动补录协程失败: %v", err)
+	}
+	return nil
+}
+
+// finishBackfillRunner 移除执行中的任务记录
+func finishBackfillRunner(jobID uint) {
+	backfillRunnersMutex.Lock()
+	if cancel, ok := backfillRunners[jobID]; ok {
+		cancel()
+		delete(backfillRunners, jobID)
+	}
+	backfillRunnersMutex.Unlock()
+}
+
+// startBackfillSession 开启一个补录会话协程
+func startBackfillSession(jobCtx context.Context, jobID uint) error {
+	return utils.GlobalSafeGoroutineManager.StartSafeGoroutineWithTimeout(
+		jobCtx,
+		fmt.Sprintf("backfill-%d", jobID),
+		backfillSessionTimeout,
+		func(ctx context.Context) {
+			err := runBackfillJob(ctx, jobID)
+
+			// 会话到期但任务未完成也未被取消，开启新的会话从断点继续
+			if jobCtx.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				log.Printf("[历史补录] 任务 %d 会话到期，开启新会话继续", jobID)
+				renewErr := startBackfillSession(jobCtx, jobID)
+				if renewErr == nil {
+					return
+				}
+				err = fmt.Errorf("续期补录协程失败: %v", renewErr)
+			}
+
+			finishBackfillRunner(jobID)
+			updates := map[string]interface{}{"finished_at": time.Now()}
+			switch {
+			case err == nil:
+				updates["status"] = model.BackfillStatusCompleted
+			case jobCtx.Err() != nil:
+				updates["status"] = model.BackfillStatusCanceled
+			default:
+				updates["status"] = model.BackfillStatusFailed
+				updates["error_message"] = err.Error()
+			}
+			if updateErr := model.UpdateBackfillJob(jobID, updates); updateErr != nil {
+				log.Printf("[历史补录] 任务 %d 更新结束状态失败: %v", jobID, updateErr)
+			}
+			log.Printf("[历史补录] 任务 %d 结束，状态: %v，错误: %v", jobID, updates["status"], err)
+		},
+	)
+}
+
+// runBackfillJob 搜索日期范围内的邮件UID，按UID升序分页补录为待处理邮件
+func runBackfillJob(ctx context.Context, jobID uint) error {
+	job, err := model.GetBackfillJob(jobID)
+	if err != nil {
+		return fmt.Errorf("获取补录任务失败: %v", err)
+	}
+	account, err := model.GetAccountByID(job.AccountId)
+	if err != nil {
+		return fmt.Errorf("获取邮箱账号失败: %v", err)
+	}
+	mailClient, err := newMailClient(account)
+	if err != nil {
+		return fmt.Errorf("创建邮件客户端失败: %v", err)
+	}
+
+	var since, before time.Time
+	if job.Since != nil {
+		since = *job.Since
+	}
+	if job.Before != nil {
+		before = *job.Before
+	}
+	search, err := mailClient.SearchUIDsByDate(job.Folder, since, before)
+	if err != nil {
+		return fmt.Errorf("搜索邮件失败: %v", err)
+	}
+
+	// UIDVALIDITY变化后旧的断点不再可用，从头补录（已存在的记录会被跳过）
+	if job.UidValidity != 0 && job.UidValidity != search.UIDValidity {
+		log.Printf("[历史补录] 任务 %d 文件夹 %s UIDVALIDITY变化: %d → %d，从头补录",
+			job.ID, job.Folder, job.UidValidity, search.UIDValidity)
+		job.LastUID = 0
+		job.ProcessedCount, job.CreatedCount, job.SkippedCount = 0, 0, 0
+	}
+	job.UidValidity = search.UIDValidity
+
+	// 跳过断点之前已处理的UID
+	pending := search.UIDs
+	for len(pending) > 0 && pending[0] <= job.LastUID {
+		pending = pending[1:]
+	}
+	if err := model.UpdateBackfillJob(job.ID, map[string]interface{}{
+		"uid_validity":    job.UidValidity,
+		"last_uid":        job.LastUID,
+		"total_count":     len(search.UIDs),
+		"processed_count": len(search.UIDs) - len(pending),
+		"created_count":   job.CreatedCount,
+		"skipped_count":   job.SkippedCount,
+	}); err != nil {
+		return fmt.Errorf("更新补录进度失败: %v", err)
+	}
+	log.Printf("[历史补录] 任务 %d 账号 %d 文件夹 %s: 共 %d 封，待补录 %d 封，断点UID: %d",
+		job.ID, account.ID, job.Folder, len(search.UIDs), len(pending), job.LastUID)
+
+	pageSize := job.PageSize
+	if pageSize <= 0 {
+		pageSize = backfillDefaultPageSize
+	}
+	processed := len(search.UIDs) - len(pending)
+	for start := 0; start < len(pending); start += pageSize {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		page := pending[start:min(start+pageSize, len(pending))]
+		emails, err := mailClient.ListEmailsByUIDs(job.Folder, page)
+		if err != nil {
+			return fmt.Errorf("获取邮件列表失败: %v", err)
+		}
+
+		emailList := make([]*model.PrimeEmail, 0, len(emails))
+		for _, email := range emails {
+			emailList = append(emailList, newPrimeEmail(account, job.Folder, search.UIDValidity, email))
+		}
+
+		created, skipped, err := saveEmailPage(emailList)
+		if err != nil {
+			return err
+		}
+		processed += len(page)
+		job.LastUID = page[len(page)-1]
+		job.CreatedCount += created
+		job.SkippedCount += skipped
+
+		if err := model.UpdateBackfillJob(job.ID, map[string]interface{}{
+			"last_uid":        job.LastUID,
+			"processed_count": processed,
+			"created_count":   job.CreatedCount,
+			"skipped_count":   job.SkippedCount,
+		}); err != nil {
+			return fmt.Errorf("更新补录进度失败: %v", err)
+		}
+		log.Printf("[历史补录] 任务 %d 进度: %d/%d，新建: %d，跳过: %d，断点UID: %d",
+			job.ID, processed, len(search.UIDs), job.CreatedCount, job.SkippedCount, job.LastUID)
+	}
+	return nil
+}
+
+// saveEmailPage 在事务中保存一页补录的邮件记录，已存在的记录跳过
+func saveEmailPage(emailList []*model.PrimeEmail) (int, int, error) {
+	if len(emailList) == 0 {
+		return 0, 0, nil
+	}
+
+	tx := db.DB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			log.Printf("[邮件补录] 保存邮件时发生异常: %v", r)
+		}
+	}()
+
+	result, err := model.BatchCreateEmailsWithStats(emailList, tx)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("批量创建邮件记录失败: %v", err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, 0, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return result.SuccessCount, result.SkippedCount, nil
+}
+
+// ResetInterruptedBackfillJobs 服务启动时把本实例上次中断的补录任务标记为失败，可通过接口重新启动从断点继续
+func ResetInterruptedBackfillJobs() {
+	count, err := model.ResetRunningBackfillJobs(instanceID())
+	if err != nil {
+		log.Printf("[历史补录] 重置中断的补录任务失败: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("[历史补录] %d 个补录任务在上次运行时中断，已标记为失败，可重新启动从断点继续", count)
+	}
+}
+
+// CreateBackfill 创建并启动历史邮件补录任务
+func CreateBackfill(c *gin.Context) {
+	var req BackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, err, "无效的参数")
+		return
+	}
+
+	since, err := parseBackfillDate(req.Since)
+	if err != nil {
+		utils.SendResponse(c, err, nil)
+		return
+	}
+	before, err := parseBackfillDate(req.Before)
+	if err != nil {
+		utils.SendResponse(c, err, nil)
+		return
+	}
+	if since != nil && before != nil && !before.After(*since) {
+		utils.SendResponse(c, errors.New("截止日期必须晚于起始日期"), nil)
+		return
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = backfillDefaultPageSize
+	}
+	if req.PageSize > backfillMaxPageSize {
+		req.PageSize = backfillMaxPageSize
+	}
+	if req.Folder == "" {
+		req.Folder = model.DefaultFolder
+	}
+
+	account, err := model.GetAccountByID(req.AccountId)
+	if err != nil {
+		utils.SendResponse(c, err, "获取邮箱账号失败")
+		return
+	}
+
+	job := model.PrimeEmailBackfill{
+		AccountId: account.ID,
+		Folder:    req.Folder,
+		Since:     since,
+		Before:    before,
+		PageSize:  req.PageSize,
+	}
+	if err := model.CreateBackfillJob(&job); err != nil {
+		utils.SendResponse(c, err, "创建补录任务失败")
+		return
+	}
+	if err := startBackfillRunner(job); err != nil {
+		utils.SendResponse(c, err, job)
+		return
+	}
+
+	log.Printf("[历史补录] 创建任务 %d: 账号 %d 文件夹 %s 日期范围 [%s, %s)", job.ID, account.ID, job.Folder, req.Since, req.Before)
+	utils.SendResponse(c, nil, job)
+}
+
+// StartBackfill 重新启动失败或已取消的补录任务，从断点继续
+func StartBackfill(c *gin.Context) {
+	var req BackfillJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, err, "无效的参数")
+		return
+	}
+
+	job, err := model.GetBackfillJob(req.ID)
+	if err != nil {
+		utils.SendResponse(c, err, "获取补录任务失败")
+		return
+	}
+	if job.Status == model.BackfillStatusCompleted {
+		utils.SendResponse(c, errors.New("补录任务已完成"), job)
+		return
+	}
+	if job.Status == model.BackfillStatusRunning {
+		utils.SendResponse(c, fmt.Errorf("补录任务正在实例 %s 上执行", job.Owner), job)
+		return
+	}
+	if err := startBackfillRunner(job); err != nil {
+		utils.SendResponse(c, err, nil)
+		return
+	}
+	utils.SendResponse(c, nil, fmt.Sprintf("补录任务 %d 已启动，从UID %d 继续", job.ID, job.LastUID))
+}
+
+// CancelBackfill 取消执行中的补录任务
+func CancelBackfill(c *gin.Context) {
+	var req BackfillJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, err, "无效的参数")
+		return
+	}
+
+	backfillRunnersMutex.Lock()
+	cancel, ok := backfillRunners[req.ID]
+	backfillRunnersMutex.Unlock()
+	if !ok {
+		utils.SendResponse(c, fmt.Errorf("补录任务 %d 不在执行中", req.ID), nil)
+		return
+	}
+	cancel()
+	utils.SendResponse(c, nil, fmt.Sprintf("补录任务 %d 已取消", req.ID))
+}
+
+// GetBackfillStatus 查询补录任务进度，传入id时返回单个任务，否则按account_id列出最近的任务
+func GetBackfillStatus(c *gin.Context) {
+	if idStr := c.Query("id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			utils.SendResponse(c, err, "无效的任务ID")
+			return
+		}
+		job, err := model.GetBackfillJob(uint(id))
+		if err != nil {
+			utils.SendResponse(c, err, "获取补录任务失败")
+			return
+		}
+		utils.SendResponse(c, nil, job)
+		return
+	}
+
+	accountID, _ := strconv.Atoi(c.Query("account_id"))
+	jobs, err := model.ListBackfillJobs(accountID, 50)
+	if err != nil {
+		utils.SendResponse(c, err, "获取补录任务失败")
+		return
+	}
+	utils.SendResponse(c, nil, jobs)
+}
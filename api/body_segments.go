@@ -0,0 +1,17 @@
+package api
+
+import (
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"go_email/pkg/utils"
+)
+
+// fillBodySegments 按已确定的正文和HTML正文（内嵌图片已替换）切分出新写的内容、引用、签名和免责声明
+func fillBodySegments(emailContent *model.PrimeEmailContent) {
+	seg := mailclient.SegmentBody(emailContent.Content, emailContent.HTMLContent)
+	emailContent.NewContent = utils.SanitizeUTF8(seg.NewText)
+	emailContent.NewHTMLContent = utils.SanitizeUTF8(seg.NewHTML)
+	emailContent.QuotedContent = utils.SanitizeUTF8(seg.Quoted)
+	emailContent.Signature = utils.SanitizeUTF8(seg.Signature)
+	emailContent.Disclaimer = utils.SanitizeUTF8(seg.Disclaimer)
+}
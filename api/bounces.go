@@ -0,0 +1,100 @@
+package api
+
+import (
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"go_email/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// buildBounces 根据解析出的退信创建退信记录，内容ID在保存邮件内容后填写，不是退信时返回nil
+func buildBounces(accountID int, emailOne model.PrimeEmail, folder string, email *mailclient.Email) []*model.PrimeEmailBounce {
+	report := email.Bounce
+	if report == nil {
+		return nil
+	}
+	bounces := make([]*model.PrimeEmailBounce, 0, len(report.Recipients))
+	for _, r := range report.Recipients {
+		bounce := &model.PrimeEmailBounce{
+			EmailID:           emailOne.EmailID,
+			AccountId:         accountID,
+			Folder:            folder,
+			OriginalMessageID: truncateRunes(utils.SanitizeUTF8(report.OriginalMessageID), 255),
+			OriginalSubject:   truncateRunes(utils.SanitizeUTF8(report.OriginalSubject), 1000),
+			Recipient:         truncateRunes(utils.SanitizeUTF8(r.Recipient), 255),
+			Action:            truncateRunes(r.Action, 16),
+			Status:            truncateRunes(r.Status, 32),
+			DiagnosticCode:    utils.SanitizeUTF8(r.DiagnosticCode),
+			RemoteMTA:         truncateRunes(utils.SanitizeUTF8(r.RemoteMTA), 255),
+			ReportingMTA:      truncateRunes(utils.SanitizeUTF8(report.ReportingMTA), 255),
+			Standard:          report.Standard,
+			CreatedAt:         utils.JsonTime{Time: time.Now()},
+		}
+		if !email.SentAt.IsZero() {
+			bounce.ReportedAt = utils.NewUTCTime(email.SentAt)
+		}
+		bounces = append(bounces, bounce)
+	}
+	return bounces
+}
+
+// pageParams 读取分页参数，page_size 默认50，最大500
+func pageParams(c *gin.Context) (offset, limit int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 500 {
+		pageSize = 50
+	}
+	return (page - 1) * pageSize, pageSize
+}
+
+// GetBounces 查询退信记录，可按账号、发出的邮件、收件人和动作(failed/delayed/delivered)过滤
+func GetBounces(c *gin.Context) {
+	accountID, _ := strconv.Atoi(c.Query("account_id"))
+	outboundID, _ := strconv.ParseUint(c.Query("outbound_id"), 10, 64)
+	recipient, _ := mailclient.NormalizeAddress(c.Query("recipient"))
+	offset, limit := pageParams(c)
+
+	bounces, total, err := model.ListBounces(model.BounceQuery{
+		AccountID:  accountID,
+		OutboundID: uint(outboundID),
+		Recipient:  recipient,
+		Action:     strings.ToLower(c.Query("action")),
+		Offset:     offset,
+		Limit:      limit,
+	})
+	if err != nil {
+		utils.SendResponse(c, err, "查询退信记录失败")
+		return
+	}
+	utils.SendResponse(c, nil, gin.H{"total": total, "list": bounces})
+}
+
+// GetOutbounds 查询本服务发出的邮件及投递状态，status=-1 时列出有退信的转发和通知
+func GetOutbounds(c *gin.Context) {
+	accountID, _ := strconv.Atoi(c.Query("account_id"))
+	query := model.OutboundQuery{AccountID: accountID, Kind: c.Query("kind")}
+	if statusStr := c.Query("status"); statusStr != "" {
+		status, err := strconv.Atoi(statusStr)
+		if err != nil {
+			utils.SendResponse(c, err, "无效的状态")
+			return
+		}
+		query.Status = &status
+	}
+	query.Offset, query.Limit = pageParams(c)
+
+	outbounds, total, err := model.ListOutbounds(query)
+	if err != nil {
+		utils.SendResponse(c, err, "查询发出的邮件失败")
+		return
+	}
+	utils.SendResponse(c, nil, gin.H{"total": total, "list": outbounds})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"go_email/pkg/utils"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// classifyRebuildBatchSize 补充邮件类型时每批处理的邮件数
+const classifyRebuildBatchSize = 500
+
+// classifyRebuilder 按账号在后台补充邮件类型
+var classifyRebuilder = newAccountRebuilder("classify-rebuild", "[邮件分类]", "邮件类型补充", rebuildAccountEmailTypes)
+
+// ClassifyRebuildRequest 补充邮件类型请求
+type ClassifyRebuildRequest struct {
+	AccountID int `json:"account_id" binding:"required"`
+}
+
+// RebuildEmailTypes 按保存的邮件头为账号中尚未分类的历史邮件补充邮件类型，在后台按内容ID升序分批执行
+func RebuildEmailTypes(c *gin.Context) {
+	var req ClassifyRebuildRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, err, "无效的参数")
+		return
+	}
+
+	if err := classifyRebuilder.start(req.AccountID); err != nil {
+		utils.SendResponse(c, err, nil)
+		return
+	}
+	utils.SendResponse(c, nil, fmt.Sprintf("账号 %d 的邮件类型补充已在后台启动", req.AccountID))
+}
+
+// rebuildAccountEmailTypes 分批为账号中type为0的邮件计算邮件类型，同一类型的邮件一次更新
+func rebuildAccountEmailTypes(ctx context.Context, accountID int) (int, error) {
+	var afterID uint
+	processed := 0
+	for {
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+		contents, err := model.GetUnclassifiedContents(accountID, afterID, classifyRebuildBatchSize)
+		if err != nil {
+			return processed, fmt.Errorf("获取邮件失败: %v", err)
+		}
+		if len(contents) == 0 {
+			return processed, nil
+		}
+
+		idsByType := make(map[int][]uint)
+		for i := range contents {
+			emailType := classifyStoredContent(&contents[i])
+			idsByType[emailType] = append(idsByType[emailType], contents[i].ID)
+		}
+		for emailType, ids := range idsByType {
+			if err := model.UpdateContentTypes(ids, emailType); err != nil {
+				return processed, fmt.Errorf("更新邮件类型失败: %v", err)
+			}
+		}
+		processed += len(contents)
+		afterID = contents[len(contents)-1].ID
+		log.Printf("[邮件分类] 账号 %d 邮件类型补充进度: 已处理 %d，断点内容ID: %d", accountID, processed, afterID)
+	}
+}
+
+// classifyStoredContent 按保存的全部邮件头计算邮件类型，早期保存的邮件没有全部邮件头时只按发件人和主题判断
+func classifyStoredContent(content *model.PrimeEmailContent) int {
+	headers := make(map[string][]string)
+	if content.RawHeaders != "" {
+		if err := json.Unmarshal([]byte(content.RawHeaders), &headers); err != nil {
+			log.Printf("[邮件分类] 解析邮件头失败，内容ID: %d, 错误: %v", content.ID, err)
+		}
+	}
+	if len(headers["From"]) == 0 && content.FromEmail != "" {
+		headers["From"] = []string{content.FromEmail}
+	}
+	return mailclient.ClassifyEmail(headers, content.Subject)
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// contentFetchBatchSize 账号内容同步每条 UID FETCH 获取的邮件数，1表示逐封获取
+func contentFetchBatchSize(account model.PrimeEmailAccount) int {
+	if account.FetchBatchSize > 0 {
+		return account.FetchBatchSize
+	}
+	if size := viper.GetInt("sync.fetch_batch_size"); size > 0 {
+		return size
+	}
+	return 1
+}
+
+// attachmentWorkerCount 账号内容同步处理附件的并发数
+func attachmentWorkerCount(account model.PrimeEmailAccount) int {
+	if account.AttachmentWorkers > 0 {
+		return account.AttachmentWorkers
+	}
+	if workers := viper.GetInt("sync.attachment_workers"); workers > 0 {
+		return workers
+	}
+	return 4
+}
+
+// contentJob 已获取并解析的邮件，等待上传附件并生成内容记录
+type contentJob struct {
+	emailOne model.PrimeEmail
+	folder   string
+	email    *mailclient.Email
+}
+
+// contentFetcher 批量获取邮件内容，*mailclient.MailClient 满足该接口，测试时可替换
+type contentFetcher interface {
+	FetchEmailContents(folder string, uids []uint32, skipAttachments bool, handle func(mailclient.FetchResult)) error
+}
+
+// 流水线中访问数据库和对象存储的步骤，测试中可替换
+var (
+	pipelineResetStatus  = resetEmailStatus
+	pipelineBuildContent = buildEmailContentData
+	pipelineSaveContents = batchSaveEmailContents
+)
+
+// syncAccountEmailContentPipelined 批量获取邮件内容：按文件夹分批，每批一条 UID FETCH，
+// 一批读取完成后交给固定数量的工作协程上传附件，最后统一保存。
+// 读取FETCH响应时只解析并暂存邮件，不等待上传附件的工作协程，避免服务器因响应读取停滞而超时
+func syncAccountEmailContentPipelined(mailClient contentFetcher, account model.PrimeEmailAccount, accountEmails []model.PrimeEmail, batchSize int, ctx context.Context) (int, error) {
+	workers := attachmentWorkerCount(account)
+	log.Printf("[批量内容同步] 账号 %d - 待处理 %d 封，每批 %d 封，附件并发 %d", account.ID, len(accountEmails), batchSize, workers)
+
+	startTime := time.Now()
+	deadline, hasDeadline := ctx.Deadline()
+	safeTimeLimit := deadline.Add(-2 * time.Minute)
+
+	// 按文件夹分组，保持原有顺序
+	var folders []string
+	byFolder := make(map[string][]model.PrimeEmail)
+	for _, emailOne := range accountEmails {
+		folder := emailOne.GetFolder()
+		if _, ok := byFolder[folder]; !ok {
+			folders = append(folders, folder)
+		}
+		byFolder[folder] = append(byFolder[folder], emailOne)
+	}
+
+	var (
+		mu              sync.Mutex
+		allEmailData    = make([]EmailContentData, 0, len(accountEmails))
+		handled         = make(map[uint]bool, len(accountEmails)) // PrimeEmail主键 → 已处理（成功或已设置失败状态）
+		failureCount    int
+		attachmentCount int
+		totalOSSTime    time.Duration
+	)
+
+	markFailed := func(emailOne model.PrimeEmail, err error) {
+		log.Printf("[批量内容同步] 获取邮件内容失败，邮件ID: %d, 文件夹: %s, 错误: %v", emailOne.EmailID, emailOne.GetFolder(), err)
+		if resetErr := pipelineResetStatus(emailOne.ID, contentFailureStatus(err, emailOne.EmailID)); resetErr != nil {
+			log.Printf("[批量内容同步] 设置邮件状态失败，邮件ID: %d, 错误: %v", emailOne.EmailID, resetErr)
+		}
+		mu.Lock()
+		handled[emailOne.ID] = true
+		failureCount++
+		mu.Unlock()
+	}
+
+	// 附件工作协程
+	jobs := make(chan contentJob, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							log.Printf("[批量内容同步] 处理邮件时发生异常，邮件ID: %d, 错误: %v", job.emailOne.EmailID, r)
+							markFailed(job.emailOne, fmt.Errorf("处理邮件时发生异常: %v", r))
+						}
+					}()
+					emailData, ossTime, count := pipelineBuildContent(account, job.emailOne, job.folder, job.email)
+					mu.Lock()
+					allEmailData = append(allEmailData, emailData)
+					handled[job.emailOne.ID] = true
+					attachmentCount += count
+					totalOSSTime += ossTime
+					mu.Unlock()
+				}()
+			}
+		}()
+	}
+
+	var stopErr error
+	fetched := 0
+fetchLoop:
+	for _, folder := range folders {
+		emails := byFolder[folder]
+		for start := 0; start < len(emails); start += batchSize {
+			select {
+			case <-ctx.Done():
+				stopErr = ctx.Err()
+				log.Printf("[批量内容同步] 上下文已取消，停止获取")
+				break fetchLoop
+			default:
+			}
+			if hasDeadline && time.Now().After(safeTimeLimit) {
+				stopErr = fmt.Errorf("达到安全时限，提前停止处理")
+				log.Printf("[批量内容同步] 已接近安全时限，提前停止获取，安全时限: %v", safeTimeLimit)
+				break fetchLoop
+			}
+
+			end := min(start+batchSize, len(emails))
+			batch := make(map[uint32]model.PrimeEmail, end-start)
+			uids := make([]uint32, 0, end-start)
+			for _, emailOne := range emails[start:end] {
+				uid := uint32(emailOne.EmailID)
+				batch[uid] = emailOne
+				uids = append(uids, uid)
+			}
+
+			batchStart := time.Now()
+			parsed := make([]contentJob, 0, len(uids))
+			err := mailClient.FetchEmailContents(folder, uids, false, func(result mailclient.FetchResult) {
+				emailOne, ok := batch[result.UID]
+				if !ok {
+					return
+				}
+				delete(batch, result.UID)
+				if result.Err != nil {
+					markFailed(emailOne, result.Err)
+					return
+				}
+				parsed = append(parsed, contentJob{emailOne: emailOne, folder: folder, email: result.Email})
+			})
+			for _, job := range parsed {
+				jobs <- job
+			}
+			fetched += len(uids) - len(batch)
+			if err != nil {
+				// 本批中没有回调的邮件按错误类型设置状态
+				for _, emailOne := range batch {
+					markFailed(emailOne, err)
+				}
+			}
+			log.Printf("[批量内容同步] 账号 %d 文件夹 %s 获取 %d 封，耗时: %v，进度: %d/%d",
+				account.ID, folder, len(uids), time.Since(batchStart), fetched, len(accountEmails))
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	// 提前停止时，尚未处理的邮件从0（处理中）回到-1（待处理）
+	if stopErr != nil {
+		resetCount := 0
+		for _, emailOne := range accountEmails {
+			if handled[emailOne.ID] {
+				continue
+			}
+			if err := pipelineResetStatus(emailOne.ID, -1); err != nil {
+				log.Printf("[批量内容同步] 重置邮件状态失败，记录ID: %d, 错误: %v", emailOne.ID, err)
+			} else {
+				resetCount++
+			}
+		}
+		log.Printf("[批量内容同步] 已重置 %d 封未处理邮件的状态为-1，等待下次同步", resetCount)
+	}
+
+	totalDuration := time.Since(startTime)
+	successCount := len(allEmailData)
+	if successCount > 0 {
+		log.Printf("[性能统计] 账号 %d 批量处理完成 - 成功: %d, 失败: %d, 总耗时: %v, 平均每邮件: %v, 总附件: %d, 平均OSS: %v",
+			account.ID, successCount, failureCount, totalDuration, totalDuration/time.Duration(successCount),
+			attachmentCount, totalOSSTime/time.Duration(max(attachmentCount, 1)))
+
+		saveStartTime := time.Now()
+		if err := pipelineSaveContents(allEmailData); err != nil {
+			log.Printf("[批量内容同步] 批量保存邮件内容失败: %v", err)
+			return 0, fmt.Errorf("批量保存邮件内容失败: %v", err)
+		}
+		log.Printf("[批量内容同步] 账号 %d 批量保存完成: 成功 %d 封，失败 %d 封，保存耗时: %v",
+			account.ID, successCount, failureCount, time.Since(saveStartTime))
+	}
+
+	return successCount, stopErr
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFetcher 按UID返回预设结果的批量获取桩，fetch 为空时每封邮件都返回成功
+type fakeFetcher struct {
+	fetch func(folder string, uids []uint32, handle func(mailclient.FetchResult)) error
+	calls [][]uint32
+}
+
+func (f *fakeFetcher) FetchEmailContents(folder string, uids []uint32, skipAttachments bool, handle func(mailclient.FetchResult)) error {
+	f.calls = append(f.calls, uids)
+	if f.fetch != nil {
+		return f.fetch(folder, uids, handle)
+	}
+	for _, uid := range uids {
+		handle(mailclient.FetchResult{UID: uid, Email: &mailclient.Email{Subject: "ok"}})
+	}
+	return nil
+}
+
+// pipelineRecorder 替换流水线中访问数据库和对象存储的步骤，记录状态重置和保存的邮件
+type pipelineRecorder struct {
+	mu       sync.Mutex
+	statuses map[uint]int
+	saved    []int
+	build    func(emailOne model.PrimeEmail)
+}
+
+func stubPipeline(t *testing.T) *pipelineRecorder {
+	t.Helper()
+	rec := &pipelineRecorder{statuses: make(map[uint]int)}
+	origReset, origBuild, origSave := pipelineResetStatus, pipelineBuildContent, pipelineSaveContents
+	t.Cleanup(func() {
+		pipelineResetStatus, pipelineBuildContent, pipelineSaveContents = origReset, origBuild, origSave
+	})
+	pipelineResetStatus = func(id uint, status int) error {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.statuses[id] = status
+		return nil
+	}
+	pipelineBuildContent = func(account model.PrimeEmailAccount, emailOne model.PrimeEmail, folder string, email *mailclient.Email) (EmailContentData, time.Duration, int) {
+		if rec.build != nil {
+			rec.build(emailOne)
+		}
+		return EmailContentData{PrimeEmailID: emailOne.ID, EmailID: emailOne.EmailID}, 0, 0
+	}
+	pipelineSaveContents = func(list []EmailContentData) error {
+		for _, data := range list {
+			rec.saved = append(rec.saved, data.EmailID)
+		}
+		return nil
+	}
+	return rec
+}
+
+func testEmails(uids ...int) []model.PrimeEmail {
+	emails := make([]model.PrimeEmail, 0, len(uids))
+	for i, uid := range uids {
+		emails = append(emails, model.PrimeEmail{ID: uint(i + 1), EmailID: uid, Folder: "INBOX"})
+	}
+	return emails
+}
+
+func TestPipelineFailureStatuses(t *testing.T) {
+	rec := stubPipeline(t)
+	fetcher := &fakeFetcher{fetch: func(folder string, uids []uint32, handle func(mailclient.FetchResult)) error {
+		handle(mailclient.FetchResult{UID: 101, Email: &mailclient.Email{}})
+		handle(mailclient.FetchResult{UID: 102, Err: errors.New("邮件不存在: UID=102")})
+		// 103 没有回调，按整批的错误设置状态
+		return errors.New("connection reset by peer")
+	}}
+
+	count, err := syncAccountEmailContentPipelined(fetcher, model.PrimeEmailAccount{ID: 1, AttachmentWorkers: 2},
+		testEmails(101, 102, 103), 10, context.Background())
+	if err != nil || count != 1 {
+		t.Fatalf("期望成功1封且没有错误，实际 %d, %v", count, err)
+	}
+	if len(rec.saved) != 1 || rec.saved[0] != 101 {
+		t.Errorf("保存的邮件错误: %v", rec.saved)
+	}
+	if rec.statuses[2] != -3 || rec.statuses[3] != -1 {
+		t.Errorf("失败邮件的状态错误: %v", rec.statuses)
+	}
+	if _, ok := rec.statuses[1]; ok {
+		t.Errorf("成功的邮件不应重置状态: %v", rec.statuses)
+	}
+}
+
+func TestPipelineResetsUnhandledOnCancel(t *testing.T) {
+	rec := stubPipeline(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fetcher := &fakeFetcher{}
+	fetcher.fetch = func(folder string, uids []uint32, handle func(mailclient.FetchResult)) error {
+		for _, uid := range uids {
+			handle(mailclient.FetchResult{UID: uid, Email: &mailclient.Email{}})
+		}
+		// 第一批完成后取消，后面的批次不再获取
+		cancel()
+		return nil
+	}
+
+	count, err := syncAccountEmailContentPipelined(fetcher, model.PrimeEmailAccount{ID: 1, AttachmentWorkers: 1},
+		testEmails(101, 102, 103), 1, ctx)
+	if !errors.Is(err, context.Canceled) || count != 1 {
+		t.Fatalf("期望取消错误且成功1封，实际 %d, %v", count, err)
+	}
+	if len(fetcher.calls) != 1 {
+		t.Errorf("取消后不应继续获取: %v", fetcher.calls)
+	}
+	if rec.statuses[2] != -1 || rec.statuses[3] != -1 || len(rec.statuses) != 2 {
+		t.Errorf("未处理的邮件应重置为-1: %v", rec.statuses)
+	}
+}
+
+func TestPipelineFetchDoesNotWaitForWorkers(t *testing.T) {
+	rec := stubPipeline(t)
+	fetchDone := make(chan struct{})
+	// 上传附件要等到整批FETCH读取完成才能继续，读取时若等待工作协程就会死锁
+	rec.build = func(model.PrimeEmail) { <-fetchDone }
+	fetcher := &fakeFetcher{}
+	fetcher.fetch = func(folder string, uids []uint32, handle func(mailclient.FetchResult)) error {
+		for _, uid := range uids {
+			handle(mailclient.FetchResult{UID: uid, Email: &mailclient.Email{}})
+		}
+		close(fetchDone)
+		return nil
+	}
+
+	result := make(chan int, 1)
+	go func() {
+		count, _ := syncAccountEmailContentPipelined(fetcher, model.PrimeEmailAccount{ID: 1, AttachmentWorkers: 1},
+			testEmails(101, 102, 103, 104, 105), 10, context.Background())
+		result <- count
+	}()
+	select {
+	case count := <-result:
+		if count != 5 {
+			t.Errorf("期望成功5封，实际 %d", count)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("读取FETCH响应时被工作协程阻塞")
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"go_email/db"
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"log"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// flagFullFetchInterval 服务器不支持CONDSTORE时全量获取标记的最小间隔
+func flagFullFetchInterval() time.Duration {
+	if minutes := viper.GetInt("sync.flag_full_fetch_minutes"); minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 30 * time.Minute
+}
+
+// flagKey 标记组合，相同组合的邮件合并为一条UPDATE
+type flagKey struct {
+	seen, flagged, deleted bool
+}
+
+// reconcileFolderFlags 对账文件夹中已保存邮件的已读、星标、删除标记，并标记服务器上已不存在的邮件
+func reconcileFolderFlags(mailClient *mailclient.MailClient, account model.PrimeEmailAccount, folder string) error {
+	state, err := model.GetEmailFolderWithTx(db.DB(), account.ID, folder)
+	if err != nil {
+		return fmt.Errorf("获取文件夹同步状态失败: %v", err)
+	}
+
+	// 没有MODSEQ时只能全量获取，按间隔节流，避免大文件夹每次同步都全量拉取标记
+	if state.HighestModSeq == 0 && state.FlagsSyncedAt != nil && time.Since(*state.FlagsSyncedAt) < flagFullFetchInterval() {
+		return nil
+	}
+
+	result, err := mailClient.FetchFlagChanges(folder, state.HighestModSeq)
+	if err != nil {
+		return err
+	}
+	if result.UIDValidity != state.UidValidity {
+		// UIDVALIDITY在列表同步之后又发生了变化，留给下次列表同步处理
+		log.Printf("[标记对账] 账号ID %d 文件夹 %s UIDVALIDITY不一致(%d/%d)，跳过本次对账",
+			account.ID, folder, state.UidValidity, result.UIDValidity)
+		return nil
+	}
+
+	groups := make(map[flagKey][]uint32)
+	for _, change := range result.Changes {
+		key := flagKey{seen: change.Seen, flagged: change.Flagged, deleted: change.Deleted}
+		groups[key] = append(groups[key], change.UID)
+	}
+
+	tx := db.DB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			log.Printf("[标记对账] 对账时发生异常: %v", r)
+		}
+	}()
+
+	var updatedCount int64
+	for key, uids := range groups {
+		count, err := model.UpdateEmailFlagsWithTx(tx, account.ID, folder, state.UidValidity, key.seen, key.flagged, key.deleted, uids)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		updatedCount += count
+	}
+
+	knownUIDs, err := model.GetKnownUIDsWithTx(tx, account.ID, folder, state.UidValidity)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("获取已保存邮件UID失败: %v", err)
+	}
+	vanished := mailclient.VanishedUIDs(knownUIDs, result.ExistingUIDs)
+	expungedCount, err := model.MarkExpungedEmailsWithTx(tx, account.ID, folder, state.UidValidity, vanished)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := model.SaveFolderFlagStateWithTx(tx, account.ID, folder, result.HighestModSeq); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("保存文件夹对账状态失败: %v", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	log.Printf("[标记对账] 账号ID %d 文件夹 %s: 标记变化 %d 封(更新 %d 条)，已删除 %d 封，HIGHESTMODSEQ: %d → %d",
+		account.ID, folder, len(result.Changes), updatedCount, expungedCount, state.HighestModSeq, result.HighestModSeq)
+	return nil
+}
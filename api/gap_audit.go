@@ -0,0 +1,197 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"go_email/db"
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"go_email/pkg/utils"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// gapAuditPageSize 补录缺失邮件时每页获取的邮件数
+const gapAuditPageSize = 200
+
+// gapAuditInterval 同一文件夹两次UID缺口检查的最小间隔，配置为负数时关闭自动检查
+func gapAuditInterval() time.Duration {
+	minutes := viper.GetInt("sync.gap_audit_minutes")
+	if minutes == 0 {
+		minutes = 360
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GapAuditRequest 手动检查UID缺口的请求参数
+type GapAuditRequest struct {
+	AccountId int    `json:"account_id" binding:"required"`
+	Folder    string `json:"folder"` // 为空时检查账号所有已同步的文件夹
+}
+
+// GapAuditResult 单个文件夹的UID缺口检查结果
+type GapAuditResult struct {
+	Folder       string   `json:"folder"`
+	ServerCount  int      `json:"server_count"`  // 服务器上检查范围内的邮件数
+	StoredCount  int      `json:"stored_count"`  // 数据库中已保存的邮件数
+	MissingUIDs  []uint32 `json:"missing_uids"`  // 服务器上存在但没有保存的UID
+	CreatedCount int      `json:"created_count"` // 补录的邮件数
+	Skipped      bool     `json:"skipped"`       // 未到检查间隔或没有游标而跳过
+}
+
+// auditFolderGaps 对比服务器UID与已保存的email_id，把游标范围内缺失的邮件补录为待处理邮件
+// 只检查已保存的最小UID到游标之间的范围：最小UID之前的旧邮件由历史补录任务负责，游标之后的由列表同步负责
+func auditFolderGaps(mailClient *mailclient.MailClient, account model.PrimeEmailAccount, folder string, force bool) (*GapAuditResult, error) {
+	result := &GapAuditResult{Folder: folder}
+
+	state, err := model.GetEmailFolderWithTx(db.DB(), account.ID, folder)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		result.Skipped = true
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取文件夹同步状态失败: %v", err)
+	}
+	if state.LastUID == 0 {
+		result.Skipped = true
+		return result, nil
+	}
+	if !force {
+		interval := gapAuditInterval()
+		if interval < 0 || (state.GapAuditedAt != nil && time.Since(*state.GapAuditedAt) < interval) {
+			result.Skipped = true
+			return result, nil
+		}
+	}
+
+	// UIDVALIDITY变化后旧记录还在等待按Message-ID重新关联，此时补录会产生重复记录
+	staleCount, err := model.CountStaleEmailsWithTx(db.DB(), account.ID, folder, state.UidValidity)
+	if err != nil {
+		return nil, fmt.Errorf("统计失效邮件失败: %v", err)
+	}
+	if staleCount > 0 {
+		log.Printf("[缺口检查] 账号ID %d 文件夹 %s 还有 %d 封UID失效的旧记录，跳过本次检查", account.ID, folder, staleCount)
+		result.Skipped = true
+		return result, nil
+	}
+
+	stored, err := model.GetStoredUIDsWithTx(db.DB(), account.ID, folder, state.UidValidity)
+	if err != nil {
+		return nil, fmt.Errorf("获取已保存邮件UID失败: %v", err)
+	}
+	result.StoredCount = len(stored)
+
+	if len(stored) > 0 {
+		minUID := slices.Min(stored)
+		if minUID <= state.LastUID {
+			search, err := mailClient.SearchUIDRange(folder, minUID, state.LastUID)
+			if err != nil {
+				return nil, err
+			}
+			if search.UIDValidity != state.UidValidity {
+				// UIDVALIDITY在列表同步之后又发生了变化，留给下次列表同步处理
+				log.Printf("[缺口检查] 账号ID %d 文件夹 %s UIDVALIDITY不一致(%d/%d)，跳过本次检查",
+					account.ID, folder, state.UidValidity, search.UIDValidity)
+				result.Skipped = true
+				return result, nil
+			}
+			result.ServerCount = len(search.UIDs)
+			result.MissingUIDs = mailclient.MissingUIDs(search.UIDs, stored)
+		}
+	}
+
+	for start := 0; start < len(result.MissingUIDs); start += gapAuditPageSize {
+		page := result.MissingUIDs[start:min(start+gapAuditPageSize, len(result.MissingUIDs))]
+		emails, err := mailClient.ListEmailsByUIDs(folder, page)
+		if err != nil {
+			return nil, fmt.Errorf("获取缺失邮件失败: %v", err)
+		}
+		emailList := make([]*model.PrimeEmail, 0, len(emails))
+		for _, email := range emails {
+			emailList = append(emailList, newPrimeEmail(account, folder, state.UidValidity, email))
+		}
+		created, _, err := saveEmailPage(emailList)
+		if err != nil {
+			return nil, err
+		}
+		result.CreatedCount += created
+	}
+
+	if err := model.SaveFolderGapAuditWithTx(db.DB(), account.ID, folder); err != nil {
+		return nil, fmt.Errorf("保存缺口检查时间失败: %v", err)
+	}
+
+	if len(result.MissingUIDs) > 0 {
+		log.Printf("[缺口检查] 账号ID %d 文件夹 %s: 服务器 %d 封，已保存 %d 封，缺失 %d 封，已补录 %d 封",
+			account.ID, folder, result.ServerCount, result.StoredCount, len(result.MissingUIDs), result.CreatedCount)
+	} else {
+		log.Printf("[缺口检查] 账号ID %d 文件夹 %s: 没有缺失的邮件，UID范围内服务器 %d 封", account.ID, folder, result.ServerCount)
+	}
+	return result, nil
+}
+
+// AuditEmailGaps 手动检查账号的UID缺口并补录缺失的邮件
+func AuditEmailGaps(c *gin.Context) {
+	var req GapAuditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, err, "无效的参数")
+		return
+	}
+
+	account, err := model.GetAccountByID(req.AccountId)
+	if err != nil {
+		utils.SendResponse(c, err, "获取邮箱账号失败")
+		return
+	}
+
+	// 与同步任务共用账号锁，避免同时写入同一批邮件记录
+	locked, err := model.TryLockAccountForSync(account.ID)
+	if err != nil {
+		utils.SendResponse(c, err, "锁定账号失败")
+		return
+	}
+	if !locked {
+		utils.SendResponse(c, fmt.Errorf("账号 %d 正在同步中，请稍后重试", account.ID), nil)
+		return
+	}
+	defer func() {
+		if err := model.UnlockAccountSync(account.ID); err != nil {
+			log.Printf("[缺口检查] 释放账号 %d 锁失败: %v", account.ID, err)
+		}
+	}()
+
+	mailClient, err := newMailClient(account)
+	if err != nil {
+		utils.SendResponse(c, err, "获取邮箱配置失败")
+		return
+	}
+
+	folders := []string{req.Folder}
+	if req.Folder == "" {
+		states, err := model.GetAccountFoldersWithTx(db.DB(), account.ID)
+		if err != nil {
+			utils.SendResponse(c, err, "获取文件夹同步状态失败")
+			return
+		}
+		folders = folders[:0]
+		for _, state := range states {
+			folders = append(folders, state.Folder)
+		}
+	}
+
+	results := make([]*GapAuditResult, 0, len(folders))
+	for _, folder := range folders {
+		result, err := auditFolderGaps(mailClient, account, folder, true)
+		if err != nil {
+			utils.SendResponse(c, err, results)
+			return
+		}
+		results = append(results, result)
+	}
+	utils.SendResponse(c, nil, results)
+}
@@ -101,7 +101,8 @@ func uploadWithRetry(filename, base64Data, fileType string, emailID int, logCont
 }
 
 // handleEmailError 统一处理邮件错误并设置相应状态
-func handleEmailError(emailID int, err error, logContext string) int {
+func handleEmailError(emailOne model.PrimeEmail, err error, logContext string) int {
+	emailID := emailOne.EmailID
 	errStr := strings.ToLower(err.Error())
 	var newStatus int
 
@@ -138,7 +139,7 @@ func handleEmailError(emailID int, err error, logContext string) int {
 	}
 
 	// 更新邮件状态
-	if resetErr := model.ResetEmailStatus(emailID, newStatus); resetErr != nil {
+	if resetErr := model.ResetEmailStatus(emailOne.ID, newStatus); resetErr != nil {
 		log.Printf("[%s] 设置邮件状态失败，邮件ID: %d, 状态: %d, 错误: %v", logContext, emailID, newStatus, resetErr)
 	}
 
@@ -264,17 +265,19 @@ func GetEmailContent(limit int, node int) error {
 	}
 
 	emailIDs := allEmailIDs
-	folder := "INBOX"
 
-	log.Printf("[邮件处理] 开始处理 %d 封邮件, 文件夹: %s", len(emailIDs), folder)
-	fmt.Printf("\n========== 开始处理 %d 封邮件，文件夹: %s ==========\n", len(emailIDs), folder)
+	log.Printf("[邮件处理] 开始处理 %d 封邮件", len(emailIDs))
+	fmt.Printf("\n========== 开始处理 %d 封邮件 ==========\n", len(emailIDs))
 
 	// 存储所有邮件内容和附件，以便后续批量存储
 	type EmailData struct {
+		PrimeEmailID uint
 		EmailID      int
 		AccountId    int
 		EmailContent *model.PrimeEmailContent
 		Attachments  []*model.PrimeEmailContentAttachment
+		Participants []*model.PrimeEmailParticipant
+		Bounces      []*model.PrimeEmailBounce
 	}
 
 	allEmailData := make([]EmailData, 0, len(emailIDs))
@@ -307,12 +310,13 @@ func GetEmailContent(limit int, node int) error {
 			fmt.Printf("❌ 失败: %v\n", err)
 			failureCount++
 			// 设置邮件状态为失败
-			resetErr := model.ResetEmailStatus(emailOne.EmailID, -2)
+			resetErr := model.ResetEmailStatus(emailOne.ID, -2)
 			if resetErr != nil {
 				log.Printf("[邮件处理] 设置邮件状态失败，邮件ID: %d, 错误: %v", emailOne.EmailID, resetErr)
 			}
 			continue
 		}
+		folder := emailOne.GetFolder()
 		email, err := mailClient.GetEmailContent(uint32(emailOne.EmailID), folder)
 		if err != nil {
 			log.Printf("[邮件处理] 获取邮件内容失败，邮件ID: %d, 错误: %v", emailOne.EmailID, err)
@@ -320,7 +324,7 @@ func GetEmailContent(limit int, node int) error {
 			failureCount++
 
 			// 使用统一错误处理函数
-			handleEmailError(emailOne.EmailID, err, "邮件处理")
+			handleEmailError(emailOne, err, "邮件处理")
 			// 继续处理下一个邮件，而不是直接返回错误
 			continue
 		}
@@ -333,20 +337,27 @@ func GetEmailContent(limit int, node int) error {
 		emailContent := &model.PrimeEmailContent{
 			EmailID:       emailOne.EmailID,
 			AccountId:     emailOne.AccountId,
+			Folder:        folder,
+			UidValidity:   emailOne.UidValidity,
 			Subject:       utils.SanitizeUTF8(email.Subject),
 			FromEmail:     utils.SanitizeUTF8(email.From),
 			ToEmail:       utils.SanitizeUTF8(email.To),
 			Date:          utils.SanitizeUTF8(email.Date),
+			SentAt:        utils.NewUTCTime(email.SentAt),
+			ReceivedAt:    utils.NewUTCTime(email.ReceivedAt),
 			Content:       utils.SanitizeUTF8(email.Body),
 			HTMLContent:   utils.SanitizeUTF8(email.BodyHTML),
-			Type:          0,
+			Type:          email.Category,
 			HasAttachment: emailOne.HasAttachment,
 			CreatedAt:     utils.JsonTime{Time: time.Now()},
 			UpdatedAt:     utils.JsonTime{Time: time.Now()},
 		}
+		verifySenderDKIM(email)
+		fillContentHeaders(emailContent, email)
 
 		// 创建附件记录列表
 		attachmentRecords := make([]*model.PrimeEmailContentAttachment, 0)
+		inlineURLs := make(map[string]string)
 		if len(email.Attachments) > 0 {
 			log.Printf("[邮件处理] 邮件含有 %d 个附件，邮件ID: %d", len(email.Attachments), emailOne.EmailID)
 			fmt.Printf("    📎 发现 %d 个附件\n", len(email.Attachments))
@@ -386,14 +397,23 @@ func GetEmailContent(limit int, node int) error {
 
 				// 创建附件记录
 				attachmentRecord := &model.PrimeEmailContentAttachment{
-					EmailID:   emailOne.EmailID,
-					AccountId: emailOne.AccountId,
-					FileName:  utils.SanitizeUTF8(attachment.Filename),
-					SizeKb:    attachment.SizeKB,
-					MimeType:  utils.SanitizeUTF8(attachment.MimeType),
-					OssUrl:    utils.SanitizeUTF8(ossURL),
-					CreatedAt: utils.JsonTime{Time: time.Now()},
-					UpdatedAt: utils.JsonTime{Time: time.Now()},
+					EmailID:     emailOne.EmailID,
+					AccountId:   emailOne.AccountId,
+					Folder:      folder,
+					UidValidity: emailOne.UidValidity,
+					FileName:    utils.SanitizeUTF8(attachment.Filename),
+					SizeKb:      attachment.SizeKB,
+					MimeType:    utils.SanitizeUTF8(attachment.MimeType),
+					OssUrl:      utils.SanitizeUTF8(ossURL),
+					CreatedAt:   utils.JsonTime{Time: time.Now()},
+					UpdatedAt:   utils.JsonTime{Time: time.Now()},
+				}
+				if attachment.Inline && attachment.ContentID != "" {
+					attachmentRecord.IsInline = 1
+					attachmentRecord.ContentID = utils.SanitizeUTF8(attachment.ContentID)
+					if ossURL != "" {
+						inlineURLs[attachment.ContentID] = inlineImageURL(ossURL, attachment.ContentID, emailOne.EmailID, emailOne.AccountId)
+					}
 				}
 
 				attachmentRecords = append(attachmentRecords, attachmentRecord)
@@ -402,13 +422,21 @@ func GetEmailContent(limit int, node int) error {
 			log.Printf("[邮件处理] 邮件没有附件，邮件ID: %d", emailOne.EmailID)
 			fmt.Printf("    📄 邮件没有附件\n")
 		}
+		if len(inlineURLs) > 0 {
+			emailContent.HTMLContent = utils.SanitizeUTF8(mailclient.RewriteCIDReferences(email.BodyHTML, inlineURLs))
+		}
+		emailContent.HTMLSanitized = sanitizedHTML(emailContent.HTMLContent, inlineURLs)
+		fillBodySegments(emailContent)
 
 		// 添加到待处理列表
 		allEmailData = append(allEmailData, EmailData{
+			PrimeEmailID: emailOne.ID,
 			EmailID:      emailOne.EmailID,
 			AccountId:    emailOne.AccountId,
 			EmailContent: emailContent,
 			Attachments:  attachmentRecords,
+			Participants: buildParticipants(emailOne.AccountId, emailOne, folder, email),
+			Bounces:      buildBounces(emailOne.AccountId, emailOne, folder, email),
 		})
 	}
 
@@ -470,11 +498,20 @@ func GetEmailContent(limit int, node int) error {
 			fmt.Printf("✅ 成功\n")
 		}
 
+		// 保存参与人，失败不影响邮件内容的保存
+		if err := model.CreateParticipantsWithTx(tx, data.EmailContent.ID, data.Participants); err != nil {
+			log.Printf("[邮件处理] 保存参与人失败，邮件ID: %d, 错误: %v", data.EmailID, err)
+		}
+		// 保存退信记录并关联发出的邮件，失败同样不影响邮件内容的保存
+		if err := model.CreateBouncesWithTx(tx, data.EmailContent.ID, data.Bounces); err != nil {
+			log.Printf("[邮件处理] 保存退信记录失败，邮件ID: %d, 错误: %v", data.EmailID, err)
+		}
+
 		// 更新邮件状态为已处理
 		log.Printf("[邮件处理] 更新邮件状态为已处理，邮件ID: %d", data.EmailID)
 		fmt.Printf("    • 更新邮件状态为已处理... ")
 
-		if err := tx.Model(&model.PrimeEmail{}).Where("email_id = ?", data.EmailID).Update("status", 1).Error; err != nil {
+		if err := tx.Model(&model.PrimeEmail{}).Where("id = ?", data.PrimeEmailID).Update("status", 1).Error; err != nil {
 			log.Printf("[邮件处理] 更新邮件状态失败，邮件ID: %d, 错误: %v", data.EmailID, err)
 			fmt.Printf("❌ 失败: %v\n", err)
 			tx.Rollback()
@@ -613,17 +650,19 @@ func GetEmailContentWithAccounts(limit int, node int, accounts []model.PrimeEmai
 	}
 
 	emailIDs := allEmailIDs
-	folder := "INBOX"
 
-	log.Printf("[邮件处理] 开始处理 %d 封邮件, 文件夹: %s", len(emailIDs), folder)
-	fmt.Printf("\n========== 开始处理 %d 封邮件，文件夹: %s ==========\n", len(emailIDs), folder)
+	log.Printf("[邮件处理] 开始处理 %d 封邮件", len(emailIDs))
+	fmt.Printf("\n========== 开始处理 %d 封邮件 ==========\n", len(emailIDs))
 
 	// 存储所有邮件内容和附件，以便后续批量存储
 	type EmailData struct {
+		PrimeEmailID uint
 		EmailID      int
 		AccountId    int
 		EmailContent *model.PrimeEmailContent
 		Attachments  []*model.PrimeEmailContentAttachment
+		Participants []*model.PrimeEmailParticipant
+		Bounces      []*model.PrimeEmailBounce
 	}
 
 	allEmailData := make([]EmailData, 0, len(emailIDs))
@@ -656,12 +695,13 @@ func GetEmailContentWithAccounts(limit int, node int, accounts []model.PrimeEmai
 			fmt.Printf("❌ 失败: %v\n", err)
 			failureCount++
 			// 设置邮件状态为失败
-			resetErr := model.ResetEmailStatus(emailOne.EmailID, -2)
+			resetErr := model.ResetEmailStatus(emailOne.ID, -2)
 			if resetErr != nil {
 				log.Printf("[邮件处理] 设置邮件状态失败，邮件ID: %d, 错误: %v", emailOne.EmailID, resetErr)
 			}
 			continue
 		}
+		folder := emailOne.GetFolder()
 		email, err := mailClient.GetEmailContent(uint32(emailOne.EmailID), folder)
 		if err != nil {
 			log.Printf("[邮件处理] 获取邮件内容失败，邮件ID: %d, 错误: %v", emailOne.EmailID, err)
@@ -669,7 +709,7 @@ func GetEmailContentWithAccounts(limit int, node int, accounts []model.PrimeEmai
 			failureCount++
 
 			// 使用统一错误处理函数
-			handleEmailError(emailOne.EmailID, err, "邮件处理")
+			handleEmailError(emailOne, err, "邮件处理")
 			// 继续处理下一个邮件，而不是直接返回错误
 			continue
 		}
@@ -682,20 +722,27 @@ func GetEmailContentWithAccounts(limit int, node int, accounts []model.PrimeEmai
 		emailContent := &model.PrimeEmailContent{
 			EmailID:       emailOne.EmailID,
 			AccountId:     emailOne.AccountId,
+			Folder:        folder,
+			UidValidity:   emailOne.UidValidity,
 			Subject:       utils.SanitizeUTF8(email.Subject),
 			FromEmail:     utils.SanitizeUTF8(email.From),
 			ToEmail:       utils.SanitizeUTF8(email.To),
 			Date:          utils.SanitizeUTF8(email.Date),
+			SentAt:        utils.NewUTCTime(email.SentAt),
+			ReceivedAt:    utils.NewUTCTime(email.ReceivedAt),
 			Content:       utils.SanitizeUTF8(email.Body),
 			HTMLContent:   utils.SanitizeUTF8(email.BodyHTML),
-			Type:          0,
+			Type:          email.Category,
 			HasAttachment: emailOne.HasAttachment,
 			CreatedAt:     utils.JsonTime{Time: time.Now()},
 			UpdatedAt:     utils.JsonTime{Time: time.Now()},
 		}
+		verifySenderDKIM(email)
+		fillContentHeaders(emailContent, email)
 
 		// 创建附件记录列表
 		attachmentRecords := make([]*model.PrimeEmailContentAttachment, 0)
+		inlineURLs := make(map[string]string)
 		if len(email.Attachments) > 0 {
 			log.Printf("[邮件处理] 邮件含有 %d 个附件，邮件ID: %d", len(email.Attachments), emailOne.EmailID)
 			fmt.Printf("    📎 发现 %d 个附件\n", len(email.Attachments))
@@ -735,14 +782,23 @@ func GetEmailContentWithAccounts(limit int, node int, accounts []model.PrimeEmai
 
 				// 创建附件记录
 				attachmentRecord := &model.PrimeEmailContentAttachment{
-					EmailID:   emailOne.EmailID,
-					AccountId: emailOne.AccountId,
-					FileName:  utils.SanitizeUTF8(attachment.Filename),
-					SizeKb:    attachment.SizeKB,
-					MimeType:  utils.SanitizeUTF8(attachment.MimeType),
-					OssUrl:    utils.SanitizeUTF8(ossURL),
-					CreatedAt: utils.JsonTime{Time: time.Now()},
-					UpdatedAt: utils.JsonTime{Time: time.Now()},
+					EmailID:     emailOne.EmailID,
+					AccountId:   emailOne.AccountId,
+					Folder:      folder,
+					UidValidity: emailOne.UidValidity,
+					FileName:    utils.SanitizeUTF8(attachment.Filename),
+					SizeKb:      attachment.SizeKB,
+					MimeType:    utils.SanitizeUTF8(attachment.MimeType),
+					OssUrl:      utils.SanitizeUTF8(ossURL),
+					CreatedAt:   utils.JsonTime{Time: time.Now()},
+					UpdatedAt:   utils.JsonTime{Time: time.Now()},
+				}
+				if attachment.Inline && attachment.ContentID != "" {
+					attachmentRecord.IsInline = 1
+					attachmentRecord.ContentID = utils.SanitizeUTF8(attachment.ContentID)
+					if ossURL != "" {
+						inlineURLs[attachment.ContentID] = inlineImageURL(ossURL, attachment.ContentID, emailOne.EmailID, emailOne.AccountId)
+					}
 				}
 
 				attachmentRecords = append(attachmentRecords, attachmentRecord)
@@ -751,13 +807,21 @@ func GetEmailContentWithAccounts(limit int, node int, accounts []model.PrimeEmai
 			log.Printf("[邮件处理] 邮件没有附件，邮件ID: %d", emailOne.EmailID)
 			fmt.Printf("    📄 邮件没有附件\n")
 		}
+		if len(inlineURLs) > 0 {
+			emailContent.HTMLContent = utils.SanitizeUTF8(mailclient.RewriteCIDReferences(email.BodyHTML, inlineURLs))
+		}
+		emailContent.HTMLSanitized = sanitizedHTML(emailContent.HTMLContent, inlineURLs)
+		fillBodySegments(emailContent)
 
 		// 添加到待处理列表
 		allEmailData = append(allEmailData, EmailData{
+			PrimeEmailID: emailOne.ID,
 			EmailID:      emailOne.EmailID,
 			AccountId:    emailOne.AccountId,
 			EmailContent: emailContent,
 			Attachments:  attachmentRecords,
+			Participants: buildParticipants(emailOne.AccountId, emailOne, folder, email),
+			Bounces:      buildBounces(emailOne.AccountId, emailOne, folder, email),
 		})
 	}
 
@@ -819,11 +883,20 @@ func GetEmailContentWithAccounts(limit int, node int, accounts []model.PrimeEmai
 			fmt.Printf("✅ 成功\n")
 		}
 
+		// 保存参与人，失败不影响邮件内容的保存
+		if err := model.CreateParticipantsWithTx(tx, data.EmailContent.ID, data.Participants); err != nil {
+			log.Printf("[邮件处理] 保存参与人失败，邮件ID: %d, 错误: %v", data.EmailID, err)
+		}
+		// 保存退信记录并关联发出的邮件，失败同样不影响邮件内容的保存
+		if err := model.CreateBouncesWithTx(tx, data.EmailContent.ID, data.Bounces); err != nil {
+			log.Printf("[邮件处理] 保存退信记录失败，邮件ID: %d, 错误: %v", data.EmailID, err)
+		}
+
 		// 更新邮件状态为已处理
 		log.Printf("[邮件处理] 更新邮件状态为已处理，邮件ID: %d", data.EmailID)
 		fmt.Printf("    • 更新邮件状态为已处理... ")
 
-		if err := tx.Model(&model.PrimeEmail{}).Where("email_id = ?", data.EmailID).Update("status", 1).Error; err != nil {
+		if err := tx.Model(&model.PrimeEmail{}).Where("id = ?", data.PrimeEmailID).Update("status", 1).Error; err != nil {
 			log.Printf("[邮件处理] 更新邮件状态失败，邮件ID: %d, 错误: %v", data.EmailID, err)
 			fmt.Printf("❌ 失败: %v\n", err)
 			tx.Rollback()
@@ -902,8 +975,9 @@ func GetEmailContentWithAccounts(limit int, node int, accounts []model.PrimeEmai
 
 // ListEmailsByUidRequest 根据UID获取邮件列表请求结构
 type ListEmailsByUidRequest struct {
-	EmailID   int `json:"email_id" binding:"required"`   // 用于获取详情的邮件ID
-	AccountId int `json:"account_id" binding:"required"` // 邮箱账号ID
+	EmailID   int    `json:"email_id" binding:"required"`   // 用于获取详情的邮件ID
+	AccountId int    `json:"account_id" binding:"required"` // 邮箱账号ID
+	Folder    string `json:"folder"`                        // 文件夹，默认INBOX
 }
 
 func ListEmailsByUid(c *gin.Context) {
@@ -947,8 +1021,11 @@ func ListEmailsByUid(c *gin.Context) {
 	result.Account.Account = account.Account
 
 	// 第一步：获取邮件列表（获取包含给定email_id在内的5封邮件）
-	folder := "INBOX"
-	log.Printf("[测试接口] 获取邮件列表，账号ID: %d, 邮件ID: %d", account.ID, req.EmailID)
+	folder := req.Folder
+	if folder == "" {
+		folder = model.DefaultFolder
+	}
+	log.Printf("[测试接口] 获取邮件列表，账号ID: %d, 文件夹: %s, 邮件ID: %d", account.ID, folder, req.EmailID)
 
 	// 从略小于传入email_id的值开始获取，确保包含传入的email_id
 	startID := uint32(req.EmailID)
@@ -972,7 +1049,7 @@ func ListEmailsByUid(c *gin.Context) {
 	// 先查询PrimeEmail表中的HasAttachment值
 	var primeEmail model.PrimeEmail
 	skipAttachments := false
-	if err := db.DB().Where("email_id = ? AND account_id = ?", req.EmailID, account.ID).First(&primeEmail).Error; err == nil {
+	if err := db.DB().Where("email_id = ? AND account_id = ? AND folder = ?", req.EmailID, account.ID, folder).First(&primeEmail).Error; err == nil {
 		// 如果查询成功且HasAttachment为0，则跳过附件解析
 		if primeEmail.HasAttachment == 0 {
 			skipAttachments = true
@@ -1040,6 +1117,11 @@ func GetGoroutineStats(c *gin.Context) {
 	utils.SendResponse(c, nil, response)
 }
 
+// GetIMAPPoolStats 获取IMAP连接池统计信息
+func GetIMAPPoolStats(c *gin.Context) {
+	utils.SendResponse(c, nil, mailclient.GetPoolStats())
+}
+
 // GetDetailedGoroutineStats 获取详细的协程统计信息
 func GetDetailedGoroutineStats(c *gin.Context) {
 	stats := utils.GlobalSafeGoroutineManager.GetGoroutineStats()
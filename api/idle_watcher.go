@@ -0,0 +1,445 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"go_email/pkg/utils"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+const (
+	// idleSessionTimeout 单个监听协程的运行时长，到期后开启新的协程继续监听，避免被协程管理器当成超时协程清理
+	idleSessionTimeout = 60 * time.Minute
+	// idleMaxBackoff 连接失败后重连的最大等待时间
+	idleMaxBackoff = 5 * time.Minute
+	// idleSyncRetryDelay 账号正在被其他同步任务处理时，延迟重试同步的时间
+	idleSyncRetryDelay = 30 * time.Second
+)
+
+// IDLE监听相关的全局变量
+var (
+	idleWatchersMutex sync.Mutex
+	idleWatchers      = make(map[int]*idleWatcher) // 账号ID → 监听器
+)
+
+// idleWatcher 单个账号的IDLE新邮件监听器
+type idleWatcher struct {
+	accountID int
+	account   string
+	folder    string
+	ctx       context.Context // 手动停止时取消
+	cancel    context.CancelFunc
+
+	mu          sync.Mutex
+	startedAt   time.Time
+	lastEventAt *time.Time
+	lastSyncAt  *time.Time
+	syncCount   int
+	reconnects  int
+	lastError   string
+	syncing     bool // 是否有同步协程在运行
+	pending     bool // 同步期间又收到了新邮件通知，需要再同步一次
+}
+
+// IdleWatcherStatus IDLE监听器状态
+type IdleWatcherStatus struct {
+	AccountID   int        `json:"account_id"`
+	Account     string     `json:"account"`
+	Folder      string     `json:"folder"`
+	StartedAt   time.Time  `json:"started_at"`
+	LastEventAt *time.Time `json:"last_event_at"`
+	LastSyncAt  *time.Time `json:"last_sync_at"`
+	SyncCount   int        `json:"sync_count"`
+	Reconnects  int        `json:"reconnects"`
+	LastError   string     `json:"last_error"`
+	Syncing     bool       `json:"syncing"`
+}
+
+// idleOptionsFromConfig 从配置读取IDLE参数
+func idleOptionsFromConfig() mailclient.IdleOptions {
+	return mailclient.IdleOptions{
+		RestartInterval: time.Duration(viper.GetInt("idle.restart_minutes")) * time.Minute,
+		PollInterval:    time.Duration(viper.GetInt("idle.poll_seconds")) * time.Second,
+	}
+}
+
+// idleSyncLimit 收到新邮件通知后每次同步的邮件数量
+func idleSyncLimit() int {
+	if limit := viper.GetInt("idle.sync_limit"); limit > 0 {
+		return limit
+	}
+	return 30
+}
+
+// startIdleWatcher 为账号开启IDLE监听，已在监听时直接返回
+func startIdleWatcher(account model.PrimeEmailAccount, folder string) error {
+	if folder == "" {
+		folder = model.DefaultFolder
+	}
+
+	idleWatchersMutex.Lock()
+	defer idleWatchersMutex.Unlock()
+
+	if _, exists := idleWatchers[account.ID]; exists {
+		log.Printf("[IDLE监听] 账号 %d 已在监听中，跳过", account.ID)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &idleWatcher{
+		accountID: account.ID,
+		account:   account.Account,
+		folder:    folder,
+		ctx:       ctx,
+		cancel:    cancel,
+		startedAt: time.Now(),
+	}
+	if err := w.startSession(); err != nil {
+		cancel()
+		return err
+	}
+	idleWatchers[account.ID] = w
+	log.Printf("[IDLE监听] 账号 %d (%s) 开启监听，文件夹: %s", account.ID, account.Account, folder)
+
+	// 先同步一次，补齐监听建立之前到达的邮件
+	w.requestSync()
+	return nil
+}
+
+// stopIdleWatcher 停止账号的IDLE监听
+func stopIdleWatcher(accountID int) bool {
+	idleWatchersMutex.Lock()
+	w, exists := idleWatchers[accountID]
+	delete(idleWatchers, accountID)
+	idleWatchersMutex.Unlock()
+
+	if !exists {
+		return false
+	}
+	w.cancel()
+	log.Printf("[IDLE监听] 账号 %d 已停止监听", accountID)
+	return true
+}
+
+// stopAllIdleWatchers 停止所有IDLE监听
+func stopAllIdleWatchers() int {
+	idleWatchersMutex.Lock()
+	var accountIDs []int
+	for id := range idleWatchers {
+		accountIDs = append(accountIDs, id)
+	}
+	idleWatchersMutex.Unlock()
+
+	count := 0
+	for _, id := range accountIDs {
+		if stopIdleWatcher(id) {
+			count++
+		}
+	}
+	return count
+}
+
+// StartIdleWatchers 为节点下所有启用了IDLE的账号开启监听，node为0时处理所有节点
+func StartIdleWatchers(node int) (int, error) {
+	accounts, err := model.GetIdleEnabledAccounts(node)
+	if err != nil {
+		return 0, fmt.Errorf("获取启用IDLE的账号失败: %v", err)
+	}
+
+	started := 0
+	for _, account := range accounts {
+		if err := startIdleWatcher(account, model.DefaultFolder); err != nil {
+			log.Printf("[IDLE监听] 账号 %d 开启监听失败: %v", account.ID, err)
+			continue
+		}
+		started++
+	}
+	log.Printf("[IDLE监听] 节点 %d - 共 %d 个启用IDLE的账号，成功开启 %d 个", node, len(accounts), started)
+	return started, nil
+}
+
+// startSession 通过协程管理器启动一个监听会话协程
+func (w *idleWatcher) startSession() error {
+	return utils.GlobalSafeGoroutineManager.StartSafeGoroutineWithTimeout(
+		w.ctx,
+		fmt.Sprintf("idle-watch-%d", w.accountID),
+		idleSessionTimeout,
+		w.runSession,
+	)
+}
+
+// runSession 保持IDLE连接，连接断开时按指数退避重连，会话到期后自动开启下一个会话
+func (w *idleWatcher) runSession(ctx context.Context) {
+	defer func() {
+		// 会话协程到期但监听未被手动停止，开启新的会话继续监听
+		if w.ctx.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			if err := w.startSession(); err != nil {
+				log.Printf("[IDLE监听] 账号 %d 续期监听协程失败，停止监听: %v", w.accountID, err)
+				stopIdleWatcher(w.accountID)
+			}
+		}
+	}()
+
+	backoff := 5 * time.Second
+	for ctx.Err() == nil {
+		err := w.watchOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		w.mu.Lock()
+		w.reconnects++
+		if err != nil {
+			w.lastError = err.Error()
+		}
+		w.mu.Unlock()
+		log.Printf("[IDLE监听] 账号 %d 监听中断，%v 后重连: %v", w.accountID, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > idleMaxBackoff {
+			backoff = idleMaxBackoff
+		}
+
+		// 重连后同步一次，补齐断线期间到达的邮件
+		w.requestSync()
+	}
+}
+
+// watchOnce 建立一次IDLE连接并阻塞监听，直到连接中断或ctx取消
+func (w *idleWatcher) watchOnce(ctx context.Context) error {
+	// 每次重连都重新读取账号，使用最新的密码和服务器配置
+	account, err := model.GetAccountByID(w.accountID)
+	if err != nil {
+		return fmt.Errorf("获取账号失败: %v", err)
+	}
+	if account.Status != 1 {
+		log.Printf("[IDLE监听] 账号 %d 已停用，停止监听", w.accountID)
+		stopIdleWatcher(w.accountID)
+		return nil
+	}
+
+	mailClient, err := newMailClient(account)
+	if err != nil {
+		return fmt.Errorf("创建邮件客户端失败: %v", err)
+	}
+
+	return mailClient.WatchNewMail(ctx, w.folder, idleOptionsFromConfig(), func(messages uint32) {
+		now := time.Now()
+		w.mu.Lock()
+		w.lastEventAt = &now
+		w.mu.Unlock()
+		w.requestSync()
+	})
+}
+
+// requestSync 请求同步账号邮件；已有同步在运行时只做标记，由运行中的同步协程再执行一轮
+func (w *idleWatcher) requestSync() {
+	if w.ctx.Err() != nil {
+		return
+	}
+
+	w.mu.Lock()
+	if w.syncing {
+		w.pending = true
+		w.mu.Unlock()
+		return
+	}
+	w.syncing = true
+	w.mu.Unlock()
+
+	timeoutMinutes := viper.GetInt("sync.timeout_minutes")
+	if timeoutMinutes <= 0 {
+		timeoutMinutes = 25
+	}
+	err := utils.GlobalSafeGoroutineManager.StartSafeGoroutineWithTimeout(
+		context.Background(),
+		fmt.Sprintf("idle-sync-%d", w.accountID),
+		time.Duration(timeoutMinutes)*time.Minute,
+		w.syncLoop,
+	)
+	if err != nil {
+		log.Printf("[IDLE监听] 账号 %d 启动同步协程失败: %v", w.accountID, err)
+		w.mu.Lock()
+		w.syncing = false
+		w.mu.Unlock()
+	}
+}
+
+// syncLoop 执行同步，同步期间收到的新邮件通知合并为下一轮同步
+func (w *idleWatcher) syncLoop(ctx context.Context) {
+	finished := false
+	defer func() {
+		// 异常退出（panic）时也要释放同步标记
+		if !finished {
+			w.mu.Lock()
+			w.syncing = false
+			w.mu.Unlock()
+		}
+	}()
+
+	for {
+		w.mu.Lock()
+		w.pending = false
+		w.mu.Unlock()
+
+		w.syncOnce(ctx)
+
+		w.mu.Lock()
+		if !w.pending || ctx.Err() != nil {
+			w.syncing = false
+			finished = true
+			w.mu.Unlock()
+			return
+		}
+		w.mu.Unlock()
+	}
+}
+
+// syncOnce 锁定账号后先同步邮件列表，再同步邮件详情
+func (w *idleWatcher) syncOnce(ctx context.Context) {
+	// 与统一同步接口共用processing_status，避免同一账号被并发同步
+	locked, err := model.TryLockAccountForSync(w.accountID)
+	if err != nil {
+		log.Printf("[IDLE监听] 账号 %d 锁定失败: %v", w.accountID, err)
+		return
+	}
+	if !locked {
+		log.Printf("[IDLE监听] 账号 %d 正在被其他同步任务处理，%v 后重试", w.accountID, idleSyncRetryDelay)
+		time.AfterFunc(idleSyncRetryDelay, w.requestSync)
+		return
+	}
+
+	account, err := model.GetAccountByID(w.accountID)
+	if err != nil {
+		log.Printf("[IDLE监听] 获取账号 %d 失败: %v", w.accountID, err)
+		if updateErr := model.ResetSyncTimeOnFailure(w.accountID); updateErr != nil {
+			log.Printf("[IDLE监听] 重置账号 %d 状态失败: %v", w.accountID, updateErr)
+		}
+		return
+	}
+
+	result := syncSingleAccountSequential(account, UnifiedSyncRequest{SyncLimit: idleSyncLimit()}, ctx)
+	if result.Error != nil {
+		log.Printf("[IDLE监听] 账号 %d 同步失败: %v", w.accountID, result.Error)
+		if updateErr := model.ResetSyncTimeOnFailure(w.accountID); updateErr != nil {
+			log.Printf("[IDLE监听] 重置账号 %d 状态失败: %v", w.accountID, updateErr)
+		}
+	} else if updateErr := model.UpdateLastSyncTimeOnComplete(w.accountID); updateErr != nil {
+		log.Printf("[IDLE监听] 更新账号 %d 完成状态失败: %v", w.accountID, updateErr)
+	}
+
+	now := time.Now()
+	w.mu.Lock()
+	w.lastSyncAt = &now
+	w.syncCount++
+	if result.Error != nil {
+		w.lastError = result.Error.Error()
+	}
+	w.mu.Unlock()
+
+	log.Printf("[IDLE监听] 账号 %d 同步完成 - 列表: %d, 详情: %d", w.accountID, result.ListCount, result.ContentCount)
+}
+
+// status 返回监听器状态快照
+func (w *idleWatcher) status() IdleWatcherStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return IdleWatcherStatus{
+		AccountID:   w.accountID,
+		Account:     w.account,
+		Folder:      w.folder,
+		StartedAt:   w.startedAt,
+		LastEventAt: w.lastEventAt,
+		LastSyncAt:  w.lastSyncAt,
+		SyncCount:   w.syncCount,
+		Reconnects:  w.reconnects,
+		LastError:   w.lastError,
+		Syncing:     w.syncing,
+	}
+}
+
+// IdleWatcherRequest IDLE监听开启/停止请求
+type IdleWatcherRequest struct {
+	AccountId int    `json:"account_id"` // 账号ID，为0时按节点批量处理
+	Node      int    `json:"node"`       // 节点编号，account_id为0时开启该节点所有启用IDLE的账号
+	Folder    string `json:"folder"`     // 监听的文件夹，默认INBOX
+}
+
+// StartIdleWatch 开启IDLE新邮件监听
+func StartIdleWatch(c *gin.Context) {
+	var req IdleWatcherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, err, "无效的参数")
+		return
+	}
+
+	if req.AccountId <= 0 {
+		started, err := StartIdleWatchers(req.Node)
+		if err != nil {
+			utils.SendResponse(c, err, "开启IDLE监听失败")
+			return
+		}
+		utils.SendResponse(c, nil, fmt.Sprintf("节点 %d 成功开启 %d 个账号的IDLE监听", req.Node, started))
+		return
+	}
+
+	account, err := model.GetAccountByID(req.AccountId)
+	if err != nil {
+		utils.SendResponse(c, err, "获取邮箱账号失败")
+		return
+	}
+	if err := startIdleWatcher(account, req.Folder); err != nil {
+		utils.SendResponse(c, err, "开启IDLE监听失败")
+		return
+	}
+	utils.SendResponse(c, nil, fmt.Sprintf("账号 %d 已开启IDLE监听", account.ID))
+}
+
+// StopIdleWatch 停止IDLE新邮件监听，account_id为0时停止所有监听
+func StopIdleWatch(c *gin.Context) {
+	var req IdleWatcherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, err, "无效的参数")
+		return
+	}
+
+	if req.AccountId <= 0 {
+		utils.SendResponse(c, nil, fmt.Sprintf("已停止 %d 个账号的IDLE监听", stopAllIdleWatchers()))
+		return
+	}
+	if !stopIdleWatcher(req.AccountId) {
+		utils.SendResponse(c, nil, fmt.Sprintf("账号 %d 没有在监听", req.AccountId))
+		return
+	}
+	utils.SendResponse(c, nil, fmt.Sprintf("账号 %d 已停止IDLE监听", req.AccountId))
+}
+
+// GetIdleWatchStatus 获取所有IDLE监听器状态
+func GetIdleWatchStatus(c *gin.Context) {
+	idleWatchersMutex.Lock()
+	statuses := make([]IdleWatcherStatus, 0, len(idleWatchers))
+	for _, w := range idleWatchers {
+		statuses = append(statuses, w.status())
+	}
+	idleWatchersMutex.Unlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].AccountID < statuses[j].AccountID })
+
+	utils.SendResponse(c, nil, map[string]interface{}{
+		"count":    len(statuses),
+		"watchers": statuses,
+	})
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"go_email/pkg/utils"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// inlineImageURL 生成替换HTML中 cid: 引用的地址
+// 配置了 sync.inline_image_url_template 时按模板生成，模板支持 {url}（原样的存储地址）{content_id}（已转义）{email_id} {account_id}，
+// 否则直接使用存储地址
+func inlineImageURL(ossURL, contentID string, emailID, accountID int) string {
+	template := viper.GetString("sync.inline_image_url_template")
+	if template == "" || ossURL == "" {
+		return ossURL
+	}
+	return strings.NewReplacer(
+		"{url}", ossURL,
+		"{content_id}", url.PathEscape(contentID),
+		"{email_id}", strconv.Itoa(emailID),
+		"{account_id}", strconv.Itoa(accountID),
+	).Replace(template)
+}
+
+// splitInlineAttachments 把解析出的附件分为HTML正文引用的内嵌图片和普通附件
+func splitInlineAttachments(attachments []mailclient.AttachmentInfo) (inline, regular []mailclient.AttachmentInfo) {
+	for _, att := range attachments {
+		if att.Inline && att.ContentID != "" {
+			inline = append(inline, att)
+		} else {
+			regular = append(regular, att)
+		}
+	}
+	return inline, regular
+}
+
+// processInlineAttachments 上传内嵌图片并创建附件记录，返回以Content-ID为键的替换地址
+// 上传失败的图片仍然记录，HTML中对应的 cid: 引用保持不变
+func processInlineAttachments(account model.PrimeEmailAccount, emailOne model.PrimeEmail, folder string,
+	inline []mailclient.AttachmentInfo) ([]*model.PrimeEmailContentAttachment, map[string]string, time.Duration) {
+	var records []*model.PrimeEmailContentAttachment
+	var ossTime time.Duration
+	urls := make(map[string]string, len(inline))
+
+	for i, att := range inline {
+		log.Printf("[内嵌图片] 开始处理内嵌图片 %d/%d，邮件ID: %d, 文件名: %s, Content-ID: %s",
+			i+1, len(inline), emailOne.EmailID, att.Filename, att.ContentID)
+
+		ossURL := ""
+		if att.Base64Data != "" {
+			fileType := ""
+			if parts := strings.Split(att.MimeType, "/"); len(parts) > 1 {
+				fileType = parts[1]
+			}
+			ossStartTime := time.Now()
+			var err error
+			ossURL, err = uploadWithRetry(att.Filename, att.Base64Data, fileType, emailOne.EmailID, "内嵌图片")
+			ossTime += time.Since(ossStartTime)
+			if err != nil {
+				log.Printf("[内嵌图片] 上传失败，邮件ID: %d, 文件名: %s, 错误: %v", emailOne.EmailID, att.Filename, err)
+			} else {
+				urls[att.ContentID] = inlineImageURL(ossURL, att.ContentID, emailOne.EmailID, account.ID)
+			}
+		}
+
+		records = append(records, &model.PrimeEmailContentAttachment{
+			EmailID:     emailOne.EmailID,
+			AccountId:   account.ID,
+			Folder:      folder,
+			UidValidity: emailOne.UidValidity,
+			FileName:    utils.SanitizeUTF8(att.Filename),
+			SizeKb:      att.SizeKB,
+			MimeType:    utils.SanitizeUTF8(att.MimeType),
+			OssUrl:      utils.SanitizeUTF8(ossURL),
+			IsInline:    1,
+			ContentID:   utils.SanitizeUTF8(att.ContentID),
+			CreatedAt:   utils.JsonTime{Time: time.Now()},
+		})
+	}
+	return records, urls, ossTime
+}
+
+// storedInlineURLs 根据已保存的内嵌图片附件记录生成替换地址，用于重新解析时比较HTML正文
+func storedInlineURLs(stored []model.PrimeEmailContentAttachment) map[string]string {
+	urls := make(map[string]string)
+	for _, att := range stored {
+		if att.IsInline == 1 && att.ContentID != "" && att.OssUrl != "" {
+			urls[att.ContentID] = inlineImageURL(att.OssUrl, att.ContentID, att.EmailID, att.AccountId)
+		}
+	}
+	return urls
+}
+
+// sanitizedHTML 生成供内部工具展示的安全HTML，只保留已上传的内嵌图片
+func sanitizedHTML(htmlContent string, inlineURLs map[string]string) string {
+	allowed := make(map[string]bool, len(inlineURLs))
+	for _, url := range inlineURLs {
+		allowed[url] = true
+	}
+	return utils.SanitizeUTF8(mailclient.SanitizeHTML(htmlContent, allowed))
+}
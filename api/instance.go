@@ -0,0 +1,22 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// instanceID 当前服务实例的标识，记录在执行中的补录和重新解析任务上，服务重启时只重置本实例的任务
+// 优先使用配置的 instance_id，未配置时使用 主机名+监听地址，同一台服务器上的多个实例监听地址不同
+var instanceID = sync.OnceValue(func() string {
+	if id := viper.GetString("instance_id"); id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s%s", host, viper.GetString("addr1"))
+})
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"go_email/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// buildParticipants 根据解析出的结构化地址创建参与人记录，内容ID在保存邮件内容后填写
+func buildParticipants(accountID int, emailOne model.PrimeEmail, folder string, email *mailclient.Email) []*model.PrimeEmailParticipant {
+	participants := make([]*model.PrimeEmailParticipant, 0, len(email.Addresses))
+	for _, addr := range email.Addresses {
+		participants = append(participants, &model.PrimeEmailParticipant{
+			EmailID:     emailOne.EmailID,
+			AccountId:   accountID,
+			Folder:      folder,
+			UidValidity: emailOne.UidValidity,
+			Role:        addr.Role,
+			Name:        truncateRunes(utils.SanitizeUTF8(addr.Name), 255),
+			Address:     truncateRunes(utils.SanitizeUTF8(addr.Address), 255),
+			Domain:      truncateRunes(utils.SanitizeUTF8(addr.Domain), 255),
+			CreatedAt:   utils.JsonTime{Time: time.Now()},
+		})
+	}
+	return participants
+}
+
+// truncateRunes 按字符截断字符串，用于写入有长度限制的字段
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// SearchEmailsByParticipant 按参与人地址或域名查询邮件，走参与人表的索引
+// domain 支持 carrier.com、@carrier.com 和 *@carrier.com 三种写法；role 为 from/to/cc/bcc/reply-to，不传时不限；
+// sent_since / sent_before 按发送时间过滤，格式同补录任务的日期；suspicious=1 只查From域名未通过认证对齐的邮件，0 只查其余邮件
+func SearchEmailsByParticipant(c *gin.Context) {
+	accountID, err := strconv.Atoi(c.Query("account_id"))
+	if err != nil {
+		utils.SendResponse(c, err, "无效的账号ID")
+		return
+	}
+	address, _ := mailclient.NormalizeAddress(c.Query("address"))
+	domain := strings.ToLower(strings.TrimSpace(c.Query("domain")))
+	domain = strings.TrimPrefix(strings.TrimPrefix(domain, "*"), "@")
+	if address == "" && domain == "" {
+		utils.SendResponse(c, errors.New("address和domain至少指定一个"), nil)
+		return
+	}
+
+	sentSince, err := parseBackfillDate(c.Query("sent_since"))
+	if err != nil {
+		utils.SendResponse(c, err, nil)
+		return
+	}
+	sentBefore, err := parseBackfillDate(c.Query("sent_before"))
+	if err != nil {
+		utils.SendResponse(c, err, nil)
+		return
+	}
+
+	var suspicious *bool
+	if value := c.Query("suspicious"); value != "" {
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.SendResponse(c, err, "无效的suspicious参数")
+			return
+		}
+		suspicious = &flag
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 500 {
+		pageSize = 50
+	}
+
+	contents, total, err := model.SearchContentsByParticipant(model.ParticipantQuery{
+		AccountID:  accountID,
+		Address:    address,
+		Domain:     domain,
+		Role:       strings.ToLower(c.Query("role")),
+		SentSince:  sentSince,
+		SentBefore: sentBefore,
+		Suspicious: suspicious,
+		Offset:     (page - 1) * pageSize,
+		Limit:      pageSize,
+	})
+	if err != nil {
+		utils.SendResponse(c, err, "查询邮件失败")
+		return
+	}
+	utils.SendResponse(c, nil, gin.H{"total": total, "list": contents})
+}
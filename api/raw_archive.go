@@ -0,0 +1,109 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"go_email/model"
+	"go_email/pkg/utils/oss"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// rawArchiveFolder 原始邮件在对象存储中的目录
+const rawArchiveFolder = "email_raw"
+
+// rawDownloadClient 下载网关归档的原始邮件，超时避免卡住重新解析任务
+var rawDownloadClient = &http.Client{Timeout: 2 * time.Minute}
+
+// rawArchiveEnabled 是否归档原始邮件，未配置时默认开启
+func rawArchiveEnabled() bool {
+	if !viper.IsSet("sync.archive_raw_email") {
+		return true
+	}
+	return viper.GetBool("sync.archive_raw_email")
+}
+
+// rawArchiveKey 按账号和内容SHA-256生成对象键，同一封邮件无论同步几次都对应同一个对象
+func rawArchiveKey(accountID int, sha string) string {
+	return fmt.Sprintf("%s/%d/%s.eml", rawArchiveFolder, accountID, sha)
+}
+
+// archiveRawEmail 把原始RFC 822邮件保存到对象存储，返回对象键和SHA-256
+// 优先使用阿里云OSS按固定对象键上传，已存在时不再重复上传；阿里云不可用时改用上传网关，此时对象键记录为网关返回的URL
+func archiveRawEmail(accountID, emailID int, raw []byte) (string, string, error) {
+	sum := sha256.Sum256(raw)
+	sha := hex.EncodeToString(sum[:])
+	objectKey := rawArchiveKey(accountID, sha)
+
+	uploader, err := oss.NewOSSUploader()
+	if err == nil {
+		exist, existErr := uploader.IsFileExist(objectKey)
+		if existErr == nil && exist {
+			log.Printf("[原始邮件归档] 对象已存在，跳过上传，邮件ID: %d, key: %s", emailID, objectKey)
+			return objectKey, sha, nil
+		}
+		if _, err = uploader.UploadObject(bytes.NewReader(raw), objectKey); err == nil {
+			log.Printf("[原始邮件归档] 上传成功，邮件ID: %d, 大小: %d 字节, key: %s", emailID, len(raw), objectKey)
+			return objectKey, sha, nil
+		}
+	}
+	log.Printf("[原始邮件归档] 阿里云OSS上传失败，改用上传网关，邮件ID: %d, 错误: %v", emailID, err)
+
+	fileURL, err := uploadWithRetry(sha+".eml", base64.StdEncoding.EncodeToString(raw), "eml", emailID, "原始邮件归档")
+	if err != nil {
+		return "", sha, fmt.Errorf("归档原始邮件失败: %v", err)
+	}
+	return fileURL, sha, nil
+}
+
+// loadRawEmail 从对象存储读取归档的原始邮件并校验SHA-256，任务取消时中断下载
+func loadRawEmail(ctx context.Context, content model.PrimeEmailContent) ([]byte, error) {
+	if content.RawObjectKey == "" {
+		return nil, fmt.Errorf("邮件没有归档原始内容")
+	}
+
+	var raw []byte
+	if strings.HasPrefix(content.RawObjectKey, "http://") || strings.HasPrefix(content.RawObjectKey, "https://") {
+		// 通过上传网关归档的邮件记录的是URL
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, content.RawObjectKey, nil)
+		if err != nil {
+			return nil, fmt.Errorf("下载原始邮件失败: %v", err)
+		}
+		resp, err := rawDownloadClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("下载原始邮件失败: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("下载原始邮件失败，状态码: %d", resp.StatusCode)
+		}
+		if raw, err = io.ReadAll(resp.Body); err != nil {
+			return nil, fmt.Errorf("读取原始邮件失败: %v", err)
+		}
+	} else {
+		uploader, err := oss.NewOSSUploader()
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = uploader.GetObject(content.RawObjectKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if content.RawSha256 != "" {
+		sum := sha256.Sum256(raw)
+		if sha := hex.EncodeToString(sum[:]); sha != content.RawSha256 {
+			return nil, fmt.Errorf("原始邮件校验失败，SHA-256不一致: %s", sha)
+		}
+	}
+	return raw, nil
+}
@@ -0,0 +1,628 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_email/db"
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"go_email/pkg/utils"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// reparseSessionTimeout 单个重新解析协程的运行时长，到期后开启新的协程从断点继续
+	reparseSessionTimeout = 60 * time.Minute
+	// reparsePageSize 每页读取的邮件内容数
+	reparsePageSize = 50
+	// reparseDateLayout 接口中日期参数的格式
+	reparseDateLayout = "2006-01-02"
+)
+
+// 执行中的重新解析任务，同一时间只允许一个任务运行，避免重复改写同一封邮件
+var (
+	reparseRunnerMutex sync.Mutex
+	reparseRunnerID    uint
+	reparseRunnerStop  context.CancelFunc
+)
+
+// ReparseRequest 创建重新解析任务的请求参数
+type ReparseRequest struct {
+	AccountId    int    `json:"account_id"`    // 为0时不限账号
+	Since        string `json:"since"`         // 内容保存日期起始（含），格式 2006-01-02，为空表示不限
+	Before       string `json:"before"`        // 内容保存日期截止（不含），格式 2006-01-02，为空表示不限
+	BelowVersion int    `json:"below_version"` // 只处理parser_version小于该值的邮件，默认当前解析器版本
+	DryRun       bool   `json:"dry_run"`       // 只生成差异报告，不修改数据
+}
+
+// ReparseJobRequest 按任务ID操作重新解析任务的请求参数
+type ReparseJobRequest struct {
+	ID uint `json:"id" binding:"required"`
+}
+
+// parseReparseDate 解析日期参数，空字符串返回nil
+func parseReparseDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(reparseDateLayout, value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("日期格式错误(%s)，应为 %s", value, reparseDateLayout)
+	}
+	return &t, nil
+}
+
+// reparseOutcome 单封邮件重新解析的结果
+type reparseOutcome struct {
+	changed  bool
+	noRaw    bool
+	failed   bool
+	canceled bool // 任务取消导致读取中断，不计入进度，下次从这封继续
+}
+
+// startReparseRunner 启动重新解析任务的执行协程
+func startReparseRunner(job model.PrimeEmailReparse) error {
+	reparseRunnerMutex.Lock()
+	if reparseRunnerStop != nil {
+		runningID := reparseRunnerID
+		reparseRunnerMutex.Unlock()
+		return fmt.Errorf("重新解析任务 %d 正在执行中", runningID)
+	}
+	// 其他实例上执行中的任务同样会改写邮件内容，整个集群同一时间只允许一个任务
+	running, err := model.HasRunningReparseJob(job.ID)
+	if err != nil {
+		reparseRunnerMutex.Unlock()
+		return fmt.Errorf("检查重新解析任务失败: %v", err)
+	}
+	if running {
+		reparseRunnerMutex.Unlock()
+		return errors.New("其他实例上有执行中的重新解析任务")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	reparseRunnerID, reparseRunnerStop = job.ID, cancel
+	reparseRunnerMutex.Unlock()
+
+	claimed, err := model.ClaimReparseJob(job.ID, instanceID())
+	if err != nil {
+		finishReparseRunner()
+		return fmt.Errorf("更新重新解析任务状态失败: %v", err)
+	}
+	if !claimed {
+		finishReparseRunner()
+		return fmt.Errorf("重新解析任务 %d 正在执行或已完成", job.ID)
+	}
+
+	if err := startReparseSession(ctx, job.ID); err != nil {
+		finishReparseRunner()
+		_ = model.UpdateReparseJob(job.ID, map[string]interface{}{"status": model.ReparseStatusFailed, "error_message": err.Error()})
+		return fmt.Errorf("启动重新解析协程失败: %v", err)
+	}
+	return nil
+}
+
+// finishReparseRunner 清除执行中的任务记录
+func finishReparseRunner() {
+	reparseRunnerMutex.Lock()
+	if reparseRunnerStop != nil {
+		reparseRunnerStop()
+	}
+	reparseRunnerID, reparseRunnerStop = 0, nil
+	reparseRunnerMutex.Unlock()
+}
+
+// startReparseSession 开启一个重新解析会话协程
+func startReparseSession(jobCtx context.Context, jobID uint) error {
+	return utils.GlobalSafeGoroutineManager.StartSafeGoroutineWithTimeout(
+		jobCtx,
+		fmt.Sprintf("reparse-%d", jobID),
+		reparseSessionTimeout,
+		func(ctx context.Context) {
+			err := runReparseJob(ctx, jobID)
+
+			// 会话到期但任务未完成也未被取消，开启新的会话从断点继续
+			if jobCtx.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				log.Printf("[重新解析] 任务 %d 会话到期，开启新会话继续", jobID)
+				renewErr := startReparseSession(jobCtx, jobID)
+				if renewErr == nil {
+					return
+				}
+				err = fmt.Errorf("续期重新解析协程失败: %v", renewErr)
+			}
+
+			finishReparseRunner()
+			updates := map[string]interface{}{"finished_at": time.Now()}
+			switch {
+			case err == nil:
+				updates["status"] = model.ReparseStatusCompleted
+			case jobCtx.Err() != nil:
+				updates["status"] = model.ReparseStatusCanceled
+			default:
+				updates["status"] = model.ReparseStatusFailed
+				updates["error_message"] = err.Error()
+			}
+			if updateErr := model.UpdateReparseJob(jobID, updates); updateErr != nil {
+				log.Printf("[重新解析] 任务 %d 更新结束状态失败: %v", jobID, updateErr)
+			}
+			log.Printf("[重新解析] 任务 %d 结束，状态: %v，错误: %v", jobID, updates["status"], err)
+		},
+	)
+}
+
+// runReparseJob 按内容ID升序分页，用归档的原始邮件重新解析符合条件的邮件
+func runReparseJob(ctx context.Context, jobID uint) error {
+	job, err := model.GetReparseJob(jobID)
+	if err != nil {
+		return fmt.Errorf("获取重新解析任务失败: %v", err)
+	}
+
+	remaining, err := model.CountReparseContents(job, job.LastContentID)
+	if err != nil {
+		return fmt.Errorf("统计邮件失败: %v", err)
+	}
+	if err := model.UpdateReparseJob(job.ID, map[string]interface{}{
+		"total_count": job.ProcessedCount + int(remaining),
+	}); err != nil {
+		return fmt.Errorf("更新重新解析进度失败: %v", err)
+	}
+	log.Printf("[重新解析] 任务 %d 开始: 账号 %d，解析器版本 %d → %d，待处理约 %d 封，断点内容ID: %d，试运行: %v",
+		job.ID, job.AccountId, job.BelowVersion, job.TargetVersion, remaining, job.LastContentID, job.DryRun)
+
+	authConfigs := make(map[int]*mailclient.EmailConfigInfo) // 账号ID → 服务器配置，用于判断哪些认证结果可信
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		contents, err := model.GetReparseContents(job, job.LastContentID, reparsePageSize)
+		if err != nil {
+			return fmt.Errorf("获取邮件内容失败: %v", err)
+		}
+		if len(contents) == 0 {
+			return nil
+		}
+
+		var unchangedIDs []uint
+		for _, content := range contents {
+			outcome := reparseEmailContent(ctx, job, content, accountAuthConfig(content.AccountId, authConfigs))
+			if outcome.canceled {
+				break
+			}
+			switch {
+			case outcome.noRaw:
+				job.NoRawCount++
+			case outcome.failed:
+				job.FailedCount++
+			case outcome.changed:
+				job.ChangedCount++
+			default:
+				unchangedIDs = append(unchangedIDs, content.ID)
+			}
+			job.ProcessedCount++
+			job.LastContentID = content.ID
+		}
+
+		if !job.DryRun {
+			if err := model.SetContentParserVersion(unchangedIDs, job.TargetVersion); err != nil {
+				return fmt.Errorf("更新解析器版本失败: %v", err)
+			}
+		}
+		if err := model.UpdateReparseJob(job.ID, map[string]interface{}{
+			"last_content_id": job.LastContentID,
+			"processed_count": job.ProcessedCount,
+			"changed_count":   job.ChangedCount,
+			"no_raw_count":    job.NoRawCount,
+			"failed_count":    job.FailedCount,
+		}); err != nil {
+			return fmt.Errorf("更新重新解析进度失败: %v", err)
+		}
+		log.Printf("[重新解析] 任务 %d 进度: 已处理 %d，有变化 %d，无原始邮件 %d，失败 %d，断点内容ID: %d",
+			job.ID, job.ProcessedCount, job.ChangedCount, job.NoRawCount, job.FailedCount, job.LastContentID)
+	}
+}
+
+// reparseEmailContent 重新解析一封邮件，有变化或失败时写入差异报告，非试运行时把新结果写回数据库
+func reparseEmailContent(ctx context.Context, job model.PrimeEmailReparse, content model.PrimeEmailContent, authConfig *mailclient.EmailConfigInfo) reparseOutcome {
+	diff := &model.PrimeEmailReparseDiff{
+		ReparseId:     job.ID,
+		ContentId:     content.ID,
+		EmailID:       content.EmailID,
+		AccountId:     content.AccountId,
+		OldVersion:    content.ParserVersion,
+		OldContentLen: len(content.Content),
+		OldHTMLLen:    len(content.HTMLContent),
+	}
+	saveDiff := func() {
+		if err := model.CreateReparseDiff(diff); err != nil {
+			log.Printf("[重新解析] 保存差异报告失败，内容ID: %d, 错误: %v", content.ID, err)
+		}
+	}
+
+	if content.RawObjectKey == "" {
+		return reparseOutcome{noRaw: true}
+	}
+
+	raw, err := loadRawEmail(ctx, content)
+	if err != nil && ctx.Err() != nil {
+		return reparseOutcome{canceled: true}
+	}
+	if err == nil {
+		var email *mailclient.Email
+		if email, err = mailclient.ParseRawEmail(raw, false); err == nil {
+			mailclient.ApplySenderAuth(email, authConfig)
+			return applyReparsedEmail(job, content, email, diff, saveDiff)
+		}
+	}
+	log.Printf("[重新解析] 邮件重新解析失败，内容ID: %d, 邮件ID: %d, 错误: %v", content.ID, content.EmailID, err)
+	diff.ErrorMessage = err.Error()
+	saveDiff()
+	return reparseOutcome{failed: true}
+}
+
+// applyReparsedEmail 比较重新解析的结果与已保存的内容，有变化时记录差异并在非试运行时写回
+func applyReparsedEmail(job model.PrimeEmailReparse, content model.PrimeEmailContent, email *mailclient.Email,
+	diff *model.PrimeEmailReparseDiff, saveDiff func()) reparseOutcome {
+	verifySenderDKIM(email)
+	stored, err := model.GetContentAttachmentsWithTx(db.DB(), content)
+	if err != nil {
+		diff.ErrorMessage = fmt.Sprintf("获取附件记录失败: %v", err)
+		saveDiff()
+		return reparseOutcome{failed: true}
+	}
+
+	// 保存的HTML已把 cid: 引用替换为内嵌图片地址，比较前按已保存的内嵌图片做同样的替换
+	newContent := utils.SanitizeUTF8(email.Body)
+	inlineURLs := storedInlineURLs(stored)
+	newHTML := utils.SanitizeUTF8(mailclient.RewriteCIDReferences(email.BodyHTML, inlineURLs))
+	diff.NewContentLen = len(newContent)
+	diff.NewHTMLLen = len(newHTML)
+	storedNames := make([]string, 0, len(stored))
+	for _, att := range stored {
+		storedNames = append(storedNames, att.FileName)
+	}
+	parsedNames := make([]string, 0, len(email.Attachments))
+	for _, att := range email.Attachments {
+		parsedNames = append(parsedNames, utils.SanitizeUTF8(att.Filename))
+	}
+	diff.OldAttachments = strings.Join(storedNames, ",")
+	diff.NewAttachments = strings.Join(parsedNames, ",")
+
+	var newHeaders model.PrimeEmailContent
+	fillContentHeaders(&newHeaders, email)
+
+	var changedFields []string
+	if newHeaders.RawHeaders != content.RawHeaders {
+		changedFields = append(changedFields, "headers")
+	}
+	if newContent != content.Content {
+		changedFields = append(changedFields, "content")
+	}
+	if newHTML != content.HTMLContent {
+		changedFields = append(changedFields, "html_content")
+	}
+	if mailclient.SegmentBody(newContent, newHTML).NewText != content.NewContent {
+		changedFields = append(changedFields, "segments")
+	}
+	if newHeaders.SPFResult != content.SPFResult || newHeaders.DKIMResult != content.DKIMResult ||
+		newHeaders.DMARCResult != content.DMARCResult || newHeaders.DKIMVerifyResult != content.DKIMVerifyResult ||
+		newHeaders.SuspiciousSender != content.SuspiciousSender {
+		changedFields = append(changedFields, "auth")
+	}
+	if email.Category != content.Type {
+		changedFields = append(changedFields, "type")
+	}
+	if count, err := model.CountContentParticipants(content.ID); err == nil && count != int64(len(email.Addresses)) {
+		changedFields = append(changedFields, "participants")
+	}
+	if email.Bounce != nil {
+		if count, err := model.CountContentBounces(content.ID); err == nil && count != int64(len(email.Bounce.Recipients)) {
+			changedFields = append(changedFields, "bounces")
+		}
+	}
+	attachmentsChanged := attachmentNamesChanged(email.Attachments, storedNames)
+	if attachmentsChanged {
+		changedFields = append(changedFields, "attachments")
+	}
+	if len(changedFields) == 0 {
+		return reparseOutcome{}
+	}
+	diff.ChangedFields = strings.Join(changedFields, ",")
+
+	if !job.DryRun {
+		if err := saveReparsedContent(content, email, newContent, newHTML, inlineURLs, attachmentsChanged); err != nil {
+			log.Printf("[重新解析] 写回邮件内容失败，内容ID: %d, 错误: %v", content.ID, err)
+			diff.ErrorMessage = err.Error()
+			saveDiff()
+			return reparseOutcome{failed: true}
+		}
+		diff.Applied = true
+	}
+	saveDiff()
+	return reparseOutcome{changed: true}
+}
+
+// attachmentNamesChanged 比较解析出的附件与已保存的附件记录
+// 压缩包和winmail.dat保存的是解出的文件，无法与原文件名对应，此时只检查普通附件是否都已保存
+func attachmentNamesChanged(parsed []mailclient.AttachmentInfo, storedNames []string) bool {
+	var plainNames []string
+	hasArchive := false
+	for _, att := range parsed {
+		if isArchiveFile(att.Filename) || isTNEFAttachment(att) {
+			hasArchive = true
+			continue
+		}
+		plainNames = append(plainNames, utils.SanitizeUTF8(att.Filename))
+	}
+
+	if hasArchive {
+		for _, name := range plainNames {
+			if !slices.Contains(storedNames, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	stored := slices.Clone(storedNames)
+	slices.Sort(plainNames)
+	slices.Sort(stored)
+	return !slices.Equal(plainNames, stored)
+}
+
+// saveReparsedContent 在事务中写回正文、HTML正文、正文分段、邮件头字段、邮件类型、发件人认证结果、参与人、退信记录和解析器版本，附件有变化时重新上传并替换附件记录
+func saveReparsedContent(content model.PrimeEmailContent, email *mailclient.Email, newContent, newHTML string, inlineURLs map[string]string, attachmentsChanged bool) error {
+	var headers model.PrimeEmailContent
+	fillContentHeaders(&headers, email)
+	updates := map[string]interface{}{
+		"content":            newContent,
+		"html_content":       newHTML,
+		"cc_email":           headers.CcEmail,
+		"bcc_email":          headers.BccEmail,
+		"reply_to":           headers.ReplyTo,
+		"message_id":         headers.MessageID,
+		"in_reply_to":        headers.InReplyTo,
+		"message_references": headers.References,
+		"raw_headers":        headers.RawHeaders,
+		"charset":            headers.Charset,
+		"parser_version":     mailclient.ParserVersion,
+	}
+	// 清理后的HTML随正文一起更新，附件有变化时下面会按重新上传后的地址覆盖
+	updates["html_content_sanitized"] = sanitizedHTML(newHTML, inlineURLs)
+	updates["type"] = email.Category
+	updates["spf_result"] = headers.SPFResult
+	updates["dkim_result"] = headers.DKIMResult
+	updates["dmarc_result"] = headers.DMARCResult
+	updates["dkim_verify_result"] = headers.DKIMVerifyResult
+	updates["suspicious_sender"] = headers.SuspiciousSender
+	updates["auth_detail"] = headers.AuthDetail
+	// 历史邮件的时间字段为空时一并补全
+	if content.SentAt.IsZero() && !email.SentAt.IsZero() {
+		updates["sent_at"] = utils.NewUTCTime(email.SentAt)
+	}
+	if content.ReceivedAt.IsZero() && !email.ReceivedAt.IsZero() {
+		updates["received_at"] = utils.NewUTCTime(email.ReceivedAt)
+	}
+
+	var attachments []*model.PrimeEmailContentAttachment
+	if attachmentsChanged {
+		// 原始邮件已经归档，重新构建内容时不再上传；buildEmailContentData只用到账号ID，
+		// 邮件记录ID为0时按解析出的附件判断是否有附件
+		email.Raw = nil
+		data, _, _ := buildEmailContentData(
+			model.PrimeEmailAccount{ID: content.AccountId},
+			model.PrimeEmail{EmailID: content.EmailID, UidValidity: content.UidValidity},
+			content.Folder, email)
+		attachments = data.Attachments
+		updates["has_attachment"] = data.EmailContent.HasAttachment
+		// 内嵌图片重新上传后地址改变，使用按新地址替换后的HTML
+		updates["html_content"] = data.EmailContent.HTMLContent
+		updates["html_content_sanitized"] = data.EmailContent.HTMLSanitized
+		newHTML = data.EmailContent.HTMLContent
+	}
+	segments := model.PrimeEmailContent{Content: newContent, HTMLContent: newHTML}
+	fillBodySegments(&segments)
+	updates["new_content"] = segments.NewContent
+	updates["new_html_content"] = segments.NewHTMLContent
+	updates["quoted_content"] = segments.QuotedContent
+	updates["signature"] = segments.Signature
+	updates["disclaimer"] = segments.Disclaimer
+
+	tx := db.DB().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := model.UpdateReparsedContentWithTx(tx, content.ID, updates); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("更新邮件内容失败: %v", err)
+	}
+	participants := buildParticipants(content.AccountId,
+		model.PrimeEmail{EmailID: content.EmailID, UidValidity: content.UidValidity}, content.Folder, email)
+	if err := model.ReplaceParticipantsWithTx(tx, content.ID, participants); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("替换参与人失败: %v", err)
+	}
+	bounces := buildBounces(content.AccountId,
+		model.PrimeEmail{EmailID: content.EmailID, UidValidity: content.UidValidity}, content.Folder, email)
+	if err := model.ReplaceBouncesWithTx(tx, content.ID, bounces); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("替换退信记录失败: %v", err)
+	}
+	if attachmentsChanged {
+		if err := model.ReplaceContentAttachmentsWithTx(tx, content, attachments); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("替换附件记录失败: %v", err)
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+	return nil
+}
+
+// ResetInterruptedReparseJobs 服务启动时把本实例上次中断的重新解析任务标记为失败，可通过接口重新启动从断点继续
+func ResetInterruptedReparseJobs() {
+	count, err := model.ResetRunningReparseJobs(instanceID())
+	if err != nil {
+		log.Printf("[重新解析] 重置中断的重新解析任务失败: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("[重新解析] %d 个重新解析任务在上次运行时中断，已标记为失败，可重新启动从断点继续", count)
+	}
+}
+
+// CreateReparse 创建并启动重新解析任务
+func CreateReparse(c *gin.Context) {
+	var req ReparseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, err, "无效的参数")
+		return
+	}
+
+	since, err := parseReparseDate(req.Since)
+	if err != nil {
+		utils.SendResponse(c, err, nil)
+		return
+	}
+	before, err := parseReparseDate(req.Before)
+	if err != nil {
+		utils.SendResponse(c, err, nil)
+		return
+	}
+	if since != nil && before != nil && !before.After(*since) {
+		utils.SendResponse(c, errors.New("截止日期必须晚于起始日期"), nil)
+		return
+	}
+	if req.BelowVersion <= 0 || req.BelowVersion > mailclient.ParserVersion {
+		req.BelowVersion = mailclient.ParserVersion
+	}
+
+	job := model.PrimeEmailReparse{
+		AccountId:     req.AccountId,
+		Since:         since,
+		Before:        before,
+		BelowVersion:  req.BelowVersion,
+		DryRun:        req.DryRun,
+		TargetVersion: mailclient.ParserVersion,
+	}
+	if err := model.CreateReparseJob(&job); err != nil {
+		utils.SendResponse(c, err, "创建重新解析任务失败")
+		return
+	}
+	if err := startReparseRunner(job); err != nil {
+		utils.SendResponse(c, err, job)
+		return
+	}
+
+	log.Printf("[重新解析] 创建任务 %d: 账号 %d 日期范围 [%s, %s) 解析器版本 < %d，试运行: %v",
+		job.ID, job.AccountId, req.Since, req.Before, job.BelowVersion, job.DryRun)
+	utils.SendResponse(c, nil, job)
+}
+
+// StartReparse 重新启动失败或已取消的重新解析任务，从断点继续
+func StartReparse(c *gin.Context) {
+	var req ReparseJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, err, "无效的参数")
+		return
+	}
+
+	job, err := model.GetReparseJob(req.ID)
+	if err != nil {
+		utils.SendResponse(c, err, "获取重新解析任务失败")
+		return
+	}
+	if job.Status == model.ReparseStatusCompleted {
+		utils.SendResponse(c, errors.New("重新解析任务已完成"), job)
+		return
+	}
+	if job.Status == model.ReparseStatusRunning {
+		utils.SendResponse(c, fmt.Errorf("重新解析任务正在实例 %s 上执行", job.Owner), job)
+		return
+	}
+	if job.TargetVersion != mailclient.ParserVersion {
+		utils.SendResponse(c, fmt.Errorf("任务创建时的解析器版本为 %d，当前为 %d，请重新创建任务", job.TargetVersion, mailclient.ParserVersion), job)
+		return
+	}
+	if err := startReparseRunner(job); err != nil {
+		utils.SendResponse(c, err, nil)
+		return
+	}
+	utils.SendResponse(c, nil, fmt.Sprintf("重新解析任务 %d 已启动，从内容ID %d 继续", job.ID, job.LastContentID))
+}
+
+// CancelReparse 取消执行中的重新解析任务
+func CancelReparse(c *gin.Context) {
+	var req ReparseJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, err, "无效的参数")
+		return
+	}
+
+	reparseRunnerMutex.Lock()
+	cancel := reparseRunnerStop
+	running := reparseRunnerID == req.ID && cancel != nil
+	reparseRunnerMutex.Unlock()
+	if !running {
+		utils.SendResponse(c, fmt.Errorf("重新解析任务 %d 不在执行中", req.ID), nil)
+		return
+	}
+	cancel()
+	utils.SendResponse(c, nil, fmt.Sprintf("重新解析任务 %d 已取消", req.ID))
+}
+
+// GetReparseStatus 查询重新解析任务进度，传入id时返回单个任务，否则列出最近的任务
+func GetReparseStatus(c *gin.Context) {
+	if idStr := c.Query("id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			utils.SendResponse(c, err, "无效的任务ID")
+			return
+		}
+		job, err := model.GetReparseJob(uint(id))
+		if err != nil {
+			utils.SendResponse(c, err, "获取重新解析任务失败")
+			return
+		}
+		utils.SendResponse(c, nil, job)
+		return
+	}
+
+	jobs, err := model.ListReparseJobs(50)
+	if err != nil {
+		utils.SendResponse(c, err, "获取重新解析任务失败")
+		return
+	}
+	utils.SendResponse(c, nil, jobs)
+}
+
+// GetReparseDiffs 分页查询重新解析任务的差异报告
+func GetReparseDiffs(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Query("id"), 10, 64)
+	if err != nil {
+		utils.SendResponse(c, err, "无效的任务ID")
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 500 {
+		pageSize = 50
+	}
+
+	diffs, total, err := model.ListReparseDiffs(uint(id), (page-1)*pageSize, pageSize)
+	if err != nil {
+		utils.SendResponse(c, err, "获取差异报告失败")
+		return
+	}
+	utils.SendResponse(c, nil, gin.H{"total": total, "list": diffs})
+}
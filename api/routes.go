@@ -39,6 +39,8 @@ func Load1(g *gin.Engine, mw ...gin.HandlerFunc) *gin.Engine {
 			system.POST("/goroutines/cleanup", ForceCleanupGoroutines)
 			// 清理卡死账号状态
 			system.POST("/cleanup-stuck-accounts", CleanupStuckAccounts)
+			// 获取IMAP连接池统计信息
+			system.GET("/imap-pool-stats", GetIMAPPoolStats)
 		}
 
 		// 邮件相关路由
@@ -50,6 +52,35 @@ func Load1(g *gin.Engine, mw ...gin.HandlerFunc) *gin.Engine {
 			// 通过指定uid获取邮件列表
 			emails.POST("/list_by_uid", ListEmailsByUid)
 
+			// IDLE新邮件监听：开启、停止、查看状态
+			emails.POST("/idle/start", StartIdleWatch)
+			emails.POST("/idle/stop", StopIdleWatch)
+			emails.GET("/idle/status", GetIdleWatchStatus)
+
+			// 历史邮件补录（按日期范围）
+			emails.POST("/backfill/create", CreateBackfill)
+			emails.POST("/backfill/start", StartBackfill)
+			emails.POST("/backfill/cancel", CancelBackfill)
+			emails.GET("/backfill/status", GetBackfillStatus)
+			// 检查UID缺口并补录漏掉的邮件
+			emails.POST("/gap-audit", AuditEmailGaps)
+			// 用归档的原始邮件重新解析正文和附件
+			emails.POST("/reparse/create", CreateReparse)
+			emails.POST("/reparse/start", StartReparse)
+			emails.POST("/reparse/cancel", CancelReparse)
+			emails.GET("/reparse/status", GetReparseStatus)
+			emails.GET("/reparse/diffs", GetReparseDiffs)
+			// 会话
+			emails.GET("/thread", GetEmailThread)
+			emails.POST("/thread/rebuild", RebuildThreads)
+			// 按参与人地址或域名查询邮件
+			emails.GET("/participants/search", SearchEmailsByParticipant)
+			// 退信记录和本服务发出的邮件的投递状态
+			emails.GET("/bounces", GetBounces)
+			emails.GET("/outbounds", GetOutbounds)
+			// 为历史邮件补充邮件类型（人工、自动回复、外出回复、邮件列表、群发、退信）
+			emails.POST("/classify/rebuild", RebuildEmailTypes)
+
 			//转发邮件 - 限制最多10个并发请求
 			//emails.POST("/tr_send", middleware.RequestLimit(10), GetForwardOriginalEmail)
 			// 发送邮件
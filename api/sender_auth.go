@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"log"
+	"net"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// dkimResolver 离线验证DKIM签名时查询公钥的DNS解析器
+var dkimResolver mailclient.TXTResolver = net.DefaultResolver
+
+// verifySenderDKIM 配置了 sender_auth.verify_dkim 时按原始邮件离线验证DKIM签名
+func verifySenderDKIM(email *mailclient.Email) {
+	if !viper.GetBool("sender_auth.verify_dkim") || len(email.Raw) == 0 {
+		return
+	}
+	timeout := 10 * time.Second
+	if seconds := viper.GetInt("sender_auth.dkim_timeout_seconds"); seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	mailclient.VerifyEmailDKIM(ctx, email, dkimResolver)
+}
+
+// accountAuthConfig 取账号的服务器配置，按账号缓存在 cache 中；获取失败时返回nil，此时不采用邮件中的认证结果
+func accountAuthConfig(accountID int, cache map[int]*mailclient.EmailConfigInfo) *mailclient.EmailConfigInfo {
+	if config, ok := cache[accountID]; ok {
+		return config
+	}
+	var config *mailclient.EmailConfigInfo
+	account, err := model.GetAccountByID(accountID)
+	if err == nil {
+		config, err = mailclient.GetEmailConfig(account)
+	}
+	if err != nil {
+		log.Printf("[发件人认证] 获取账号 %d 的服务器配置失败，不采用邮件中的认证结果: %v", accountID, err)
+		config = nil
+	}
+	cache[accountID] = config
+	return config
+}
+
+// fillSenderAuth 把发件人认证结果写入邮件内容记录
+func fillSenderAuth(content *model.PrimeEmailContent, email *mailclient.Email) {
+	auth := email.Auth
+	content.SPFResult = truncateRunes(auth.SPF, 16)
+	content.DKIMResult = truncateRunes(auth.DKIM, 16)
+	content.DMARCResult = truncateRunes(auth.DMARC, 16)
+	content.DKIMVerifyResult = truncateRunes(auth.DKIMVerify, 16)
+	content.SuspiciousSender = auth.Suspicious
+	content.AuthDetail = ""
+	if auth.Source == "" && auth.DKIMVerify == "" {
+		return
+	}
+	if data, err := json.Marshal(auth); err == nil {
+		content.AuthDetail = string(data)
+	} else {
+		log.Printf("[发件人认证] 序列化认证结果失败，邮件ID: %s, 错误: %v", email.EmailID, err)
+	}
+}
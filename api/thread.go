@@ -0,0 +1,319 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_email/model"
+	"go_email/pkg/thread"
+	"go_email/pkg/utils"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// threadNeighborLimit 计算会话时最多加载的已保存邮件数
+const threadNeighborLimit = 500
+
+// threadRebuildBatchSize 重建会话时每批处理的邮件数
+const threadRebuildBatchSize = 200
+
+// threadRebuilder 按账号在后台重建会话
+var threadRebuilder = newAccountRebuilder("thread-rebuild", "[会话]", "会话重建", rebuildAccountThreads)
+
+// threadSubjectWindow 按主题归并会话时向前查找的时间范围，配置为0时只按Message-ID计算会话
+func threadSubjectWindow() time.Duration {
+	days := 30
+	if viper.IsSet("thread.subject_window_days") {
+		days = viper.GetInt("thread.subject_window_days")
+	}
+	if days <= 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// threadMessage 把邮件内容转换为会话计算的输入，In-Reply-To追加在References末尾
+func threadMessage(c *model.PrimeEmailContent) *thread.Message {
+	refs := strings.Fields(c.References)
+	if c.InReplyTo != "" && (len(refs) == 0 || refs[len(refs)-1] != c.InReplyTo) {
+		refs = append(refs, c.InReplyTo)
+	}
+	date := c.CreatedAt.Time
+	if !c.SentAt.IsZero() {
+		date = c.SentAt.Time
+	} else if t, ok := utils.ParseMailDate(c.Date); ok {
+		date = t
+	}
+	return &thread.Message{ID: c.ID, MessageID: c.MessageID, References: refs, Subject: c.Subject, Date: date}
+}
+
+// threadSubject 计算保存到thread_subject的规范化主题
+func threadSubject(subject string) string {
+	normalized, _ := thread.NormalizeSubject(subject)
+	if runes := []rune(normalized); len(runes) > 255 {
+		normalized = string(runes[:255])
+	}
+	return normalized
+}
+
+// assignThreads 为刚保存的邮件计算会话，失败只记录日志，不影响邮件保存
+func assignThreads(contents []*model.PrimeEmailContent) {
+	byAccount := make(map[int][]*model.PrimeEmailContent)
+	for _, c := range contents {
+		if c.ID != 0 {
+			byAccount[c.AccountId] = append(byAccount[c.AccountId], c)
+		}
+	}
+	for accountID, list := range byAccount {
+		if err := assignAccountThreads(accountID, list); err != nil {
+			log.Printf("[会话] 计算会话失败，账号ID: %d, 邮件数: %d, 错误: %v", accountID, len(list), err)
+		}
+	}
+}
+
+// assignAccountThreads 对新邮件和与其相关的已保存邮件执行JWZ算法，为新邮件所在的会话分配会话ID
+// 会话ID取会话中已有的最小会话ID，都没有时取最小的邮件内容ID；一封新邮件连接了多个已有会话时把它们合并
+func assignAccountThreads(accountID int, contents []*model.PrimeEmailContent) error {
+	window := threadSubjectWindow()
+	query := model.ThreadNeighborQuery{
+		AccountID:    accountID,
+		SubjectSince: time.Now().Add(-window),
+		Limit:        threadNeighborLimit,
+	}
+	isNew := make(map[uint]bool, len(contents))
+	all := make(map[uint]*model.PrimeEmailContent, len(contents))
+	for _, c := range contents {
+		isNew[c.ID] = true
+		all[c.ID] = c
+		msg := threadMessage(c)
+		if c.MessageID != "" {
+			query.MessageIDs = append(query.MessageIDs, c.MessageID)
+			query.ReplyTo = append(query.ReplyTo, c.MessageID)
+		}
+		query.MessageIDs = append(query.MessageIDs, msg.References...)
+		// 只有去掉了回复邮件头的回复才按主题查找，同主题的通知邮件不会因此连成一个会话
+		if window > 0 && thread.SubjectOnlyReply(msg) {
+			if subject := threadSubject(c.Subject); subject != "" {
+				query.Subjects = append(query.Subjects, subject)
+			}
+		}
+	}
+	for _, list := range []*[]string{&query.MessageIDs, &query.ReplyTo, &query.Subjects} {
+		slices.Sort(*list)
+		*list = slices.Compact(*list)
+	}
+
+	neighbors, err := model.GetThreadNeighbors(query)
+	if err != nil {
+		return fmt.Errorf("查询相关邮件失败: %v", err)
+	}
+	for i := range neighbors {
+		if _, ok := all[neighbors[i].ID]; !ok {
+			all[neighbors[i].ID] = &neighbors[i]
+		}
+	}
+
+	ids := make([]uint, 0, len(all))
+	for id := range all {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	messages := make([]*thread.Message, 0, len(ids))
+	for _, id := range ids {
+		messages = append(messages, threadMessage(all[id]))
+	}
+
+	for _, group := range thread.Group(messages, window > 0) {
+		hasNew := false
+		var existing []uint
+		minID := group[0].ID
+		for _, msg := range group {
+			c := all[msg.ID]
+			if isNew[c.ID] {
+				hasNew = true
+			}
+			if c.ThreadID != 0 && !slices.Contains(existing, c.ThreadID) {
+				existing = append(existing, c.ThreadID)
+			}
+			minID = min(minID, c.ID)
+		}
+		if !hasNew {
+			continue
+		}
+
+		target := minID
+		if len(existing) > 0 {
+			target = slices.Min(existing)
+		}
+		others := slices.DeleteFunc(existing, func(id uint) bool { return id == target })
+		if len(others) > 0 {
+			merged, err := model.MergeThreads(accountID, others, target)
+			if err != nil {
+				return fmt.Errorf("合并会话失败: %v", err)
+			}
+			log.Printf("[会话] 合并会话，账号ID: %d, 会话 %v → %d, 邮件数: %d", accountID, others, target, merged)
+		}
+
+		for _, msg := range group {
+			c := all[msg.ID]
+			subject := threadSubject(c.Subject)
+			if c.ThreadID == target && c.ThreadSubject == subject {
+				continue
+			}
+			if err := model.UpdateContentThread(c.ID, target, subject); err != nil {
+				return fmt.Errorf("更新会话ID失败，内容ID: %d, 错误: %v", c.ID, err)
+			}
+			c.ThreadID, c.ThreadSubject = target, subject
+		}
+	}
+	return nil
+}
+
+// ThreadRebuildRequest 重建会话请求
+type ThreadRebuildRequest struct {
+	AccountID int `json:"account_id" binding:"required"`
+}
+
+// RebuildThreads 为账号中尚未计算会话的历史邮件计算会话，在后台按内容ID升序分批执行
+func RebuildThreads(c *gin.Context) {
+	var req ThreadRebuildRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.SendResponse(c, err, "无效的参数")
+		return
+	}
+
+	if err := threadRebuilder.start(req.AccountID); err != nil {
+		utils.SendResponse(c, err, nil)
+		return
+	}
+	utils.SendResponse(c, nil, fmt.Sprintf("账号 %d 的会话重建已在后台启动", req.AccountID))
+}
+
+// rebuildAccountThreads 分批为账号中thread_id为0的邮件计算会话
+func rebuildAccountThreads(ctx context.Context, accountID int) (int, error) {
+	var afterID uint
+	processed := 0
+	for {
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+		contents, err := model.GetUnthreadedContents(accountID, afterID, threadRebuildBatchSize)
+		if err != nil {
+			return processed, fmt.Errorf("获取邮件失败: %v", err)
+		}
+		if len(contents) == 0 {
+			return processed, nil
+		}
+
+		batch := make([]*model.PrimeEmailContent, 0, len(contents))
+		for i := range contents {
+			batch = append(batch, &contents[i])
+		}
+		if err := assignAccountThreads(accountID, batch); err != nil {
+			return processed, err
+		}
+		processed += len(contents)
+		afterID = contents[len(contents)-1].ID
+		log.Printf("[会话] 账号 %d 会话重建进度: 已处理 %d，断点内容ID: %d", accountID, processed, afterID)
+	}
+}
+
+// threadNode 会话树中的一封邮件
+type threadNode struct {
+	ContentID uint          `json:"content_id"`
+	Subject   string        `json:"subject"`
+	FromEmail string        `json:"from_email"`
+	Date      string        `json:"date"`
+	SentAt    utils.UTCTime `json:"sent_at"`
+	Children  []*threadNode `json:"children,omitempty"`
+}
+
+// buildThreadTree 按回复关系把会话中的邮件组织成树
+func buildThreadTree(contents []model.PrimeEmailContent) []*threadNode {
+	byID := make(map[uint]*model.PrimeEmailContent, len(contents))
+	messages := make([]*thread.Message, 0, len(contents))
+	for i := range contents {
+		byID[contents[i].ID] = &contents[i]
+		messages = append(messages, threadMessage(&contents[i]))
+	}
+
+	var convert func(c *thread.Container) []*threadNode
+	convert = func(c *thread.Container) []*threadNode {
+		var children []*threadNode
+		for _, child := range c.Children {
+			children = append(children, convert(child)...)
+		}
+		if c.Message == nil {
+			// 按主题归并时产生的空容器，子节点直接上移
+			return children
+		}
+		content := byID[c.Message.ID]
+		return []*threadNode{{
+			ContentID: content.ID,
+			Subject:   content.Subject,
+			FromEmail: content.FromEmail,
+			Date:      content.Date,
+			SentAt:    content.SentAt,
+			Children:  children,
+		}}
+	}
+
+	var tree []*threadNode
+	for _, root := range thread.Build(messages, true) {
+		tree = append(tree, convert(root)...)
+	}
+	return tree
+}
+
+// GetEmailThread 获取整个会话的邮件，按thread_id或会话中任意一封邮件的content_id查询
+func GetEmailThread(c *gin.Context) {
+	var threadID uint
+	if idStr := c.Query("thread_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			utils.SendResponse(c, err, "无效的会话ID")
+			return
+		}
+		threadID = uint(id)
+	} else if idStr := c.Query("content_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			utils.SendResponse(c, err, "无效的邮件内容ID")
+			return
+		}
+		content, err := model.GetContentByID(uint(id))
+		if err != nil {
+			utils.SendResponse(c, err, "获取邮件内容失败")
+			return
+		}
+		if content.ThreadID == 0 {
+			// 会话重建之前保存的邮件，先计算会话
+			if err := assignAccountThreads(content.AccountId, []*model.PrimeEmailContent{content}); err != nil {
+				utils.SendResponse(c, err, "计算会话失败")
+				return
+			}
+		}
+		threadID = content.ThreadID
+	} else {
+		utils.SendResponse(c, errors.New("缺少thread_id或content_id参数"), nil)
+		return
+	}
+
+	contents, err := model.GetThreadContents(threadID)
+	if err != nil {
+		utils.SendResponse(c, err, "获取会话邮件失败")
+		return
+	}
+	utils.SendResponse(c, nil, gin.H{
+		"thread_id": threadID,
+		"total":     len(contents),
+		"list":      contents,
+		"tree":      buildThreadTree(contents),
+	})
+}
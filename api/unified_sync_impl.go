@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go_email/db"
@@ -22,11 +23,60 @@ import (
 	"gorm.io/gorm"
 )
 
-// syncAccountEmailList 同步单个账号的邮件列表
+// syncAccountEmailList 同步单个账号的邮件列表（按账号配置的文件夹逐个同步）
 func syncAccountEmailList(mailClient *mailclient.MailClient, account model.PrimeEmailAccount, limit int, ctx context.Context) (int, error) {
-	folder := "INBOX"
+	folders, err := mailClient.ResolveFolders(account.FolderPatterns())
+	if err != nil {
+		return 0, fmt.Errorf("解析同步文件夹失败: %v", err)
+	}
+
+	if len(folders) == 0 {
+		log.Printf("账号ID %d: 没有匹配到需要同步的文件夹，配置: %v", account.ID, account.FolderPatterns())
+		return 0, nil
+	}
+
+	log.Printf("账号ID %d: 需要同步 %d 个文件夹: %v", account.ID, len(folders), folders)
+
+	totalCount := 0
+	var failedFolders []string
+	for _, folder := range folders {
+		if ctx.Err() != nil {
+			return totalCount, ctx.Err()
+		}
+
+		count, err := syncFolderEmailList(mailClient, account, folder, limit)
+		if err != nil {
+			log.Printf("账号ID %d: 文件夹 %s 同步失败: %v", account.ID, folder, err)
+			failedFolders = append(failedFolders, folder)
+			continue
+		}
+		totalCount += count
+
+		// 列表同步成功后对账已读/星标/删除状态，失败不影响列表同步结果
+		if err := reconcileFolderFlags(mailClient, account, folder); err != nil {
+			log.Printf("[标记对账] 账号ID %d 文件夹 %s 对账失败: %v", account.ID, folder, err)
+		}
+
+		// 定期检查游标范围内是否有漏掉的UID，缺失的邮件补录为待处理
+		if _, err := auditFolderGaps(mailClient, account, folder, false); err != nil {
+			log.Printf("[缺口检查] 账号ID %d 文件夹 %s 检查失败: %v", account.ID, folder, err)
+		}
+	}
+
+	// 只有全部文件夹都失败时才认为账号同步失败，单个文件夹失败不影响其他文件夹
+	if len(failedFolders) == len(folders) {
+		return totalCount, fmt.Errorf("所有文件夹同步失败: %v", failedFolders)
+	}
+	if len(failedFolders) > 0 {
+		log.Printf("账号ID %d: 部分文件夹同步失败: %v", account.ID, failedFolders)
+	}
 
-	// 使用数据库事务获取最新邮件ID并处理邮件
+	return totalCount, nil
+}
+
+// syncFolderEmailList 同步账号单个文件夹的邮件列表，每个文件夹维护独立的UID游标
+func syncFolderEmailList(mailClient *mailclient.MailClient, account model.PrimeEmailAccount, folder string, limit int) (int, error) {
+	// 使用数据库事务获取文件夹游标并处理邮件
 	tx := db.DB().Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -35,20 +85,68 @@ func syncAccountEmailList(mailClient *mailclient.MailClient, account model.Prime
 		}
 	}()
 
-	lastEmail, err := model.GetLatestEmailWithTx(tx, account.ID)
+	// 获取文件夹当前的UIDVALIDITY
+	folderStatus, err := mailClient.GetFolderStatus(folder)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Printf("账号ID %d 数据库中没有邮件记录，可能为第一次同步", account.ID)
-		} else {
+		tx.Rollback()
+		return 0, fmt.Errorf("获取文件夹状态失败: %v", err)
+	}
+	uidValidity := folderStatus.UIDValidity
+
+	var lastUID uint32
+	resync := false
+	folderState, err := model.GetEmailFolderWithTx(tx, account.ID, folder)
+	if err == nil {
+		lastUID = folderState.LastUID
+		switch {
+		case folderState.UidValidity == 0:
+			// 升级前的游标没有记录UIDVALIDITY，视为当前值并补齐历史数据
+			if err := model.BackfillUIDValidityWithTx(tx, account.ID, folder, uidValidity); err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+		case folderState.UidValidity != uidValidity:
+			// UIDVALIDITY变化：旧UID全部失效，标记旧记录并从头重新同步
+			staleCount, err := model.MarkStaleEmailsWithTx(tx, account.ID, folder, uidValidity)
+			if err != nil {
+				tx.Rollback()
+				return 0, fmt.Errorf("标记失效邮件失败: %v", err)
+			}
+			log.Printf("[UIDVALIDITY] 账号ID %d 文件夹 %s UIDVALIDITY变化: %d → %d，已标记 %d 封待处理邮件为UID失效，游标从 %d 重置为0",
+				account.ID, folder, folderState.UidValidity, uidValidity, staleCount, lastUID)
+			if err := model.ResetFolderModSeqWithTx(tx, account.ID, folder); err != nil {
+				tx.Rollback()
+				return 0, fmt.Errorf("重置文件夹MODSEQ失败: %v", err)
+			}
+			lastUID = 0
+			resync = true
+		}
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 没有游标记录时，兼容历史数据：从该文件夹已保存的最大email_id继续
+		lastEmail, err := model.GetLatestEmailWithTx(tx, account.ID, folder)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 			tx.Rollback()
 			return 0, fmt.Errorf("获取最大email_id失败: %v", err)
 		}
+		if lastEmail.EmailID > 0 {
+			lastUID = uint32(lastEmail.EmailID)
+			if err := model.BackfillUIDValidityWithTx(tx, account.ID, folder, uidValidity); err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+		} else {
+			log.Printf("账号ID %d 文件夹 %s 数据库中没有邮件记录，可能为第一次同步", account.ID, folder)
+		}
+	} else {
+		tx.Rollback()
+		return 0, fmt.Errorf("获取文件夹同步状态失败: %v", err)
 	}
 
 	var emailsResult []mailclient.EmailInfo
-	if lastEmail.EmailID > 0 {
-		log.Printf("账号ID %d 当前数据库最大email_id: %d", account.ID, lastEmail.EmailID)
-		emailsResult, err = mailClient.ListEmailsFromUID(folder, limit, uint32(lastEmail.EmailID))
+	if lastUID > 0 || resync {
+		// 增量同步和UIDVALIDITY重置后的重新同步都按UID升序分批获取
+		log.Printf("账号ID %d 文件夹 %s 当前UID游标: %d (UIDVALIDITY: %d)", account.ID, folder, lastUID, uidValidity)
+		emailsResult, err = mailClient.ListEmailsFromUID(folder, limit, lastUID)
 	} else {
 		emailsResult, err = mailClient.ListEmails(folder, limit)
 	}
@@ -60,12 +158,17 @@ func syncAccountEmailList(mailClient *mailclient.MailClient, account model.Prime
 
 	// 如果没有新邮件，也要更新同步时间后提交事务并返回
 	if len(emailsResult) == 0 {
+		if err := model.SaveFolderCursorWithTx(tx, account.ID, folder, uidValidity, lastUID); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("保存文件夹游标失败: %v", err)
+		}
+
 		if err := model.UpdateLastSyncTimeWithTx(tx, account.ID); err != nil {
 			tx.Rollback()
 			return 0, fmt.Errorf("更新最后同步时间失败: %v", err)
 		}
 
-		log.Printf("账号ID %d: 没有新邮件，但已更新最后同步时间", account.ID)
+		log.Printf("账号ID %d 文件夹 %s: 没有新邮件，但已更新最后同步时间", account.ID, folder)
 
 		if err := tx.Commit().Error; err != nil {
 			return 0, fmt.Errorf("提交事务失败: %v", err)
@@ -75,24 +178,29 @@ func syncAccountEmailList(mailClient *mailclient.MailClient, account model.Prime
 
 	// 构建邮件列表
 	var emailList []*model.PrimeEmail
+	maxUID := lastUID
 	for _, email := range emailsResult {
-		emailID, _ := strconv.Atoi(email.EmailID)
-		emailInfo := &model.PrimeEmail{
-			EmailID:       emailID,
-			FromEmail:     utils.SanitizeUTF8(email.From),
-			Subject:       utils.SanitizeUTF8(email.Subject),
-			Date:          utils.SanitizeUTF8(email.Date),
-			HasAttachment: 0,
-			AccountId:     account.ID,
-			Status:        -1, // 初始状态
-			CreatedAt:     utils.JsonTime{Time: time.Now()},
+		if email.UID > maxUID {
+			maxUID = email.UID
 		}
+		emailList = append(emailList, newPrimeEmail(account, folder, uidValidity, email))
+	}
 
-		if email.HasAttachments {
-			emailInfo.HasAttachment = 1
+	// 文件夹中存在旧UIDVALIDITY的记录时，先按Message-ID重新关联，避免重复创建
+	rekeyedCount := 0
+	staleCount, err := model.CountStaleEmailsWithTx(tx, account.ID, folder, uidValidity)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("统计失效邮件失败: %v", err)
+	}
+	if staleCount > 0 {
+		rekeyedCount, emailList, err = model.RekeyStaleEmailsWithTx(tx, emailList)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
 		}
-
-		emailList = append(emailList, emailInfo)
+		log.Printf("[UIDVALIDITY] 账号ID %d 文件夹 %s: 旧记录 %d 封，本批按Message-ID重新关联 %d 封",
+			account.ID, folder, staleCount, rekeyedCount)
 	}
 
 	// 批量创建邮件记录（容错处理）
@@ -102,6 +210,12 @@ func syncAccountEmailList(mailClient *mailclient.MailClient, account model.Prime
 		return 0, fmt.Errorf("批量创建邮件记录失败: %v", err)
 	}
 
+	// 在同一事务中推进文件夹游标
+	if err := model.SaveFolderCursorWithTx(tx, account.ID, folder, uidValidity, maxUID); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("保存文件夹游标失败: %v", err)
+	}
+
 	// 更新账号的最后同步时间
 	if err := model.UpdateLastSyncTimeWithTx(tx, account.ID); err != nil {
 		tx.Rollback()
@@ -113,12 +227,37 @@ func syncAccountEmailList(mailClient *mailclient.MailClient, account model.Prime
 		return 0, fmt.Errorf("提交事务失败: %v", err)
 	}
 
-	log.Printf("账号ID %d: 邮件列表同步成功 - 总计:%d, 成功:%d, 跳过:%d, 失败:%d",
-		account.ID, result.TotalCount, result.SuccessCount, result.SkippedCount, result.FailedCount)
+	log.Printf("账号ID %d 文件夹 %s: 邮件列表同步成功 - 总计:%d, 成功:%d, 跳过:%d, 失败:%d, 重新关联:%d, UID游标: %d → %d",
+		account.ID, folder, result.TotalCount+rekeyedCount, result.SuccessCount, result.SkippedCount, result.FailedCount, rekeyedCount, lastUID, maxUID)
 
 	return result.SuccessCount, nil
 }
 
+// newPrimeEmail 根据邮件列表信息创建待获取内容的邮件记录
+func newPrimeEmail(account model.PrimeEmailAccount, folder string, uidValidity uint32, email mailclient.EmailInfo) *model.PrimeEmail {
+	emailID, _ := strconv.Atoi(email.EmailID)
+	emailInfo := &model.PrimeEmail{
+		EmailID:       emailID,
+		Folder:        folder,
+		UidValidity:   uidValidity,
+		MessageID:     utils.SanitizeUTF8(email.MessageID),
+		FromEmail:     utils.SanitizeUTF8(email.From),
+		Subject:       utils.SanitizeUTF8(email.Subject),
+		Date:          utils.SanitizeUTF8(email.Date),
+		SentAt:        utils.NewUTCTime(email.SentAt),
+		ReceivedAt:    utils.NewUTCTime(email.ReceivedAt),
+		HasAttachment: 0,
+		AccountId:     account.ID,
+		Status:        -1, // 初始状态
+		CreatedAt:     utils.JsonTime{Time: time.Now()},
+	}
+
+	if email.HasAttachments {
+		emailInfo.HasAttachment = 1
+	}
+	return emailInfo
+}
+
 // syncAccountEmailContent 同步单个账号的邮件内容
 func syncAccountEmailContent(mailClient *mailclient.MailClient, account model.PrimeEmailAccount, limit int, ctx context.Context) (int, error) {
 	// 获取该账号的待处理邮件
@@ -134,7 +273,11 @@ func syncAccountEmailContent(mailClient *mailclient.MailClient, account model.Pr
 
 	log.Printf("账号 %d (%s) - 获取到 %d 封待处理邮件", account.ID, account.Account, len(accountEmails))
 
-	folder := "INBOX"
+	// 配置了批量获取时，每批只执行一次SELECT和UID FETCH，附件由工作协程并发上传
+	if batchSize := contentFetchBatchSize(account); batchSize > 1 {
+		return syncAccountEmailContentPipelined(mailClient, account, accountEmails, batchSize, ctx)
+	}
+
 	startTime := time.Now()
 
 	// 从context获取deadline，计算实际可用时间
@@ -201,9 +344,9 @@ func syncAccountEmailContent(mailClient *mailclient.MailClient, account model.Pr
 			// 重置未处理邮件的状态：从0（处理中）回到-1（待处理），下次同步时会被重新处理
 			if remainingEmails > 0 {
 				log.Printf("[邮件内容同步] 开始重置 %d 封未处理邮件的状态（status: 0 → -1）", remainingEmails)
-				var resetEmailIDs []int
+				var resetEmailIDs []uint
 				for j := i; j < len(accountEmails); j++ {
-					resetEmailIDs = append(resetEmailIDs, accountEmails[j].EmailID)
+					resetEmailIDs = append(resetEmailIDs, accountEmails[j].ID)
 				}
 
 				// 批量重置状态
@@ -211,7 +354,7 @@ func syncAccountEmailContent(mailClient *mailclient.MailClient, account model.Pr
 					resetCount := 0
 					for _, emailID := range resetEmailIDs {
 						if resetErr := resetEmailStatus(emailID, -1); resetErr != nil {
-							log.Printf("[邮件内容同步] 重置邮件状态失败，邮件ID: %d, 错误: %v", emailID, resetErr)
+							log.Printf("[邮件内容同步] 重置邮件状态失败，记录ID: %d, 错误: %v", emailID, resetErr)
 						} else {
 							resetCount++
 						}
@@ -230,54 +373,17 @@ func syncAccountEmailContent(mailClient *mailclient.MailClient, account model.Pr
 		}
 
 		emailStartTime := time.Now()
+		folder := emailOne.GetFolder()
 		email, err := mailClient.GetEmailContent(uint32(emailOne.EmailID), folder)
 		emailDuration := time.Since(emailStartTime)
 		totalFetchTime += emailDuration
 
 		if err != nil {
-			log.Printf("[邮件内容同步] 获取邮件内容失败，邮件ID: %d, 耗时: %v, 错误: %v", emailOne.EmailID, emailDuration, err)
+			log.Printf("[邮件内容同步] 获取邮件内容失败，邮件ID: %d, 文件夹: %s, 耗时: %v, 错误: %v", emailOne.EmailID, folder, emailDuration, err)
 			failureCount++
 
-			// 根据错误类型决定状态：
-			// - 网络/连接错误 → -1（重新处理）
-			// - 邮件已删除 → -3（已删除）
-			// - 其他错误 → -2（永久失败）
-			var newStatus int
-			errStr := strings.ToLower(err.Error())
-
-			// 检查是否是邮件已删除或UID无效的错误
-			if strings.Contains(errStr, "邮件不存在") ||
-				strings.Contains(errStr, "邮件uid无效") ||
-				strings.Contains(errStr, "bad sequence") {
-				newStatus = -3 // 已删除
-				log.Printf("[邮件内容同步] 检测到邮件已删除或UID无效，标记为已删除状态: 邮件ID=%d", emailOne.EmailID)
-			} else if strings.Contains(errStr, "timeout") ||
-				strings.Contains(errStr, "connection") ||
-				strings.Contains(errStr, "network") ||
-				strings.Contains(errStr, "read tcp") ||
-				strings.Contains(errStr, "write tcp") ||
-				strings.Contains(errStr, "broken pipe") ||
-				strings.Contains(errStr, "connection reset") ||
-				strings.Contains(errStr, "i/o timeout") ||
-				strings.Contains(errStr, "operation timed out") ||
-				strings.Contains(errStr, "context deadline exceeded") ||
-				strings.Contains(errStr, "context canceled") ||
-				strings.Contains(errStr, "error reading response") ||
-				strings.Contains(errStr, "server error") ||
-				strings.Contains(errStr, "temporary failure") ||
-				strings.Contains(errStr, "service unavailable") ||
-				strings.Contains(errStr, "server busy") ||
-				strings.Contains(errStr, "please try again later") ||
-				strings.Contains(errStr, "连接状态异常") ||
-				strings.Contains(errStr, "需要重新建立连接") {
-				newStatus = -1 // 重新处理
-				log.Printf("[邮件内容同步] 检测到临时错误，设置状态为-1（重新处理），邮件ID: %d", emailOne.EmailID)
-			} else {
-				newStatus = -2 // 永久失败
-				log.Printf("[邮件内容同步] 检测到永久错误，设置状态为-2（永久失败），邮件ID: %d", emailOne.EmailID)
-			}
-
-			resetErr := resetEmailStatus(emailOne.EmailID, newStatus)
+			newStatus := contentFailureStatus(err, emailOne.EmailID)
+			resetErr := resetEmailStatus(emailOne.ID, newStatus)
 			if resetErr != nil {
 				log.Printf("[邮件内容同步] 设置邮件状态失败，邮件ID: %d, 错误: %v", emailOne.EmailID, resetErr)
 			}
@@ -290,196 +396,12 @@ func syncAccountEmailContent(mailClient *mailclient.MailClient, account model.Pr
 			continue
 		}
 
-		// 创建邮件内容记录
-		emailContent := &model.PrimeEmailContent{
-			EmailID:     emailOne.EmailID,
-			AccountId:   account.ID,
-			Subject:     utils.SanitizeUTF8(email.Subject),
-			FromEmail:   utils.SanitizeUTF8(email.From),
-			ToEmail:     utils.SanitizeUTF8(email.To),
-			Date:        utils.SanitizeUTF8(email.Date),
-			Content:     utils.SanitizeUTF8(email.Body),
-			HTMLContent: utils.SanitizeUTF8(email.BodyHTML),
-			Type:        0,
-			Status:      -1,
-			CreatedAt:   utils.JsonTime{Time: time.Now()},
-		}
-
-		// 查询对应的PrimeEmail记录，以获取HasAttachment值
-		var primeEmail model.PrimeEmail
-		if err := db.DB().Where("email_id = ? AND account_id = ?", emailOne.EmailID, account.ID).First(&primeEmail).Error; err != nil {
-			log.Printf("[邮件内容同步] 查询PrimeEmail记录失败，使用默认附件状态: %v", err)
-			// 如果查询失败，则使用默认的附件检测逻辑
-			if len(email.Attachments) > 0 {
-				emailContent.HasAttachment = 1
-			} else {
-				emailContent.HasAttachment = 0
-			}
-		} else {
-			// 使用PrimeEmail表中的HasAttachment值
-			emailContent.HasAttachment = primeEmail.HasAttachment
-			log.Printf("[邮件内容同步] 使用PrimeEmail记录的附件状态，邮件ID: %d, HasAttachment: %d",
-				emailOne.EmailID, primeEmail.HasAttachment)
-		}
-
-		// 处理附件 - 仅在PrimeEmail表示有附件时处理
-		var attachments []*model.PrimeEmailContentAttachment
-		var attachmentOSSTime time.Duration
-
-		// 如果PrimeEmail表示没有附件，则跳过附件处理，不需要再检查实际邮件
-		if emailContent.HasAttachment == 0 {
-			log.Printf("[邮件内容同步] 根据PrimeEmail记录判断邮件无附件，跳过附件处理，邮件ID: %d", emailOne.EmailID)
-		} else if len(email.Attachments) > 0 {
-			log.Printf("[邮件内容同步] 邮件含有 %d 个附件，邮件ID: %d", len(email.Attachments), emailOne.EmailID)
-
-			attachmentCount += len(email.Attachments)
-
-			for i, att := range email.Attachments {
-				log.Printf("[附件处理] 开始处理附件 %d/%d，邮件ID: %d, 文件名: %s",
-					i+1, len(email.Attachments), emailOne.EmailID, att.Filename)
-
-				if att.Base64Data != "" {
-					// 检查是否为压缩包文件
-					if isArchiveFile(att.Filename) {
-						// 处理压缩包文件
-						log.Printf("[附件处理] 检测到压缩包文件，开始解压处理，邮件ID: %d, 文件名: %s", emailOne.EmailID, att.Filename)
-						archiveStartTime := time.Now()
-
-						processedAttachments, archiveErr := processArchiveAttachment(att, int64(emailOne.EmailID), uint(account.ID))
-						archiveDuration := time.Since(archiveStartTime)
-						attachmentOSSTime += archiveDuration
-
-						if archiveErr != nil {
-							log.Printf("[附件处理] 压缩包处理失败，邮件ID: %d, 文件名: %s, 错误: %v",
-								emailOne.EmailID, att.Filename, archiveErr)
-						} else if len(processedAttachments) > 0 {
-							// 压缩包处理成功，为每个解压出来的文件创建附件记录
-							log.Printf("[附件处理] 压缩包处理成功，共上传 %d 个文件，总耗时: %v，邮件ID: %d, 文件名: %s",
-								len(processedAttachments), archiveDuration, emailOne.EmailID, att.Filename)
-
-							for _, processedAtt := range processedAttachments {
-								attachment := &model.PrimeEmailContentAttachment{
-									EmailID:   emailOne.EmailID,
-									AccountId: account.ID,
-									FileName:  utils.SanitizeUTF8(processedAtt.FileName),
-									SizeKb:    processedAtt.SizeKB,
-									MimeType:  utils.SanitizeUTF8(processedAtt.MimeType),
-									OssUrl:    utils.SanitizeUTF8(processedAtt.OssURL),
-									CreatedAt: utils.JsonTime{Time: time.Now()},
-								}
-								attachments = append(attachments, attachment)
-							}
-						} else {
-							log.Printf("[附件处理] 压缩包处理完成但没有成功上传任何文件，邮件ID: %d, 文件名: %s",
-								emailOne.EmailID, att.Filename)
-						}
-
-						// 无论压缩包处理是否成功，都为原始压缩包文件创建一个附件记录
-						originalOssURL := ""
-						if archiveErr != nil || len(processedAttachments) == 0 {
-							// 如果压缩包处理失败或没有成功上传任何文件，尝试上传原始压缩包
-							log.Printf("[附件处理] 上传原始压缩包文件，邮件ID: %d, 文件名: %s",
-								emailOne.EmailID, att.Filename)
-
-							fileType := ""
-							if att.MimeType != "" {
-								parts := strings.Split(att.MimeType, "/")
-								if len(parts) > 1 {
-									fileType = parts[1]
-								}
-							}
-
-							// 上传原始压缩包的逻辑（使用封装的重试函数）
-							ossStartTime := time.Now()
-							originalOssURL, err = uploadWithRetry(att.Filename, att.Base64Data, fileType, emailOne.EmailID, "附件处理")
-							ossDuration := time.Since(ossStartTime)
-							attachmentOSSTime += ossDuration
-							if err != nil {
-								log.Printf("[附件处理] 原始压缩包上传失败，邮件ID: %d, 文件名: %s, 错误: %v", emailOne.EmailID, att.Filename, err)
-							}
-						} else {
-							// 压缩包处理成功，也上传原始压缩包作为备份
-							log.Printf("[附件处理] 上传原始压缩包文件作为备份，邮件ID: %d, 文件名: %s",
-								emailOne.EmailID, att.Filename)
-
-							fileType := ""
-							if att.MimeType != "" {
-								parts := strings.Split(att.MimeType, "/")
-								if len(parts) > 1 {
-									fileType = parts[1]
-								}
-							}
-
-							ossStartTime := time.Now()
-							originalOssURL, err = uploadWithRetry(att.Filename, att.Base64Data, fileType, emailOne.EmailID, "附件处理")
-							ossDuration := time.Since(ossStartTime)
-							attachmentOSSTime += ossDuration
-							if err != nil {
-								log.Printf("[附件处理] 原始压缩包上传失败，邮件ID: %d, 文件名: %s, 错误: %v", emailOne.EmailID, att.Filename, err)
-							}
-						}
-
-						// 创建原始压缩包的附件记录
-						if originalOssURL != "" {
-							originalAttachment := &model.PrimeEmailContentAttachment{
-								EmailID:   emailOne.EmailID,
-								AccountId: account.ID,
-								FileName:  utils.SanitizeUTF8(att.Filename),
-								SizeKb:    att.SizeKB,
-								MimeType:  utils.SanitizeUTF8(att.MimeType),
-								OssUrl:    utils.SanitizeUTF8(originalOssURL),
-								CreatedAt: utils.JsonTime{Time: time.Now()},
-							}
-							attachments = append(attachments, originalAttachment)
-						}
-					} else {
-						// 处理普通附件文件（保持原有逻辑）
-						fileType := ""
-						if att.MimeType != "" {
-							parts := strings.Split(att.MimeType, "/")
-							if len(parts) > 1 {
-								fileType = parts[1]
-							}
-						}
-
-						// 使用封装的重试上传函数
-						ossStartTime := time.Now()
-						ossURL, err := uploadWithRetry(att.Filename, att.Base64Data, fileType, emailOne.EmailID, "附件处理")
-						ossDuration := time.Since(ossStartTime)
-						attachmentOSSTime += ossDuration
-						if err != nil {
-							log.Printf("[附件处理] 普通附件上传最终失败，邮件ID: %d, 文件名: %s, 错误: %v", emailOne.EmailID, att.Filename, err)
-						}
-
-						// 创建普通附件记录
-						attachment := &model.PrimeEmailContentAttachment{
-							EmailID:   emailOne.EmailID,
-							AccountId: account.ID,
-							FileName:  utils.SanitizeUTF8(att.Filename),
-							SizeKb:    att.SizeKB,
-							MimeType:  utils.SanitizeUTF8(att.MimeType),
-							OssUrl:    utils.SanitizeUTF8(ossURL),
-							CreatedAt: utils.JsonTime{Time: time.Now()},
-						}
-						attachments = append(attachments, attachment)
-					}
-				} else {
-					log.Printf("[附件处理] 附件没有Base64数据，跳过创建附件记录，邮件ID: %d, 文件名: %s", emailOne.EmailID, att.Filename)
-				}
-			}
-		} else {
-			log.Printf("[邮件内容同步] 邮件没有附件，邮件ID: %d", emailOne.EmailID)
-		}
-
+		emailData, attachmentOSSTime, emailAttachments := buildEmailContentData(account, emailOne, folder, email)
+		attachmentCount += emailAttachments
 		totalOSSTime += attachmentOSSTime
 
 		// 添加到批量处理列表
-		allEmailData = append(allEmailData, EmailContentData{
-			EmailID:      emailOne.EmailID,
-			AccountId:    account.ID,
-			EmailContent: emailContent,
-			Attachments:  attachments,
-		})
+		allEmailData = append(allEmailData, emailData)
 
 		successCount++
 		totalEmailTime := emailDuration + attachmentOSSTime
@@ -522,18 +444,331 @@ func syncAccountEmailContent(mailClient *mailclient.MailClient, account model.Pr
 	return successCount, nil
 }
 
-// resetEmailStatus 重置邮件状态
-func resetEmailStatus(emailID int, status int) error {
-	result := db.DB().Model(&model.PrimeEmail{}).Where("email_id = ?", emailID).Update("status", status)
+// buildEmailContentData 根据获取到的邮件创建内容记录，并上传附件到OSS
+// 返回附件上传耗时和附件数量，用于性能统计
+func buildEmailContentData(account model.PrimeEmailAccount, emailOne model.PrimeEmail, folder string, email *mailclient.Email) (EmailContentData, time.Duration, int) {
+	var err error
+	var attachmentCount int
+
+	// 创建邮件内容记录
+	emailContent := &model.PrimeEmailContent{
+		EmailID:       emailOne.EmailID,
+		AccountId:     account.ID,
+		Folder:        folder,
+		UidValidity:   emailOne.UidValidity,
+		Subject:       utils.SanitizeUTF8(email.Subject),
+		FromEmail:     utils.SanitizeUTF8(email.From),
+		ToEmail:       utils.SanitizeUTF8(email.To),
+		Date:          utils.SanitizeUTF8(email.Date),
+		SentAt:        utils.NewUTCTime(email.SentAt),
+		ReceivedAt:    utils.NewUTCTime(email.ReceivedAt),
+		Content:       utils.SanitizeUTF8(email.Body),
+		HTMLContent:   utils.SanitizeUTF8(email.BodyHTML),
+		Type:          email.Category,
+		Status:        -1,
+		ParserVersion: mailclient.ParserVersion,
+		CreatedAt:     utils.JsonTime{Time: time.Now()},
+	}
+
+	verifySenderDKIM(email)
+	fillContentHeaders(emailContent, email)
+	emailContent.ThreadSubject = threadSubject(emailContent.Subject)
+
+	// 内嵌图片与普通附件分开处理，是否有附件只取决于普通附件
+	inlineAttachments, regularAttachments := splitInlineAttachments(email.Attachments)
+
+	// 查询对应的PrimeEmail记录，以获取HasAttachment值
+	var primeEmail model.PrimeEmail
+	if err := db.DB().Where("id = ?", emailOne.ID).First(&primeEmail).Error; err != nil {
+		log.Printf("[邮件内容同步] 查询PrimeEmail记录失败，使用默认附件状态: %v", err)
+		// 如果查询失败，则使用默认的附件检测逻辑
+		if len(regularAttachments) > 0 {
+			emailContent.HasAttachment = 1
+		} else {
+			emailContent.HasAttachment = 0
+		}
+	} else {
+		// 使用PrimeEmail表中的HasAttachment值
+		emailContent.HasAttachment = primeEmail.HasAttachment
+		log.Printf("[邮件内容同步] 使用PrimeEmail记录的附件状态，邮件ID: %d, HasAttachment: %d",
+			emailOne.EmailID, primeEmail.HasAttachment)
+	}
+
+	// 处理附件 - 仅在PrimeEmail表示有附件时处理
+	var attachments []*model.PrimeEmailContentAttachment
+	var attachmentOSSTime time.Duration
+
+	// 内嵌图片不受HasAttachment影响（服务器通常不把内嵌图片算作附件），上传后替换HTML中的 cid: 引用
+	var inlineURLs map[string]string
+	if len(inlineAttachments) > 0 {
+		log.Printf("[邮件内容同步] 邮件含有 %d 个内嵌图片，邮件ID: %d", len(inlineAttachments), emailOne.EmailID)
+		attachmentCount += len(inlineAttachments)
+
+		var inlineRecords []*model.PrimeEmailContentAttachment
+		var inlineOSSTime time.Duration
+		inlineRecords, inlineURLs, inlineOSSTime = processInlineAttachments(account, emailOne, folder, inlineAttachments)
+		attachments = append(attachments, inlineRecords...)
+		attachmentOSSTime += inlineOSSTime
+		emailContent.HTMLContent = utils.SanitizeUTF8(mailclient.RewriteCIDReferences(email.BodyHTML, inlineURLs))
+	}
+	emailContent.HTMLSanitized = sanitizedHTML(emailContent.HTMLContent, inlineURLs)
+	fillBodySegments(emailContent)
+
+	// 如果PrimeEmail表示没有附件，则跳过附件处理，不需要再检查实际邮件
+	if emailContent.HasAttachment == 0 {
+		log.Printf("[邮件内容同步] 根据PrimeEmail记录判断邮件无附件，跳过附件处理，邮件ID: %d", emailOne.EmailID)
+	} else if len(regularAttachments) > 0 {
+		log.Printf("[邮件内容同步] 邮件含有 %d 个附件，邮件ID: %d", len(regularAttachments), emailOne.EmailID)
+
+		attachmentCount += len(regularAttachments)
+
+		for i, att := range regularAttachments {
+			log.Printf("[附件处理] 开始处理附件 %d/%d，邮件ID: %d, 文件名: %s",
+				i+1, len(regularAttachments), emailOne.EmailID, att.Filename)
+
+			if att.Base64Data != "" {
+				// 检查是否为压缩包文件
+				if isArchiveFile(att.Filename) || isTNEFAttachment(att) {
+					// 处理压缩包文件（包括Outlook的winmail.dat）
+					log.Printf("[附件处理] 检测到压缩包文件，开始解压处理，邮件ID: %d, 文件名: %s", emailOne.EmailID, att.Filename)
+					archiveStartTime := time.Now()
+
+					processedAttachments, archiveErr := processArchiveAttachment(att, int64(emailOne.EmailID), uint(account.ID))
+					archiveDuration := time.Since(archiveStartTime)
+					attachmentOSSTime += archiveDuration
+
+					if archiveErr != nil {
+						log.Printf("[附件处理] 压缩包处理失败，邮件ID: %d, 文件名: %s, 错误: %v",
+							emailOne.EmailID, att.Filename, archiveErr)
+					} else if len(processedAttachments) > 0 {
+						// 压缩包处理成功，为每个解压出来的文件创建附件记录
+						log.Printf("[附件处理] 压缩包处理成功，共上传 %d 个文件，总耗时: %v，邮件ID: %d, 文件名: %s",
+							len(processedAttachments), archiveDuration, emailOne.EmailID, att.Filename)
+
+						for _, processedAtt := range processedAttachments {
+							attachment := &model.PrimeEmailContentAttachment{
+								EmailID:     emailOne.EmailID,
+								AccountId:   account.ID,
+								Folder:      folder,
+								UidValidity: emailOne.UidValidity,
+								FileName:    utils.SanitizeUTF8(processedAtt.FileName),
+								SizeKb:      processedAtt.SizeKB,
+								MimeType:    utils.SanitizeUTF8(processedAtt.MimeType),
+								OssUrl:      utils.SanitizeUTF8(processedAtt.OssURL),
+								CreatedAt:   utils.JsonTime{Time: time.Now()},
+							}
+							attachments = append(attachments, attachment)
+						}
+					} else {
+						log.Printf("[附件处理] 压缩包处理完成但没有成功上传任何文件，邮件ID: %d, 文件名: %s",
+							emailOne.EmailID, att.Filename)
+					}
+
+					// 无论压缩包处理是否成功，都为原始压缩包文件创建一个附件记录
+					originalOssURL := ""
+					if archiveErr != nil || len(processedAttachments) == 0 {
+						// 如果压缩包处理失败或没有成功上传任何文件，尝试上传原始压缩包
+						log.Printf("[附件处理] 上传原始压缩包文件，邮件ID: %d, 文件名: %s",
+							emailOne.EmailID, att.Filename)
+
+						fileType := ""
+						if att.MimeType != "" {
+							parts := strings.Split(att.MimeType, "/")
+							if len(parts) > 1 {
+								fileType = parts[1]
+							}
+						}
+
+						// 上传原始压缩包的逻辑（使用封装的重试函数）
+						ossStartTime := time.Now()
+						originalOssURL, err = uploadWithRetry(att.Filename, att.Base64Data, fileType, emailOne.EmailID, "附件处理")
+						ossDuration := time.Since(ossStartTime)
+						attachmentOSSTime += ossDuration
+						if err != nil {
+							log.Printf("[附件处理] 原始压缩包上传失败，邮件ID: %d, 文件名: %s, 错误: %v", emailOne.EmailID, att.Filename, err)
+						}
+					} else {
+						// 压缩包处理成功，也上传原始压缩包作为备份
+						log.Printf("[附件处理] 上传原始压缩包文件作为备份，邮件ID: %d, 文件名: %s",
+							emailOne.EmailID, att.Filename)
+
+						fileType := ""
+						if att.MimeType != "" {
+							parts := strings.Split(att.MimeType, "/")
+							if len(parts) > 1 {
+								fileType = parts[1]
+							}
+						}
+
+						ossStartTime := time.Now()
+						originalOssURL, err = uploadWithRetry(att.Filename, att.Base64Data, fileType, emailOne.EmailID, "附件处理")
+						ossDuration := time.Since(ossStartTime)
+						attachmentOSSTime += ossDuration
+						if err != nil {
+							log.Printf("[附件处理] 原始压缩包上传失败，邮件ID: %d, 文件名: %s, 错误: %v", emailOne.EmailID, att.Filename, err)
+						}
+					}
+
+					// 创建原始压缩包的附件记录
+					if originalOssURL != "" {
+						originalAttachment := &model.PrimeEmailContentAttachment{
+							EmailID:     emailOne.EmailID,
+							AccountId:   account.ID,
+							Folder:      folder,
+							UidValidity: emailOne.UidValidity,
+							FileName:    utils.SanitizeUTF8(att.Filename),
+							SizeKb:      att.SizeKB,
+							MimeType:    utils.SanitizeUTF8(att.MimeType),
+							OssUrl:      utils.SanitizeUTF8(originalOssURL),
+							CreatedAt:   utils.JsonTime{Time: time.Now()},
+						}
+						attachments = append(attachments, originalAttachment)
+					}
+				} else {
+					// 处理普通附件文件（保持原有逻辑）
+					fileType := ""
+					if att.MimeType != "" {
+						parts := strings.Split(att.MimeType, "/")
+						if len(parts) > 1 {
+							fileType = parts[1]
+						}
+					}
+
+					// 使用封装的重试上传函数
+					ossStartTime := time.Now()
+					ossURL, err := uploadWithRetry(att.Filename, att.Base64Data, fileType, emailOne.EmailID, "附件处理")
+					ossDuration := time.Since(ossStartTime)
+					attachmentOSSTime += ossDuration
+					if err != nil {
+						log.Printf("[附件处理] 普通附件上传最终失败，邮件ID: %d, 文件名: %s, 错误: %v", emailOne.EmailID, att.Filename, err)
+					}
+
+					// 创建普通附件记录
+					attachment := &model.PrimeEmailContentAttachment{
+						EmailID:     emailOne.EmailID,
+						AccountId:   account.ID,
+						Folder:      folder,
+						UidValidity: emailOne.UidValidity,
+						FileName:    utils.SanitizeUTF8(att.Filename),
+						SizeKb:      att.SizeKB,
+						MimeType:    utils.SanitizeUTF8(att.MimeType),
+						OssUrl:      utils.SanitizeUTF8(ossURL),
+						CreatedAt:   utils.JsonTime{Time: time.Now()},
+					}
+					attachments = append(attachments, attachment)
+				}
+			} else {
+				log.Printf("[附件处理] 附件没有Base64数据，跳过创建附件记录，邮件ID: %d, 文件名: %s", emailOne.EmailID, att.Filename)
+			}
+		}
+	} else {
+		log.Printf("[邮件内容同步] 邮件没有附件，邮件ID: %d", emailOne.EmailID)
+	}
+
+	// 归档原始邮件，失败时只记录日志，不影响内容保存
+	if len(email.Raw) > 0 && rawArchiveEnabled() {
+		archiveStartTime := time.Now()
+		objectKey, sha, archiveErr := archiveRawEmail(account.ID, emailOne.EmailID, email.Raw)
+		attachmentOSSTime += time.Since(archiveStartTime)
+		if archiveErr != nil {
+			log.Printf("[原始邮件归档] 归档失败，邮件ID: %d, 错误: %v", emailOne.EmailID, archiveErr)
+		} else {
+			emailContent.RawObjectKey = utils.SanitizeUTF8(objectKey)
+		}
+		emailContent.RawSha256 = sha
+	}
+
+	return EmailContentData{
+		PrimeEmailID: emailOne.ID,
+		EmailID:      emailOne.EmailID,
+		AccountId:    account.ID,
+		EmailContent: emailContent,
+		Attachments:  attachments,
+		Participants: buildParticipants(account.ID, emailOne, folder, email),
+		Bounces:      buildBounces(account.ID, emailOne, folder, email),
+	}, attachmentOSSTime, attachmentCount
+}
+
+// fillContentHeaders 把抄送、回复地址、会话相关邮件头、全部邮件头、正文字符集和发件人认证结果写入邮件内容记录
+func fillContentHeaders(content *model.PrimeEmailContent, email *mailclient.Email) {
+	content.CcEmail = utils.SanitizeUTF8(email.Cc)
+	content.BccEmail = utils.SanitizeUTF8(email.Bcc)
+	// 按列长度截断，严格模式下一个超长的Message-ID会导致整批邮件内容保存失败
+	content.ReplyTo = truncateRunes(utils.SanitizeUTF8(email.ReplyTo), 512)
+	content.MessageID = truncateRunes(utils.SanitizeUTF8(email.MessageID), 255)
+	content.InReplyTo = truncateRunes(utils.SanitizeUTF8(email.InReplyTo), 255)
+	content.References = utils.SanitizeUTF8(strings.Join(email.References, " "))
+	content.Charset = truncateRunes(utils.SanitizeUTF8(email.Charset.String()), 128)
+	content.RawHeaders = ""
+	if len(email.Headers) > 0 {
+		if data, err := json.Marshal(email.Headers); err == nil {
+			content.RawHeaders = utils.SanitizeUTF8(string(data))
+		} else {
+			log.Printf("[邮件内容同步] 序列化邮件头失败，邮件ID: %s, 错误: %v", email.EmailID, err)
+		}
+	}
+	fillSenderAuth(content, email)
+}
+
+// contentFailureStatus 根据获取邮件内容的错误决定邮件的新状态
+func contentFailureStatus(err error, emailID int) int {
+	// 根据错误类型决定状态：
+	// - 网络/连接错误 → -1（重新处理）
+	// - 邮件已删除 → -3（已删除）
+	// - 其他错误 → -2（永久失败）
+	var newStatus int
+	errStr := strings.ToLower(err.Error())
+
+	// 检查是否是邮件已删除或UID无效的错误
+	if strings.Contains(errStr, "邮件不存在") ||
+		strings.Contains(errStr, "邮件uid无效") ||
+		strings.Contains(errStr, "bad sequence") {
+		newStatus = -3 // 已删除
+		log.Printf("[邮件内容同步] 检测到邮件已删除或UID无效，标记为已删除状态: 邮件ID=%d", emailID)
+	} else if strings.Contains(errStr, "timeout") ||
+		strings.Contains(errStr, "connection") ||
+		strings.Contains(errStr, "network") ||
+		strings.Contains(errStr, "read tcp") ||
+		strings.Contains(errStr, "write tcp") ||
+		strings.Contains(errStr, "broken pipe") ||
+		strings.Contains(errStr, "connection reset") ||
+		strings.Contains(errStr, "i/o timeout") ||
+		strings.Contains(errStr, "operation timed out") ||
+		strings.Contains(errStr, "context deadline exceeded") ||
+		strings.Contains(errStr, "context canceled") ||
+		strings.Contains(errStr, "error reading response") ||
+		strings.Contains(errStr, "server error") ||
+		strings.Contains(errStr, "temporary failure") ||
+		strings.Contains(errStr, "service unavailable") ||
+		strings.Contains(errStr, "server busy") ||
+		strings.Contains(errStr, "please try again later") ||
+		strings.Contains(errStr, "连接状态异常") ||
+		strings.Contains(errStr, "需要重新建立连接") {
+		newStatus = -1 // 重新处理
+		log.Printf("[邮件内容同步] 检测到临时错误，设置状态为-1（重新处理），邮件ID: %d", emailID)
+	} else {
+		newStatus = -2 // 永久失败
+		log.Printf("[邮件内容同步] 检测到永久错误，设置状态为-2（永久失败），邮件ID: %d", emailID)
+	}
+
+	return newStatus
+}
+
+// resetEmailStatus 根据PrimeEmail主键重置邮件状态
+func resetEmailStatus(id uint, status int) error {
+	result := db.DB().Model(&model.PrimeEmail{}).Where("id = ?", id).Update("status", status)
 	return result.Error
 }
 
 // EmailContentData 邮件内容数据结构
 type EmailContentData struct {
+	PrimeEmailID uint // PrimeEmail主键，用于更新状态（email_id只在同一文件夹内唯一）
 	EmailID      int
 	AccountId    int
 	EmailContent *model.PrimeEmailContent
 	Attachments  []*model.PrimeEmailContentAttachment
+	Participants []*model.PrimeEmailParticipant
+	Bounces      []*model.PrimeEmailBounce // 退信/投递状态通知的每个收件人，不是退信时为空
 }
 
 // batchSaveEmailContents 批量保存邮件内容和附件
@@ -557,6 +792,7 @@ func batchSaveEmailContents(emailDataList []EmailContentData) error {
 
 	successCount := 0
 	failedCount := 0
+	var savedContents []*model.PrimeEmailContent
 
 	for _, emailData := range emailDataList {
 		// 保存邮件内容
@@ -575,14 +811,27 @@ func batchSaveEmailContents(emailDataList []EmailContentData) error {
 			}
 		}
 
+		// 保存参与人，失败同样不影响邮件内容的保存
+		if err := model.CreateParticipantsWithTx(tx, emailData.EmailContent.ID, emailData.Participants); err != nil {
+			log.Printf("[批量保存邮件内容] 保存参与人失败: EmailID=%d, 错误=%v", emailData.EmailID, err)
+		}
+		// 保存退信记录并关联发出的邮件
+		if err := model.CreateBouncesWithTx(tx, emailData.EmailContent.ID, emailData.Bounces); err != nil {
+			log.Printf("[批量保存邮件内容] 保存退信记录失败: EmailID=%d, 错误=%v", emailData.EmailID, err)
+		} else if len(emailData.Bounces) > 0 {
+			log.Printf("[批量保存邮件内容] 识别到退信: EmailID=%d, 收件人数=%d, 原邮件Message-ID=%s",
+				emailData.EmailID, len(emailData.Bounces), emailData.Bounces[0].OriginalMessageID)
+		}
+
 		// 更新邮件状态：-1（待处理）→ 1（已处理）
-		if err := tx.Model(&model.PrimeEmail{}).Where("email_id = ?", emailData.EmailID).Update("status", 1).Error; err != nil {
+		if err := tx.Model(&model.PrimeEmail{}).Where("id = ?", emailData.PrimeEmailID).Update("status", 1).Error; err != nil {
 			log.Printf("[批量保存邮件内容] 更新邮件状态失败: EmailID=%d, status: -1 → 1, 错误=%v", emailData.EmailID, err)
 		} else {
 			log.Printf("[批量保存邮件内容] 邮件状态更新成功: EmailID=%d, status: -1 → 1", emailData.EmailID)
 		}
 
 		successCount++
+		savedContents = append(savedContents, emailData.EmailContent)
 	}
 
 	// 提交事务
@@ -592,6 +841,9 @@ func batchSaveEmailContents(emailDataList []EmailContentData) error {
 	}
 
 	log.Printf("[批量保存邮件内容] 批量保存完成: 成功=%d, 失败=%d", successCount, failedCount)
+
+	// 增量计算新邮件所属的会话
+	assignThreads(savedContents)
 	return nil
 }
 
@@ -615,6 +867,11 @@ func isArchiveFile(filename string) bool {
 	return ext == ".zip" || ext == ".rar"
 }
 
+// isTNEFAttachment 判断附件是否为Outlook的TNEF附件（winmail.dat），其中的文件与压缩包一样解出后单独保存
+func isTNEFAttachment(att mailclient.AttachmentInfo) bool {
+	return mailclient.IsTNEF(att.MimeType, att.Filename, nil)
+}
+
 // isSystemFile 判断是否为系统文件（需要跳过的文件）
 func isSystemFile(filename string) bool {
 	filename = strings.ToLower(filename)
@@ -755,6 +1012,37 @@ func extractRarFiles(base64Data string) ([]ExtractedFile, error) {
 	return extractedFiles, nil
 }
 
+// extractTNEFFiles 解析TNEF（winmail.dat）并返回其中的所有文件
+func extractTNEFFiles(base64Data string) ([]ExtractedFile, error) {
+	// 解码Base64数据
+	tnefData, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		return nil, fmt.Errorf("解码Base64数据失败: %v", err)
+	}
+
+	tnefAttachments, err := mailclient.DecodeTNEF(tnefData)
+	if err != nil && len(tnefAttachments) == 0 {
+		return nil, fmt.Errorf("解析TNEF失败: %v", err)
+	}
+	if err != nil {
+		log.Printf("TNEF数据不完整，保留已解出的 %d 个文件: %v", len(tnefAttachments), err)
+	}
+
+	var extractedFiles []ExtractedFile
+	for i, att := range tnefAttachments {
+		name := att.Filename
+		if name == "" {
+			name = fmt.Sprintf("attachment_%d.bin", i+1)
+		}
+		extractedFiles = append(extractedFiles, ExtractedFile{
+			Name: name,
+			Data: att.Data,
+		})
+	}
+
+	return extractedFiles, nil
+}
+
 // processArchiveAttachment 处理压缩包附件，解压并上传所有文件
 // ProcessedAttachment 表示处理后的附件信息
 type ProcessedAttachment struct {
@@ -768,13 +1056,16 @@ func processArchiveAttachment(attachment mailclient.AttachmentInfo, emailID int6
 	var extractedFiles []ExtractedFile
 	var err error
 
-	// 根据文件扩展名选择解压方法
+	// 根据文件扩展名选择解压方法，TNEF按媒体类型识别
 	ext := strings.ToLower(filepath.Ext(attachment.Filename))
-	switch ext {
-	case ".zip":
+	switch {
+	case isTNEFAttachment(attachment):
+		log.Printf("[压缩包处理] 开始解析TNEF文件，邮件ID: %d, 文件名: %s", emailID, attachment.Filename)
+		extractedFiles, err = extractTNEFFiles(attachment.Base64Data)
+	case ext == ".zip":
 		log.Printf("[压缩包处理] 开始解压ZIP文件，邮件ID: %d, 文件名: %s", emailID, attachment.Filename)
 		extractedFiles, err = extractZipFiles(attachment.Base64Data)
-	case ".rar":
+	case ext == ".rar":
 		log.Printf("[压缩包处理] 开始解压RAR文件，邮件ID: %d, 文件名: %s", emailID, attachment.Filename)
 		extractedFiles, err = extractRarFiles(attachment.Base64Data)
 	default:
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"go_email/model"
+	"go_email/pkg/mailclient"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFillContentHeadersTruncatesIndexedColumns(t *testing.T) {
+	longID := strings.Repeat("a", 300) + "@example.com"
+	email := &mailclient.Email{
+		MessageID: longID,
+		InReplyTo: longID,
+		ReplyTo:   strings.Repeat("回复", 300) + " <reply@example.com>",
+	}
+	var content model.PrimeEmailContent
+	fillContentHeaders(&content, email)
+
+	if n := utf8.RuneCountInString(content.MessageID); n != 255 {
+		t.Errorf("Message-ID应截断为255个字符，实际 %d", n)
+	}
+	if n := utf8.RuneCountInString(content.InReplyTo); n != 255 {
+		t.Errorf("In-Reply-To应截断为255个字符，实际 %d", n)
+	}
+	if n := utf8.RuneCountInString(content.ReplyTo); n != 512 {
+		t.Errorf("回复地址应截断为512个字符，实际 %d", n)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"go_email/api"
 	"go_email/config"
+	"go_email/model"
 	"io"
 	stdlog "log"
 	"os"
@@ -72,6 +73,24 @@ func main() {
 	// 初始化标准库日志，确保在设置gin之前初始化
 	initStdLog()
 
+	// 按配置自动迁移表结构（新增字段/新表）
+	if viper.GetBool("db.auto_migrate") {
+		if err := model.AutoMigrate(); err != nil {
+			panic(err)
+		}
+	}
+
+	// 上次运行时中断的历史补录和重新解析任务标记为失败
+	api.ResetInterruptedBackfillJobs()
+	api.ResetInterruptedReparseJobs()
+
+	// 按配置为启用IDLE的账号开启新邮件监听
+	if viper.GetBool("idle.auto_start") {
+		if _, err := api.StartIdleWatchers(viper.GetInt("idle.node")); err != nil {
+			stdlog.Printf("开启IDLE监听失败: %v", err)
+		}
+	}
+
 	// Set gin mode.
 	gin.SetMode(viper.GetString("run_mode"))
 
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_email/db"
+	"go_email/pkg/utils"
+	"log"
+	"time"
+)
+
+// migrateModels 需要自动迁移的表结构，新增表或字段时在此登记
+var migrateModels = []interface{}{
+	&PrimeEmailProvider{},
+	&PrimeEmailAccount{},
+	&PrimeEmail{},
+	&PrimeEmailContent{},
+	&PrimeEmailContentAttachment{},
+	&PrimeEmailFolder{},
+	&PrimeEmailBackfill{},
+	&PrimeEmailReparse{},
+	&PrimeEmailReparseDiff{},
+	&PrimeEmailParticipant{},
+	&PrimeEmailOutbound{},
+	&PrimeEmailBounce{},
+}
+
+// AutoMigrate 自动创建/补齐表结构（只增加表和字段，不删除已有字段）
+func AutoMigrate() error {
+	for _, m := range migrateModels {
+		if err := db.DB().AutoMigrate(m); err != nil {
+			return fmt.Errorf("自动迁移表结构失败(%T): %w", m, err)
+		}
+	}
+	log.Printf("[数据库迁移] 表结构迁移完成，共 %d 张表", len(migrateModels))
+	return backfillMailDates()
+}
+
+// mailDateBackfillBatch 回填时间字段时每批读取的行数
+const mailDateBackfillBatch = 1000
+
+// mailDateRow 回填时间字段时读取的列
+type mailDateRow struct {
+	ID         uint
+	Date       string
+	RawHeaders string
+	ReceivedAt utils.UTCTime
+}
+
+// backfillMailDates 从date字符串字段回填sent_at，邮件内容另从保存的Received邮件头回填received_at
+// 只处理sent_at为空的行，可以重复执行；日期无法解析的行保持为空
+func backfillMailDates() error {
+	for _, table := range []struct {
+		model      interface{}
+		rawHeaders bool
+	}{
+		{&PrimeEmail{}, false},
+		{&PrimeEmailContent{}, true},
+	} {
+		columns := []string{"id", "date", "received_at"}
+		if table.rawHeaders {
+			columns = append(columns, "raw_headers")
+		}
+
+		var lastID uint
+		updated := 0
+		for {
+			var rows []mailDateRow
+			err := db.DB().Model(table.model).Select(columns).
+				Where("id > ? AND sent_at IS NULL AND date <> ''", lastID).
+				Order("id").Limit(mailDateBackfillBatch).
+				Find(&rows).Error
+			if err != nil {
+				return fmt.Errorf("读取待回填时间的记录失败(%T): %w", table.model, err)
+			}
+			if len(rows) == 0 {
+				break
+			}
+			lastID = rows[len(rows)-1].ID
+
+			for _, row := range rows {
+				updates := map[string]interface{}{}
+				if sentAt, ok := utils.ParseMailDate(row.Date); ok {
+					updates["sent_at"] = utils.NewUTCTime(sentAt)
+				}
+				if receivedAt, ok := receivedAtFromHeaders(row.RawHeaders); ok && row.ReceivedAt.IsZero() {
+					updates["received_at"] = utils.NewUTCTime(receivedAt)
+				}
+				if len(updates) == 0 {
+					continue
+				}
+				if err := db.DB().Model(table.model).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+					return fmt.Errorf("回填时间字段失败(%T, ID=%d): %w", table.model, row.ID, err)
+				}
+				updated++
+			}
+		}
+		if updated > 0 {
+			log.Printf("[数据库迁移] %T 回填发送/接收时间 %d 条", table.model, updated)
+		}
+	}
+	return nil
+}
+
+// receivedAtFromHeaders 从保存的邮件头JSON中取最近一跳Received邮件头的投递时间
+func receivedAtFromHeaders(rawHeaders string) (time.Time, bool) {
+	if rawHeaders == "" {
+		return time.Time{}, false
+	}
+	var headers map[string][]string
+	if err := json.Unmarshal([]byte(rawHeaders), &headers); err != nil {
+		return time.Time{}, false
+	}
+	for _, received := range headers["Received"] {
+		if t, ok := utils.ParseReceivedDate(received); ok {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
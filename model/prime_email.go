@@ -5,6 +5,7 @@ import (
 	"go_email/db"
 	"go_email/pkg/utils"
 	"log"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -14,15 +15,42 @@ type PrimeEmail struct {
 	ID            uint           `gorm:"primarykey;column:id" json:"id"`
 	EmailID       int            `gorm:"column:email_id" json:"email_id"`
 	AccountId     int            `gorm:"column:account_id" json:"account_id"`
-	FromEmail     string         `gorm:"column:from_email;size:255" json:"from_email"` // 发送者
-	Subject       string         `gorm:"column:subject;size:255" json:"subject"`       // 主题
-	Date          string         `gorm:"column:date;size:255" json:"date"`             // 邮件日期
-	HasAttachment int            `gorm:"column:has_attachment" json:"has_attachment"`  // 附件 0:没有 1:有
+	Folder        string         `gorm:"column:folder;size:255;default:INBOX" json:"folder"`        // 所在文件夹
+	UidValidity   uint32         `gorm:"column:uid_validity;default:0" json:"uid_validity"`         // 记录email_id时文件夹的UIDVALIDITY
+	MessageID     string         `gorm:"column:message_id;size:255;index" json:"message_id"`        // Message-ID（不含尖括号）
+	FromEmail     string         `gorm:"column:from_email;size:255" json:"from_email"`              // 发送者
+	Subject       string         `gorm:"column:subject;size:255" json:"subject"`                    // 主题
+	Date          string         `gorm:"column:date;size:255" json:"date"`                          // 邮件日期
+	SentAt        utils.UTCTime  `gorm:"column:sent_at;type:datetime;index" json:"sent_at"`         // Date邮件头解析出的发送时间（UTC）
+	ReceivedAt    utils.UTCTime  `gorm:"column:received_at;type:datetime;index" json:"received_at"` // 服务器INTERNALDATE（UTC）
+	HasAttachment int            `gorm:"column:has_attachment" json:"has_attachment"`               // 附件 0:没有 1:有
 	Status        int            `gorm:"column:status" json:"status"`
+	IsSeen        int            `gorm:"column:is_seen;default:0" json:"is_seen"`         // 服务器\Seen标记 0:未读 1:已读
+	IsFlagged     int            `gorm:"column:is_flagged;default:0" json:"is_flagged"`   // 服务器\Flagged标记
+	IsDeleted     int            `gorm:"column:is_deleted;default:0" json:"is_deleted"`   // 服务器\Deleted标记（待EXPUNGE）
+	IsExpunged    int            `gorm:"column:is_expunged;default:0" json:"is_expunged"` // 邮件已从服务器文件夹中删除或移走
+	FlagsSyncedAt *time.Time     `gorm:"column:flags_synced_at" json:"flags_synced_at"`   // 最后一次标记对账时间
 	CreatedAt     utils.JsonTime `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt     utils.JsonTime `gorm:"column:updated_at" json:"updated_at"`
 }
 
+// DefaultFolder 未指定文件夹时使用的默认文件夹
+const DefaultFolder = "INBOX"
+
+// EmailStatusDeleted 邮件已从服务器删除，不再获取内容
+const EmailStatusDeleted = -3
+
+// EmailStatusUIDInvalid 文件夹UIDVALIDITY变化后旧UID已失效，等待按Message-ID重新匹配
+const EmailStatusUIDInvalid = -4
+
+// GetFolder 返回邮件所在文件夹，历史数据为空时视为INBOX
+func (e PrimeEmail) GetFolder() string {
+	if e.Folder == "" {
+		return DefaultFolder
+	}
+	return e.Folder
+}
+
 // 清理邮件字段中的非法UTF-8字符
 func sanitizeEmailFields(email *PrimeEmail) {
 	// 确保所有文本字段都是有效的UTF-8
@@ -112,10 +140,10 @@ func GetLatestEmail() (PrimeEmail, error) {
 	return email, err
 }
 
-// GetLatestEmailWithTx 使用事务获取最新的邮件记录
-func GetLatestEmailWithTx(tx *gorm.DB, accountId int) (PrimeEmail, error) {
+// GetLatestEmailWithTx 使用事务获取指定账号指定文件夹中UID最大的邮件记录
+func GetLatestEmailWithTx(tx *gorm.DB, accountId int, folder string) (PrimeEmail, error) {
 	var email PrimeEmail
-	err := tx.Where("account_id=?", accountId).Order("email_id desc").First(&email).Error
+	err := tx.Where("account_id = ? AND folder = ?", accountId, folder).Order("email_id desc").First(&email).Error
 	return email, err
 }
 
@@ -130,10 +158,10 @@ func BatchCreateEmailsWithTx(emails []*PrimeEmail, tx *gorm.DB) error {
 	var failedEmails []string
 
 	for _, email := range emails {
-		// 先检查是否已存在相同的email_id和account_id记录
+		// 先检查是否已存在相同的email_id、account_id和folder记录
 		var count int64
 		if err := tx.Model(&PrimeEmail{}).
-			Where("email_id = ? AND account_id = ?", email.EmailID, email.AccountId).
+			Where("email_id = ? AND account_id = ? AND folder = ?", email.EmailID, email.AccountId, email.GetFolder()).
 			Count(&count).Error; err != nil {
 			log.Printf("[邮件批量插入] 检查记录是否存在时出错: email_id=%d, account_id=%d, 错误=%v",
 				email.EmailID, email.AccountId, err)
@@ -231,7 +259,7 @@ func GetEmailByStatusAndNode(status, limit, node int) ([]PrimeEmail, error) {
 	log.Printf("[邮件分配] 节点 %d - 总限制: %d, 账户数量: %d, 每账户基础分配: %d, 余数: %d",
 		node, limit, len(accountIds), perAccountLimit, remainder)
 
-	var allEmailIDs []int
+	var allIDs []uint
 
 	// 第三步：对每个AccountId分别查询相应数量的记录
 	for i, accountId := range accountIds {
@@ -263,9 +291,9 @@ func GetEmailByStatusAndNode(status, limit, node int) ([]PrimeEmail, error) {
 		// 将此账户的邮件添加到总结果中
 		emails = append(emails, accountEmails...)
 
-		// 收集email_id用于后续状态更新
+		// 收集主键ID用于后续状态更新（email_id只在同一账号同一文件夹内唯一）
 		for _, email := range accountEmails {
-			allEmailIDs = append(allEmailIDs, email.EmailID)
+			allIDs = append(allIDs, email.ID)
 		}
 	}
 
@@ -278,7 +306,7 @@ func GetEmailByStatusAndNode(status, limit, node int) ([]PrimeEmail, error) {
 
 	// 第四步：更新这些邮件的状态为"处理中"(0)
 	err = tx.Model(&PrimeEmail{}).
-		Where("email_id IN (?)", allEmailIDs).
+		Where("id IN (?)", allIDs).
 		Update("status", 0).Error
 
 	if err != nil {
@@ -295,10 +323,10 @@ func GetEmailByStatusAndNode(status, limit, node int) ([]PrimeEmail, error) {
 	return emails, nil
 }
 
-// ResetEmailStatus 重置邮件状态
-func ResetEmailStatus(emailID int, status int) error {
+// ResetEmailStatus 根据主键ID重置邮件状态
+func ResetEmailStatus(id uint, status int) error {
 	return db.DB().Model(&PrimeEmail{}).
-		Where("email_id = ?", emailID).
+		Where("id = ?", id).
 		Update("status", status).Error
 }
 
@@ -331,10 +359,11 @@ func BatchCreateEmailsWithStats(emails []*PrimeEmail, tx *gorm.DB) (*BatchCreate
 	var existingEmailIDs []int
 
 	for _, email := range emails {
-		// 检查是否已存在相同的email_id和account_id记录
+		// 检查是否已存在相同的email_id、account_id、folder和uid_validity记录（UID只在同一文件夹同一UIDVALIDITY内唯一）
 		var count int64
 		if err := tx.Model(&PrimeEmail{}).
-			Where("email_id = ? AND account_id = ?", email.EmailID, email.AccountId).
+			Where("email_id = ? AND account_id = ? AND folder = ? AND uid_validity = ?",
+				email.EmailID, email.AccountId, email.GetFolder(), email.UidValidity).
 			Count(&count).Error; err != nil {
 			log.Printf("[邮件批量插入] 检查记录是否存在时出错: email_id=%d, account_id=%d, 错误=%v",
 				email.EmailID, email.AccountId, err)
@@ -405,15 +434,15 @@ func GetEmailByStatusAndAccount(status int, accountID int, limit int) ([]PrimeEm
 		return emails, nil
 	}
 
-	// 收集email_id用于状态更新
-	var emailIDs []int
+	// 收集主键ID用于状态更新
+	var ids []uint
 	for _, email := range emails {
-		emailIDs = append(emailIDs, email.EmailID)
+		ids = append(ids, email.ID)
 	}
 
 	// 更新这些邮件的状态为"处理中"(0)
 	err = tx.Model(&PrimeEmail{}).
-		Where("email_id IN (?)", emailIDs).
+		Where("id IN (?)", ids).
 		Update("status", 0).Error
 
 	if err != nil {
@@ -429,3 +458,177 @@ func GetEmailByStatusAndAccount(status int, accountID int, limit int) ([]PrimeEm
 	log.Printf("[邮件分配] 账号ID %d - 成功分配 %d 封邮件", accountID, len(emails))
 	return emails, nil
 }
+
+// BackfillUIDValidityWithTx 首次记录文件夹UIDVALIDITY时，为该文件夹中尚未记录UIDVALIDITY的历史数据补齐
+func BackfillUIDValidityWithTx(tx *gorm.DB, accountID int, folder string, uidValidity uint32) error {
+	for _, m := range []interface{}{&PrimeEmail{}, &PrimeEmailContent{}, &PrimeEmailContentAttachment{}} {
+		result := tx.Model(m).
+			Where("account_id = ? AND folder = ? AND uid_validity = 0", accountID, folder).
+			Update("uid_validity", uidValidity)
+		if result.Error != nil {
+			return fmt.Errorf("补齐uid_validity失败(%T): %w", m, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("[UIDVALIDITY] 补齐历史数据: 账号=%d, 文件夹=%s, 表=%T, 数量=%d, uid_validity=%d",
+				accountID, folder, m, result.RowsAffected, uidValidity)
+		}
+	}
+	return nil
+}
+
+// MarkStaleEmailsWithTx UIDVALIDITY变化后，将旧UIDVALIDITY下尚未获取内容的邮件标记为UID失效
+// 已处理完成的邮件保持原状态，通过uid_validity即可识别其UID已过期
+func MarkStaleEmailsWithTx(tx *gorm.DB, accountID int, folder string, newUIDValidity uint32) (int64, error) {
+	result := tx.Model(&PrimeEmail{}).
+		Where("account_id = ? AND folder = ? AND uid_validity <> ? AND status IN (?)",
+			accountID, folder, newUIDValidity, []int{-1, 0}).
+		Update("status", EmailStatusUIDInvalid)
+	return result.RowsAffected, result.Error
+}
+
+// CountStaleEmailsWithTx 统计文件夹中UIDVALIDITY与当前值不一致的记录数
+func CountStaleEmailsWithTx(tx *gorm.DB, accountID int, folder string, uidValidity uint32) (int64, error) {
+	var count int64
+	err := tx.Model(&PrimeEmail{}).
+		Where("account_id = ? AND folder = ? AND uid_validity <> ?", accountID, folder, uidValidity).
+		Count(&count).Error
+	return count, err
+}
+
+// RekeyStaleEmailsWithTx 按Message-ID将旧UIDVALIDITY下的记录重新关联到新UID，避免重新同步时产生重复记录
+// 返回重新关联的数量以及未匹配上、需要新建的邮件
+func RekeyStaleEmailsWithTx(tx *gorm.DB, emails []*PrimeEmail) (int, []*PrimeEmail, error) {
+	rekeyedCount := 0
+	var remaining []*PrimeEmail
+
+	for _, email := range emails {
+		if email.MessageID == "" {
+			remaining = append(remaining, email)
+			continue
+		}
+
+		var old PrimeEmail
+		err := tx.Where("account_id = ? AND folder = ? AND message_id = ? AND uid_validity <> ?",
+			email.AccountId, email.GetFolder(), email.MessageID, email.UidValidity).
+			Order("id asc").First(&old).Error
+		if db.IsRecordNotFoundError(err) {
+			remaining = append(remaining, email)
+			continue
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("按Message-ID查询旧记录失败: message_id=%s, 错误=%w", email.MessageID, err)
+		}
+
+		updates := map[string]interface{}{
+			"email_id":     email.EmailID,
+			"uid_validity": email.UidValidity,
+		}
+		if old.Status == EmailStatusUIDInvalid {
+			// 之前未获取内容的邮件恢复为待处理
+			updates["status"] = -1
+		}
+		if err := tx.Model(&PrimeEmail{}).Where("id = ?", old.ID).Updates(updates).Error; err != nil {
+			return 0, nil, fmt.Errorf("重新关联邮件失败: id=%d, 错误=%w", old.ID, err)
+		}
+
+		// 同步更新已保存的内容和附件记录
+		for _, m := range []interface{}{&PrimeEmailContent{}, &PrimeEmailContentAttachment{}} {
+			if err := tx.Model(m).
+				Where("account_id = ? AND folder = ? AND uid_validity = ? AND email_id = ?",
+					old.AccountId, old.GetFolder(), old.UidValidity, old.EmailID).
+				Updates(map[string]interface{}{"email_id": email.EmailID, "uid_validity": email.UidValidity}).Error; err != nil {
+				return 0, nil, fmt.Errorf("重新关联邮件内容失败(%T): id=%d, 错误=%w", m, old.ID, err)
+			}
+		}
+
+		log.Printf("[UIDVALIDITY] 按Message-ID重新关联邮件: 账号=%d, 文件夹=%s, message_id=%s, UID %d(%d) → %d(%d)",
+			email.AccountId, email.GetFolder(), email.MessageID, old.EmailID, old.UidValidity, email.EmailID, email.UidValidity)
+		rekeyedCount++
+	}
+
+	return rekeyedCount, remaining, nil
+}
+
+// flagUpdateBatchSize 按UID批量更新标记时每批的数量
+const flagUpdateBatchSize = 500
+
+// GetKnownUIDsWithTx 获取文件夹当前UIDVALIDITY下尚未标记为已删除的邮件UID
+func GetKnownUIDsWithTx(tx *gorm.DB, accountID int, folder string, uidValidity uint32) ([]uint32, error) {
+	var uids []uint32
+	err := tx.Model(&PrimeEmail{}).
+		Where("account_id = ? AND folder = ? AND uid_validity = ? AND is_expunged = 0", accountID, folder, uidValidity).
+		Pluck("email_id", &uids).Error
+	return uids, err
+}
+
+// GetStoredUIDsWithTx 获取文件夹中已保存的全部UID（包括任何状态的记录），用于检查UID缺口
+func GetStoredUIDsWithTx(tx *gorm.DB, accountID int, folder string, uidValidity uint32) ([]uint32, error) {
+	var uids []uint32
+	err := tx.Model(&PrimeEmail{}).
+		Where("account_id = ? AND folder = ? AND uid_validity = ?", accountID, folder, uidValidity).
+		Pluck("email_id", &uids).Error
+	return uids, err
+}
+
+// UpdateEmailFlagsWithTx 将一组UID的邮件标记更新为相同的状态
+func UpdateEmailFlagsWithTx(tx *gorm.DB, accountID int, folder string, uidValidity uint32, seen, flagged, deleted bool, uids []uint32) (int64, error) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"is_seen":         boolToInt(seen),
+		"is_flagged":      boolToInt(flagged),
+		"is_deleted":      boolToInt(deleted),
+		"flags_synced_at": now,
+	}
+
+	var total int64
+	for start := 0; start < len(uids); start += flagUpdateBatchSize {
+		end := start + flagUpdateBatchSize
+		if end > len(uids) {
+			end = len(uids)
+		}
+		result := tx.Model(&PrimeEmail{}).
+			Where("account_id = ? AND folder = ? AND uid_validity = ? AND email_id IN (?)", accountID, folder, uidValidity, uids[start:end]).
+			Updates(updates)
+		if result.Error != nil {
+			return total, fmt.Errorf("更新邮件标记失败: %w", result.Error)
+		}
+		total += result.RowsAffected
+	}
+	return total, nil
+}
+
+// MarkExpungedEmailsWithTx 将服务器上已不存在的邮件标记为已删除，尚未获取内容的邮件不再获取
+func MarkExpungedEmailsWithTx(tx *gorm.DB, accountID int, folder string, uidValidity uint32, uids []uint32) (int64, error) {
+	now := time.Now()
+	var total int64
+	for start := 0; start < len(uids); start += flagUpdateBatchSize {
+		end := start + flagUpdateBatchSize
+		if end > len(uids) {
+			end = len(uids)
+		}
+		batch := uids[start:end]
+
+		result := tx.Model(&PrimeEmail{}).
+			Where("account_id = ? AND folder = ? AND uid_validity = ? AND email_id IN (?)", accountID, folder, uidValidity, batch).
+			Updates(map[string]interface{}{"is_expunged": 1, "flags_synced_at": now})
+		if result.Error != nil {
+			return total, fmt.Errorf("标记邮件已删除失败: %w", result.Error)
+		}
+		total += result.RowsAffected
+
+		if err := tx.Model(&PrimeEmail{}).
+			Where("account_id = ? AND folder = ? AND uid_validity = ? AND email_id IN (?) AND status = ?", accountID, folder, uidValidity, batch, -1).
+			Update("status", EmailStatusDeleted).Error; err != nil {
+			return total, fmt.Errorf("更新已删除邮件状态失败: %w", err)
+		}
+	}
+	return total, nil
+}
+
+// boolToInt 将布尔值转换为表中使用的0/1
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
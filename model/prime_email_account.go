@@ -13,17 +13,53 @@ import (
 
 // PrimeEmailAccount 表示邮箱账号表结构
 type PrimeEmailAccount struct {
-	ID               int        `json:"id" gorm:"primaryKey;autoIncrement"`
-	Account          string     `json:"account" gorm:"type:varchar(255)"`
-	Password         string     `json:"password" gorm:"type:varchar(255)"`
-	AppPassword      string     `json:"app_password" gorm:"type:varchar(255)"`
-	Status           int        `json:"status" gorm:"comment:'-1:删除 0:未启用 1:已启用'"`
-	Type             int        `json:"type" gorm:"comment:'0:op账号'"`
-	Node             int        `json:"node" gorm:"type:int;default:1;comment:'节点编号，用于区分不同服务器'"`
-	LastSyncTime     *time.Time `json:"last_sync_time" gorm:"type:datetime;comment:'最后同步时间'"`
-	ProcessingStatus *int       `json:"processing_status" gorm:"type:int;default:0;comment:'处理状态: 0:空闲 1:处理中'"`
-	CreatedAt        time.Time  `json:"created_at" gorm:"type:datetime"`
-	UpdatedAt        time.Time  `json:"updated_at" gorm:"type:datetime"`
+	ID                int        `json:"id" gorm:"primaryKey;autoIncrement"`
+	Account           string     `json:"account" gorm:"type:varchar(255)"`
+	Password          string     `json:"password" gorm:"type:varchar(255)"`
+	AppPassword       string     `json:"app_password" gorm:"type:varchar(255)"`
+	Status            int        `json:"status" gorm:"comment:'-1:删除 0:未启用 1:已启用'"`
+	Type              int        `json:"type" gorm:"comment:'0:op账号'"`
+	Node              int        `json:"node" gorm:"type:int;default:1;comment:'节点编号，用于区分不同服务器'"`
+	LastSyncTime      *time.Time `json:"last_sync_time" gorm:"type:datetime;comment:'最后同步时间'"`
+	ProcessingStatus  *int       `json:"processing_status" gorm:"type:int;default:0;comment:'处理状态: 0:空闲 1:处理中'"`
+	ProviderId        int        `json:"provider_id" gorm:"type:int;default:0;comment:'服务商配置ID，0表示不引用服务商模板'"`
+	ImapHost          string     `json:"imap_host" gorm:"type:varchar(255);comment:'IMAP服务器地址，为空时使用服务商配置'"`
+	ImapPort          int        `json:"imap_port" gorm:"type:int;default:0;comment:'IMAP端口，0时使用服务商配置'"`
+	ImapSecurity      string     `json:"imap_security" gorm:"type:varchar(16);comment:'IMAP加密方式: ssl/starttls/plain，为空时使用服务商配置'"`
+	SmtpHost          string     `json:"smtp_host" gorm:"type:varchar(255);comment:'SMTP服务器地址，为空时使用服务商配置'"`
+	SmtpPort          int        `json:"smtp_port" gorm:"type:int;default:0;comment:'SMTP端口，0时使用服务商配置'"`
+	SmtpSecurity      string     `json:"smtp_security" gorm:"type:varchar(16);comment:'SMTP加密方式: ssl/starttls/plain，为空时使用服务商配置'"`
+	AuthMechanism     string     `json:"auth_mechanism" gorm:"type:varchar(16);comment:'认证方式: plain/login/xoauth2/oauthbearer，为空时使用服务商配置'"`
+	CredentialType    string     `json:"credential_type" gorm:"type:varchar(16);default:'password';comment:'凭据类型: password:密码 oauth2:OAuth2刷新令牌'"`
+	OAuthTokenURL     string     `json:"oauth_token_url" gorm:"column:oauth_token_url;type:varchar(512);comment:'OAuth2令牌端点，为空时使用服务商配置'"`
+	OAuthClientID     string     `json:"oauth_client_id" gorm:"column:oauth_client_id;type:varchar(255);comment:'OAuth2客户端ID'"`
+	OAuthClientSecret string     `json:"-" gorm:"column:oauth_client_secret;type:varchar(255);comment:'OAuth2客户端密钥'"`
+	OAuthScope        string     `json:"oauth_scope" gorm:"column:oauth_scope;type:varchar(512);comment:'OAuth2刷新令牌时请求的scope，为空时使用服务商配置'"`
+	OAuthRefreshToken string     `json:"-" gorm:"column:oauth_refresh_token;type:text;comment:'OAuth2刷新令牌'"`
+	OAuthAccessToken  string     `json:"-" gorm:"column:oauth_access_token;type:text;comment:'OAuth2访问令牌（自动刷新）'"`
+	OAuthTokenExpiry  *time.Time `json:"oauth_token_expiry" gorm:"column:oauth_token_expiry;type:datetime;comment:'OAuth2访问令牌过期时间'"`
+	Folders           string     `json:"folders" gorm:"type:varchar(1024);comment:'同步的文件夹列表，逗号分隔，支持*和%通配符，为空时只同步INBOX'"`
+	IdleEnabled       int        `json:"idle_enabled" gorm:"type:int;default:0;comment:'是否启用IDLE新邮件监听: 0:否 1:是'"`
+	FetchBatchSize    int        `json:"fetch_batch_size" gorm:"type:int;default:0;comment:'内容同步每条UID FETCH获取的邮件数，0时使用全局配置，1表示逐封获取'"`
+	AttachmentWorkers int        `json:"attachment_workers" gorm:"type:int;default:0;comment:'内容同步处理附件的并发数，0时使用全局配置'"`
+	CreatedAt         time.Time  `json:"created_at" gorm:"type:datetime"`
+	UpdatedAt         time.Time  `json:"updated_at" gorm:"type:datetime"`
+}
+
+// FolderPatterns 返回账号配置的同步文件夹列表（可能包含通配符），未配置时只同步INBOX
+func (a PrimeEmailAccount) FolderPatterns() []string {
+	var patterns []string
+	for _, item := range strings.FieldsFunc(a.Folders, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n'
+	}) {
+		if item = strings.TrimSpace(item); item != "" {
+			patterns = append(patterns, item)
+		}
+	}
+	if len(patterns) == 0 {
+		return []string{DefaultFolder}
+	}
+	return patterns
 }
 
 // GetAccountByID 根据ID获取账号信息
@@ -207,6 +243,46 @@ func getAndUpdateAccountsForUnifiedSyncOnce(node int, limit int) ([]PrimeEmailAc
 	return accounts, nil
 }
 
+// UpdateAccountOAuthToken 保存刷新后的OAuth2访问令牌，服务器轮换了刷新令牌时一并保存
+func UpdateAccountOAuthToken(accountID int, accessToken, refreshToken string, expiry time.Time) error {
+	updates := map[string]interface{}{
+		"oauth_access_token": accessToken,
+		"oauth_token_expiry": expiry,
+	}
+	if refreshToken != "" {
+		updates["oauth_refresh_token"] = refreshToken
+	}
+	return db.DB().Model(&PrimeEmailAccount{}).Where("id = ?", accountID).Updates(updates).Error
+}
+
+// GetIdleEnabledAccounts 获取启用了IDLE监听的账号，node为0时返回所有节点的账号
+func GetIdleEnabledAccounts(node int) ([]PrimeEmailAccount, error) {
+	var accounts []PrimeEmailAccount
+	query := db.DB().Where("status = ? AND idle_enabled = ?", 1, 1)
+	if node > 0 {
+		query = query.Where("node = ?", node)
+	}
+	err := query.Order("id ASC").Find(&accounts).Error
+	return accounts, err
+}
+
+// TryLockAccountForSync 尝试将单个空闲账号标记为处理中（processing_status: 0 → 1）
+// 返回false表示账号正在被其他同步任务处理，完成后需调用 UpdateLastSyncTimeOnComplete 或 ResetSyncTimeOnFailure 释放
+func TryLockAccountForSync(accountID int) (bool, error) {
+	result := db.DB().Model(&PrimeEmailAccount{}).
+		Where("id = ? AND status = ? AND (processing_status IS NULL OR processing_status = 0)", accountID, 1).
+		Update("processing_status", 1)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UnlockAccountSync 释放 TryLockAccountForSync 获取的账号锁，不更新同步时间
+func UnlockAccountSync(accountID int) error {
+	return db.DB().Model(&PrimeEmailAccount{}).Where("id = ?", accountID).Update("processing_status", 0).Error
+}
+
 // UpdateLastSyncTimeOnComplete 在账号处理完成后更新真正的同步时间
 func UpdateLastSyncTimeOnComplete(accountID int) error {
 	now := time.Now()
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"go_email/db"
+	"go_email/pkg/utils"
+	"time"
+)
+
+// 历史邮件补录任务状态
+const (
+	BackfillStatusPending   = 0  // 等待执行
+	BackfillStatusRunning   = 1  // 执行中
+	BackfillStatusCompleted = 2  // 已完成
+	BackfillStatusFailed    = -1 // 执行失败，可重新启动从断点继续
+	BackfillStatusCanceled  = -2 // 已取消
+)
+
+// PrimeEmailBackfill 历史邮件补录任务表结构，按日期范围把文件夹中的旧邮件补录为待处理邮件
+type PrimeEmailBackfill struct {
+	ID             uint           `gorm:"primarykey;column:id" json:"id"`
+	AccountId      int            `gorm:"column:account_id;index" json:"account_id"`
+	Folder         string         `gorm:"column:folder;size:255" json:"folder"`
+	Since          *time.Time     `gorm:"column:since" json:"since"`                               // 起始日期（含），为空表示不限
+	Before         *time.Time     `gorm:"column:before" json:"before"`                             // 截止日期（不含），为空表示不限
+	PageSize       int            `gorm:"column:page_size;default:200" json:"page_size"`           // 每页获取的邮件数
+	UidValidity    uint32         `gorm:"column:uid_validity;default:0" json:"uid_validity"`       // 搜索时文件夹的UIDVALIDITY
+	LastUID        uint32         `gorm:"column:last_uid;default:0" json:"last_uid"`               // 已补录到的最大UID，重新启动时从这里继续
+	TotalCount     int            `gorm:"column:total_count;default:0" json:"total_count"`         // 日期范围内的邮件总数
+	ProcessedCount int            `gorm:"column:processed_count;default:0" json:"processed_count"` // 已处理的邮件数
+	CreatedCount   int            `gorm:"column:created_count;default:0" json:"created_count"`     // 新建的邮件记录数
+	SkippedCount   int            `gorm:"column:skipped_count;default:0" json:"skipped_count"`     // 已存在而跳过的邮件数
+	Status         int            `gorm:"column:status;default:0" json:"status"`                   // 0:等待 1:执行中 2:完成 -1:失败 -2:取消
+	Owner          string         `gorm:"column:owner;size:128;index" json:"owner"`                // 执行任务的服务实例，服务重启时只重置本实例的任务
+	ErrorMessage   string         `gorm:"column:error_message;type:text" json:"error_message"`
+	StartedAt      *time.Time     `gorm:"column:started_at" json:"started_at"`
+	FinishedAt     *time.Time     `gorm:"column:finished_at" json:"finished_at"`
+	CreatedAt      utils.JsonTime `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      utils.JsonTime `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// CreateBackfillJob 创建补录任务
+func CreateBackfillJob(job *PrimeEmailBackfill) error {
+	now := time.Now()
+	job.Status = BackfillStatusPending
+	job.CreatedAt = utils.JsonTime{Time: now}
+	job.UpdatedAt = utils.JsonTime{Time: now}
+	return db.DB().Create(job).Error
+}
+
+// GetBackfillJob 根据ID获取补录任务
+func GetBackfillJob(id uint) (PrimeEmailBackfill, error) {
+	var job PrimeEmailBackfill
+	err := db.DB().Where("id = ?", id).First(&job).Error
+	return job, err
+}
+
+// ListBackfillJobs 获取补录任务列表，accountID为0时返回所有账号的任务
+func ListBackfillJobs(accountID int, limit int) ([]PrimeEmailBackfill, error) {
+	var jobs []PrimeEmailBackfill
+	query := db.DB().Order("id DESC")
+	if accountID > 0 {
+		query = query.Where("account_id = ?", accountID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&jobs).Error
+	return jobs, err
+}
+
+// HasActiveBackfillJob 检查账号文件夹是否已有执行中的补录任务
+func HasActiveBackfillJob(accountID int, folder string, excludeID uint) (bool, error) {
+	var count int64
+	err := db.DB().Model(&PrimeEmailBackfill{}).
+		Where("account_id = ? AND folder = ? AND status = ? AND id <> ?", accountID, folder, BackfillStatusRunning, excludeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// UpdateBackfillJob 更新补录任务的进度或状态
+func UpdateBackfillJob(id uint, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	return db.DB().Model(&PrimeEmailBackfill{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// ClaimBackfillJob 由当前实例开始执行补录任务，任务正在执行（可能在其他实例上）或已完成时返回false
+func ClaimBackfillJob(id uint, owner string) (bool, error) {
+	now := time.Now()
+	result := db.DB().Model(&PrimeEmailBackfill{}).
+		Where("id = ? AND status NOT IN ?", id, []int{BackfillStatusRunning, BackfillStatusCompleted}).
+		Updates(map[string]interface{}{
+			"status": BackfillStatusRunning, "owner": owner, "error_message": "",
+			"started_at": now, "finished_at": nil, "updated_at": now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ResetRunningBackfillJobs 服务重启后把本实例中断的执行中任务标记为失败，便于重新启动从断点继续
+// 其他实例上执行中的任务不受影响；升级前创建的任务没有记录实例，也一并重置
+func ResetRunningBackfillJobs(owner string) (int64, error) {
+	result := db.DB().Model(&PrimeEmailBackfill{}).
+		Where("status = ? AND owner IN ?", BackfillStatusRunning, []string{owner, ""}).
+		Updates(map[string]interface{}{"status": BackfillStatusFailed, "error_message": "服务重启，任务中断", "updated_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
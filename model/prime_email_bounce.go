@@ -0,0 +1,122 @@
+package model
+
+import (
+	"go_email/db"
+	"go_email/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+// PrimeEmailBounce 退信/投递状态通知表结构，每封通知的每个收件人一条
+type PrimeEmailBounce struct {
+	ID                uint           `gorm:"primarykey;column:id" json:"id"`
+	ContentId         uint           `gorm:"column:content_id;index" json:"content_id"` // 通知本身的prime_email_content主键
+	EmailID           int            `gorm:"column:email_id" json:"email_id"`
+	AccountId         int            `gorm:"column:account_id;index" json:"account_id"`
+	Folder            string         `gorm:"column:folder;size:255;default:INBOX" json:"folder"`
+	OutboundId        uint           `gorm:"column:outbound_id;default:0;index" json:"outbound_id"`                // 关联的本服务发出的邮件，0表示不是本服务发出的
+	OriginalContentId uint           `gorm:"column:original_content_id;default:0" json:"original_content_id"`      // 关联的已同步邮件（如已发送文件夹中的原邮件），0表示未找到
+	OriginalMessageID string         `gorm:"column:original_message_id;size:255;index" json:"original_message_id"` // 原邮件的Message-ID（不含尖括号）
+	OriginalSubject   string         `gorm:"column:original_subject;size:1000" json:"original_subject"`
+	Recipient         string         `gorm:"column:recipient;size:255;index" json:"recipient"` // 小写的收件人地址
+	Action            string         `gorm:"column:action;size:16" json:"action"`              // failed / delayed / delivered / relayed / expanded
+	Status            string         `gorm:"column:status;size:32" json:"status"`              // 增强状态码，如 5.1.1
+	DiagnosticCode    string         `gorm:"column:diagnostic_code;type:text" json:"diagnostic_code"`
+	RemoteMTA         string         `gorm:"column:remote_mta;size:255" json:"remote_mta"`
+	ReportingMTA      string         `gorm:"column:reporting_mta;size:255" json:"reporting_mta"`
+	Standard          bool           `gorm:"column:standard" json:"standard"`                     // 是否为标准DSN，否则为按发件人和主题识别的非标准退信
+	ReportedAt        utils.UTCTime  `gorm:"column:reported_at;type:datetime" json:"reported_at"` // 通知的发送时间
+	CreatedAt         utils.JsonTime `gorm:"column:created_at" json:"created_at"`
+}
+
+// CreateBouncesWithTx 在事务中保存退信记录，并按原邮件Message-ID关联本服务发出的邮件和已同步的原邮件，更新发出邮件的投递状态
+func CreateBouncesWithTx(tx *gorm.DB, contentID uint, bounces []*PrimeEmailBounce) error {
+	if len(bounces) == 0 {
+		return nil
+	}
+	for _, b := range bounces {
+		b.ContentId = contentID
+		if b.OriginalMessageID == "" {
+			continue
+		}
+		outbound, err := getOutboundByMessageIDWithTx(tx, b.OriginalMessageID)
+		if err != nil {
+			return err
+		}
+		if outbound != nil {
+			b.OutboundId = outbound.ID
+		}
+		var originalIDs []uint
+		if err := tx.Model(&PrimeEmailContent{}).
+			Where("account_id = ? AND message_id = ? AND id <> ?", b.AccountId, b.OriginalMessageID, contentID).
+			Order("id ASC").Limit(1).Pluck("id", &originalIDs).Error; err != nil {
+			return err
+		}
+		if len(originalIDs) > 0 {
+			b.OriginalContentId = originalIDs[0]
+		}
+	}
+	if err := tx.Create(bounces).Error; err != nil {
+		return err
+	}
+	// 退信记录保存后再更新发出邮件，退信收件人数按已保存的记录统计
+	for _, b := range bounces {
+		if b.OutboundId == 0 {
+			continue
+		}
+		if err := applyBounceToOutboundWithTx(tx, b.OutboundId, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceBouncesWithTx 删除通知原有的退信记录并写入新的记录，重新解析时使用
+func ReplaceBouncesWithTx(tx *gorm.DB, contentID uint, bounces []*PrimeEmailBounce) error {
+	if err := tx.Where("content_id = ?", contentID).Delete(&PrimeEmailBounce{}).Error; err != nil {
+		return err
+	}
+	return CreateBouncesWithTx(tx, contentID, bounces)
+}
+
+// CountContentBounces 统计通知已保存的退信记录数量
+func CountContentBounces(contentID uint) (int64, error) {
+	var count int64
+	err := db.DB().Model(&PrimeEmailBounce{}).Where("content_id = ?", contentID).Count(&count).Error
+	return count, err
+}
+
+// BounceQuery 查询退信记录的条件
+type BounceQuery struct {
+	AccountID  int    // 为0时不限账号
+	OutboundID uint   // 为0时不限
+	Recipient  string // 已规范化为小写
+	Action     string
+	Offset     int
+	Limit      int
+}
+
+// ListBounces 按条件查询退信记录，按ID倒序
+func ListBounces(q BounceQuery) ([]PrimeEmailBounce, int64, error) {
+	query := db.DB().Model(&PrimeEmailBounce{})
+	if q.AccountID > 0 {
+		query = query.Where("account_id = ?", q.AccountID)
+	}
+	if q.OutboundID > 0 {
+		query = query.Where("outbound_id = ?", q.OutboundID)
+	}
+	if q.Recipient != "" {
+		query = query.Where("recipient = ?", q.Recipient)
+	}
+	if q.Action != "" {
+		query = query.Where("action = ?", q.Action)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var bounces []PrimeEmailBounce
+	err := query.Order("id DESC").Offset(q.Offset).Limit(q.Limit).Find(&bounces).Error
+	return bounces, total, err
+}
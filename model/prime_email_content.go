@@ -10,22 +10,66 @@ import (
 	"gorm.io/gorm"
 )
 
+// 邮件类型（prime_email_content.type），保存时按邮件头和主题自动分类，下游分析可据此跳过自动回复和群发邮件
+const (
+	EmailTypeUnclassified = 0 // 未分类，分类功能上线前保存的邮件，可通过重新分类接口补全
+	EmailTypeHuman        = 1 // 人工发送的普通邮件，包括没有群发标记的系统通知
+	EmailTypeAutoReply    = 2 // 自动回复
+	EmailTypeOutOfOffice  = 3 // 外出/休假自动回复
+	EmailTypeMailingList  = 4 // 邮件列表
+	EmailTypeBulk         = 5 // 群发/营销邮件
+	EmailTypeBounce       = 6 // 退信/投递状态通知
+)
+
 // PrimeEmailContent 邮件内容表结构
 type PrimeEmailContent struct {
 	ID            uint           `gorm:"primarykey;column:id" json:"id"`
 	EmailID       int            `gorm:"column:email_id" json:"email_id"`
 	AccountId     int            `gorm:"column:account_id" json:"account_id"`
-	Subject       string         `gorm:"column:subject;size:255" json:"subject"`                // 主题
-	FromEmail     string         `gorm:"column:from_email;size:255" json:"from_email"`          // 发送者
-	ToEmail       string         `gorm:"column:to_email;size:255" json:"to_email"`              // 接收者
-	Date          string         `gorm:"column:date;size:255" json:"date"`                      // 邮件日期
-	Content       string         `gorm:"column:content;type:text" json:"content"`               // 正文
-	HTMLContent   string         `gorm:"column:html_content;type:longtext" json:"html_content"` // html正文
-	HasAttachment int            `gorm:"column:has_attachment;" json:"has_attachment"`          // 附件 0:没有1:有
-	Type          int            `gorm:"column:type" json:"type"`                               // 邮件类型
+	Folder        string         `gorm:"column:folder;size:255;default:INBOX" json:"folder"`         // 所在文件夹
+	UidValidity   uint32         `gorm:"column:uid_validity;default:0" json:"uid_validity"`          // 所在文件夹的UIDVALIDITY
+	Subject       string         `gorm:"column:subject;size:255" json:"subject"`                     // 主题
+	FromEmail     string         `gorm:"column:from_email;size:255" json:"from_email"`               // 发送者
+	ToEmail       string         `gorm:"column:to_email;type:text" json:"to_email"`                  // 接收者，结构化地址见prime_email_participant
+	CcEmail       string         `gorm:"column:cc_email;type:text" json:"cc_email"`                  // 抄送
+	BccEmail      string         `gorm:"column:bcc_email;type:text" json:"bcc_email"`                // 密送（仅发件箱等能看到）
+	ReplyTo       string         `gorm:"column:reply_to;size:512" json:"reply_to"`                   // 回复地址
+	MessageID     string         `gorm:"column:message_id;size:255;index" json:"message_id"`         // Message-ID（不含尖括号）
+	InReplyTo     string         `gorm:"column:in_reply_to;size:255;index" json:"in_reply_to"`       // 回复的邮件的Message-ID（不含尖括号）
+	References    string         `gorm:"column:message_references;type:text" json:"references"`      // References中的Message-ID，空格分隔
+	RawHeaders    string         `gorm:"column:raw_headers;type:longtext" json:"raw_headers"`        // 全部邮件头的JSON，键为头部名称，值为解码后的取值列表
+	Charset       string         `gorm:"column:charset;size:128" json:"charset"`                     // 正文字符集，按内容检测时注明声明的字符集和置信度
+	ThreadID      uint           `gorm:"column:thread_id;default:0;index" json:"thread_id"`          // 所属会话，取会话中最早保存的邮件内容ID，0表示尚未计算
+	ThreadSubject string         `gorm:"column:thread_subject;size:255;index" json:"thread_subject"` // 去掉回复/转发前缀后的小写主题，用于按主题归并会话
+	Date          string         `gorm:"column:date;size:255" json:"date"`                           // 邮件日期
+	SentAt        utils.UTCTime  `gorm:"column:sent_at;type:datetime;index" json:"sent_at"`          // Date邮件头解析出的发送时间（UTC）
+	ReceivedAt    utils.UTCTime  `gorm:"column:received_at;type:datetime;index" json:"received_at"`  // 服务器INTERNALDATE（UTC）
+	Content       string         `gorm:"column:content;type:text" json:"content"`                    // 正文
+	HTMLContent   string         `gorm:"column:html_content;type:longtext" json:"html_content"`      // html正文
+	HasAttachment int            `gorm:"column:has_attachment;" json:"has_attachment"`               // 附件 0:没有1:有
+	Type          int            `gorm:"column:type;index" json:"type"`                              // 邮件类型，取值见 EmailType* 常量
 	Status        int            `gorm:"column:status" json:"status"`
+	RawObjectKey  string         `gorm:"column:raw_object_key;size:512" json:"raw_object_key"`                      // 原始.eml在对象存储中的key（网关上传时为URL）
+	RawSha256     string         `gorm:"column:raw_sha256;size:64;index" json:"raw_sha256"`                         // 原始.eml的SHA-256
+	ParserVersion int            `gorm:"column:parser_version;default:0" json:"parser_version"`                     // 解析正文和附件时的解析器版本，0表示版本记录之前保存
+	HTMLSanitized string         `gorm:"column:html_content_sanitized;type:longtext" json:"html_content_sanitized"` // 按白名单清理后的html正文，去掉了脚本、事件处理和远程图片
 	CreatedAt     utils.JsonTime `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt     utils.JsonTime `gorm:"column:updated_at" json:"updated_at"`
+
+	// 正文分段结果，内容抽取只需处理新写的部分
+	NewContent     string `gorm:"column:new_content;type:longtext" json:"new_content"`           // 新写的纯文本内容，去掉了引用的历史邮件、签名和免责声明
+	NewHTMLContent string `gorm:"column:new_html_content;type:longtext" json:"new_html_content"` // 去掉引用的历史邮件和签名后的html正文
+	QuotedContent  string `gorm:"column:quoted_content;type:longtext" json:"quoted_content"`     // 引用或转发的历史邮件
+	Signature      string `gorm:"column:signature;type:text" json:"signature"`                   // 签名
+	Disclaimer     string `gorm:"column:disclaimer;type:text" json:"disclaimer"`                 // 免责声明
+
+	// 发件人认证结果，取自收件服务器添加的Authentication-Results或ARC-Authentication-Results
+	SPFResult        string `gorm:"column:spf_result;size:16" json:"spf_result"`                           // pass / fail / softfail / neutral / none / temperror / permerror
+	DKIMResult       string `gorm:"column:dkim_result;size:16" json:"dkim_result"`                         // 有签名通过时为pass
+	DMARCResult      string `gorm:"column:dmarc_result;size:16" json:"dmarc_result"`                       // DMARC结果
+	DKIMVerifyResult string `gorm:"column:dkim_verify_result;size:16" json:"dkim_verify_result"`           // 离线验证DKIM签名的结果，未启用验证时为空
+	SuspiciousSender bool   `gorm:"column:suspicious_sender;default:false;index" json:"suspicious_sender"` // From域名没有通过SPF/DKIM对齐检查，可能是仿冒的发件人
+	AuthDetail       string `gorm:"column:auth_detail;type:text" json:"auth_detail"`                       // 认证结果详情的JSON，包括认证服务器、SPF域名、DKIM签名域和离线验证错误
 }
 
 // Create 创建一条邮件内容记录
@@ -93,3 +137,21 @@ func (e *PrimeEmailContent) CreateWithTransaction(tx *gorm.DB) error {
 	log.Printf("[邮件内容保存] 成功保存邮件内容: ID=%d", e.EmailID)
 	return nil
 }
+
+// GetUnclassifiedContents 获取账号中尚未分类的邮件，只读取分类需要的字段
+func GetUnclassifiedContents(accountID int, afterID uint, limit int) ([]PrimeEmailContent, error) {
+	var contents []PrimeEmailContent
+	err := db.DB().Select("id", "subject", "from_email", "raw_headers").
+		Where("account_id = ? AND type = ? AND id > ?", accountID, EmailTypeUnclassified, afterID).
+		Order("id ASC").Limit(limit).
+		Find(&contents).Error
+	return contents, err
+}
+
+// UpdateContentTypes 批量写入邮件类型
+func UpdateContentTypes(ids []uint, emailType int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.DB().Model(&PrimeEmailContent{}).Where("id IN ?", ids).Update("type", emailType).Error
+}
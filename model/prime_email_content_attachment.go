@@ -9,15 +9,19 @@ import (
 
 // PrimeEmailContentAttachment 邮件附件表结构
 type PrimeEmailContentAttachment struct {
-	ID        uint           `gorm:"primarykey;column:id" json:"id"`
-	EmailID   int            `gorm:"column:email_id" json:"email_id"` // 邮件ID
-	AccountId int            `gorm:"column:account_id" json:"account_id"`
-	FileName  string         `gorm:"column:file_name;size:255" json:"file_name"` // 文件名
-	SizeKb    float64        `gorm:"column:size_kb" json:"size_kb"`              // 文件大小
-	MimeType  string         `gorm:"column:mime_type;size:255" json:"mime_type"` // 文件类型
-	OssUrl    string         `gorm:"column:oss_url;size:255" json:"oss_url"`     // oss链接
-	CreatedAt utils.JsonTime `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt utils.JsonTime `gorm:"column:updated_at" json:"updated_at"`
+	ID          uint           `gorm:"primarykey;column:id" json:"id"`
+	EmailID     int            `gorm:"column:email_id" json:"email_id"` // 邮件ID
+	AccountId   int            `gorm:"column:account_id" json:"account_id"`
+	Folder      string         `gorm:"column:folder;size:255;default:INBOX" json:"folder"` // 所在文件夹
+	UidValidity uint32         `gorm:"column:uid_validity;default:0" json:"uid_validity"`  // 所在文件夹的UIDVALIDITY
+	FileName    string         `gorm:"column:file_name;size:255" json:"file_name"`         // 文件名
+	SizeKb      float64        `gorm:"column:size_kb" json:"size_kb"`                      // 文件大小
+	MimeType    string         `gorm:"column:mime_type;size:255" json:"mime_type"`         // 文件类型
+	OssUrl      string         `gorm:"column:oss_url;size:255" json:"oss_url"`             // oss链接
+	IsInline    int            `gorm:"column:is_inline;default:0" json:"is_inline"`        // 是否为HTML正文通过cid:引用的内嵌图片
+	ContentID   string         `gorm:"column:content_id;size:255" json:"content_id"`       // 去掉尖括号的Content-ID
+	CreatedAt   utils.JsonTime `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   utils.JsonTime `gorm:"column:updated_at" json:"updated_at"`
 }
 
 // Create 创建一条邮件附件记录
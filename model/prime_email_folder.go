@@ -0,0 +1,79 @@
+package model
+
+import (
+	"go_email/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PrimeEmailFolder 账号文件夹同步状态表结构，每个(账号, 文件夹)一条记录
+type PrimeEmailFolder struct {
+	ID            uint           `gorm:"primarykey;column:id" json:"id"`
+	AccountId     int            `gorm:"column:account_id;uniqueIndex:uk_account_folder" json:"account_id"`
+	Folder        string         `gorm:"column:folder;size:255;uniqueIndex:uk_account_folder" json:"folder"` // 文件夹名称
+	UidValidity   uint32         `gorm:"column:uid_validity;default:0" json:"uid_validity"`                  // 游标对应的UIDVALIDITY
+	LastUID       uint32         `gorm:"column:last_uid" json:"last_uid"`                                    // 已同步到的最大UID
+	LastSyncTime  *time.Time     `gorm:"column:last_sync_time" json:"last_sync_time"`                        // 最后同步时间
+	HighestModSeq uint64         `gorm:"column:highest_mod_seq;default:0" json:"highest_mod_seq"`            // 上次标记对账时的HIGHESTMODSEQ（CONDSTORE）
+	FlagsSyncedAt *time.Time     `gorm:"column:flags_synced_at" json:"flags_synced_at"`                      // 最后一次标记对账时间
+	GapAuditedAt  *time.Time     `gorm:"column:gap_audited_at" json:"gap_audited_at"`                        // 最后一次UID缺口检查时间
+	CreatedAt     utils.JsonTime `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     utils.JsonTime `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// GetEmailFolderWithTx 使用事务获取账号文件夹的同步状态
+func GetEmailFolderWithTx(tx *gorm.DB, accountID int, folder string) (PrimeEmailFolder, error) {
+	var state PrimeEmailFolder
+	err := tx.Where("account_id = ? AND folder = ?", accountID, folder).First(&state).Error
+	return state, err
+}
+
+// SaveFolderCursorWithTx 使用事务保存账号文件夹的UIDVALIDITY和UID游标（不存在则创建）
+func SaveFolderCursorWithTx(tx *gorm.DB, accountID int, folder string, uidValidity uint32, lastUID uint32) error {
+	now := time.Now()
+	state := PrimeEmailFolder{
+		AccountId:    accountID,
+		Folder:       folder,
+		UidValidity:  uidValidity,
+		LastUID:      lastUID,
+		LastSyncTime: &now,
+		CreatedAt:    utils.JsonTime{Time: now},
+		UpdatedAt:    utils.JsonTime{Time: now},
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_id"}, {Name: "folder"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"uid_validity": uidValidity, "last_uid": lastUID, "last_sync_time": now, "updated_at": now}),
+	}).Create(&state).Error
+}
+
+// SaveFolderFlagStateWithTx 标记对账完成后保存文件夹的HIGHESTMODSEQ和对账时间
+func SaveFolderFlagStateWithTx(tx *gorm.DB, accountID int, folder string, highestModSeq uint64) error {
+	now := time.Now()
+	return tx.Model(&PrimeEmailFolder{}).
+		Where("account_id = ? AND folder = ?", accountID, folder).
+		Updates(map[string]interface{}{"highest_mod_seq": highestModSeq, "flags_synced_at": now, "updated_at": now}).Error
+}
+
+// ResetFolderModSeqWithTx UIDVALIDITY变化后旧的MODSEQ不再可比，清零以便下次全量对账标记
+func ResetFolderModSeqWithTx(tx *gorm.DB, accountID int, folder string) error {
+	return tx.Model(&PrimeEmailFolder{}).
+		Where("account_id = ? AND folder = ?", accountID, folder).
+		Updates(map[string]interface{}{"highest_mod_seq": 0, "flags_synced_at": nil}).Error
+}
+
+// GetAccountFoldersWithTx 获取账号所有文件夹的同步状态
+func GetAccountFoldersWithTx(tx *gorm.DB, accountID int) ([]PrimeEmailFolder, error) {
+	var states []PrimeEmailFolder
+	err := tx.Where("account_id = ?", accountID).Order("id").Find(&states).Error
+	return states, err
+}
+
+// SaveFolderGapAuditWithTx 保存文件夹最后一次UID缺口检查时间
+func SaveFolderGapAuditWithTx(tx *gorm.DB, accountID int, folder string) error {
+	now := time.Now()
+	return tx.Model(&PrimeEmailFolder{}).
+		Where("account_id = ? AND folder = ?", accountID, folder).
+		Updates(map[string]interface{}{"gap_audited_at": now, "updated_at": now}).Error
+}
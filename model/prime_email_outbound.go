@@ -0,0 +1,127 @@
+package model
+
+import (
+	"go_email/db"
+	"go_email/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 发出邮件的投递状态，收到退信/投递状态通知后更新
+const (
+	OutboundStatusSent      = 1  // 已发送，未收到通知
+	OutboundStatusDelayed   = 2  // 收到延迟投递通知，服务器仍在重试
+	OutboundStatusDelivered = 3  // 收到投递成功通知
+	OutboundStatusBounced   = -1 // 有收件人退信
+)
+
+// 发出邮件的类型
+const (
+	OutboundKindSend              = "send"               // SendEmail发送的通知
+	OutboundKindForwardOriginal   = "forward_original"   // ForwardOriginalEmail以附件转发的原邮件
+	OutboundKindForwardStructured = "forward_structured" // ForwardStructuredEmail转发的邮件
+)
+
+// PrimeEmailOutbound 本服务发出的邮件，按Message-ID把退信关联回来
+type PrimeEmailOutbound struct {
+	ID             uint           `gorm:"primarykey;column:id" json:"id"`
+	AccountId      int            `gorm:"column:account_id;index" json:"account_id"`
+	MessageID      string         `gorm:"column:message_id;size:255;index" json:"message_id"` // 发送时生成的Message-ID（不含尖括号）
+	Kind           string         `gorm:"column:kind;size:32" json:"kind"`                    // send / forward_original / forward_structured
+	FromEmail      string         `gorm:"column:from_email;size:255" json:"from_email"`
+	ToEmail        string         `gorm:"column:to_email;type:text" json:"to_email"`
+	Subject        string         `gorm:"column:subject;size:1000" json:"subject"`
+	SourceUID      uint32         `gorm:"column:source_uid;default:0" json:"source_uid"`           // 转发的原邮件UID
+	SourceFolder   string         `gorm:"column:source_folder;size:255" json:"source_folder"`      // 转发的原邮件所在文件夹
+	Status         int            `gorm:"column:status;default:1;index" json:"status"`             // 1:已发送 2:延迟 3:已投递 -1:退信
+	BounceCount    int            `gorm:"column:bounce_count;default:0" json:"bounce_count"`       // 退信的收件人数
+	LastStatus     string         `gorm:"column:last_status;size:32" json:"last_status"`           // 最近一次通知的状态码，如 5.1.1
+	LastDiagnostic string         `gorm:"column:last_diagnostic;type:text" json:"last_diagnostic"` // 最近一次通知的诊断信息
+	SentAt         utils.UTCTime  `gorm:"column:sent_at;type:datetime;index" json:"sent_at"`
+	CreatedAt      utils.JsonTime `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      utils.JsonTime `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// CreateOutbound 记录一封发出的邮件
+func CreateOutbound(outbound *PrimeEmailOutbound) error {
+	now := time.Now()
+	if outbound.Status == 0 {
+		outbound.Status = OutboundStatusSent
+	}
+	if outbound.SentAt.IsZero() {
+		outbound.SentAt = utils.NewUTCTime(now)
+	}
+	outbound.CreatedAt = utils.JsonTime{Time: now}
+	outbound.UpdatedAt = utils.JsonTime{Time: now}
+	return db.DB().Create(outbound).Error
+}
+
+// getOutboundByMessageIDWithTx 按Message-ID查找发出的邮件，找不到时返回nil
+func getOutboundByMessageIDWithTx(tx *gorm.DB, messageID string) (*PrimeEmailOutbound, error) {
+	var outbounds []PrimeEmailOutbound
+	if err := tx.Where("message_id = ?", messageID).Order("id DESC").Limit(1).Find(&outbounds).Error; err != nil {
+		return nil, err
+	}
+	if len(outbounds) == 0 {
+		return nil, nil
+	}
+	return &outbounds[0], nil
+}
+
+// applyBounceToOutboundWithTx 按通知中的动作更新发出邮件的投递状态
+// 退信总是覆盖其他状态；延迟只更新仍为已发送的邮件；投递成功不覆盖退信
+func applyBounceToOutboundWithTx(tx *gorm.DB, outboundID uint, bounce *PrimeEmailBounce) error {
+	updates := map[string]interface{}{
+		"last_status":     bounce.Status,
+		"last_diagnostic": bounce.DiagnosticCode,
+		"updated_at":      utils.JsonTime{Time: time.Now()},
+	}
+	query := tx.Model(&PrimeEmailOutbound{}).Where("id = ?", outboundID)
+	switch bounce.Action {
+	case "failed":
+		updates["status"] = OutboundStatusBounced
+		updates["bounce_count"] = tx.Model(&PrimeEmailBounce{}).Select("COUNT(DISTINCT recipient)").
+			Where("outbound_id = ? AND action = ?", outboundID, "failed")
+	case "delayed":
+		updates["status"] = OutboundStatusDelayed
+		query = query.Where("status = ?", OutboundStatusSent)
+	case "delivered", "relayed", "expanded":
+		updates["status"] = OutboundStatusDelivered
+		query = query.Where("status IN ?", []int{OutboundStatusSent, OutboundStatusDelayed})
+	default:
+		return nil
+	}
+	return query.Updates(updates).Error
+}
+
+// OutboundQuery 查询发出邮件的条件
+type OutboundQuery struct {
+	AccountID int  // 为0时不限账号
+	Status    *int // 为空时不限状态
+	Kind      string
+	Offset    int
+	Limit     int
+}
+
+// ListOutbounds 按条件查询发出的邮件，按ID倒序
+func ListOutbounds(q OutboundQuery) ([]PrimeEmailOutbound, int64, error) {
+	query := db.DB().Model(&PrimeEmailOutbound{})
+	if q.AccountID > 0 {
+		query = query.Where("account_id = ?", q.AccountID)
+	}
+	if q.Status != nil {
+		query = query.Where("status = ?", *q.Status)
+	}
+	if q.Kind != "" {
+		query = query.Where("kind = ?", q.Kind)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var outbounds []PrimeEmailOutbound
+	err := query.Order("id DESC").Offset(q.Offset).Limit(q.Limit).Find(&outbounds).Error
+	return outbounds, total, err
+}
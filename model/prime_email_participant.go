@@ -0,0 +1,107 @@
+package model
+
+import (
+	"go_email/db"
+	"go_email/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PrimeEmailParticipant 邮件参与人表结构，每封邮件的每个发件人/收件人/抄送/密送/回复地址一条
+type PrimeEmailParticipant struct {
+	ID          uint           `gorm:"primarykey;column:id" json:"id"`
+	ContentId   uint           `gorm:"column:content_id;index" json:"content_id"` // prime_email_content主键
+	EmailID     int            `gorm:"column:email_id" json:"email_id"`
+	AccountId   int            `gorm:"column:account_id;index:idx_participant_address,priority:1;index:idx_participant_domain,priority:1" json:"account_id"`
+	Folder      string         `gorm:"column:folder;size:255;default:INBOX" json:"folder"`
+	UidValidity uint32         `gorm:"column:uid_validity;default:0" json:"uid_validity"`
+	Role        string         `gorm:"column:role;size:16" json:"role"`                                                 // from / to / cc / bcc / reply-to
+	Name        string         `gorm:"column:name;size:255" json:"name"`                                                // 显示名
+	Address     string         `gorm:"column:address;size:255;index:idx_participant_address,priority:2" json:"address"` // 小写的邮件地址
+	Domain      string         `gorm:"column:domain;size:255;index:idx_participant_domain,priority:2" json:"domain"`    // 小写的域名
+	CreatedAt   utils.JsonTime `gorm:"column:created_at" json:"created_at"`
+}
+
+// CreateParticipantsWithTx 在事务中保存邮件参与人
+func CreateParticipantsWithTx(tx *gorm.DB, contentID uint, participants []*PrimeEmailParticipant) error {
+	if len(participants) == 0 {
+		return nil
+	}
+	for _, p := range participants {
+		p.ContentId = contentID
+	}
+	return tx.Create(participants).Error
+}
+
+// ReplaceParticipantsWithTx 删除邮件内容原有的参与人并写入新的参与人
+func ReplaceParticipantsWithTx(tx *gorm.DB, contentID uint, participants []*PrimeEmailParticipant) error {
+	if err := tx.Where("content_id = ?", contentID).Delete(&PrimeEmailParticipant{}).Error; err != nil {
+		return err
+	}
+	return CreateParticipantsWithTx(tx, contentID, participants)
+}
+
+// CountContentParticipants 统计邮件内容已保存的参与人数量
+func CountContentParticipants(contentID uint) (int64, error) {
+	var count int64
+	err := db.DB().Model(&PrimeEmailParticipant{}).Where("content_id = ?", contentID).Count(&count).Error
+	return count, err
+}
+
+// ParticipantQuery 按参与人查询邮件的条件，Address和Domain至少指定一个
+type ParticipantQuery struct {
+	AccountID  int
+	Address    string     // 完整地址，已规范化为小写
+	Domain     string     // 域名，已规范化为小写
+	Role       string     // 为空时不限角色
+	SentSince  *time.Time // 发送时间起始（含），为空表示不限
+	SentBefore *time.Time // 发送时间截止（不含），为空表示不限
+	Suspicious *bool      // 按是否为可疑发件人过滤，为空表示不限
+	Offset     int
+	Limit      int
+}
+
+// SearchContentsByParticipant 按参与人地址或域名查询邮件内容（不含正文），按发送时间倒序
+func SearchContentsByParticipant(q ParticipantQuery) ([]PrimeEmailContent, int64, error) {
+	// 子查询每次使用时重新构建，避免同一个语句对象被两次查询共用
+	contentIDs := func() *gorm.DB {
+		query := db.DB().Model(&PrimeEmailParticipant{}).Where("account_id = ?", q.AccountID)
+		if q.Address != "" {
+			query = query.Where("address = ?", q.Address)
+		}
+		if q.Domain != "" {
+			query = query.Where("domain = ?", q.Domain)
+		}
+		if q.Role != "" {
+			query = query.Where("role = ?", q.Role)
+		}
+		return query.Distinct("content_id")
+	}
+
+	contentQuery := func() *gorm.DB {
+		query := db.DB().Model(&PrimeEmailContent{}).Where("id IN (?)", contentIDs())
+		if q.SentSince != nil {
+			query = query.Where("sent_at >= ?", utils.NewUTCTime(*q.SentSince))
+		}
+		if q.SentBefore != nil {
+			query = query.Where("sent_at < ?", utils.NewUTCTime(*q.SentBefore))
+		}
+		if q.Suspicious != nil {
+			query = query.Where("suspicious_sender = ?", *q.Suspicious)
+		}
+		return query
+	}
+
+	var total int64
+	if err := contentQuery().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var contents []PrimeEmailContent
+	err := contentQuery().Omit("content", "html_content", "html_content_sanitized", "raw_headers",
+		"new_html_content", "quoted_content").
+		Order("sent_at DESC, id DESC").Offset(q.Offset).Limit(q.Limit).
+		Find(&contents).Error
+	return contents, total, err
+}
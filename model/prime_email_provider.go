@@ -0,0 +1,41 @@
+package model
+
+import (
+	"fmt"
+	"go_email/db"
+	"time"
+)
+
+// PrimeEmailProvider 邮箱服务商配置表结构（IMAP/SMTP服务器模板，账号通过 provider_id 引用）
+type PrimeEmailProvider struct {
+	ID            int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name          string    `json:"name" gorm:"type:varchar(64);comment:'服务商名称，如 yahoo/gmail/outlook/exmail'"`
+	ImapHost      string    `json:"imap_host" gorm:"type:varchar(255);comment:'IMAP服务器地址'"`
+	ImapPort      int       `json:"imap_port" gorm:"type:int;default:0;comment:'IMAP端口，0表示按加密方式取默认值'"`
+	ImapSecurity  string    `json:"imap_security" gorm:"type:varchar(16);comment:'IMAP加密方式: ssl:隐式TLS starttls:STARTTLS plain:明文'"`
+	SmtpHost      string    `json:"smtp_host" gorm:"type:varchar(255);comment:'SMTP服务器地址'"`
+	SmtpPort      int       `json:"smtp_port" gorm:"type:int;default:0;comment:'SMTP端口，0表示按加密方式取默认值'"`
+	SmtpSecurity  string    `json:"smtp_security" gorm:"type:varchar(16);comment:'SMTP加密方式: ssl:隐式TLS starttls:STARTTLS plain:明文'"`
+	AuthMechanism string    `json:"auth_mechanism" gorm:"type:varchar(16);comment:'认证方式: plain/login/xoauth2/oauthbearer'"`
+	OAuthTokenURL string    `json:"oauth_token_url" gorm:"column:oauth_token_url;type:varchar(512);comment:'OAuth2令牌端点，账号未配置时使用'"`
+	OAuthScope    string    `json:"oauth_scope" gorm:"column:oauth_scope;type:varchar(512);comment:'OAuth2刷新令牌时请求的scope，账号未配置时使用'"`
+	AuthServIds   string    `json:"auth_serv_ids" gorm:"column:auth_serv_ids;type:varchar(512);comment:'可信的认证服务器(authserv-id)，逗号分隔，如 mx.google.com；none表示信任没有authserv-id的结果'"`
+	Status        int       `json:"status" gorm:"type:int;default:1;comment:'0:停用 1:启用'"`
+	CreatedAt     time.Time `json:"created_at" gorm:"type:datetime"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"type:datetime"`
+}
+
+// ProviderStatusEnabled 服务商配置启用状态
+const ProviderStatusEnabled = 1
+
+// GetProviderByID 根据ID获取启用的服务商配置，已停用的配置返回错误，不再向引用它的账号提供服务器设置
+func GetProviderByID(id int) (PrimeEmailProvider, error) {
+	var provider PrimeEmailProvider
+	if err := db.DB().Where("id = ?", id).First(&provider).Error; err != nil {
+		return provider, err
+	}
+	if provider.Status != ProviderStatusEnabled {
+		return PrimeEmailProvider{}, fmt.Errorf("服务商配置已停用(id=%d)", id)
+	}
+	return provider, nil
+}
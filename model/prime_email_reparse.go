@@ -0,0 +1,211 @@
+package model
+
+import (
+	"go_email/db"
+	"go_email/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 重新解析任务状态
+const (
+	ReparseStatusPending   = 0  // 等待执行
+	ReparseStatusRunning   = 1  // 执行中
+	ReparseStatusCompleted = 2  // 已完成
+	ReparseStatusFailed    = -1 // 执行失败，可重新启动从断点继续
+	ReparseStatusCanceled  = -2 // 已取消
+)
+
+// PrimeEmailReparse 重新解析任务表结构，用归档的原始邮件重新运行解析器，修正已保存的正文和附件
+type PrimeEmailReparse struct {
+	ID             uint           `gorm:"primarykey;column:id" json:"id"`
+	AccountId      int            `gorm:"column:account_id;index" json:"account_id"`               // 为0时不限账号
+	Since          *time.Time     `gorm:"column:since" json:"since"`                               // 内容保存时间起始（含），为空表示不限
+	Before         *time.Time     `gorm:"column:before" json:"before"`                             // 内容保存时间截止（不含），为空表示不限
+	BelowVersion   int            `gorm:"column:below_version" json:"below_version"`               // 只处理parser_version小于该值的邮件
+	DryRun         bool           `gorm:"column:dry_run" json:"dry_run"`                           // 只生成差异报告，不修改数据
+	LastContentID  uint           `gorm:"column:last_content_id;default:0" json:"last_content_id"` // 已处理到的内容ID，重新启动时从这里继续
+	TotalCount     int            `gorm:"column:total_count;default:0" json:"total_count"`         // 符合条件的邮件总数
+	ProcessedCount int            `gorm:"column:processed_count;default:0" json:"processed_count"` // 已处理的邮件数
+	ChangedCount   int            `gorm:"column:changed_count;default:0" json:"changed_count"`     // 解析结果有变化的邮件数
+	NoRawCount     int            `gorm:"column:no_raw_count;default:0" json:"no_raw_count"`       // 没有归档原始邮件而跳过的邮件数
+	FailedCount    int            `gorm:"column:failed_count;default:0" json:"failed_count"`       // 下载或解析失败的邮件数
+	TargetVersion  int            `gorm:"column:target_version" json:"target_version"`             // 本次使用的解析器版本
+	Status         int            `gorm:"column:status;default:0" json:"status"`                   // 0:等待 1:执行中 2:完成 -1:失败 -2:取消
+	Owner          string         `gorm:"column:owner;size:128;index" json:"owner"`                // 执行任务的服务实例，服务重启时只重置本实例的任务
+	ErrorMessage   string         `gorm:"column:error_message;type:text" json:"error_message"`
+	StartedAt      *time.Time     `gorm:"column:started_at" json:"started_at"`
+	FinishedAt     *time.Time     `gorm:"column:finished_at" json:"finished_at"`
+	CreatedAt      utils.JsonTime `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      utils.JsonTime `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// PrimeEmailReparseDiff 重新解析的差异报告，每封解析结果有变化或失败的邮件一条
+type PrimeEmailReparseDiff struct {
+	ID             uint           `gorm:"primarykey;column:id" json:"id"`
+	ReparseId      uint           `gorm:"column:reparse_id;index" json:"reparse_id"`
+	ContentId      uint           `gorm:"column:content_id" json:"content_id"`
+	EmailID        int            `gorm:"column:email_id" json:"email_id"`
+	AccountId      int            `gorm:"column:account_id" json:"account_id"`
+	OldVersion     int            `gorm:"column:old_version" json:"old_version"`
+	ChangedFields  string         `gorm:"column:changed_fields;size:255" json:"changed_fields"` // 逗号分隔：headers,content,html_content,participants,attachments
+	OldContentLen  int            `gorm:"column:old_content_len" json:"old_content_len"`
+	NewContentLen  int            `gorm:"column:new_content_len" json:"new_content_len"`
+	OldHTMLLen     int            `gorm:"column:old_html_len" json:"old_html_len"`
+	NewHTMLLen     int            `gorm:"column:new_html_len" json:"new_html_len"`
+	OldAttachments string         `gorm:"column:old_attachments;type:text" json:"old_attachments"` // 原附件文件名，逗号分隔
+	NewAttachments string         `gorm:"column:new_attachments;type:text" json:"new_attachments"` // 新附件文件名，逗号分隔
+	Applied        bool           `gorm:"column:applied" json:"applied"`                           // 是否已写回数据库
+	ErrorMessage   string         `gorm:"column:error_message;type:text" json:"error_message"`
+	CreatedAt      utils.JsonTime `gorm:"column:created_at" json:"created_at"`
+}
+
+// CreateReparseJob 创建重新解析任务
+func CreateReparseJob(job *PrimeEmailReparse) error {
+	now := time.Now()
+	job.Status = ReparseStatusPending
+	job.CreatedAt = utils.JsonTime{Time: now}
+	job.UpdatedAt = utils.JsonTime{Time: now}
+	return db.DB().Create(job).Error
+}
+
+// GetReparseJob 根据ID获取重新解析任务
+func GetReparseJob(id uint) (PrimeEmailReparse, error) {
+	var job PrimeEmailReparse
+	err := db.DB().Where("id = ?", id).First(&job).Error
+	return job, err
+}
+
+// ListReparseJobs 获取重新解析任务列表
+func ListReparseJobs(limit int) ([]PrimeEmailReparse, error) {
+	var jobs []PrimeEmailReparse
+	query := db.DB().Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&jobs).Error
+	return jobs, err
+}
+
+// UpdateReparseJob 更新重新解析任务的进度或状态
+func UpdateReparseJob(id uint, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	return db.DB().Model(&PrimeEmailReparse{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// HasRunningReparseJob 检查是否有其他执行中的重新解析任务，包括其他实例上的任务
+func HasRunningReparseJob(excludeID uint) (bool, error) {
+	var count int64
+	err := db.DB().Model(&PrimeEmailReparse{}).
+		Where("status = ? AND id <> ?", ReparseStatusRunning, excludeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ClaimReparseJob 由当前实例开始执行重新解析任务，任务正在执行（可能在其他实例上）或已完成时返回false
+func ClaimReparseJob(id uint, owner string) (bool, error) {
+	now := time.Now()
+	result := db.DB().Model(&PrimeEmailReparse{}).
+		Where("id = ? AND status NOT IN ?", id, []int{ReparseStatusRunning, ReparseStatusCompleted}).
+		Updates(map[string]interface{}{
+			"status": ReparseStatusRunning, "owner": owner, "error_message": "",
+			"started_at": now, "finished_at": nil, "updated_at": now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ResetRunningReparseJobs 服务重启后把本实例中断的执行中任务标记为失败，便于重新启动从断点继续
+// 其他实例上执行中的任务不受影响；升级前创建的任务没有记录实例，也一并重置
+func ResetRunningReparseJobs(owner string) (int64, error) {
+	result := db.DB().Model(&PrimeEmailReparse{}).
+		Where("status = ? AND owner IN ?", ReparseStatusRunning, []string{owner, ""}).
+		Updates(map[string]interface{}{"status": ReparseStatusFailed, "error_message": "服务重启，任务中断", "updated_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+// reparseQuery 按任务条件筛选邮件内容
+func reparseQuery(job PrimeEmailReparse) *gorm.DB {
+	query := db.DB().Model(&PrimeEmailContent{}).Where("parser_version < ?", job.BelowVersion)
+	if job.AccountId > 0 {
+		query = query.Where("account_id = ?", job.AccountId)
+	}
+	if job.Since != nil {
+		query = query.Where("created_at >= ?", *job.Since)
+	}
+	if job.Before != nil {
+		query = query.Where("created_at < ?", *job.Before)
+	}
+	return query
+}
+
+// CountReparseContents 统计内容ID在afterID之后、符合重新解析条件的邮件数
+func CountReparseContents(job PrimeEmailReparse, afterID uint) (int64, error) {
+	var count int64
+	err := reparseQuery(job).Where("id > ?", afterID).Count(&count).Error
+	return count, err
+}
+
+// GetReparseContents 按内容ID升序获取下一页需要重新解析的邮件
+func GetReparseContents(job PrimeEmailReparse, afterID uint, limit int) ([]PrimeEmailContent, error) {
+	var contents []PrimeEmailContent
+	err := reparseQuery(job).Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&contents).Error
+	return contents, err
+}
+
+// CreateReparseDiff 保存一条差异报告
+func CreateReparseDiff(diff *PrimeEmailReparseDiff) error {
+	diff.CreatedAt = utils.JsonTime{Time: time.Now()}
+	return db.DB().Create(diff).Error
+}
+
+// ListReparseDiffs 分页获取任务的差异报告
+func ListReparseDiffs(reparseID uint, offset, limit int) ([]PrimeEmailReparseDiff, int64, error) {
+	var diffs []PrimeEmailReparseDiff
+	var total int64
+	query := db.DB().Model(&PrimeEmailReparseDiff{}).Where("reparse_id = ?", reparseID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id ASC").Offset(offset).Limit(limit).Find(&diffs).Error
+	return diffs, total, err
+}
+
+// GetContentAttachmentsWithTx 获取邮件内容对应的附件记录
+func GetContentAttachmentsWithTx(tx *gorm.DB, content PrimeEmailContent) ([]PrimeEmailContentAttachment, error) {
+	var attachments []PrimeEmailContentAttachment
+	err := tx.Where("account_id = ? AND folder = ? AND uid_validity = ? AND email_id = ?",
+		content.AccountId, content.Folder, content.UidValidity, content.EmailID).
+		Order("id ASC").Find(&attachments).Error
+	return attachments, err
+}
+
+// ReplaceContentAttachmentsWithTx 删除邮件内容原有的附件记录并写入新的附件记录
+func ReplaceContentAttachmentsWithTx(tx *gorm.DB, content PrimeEmailContent, attachments []*PrimeEmailContentAttachment) error {
+	if err := tx.Where("account_id = ? AND folder = ? AND uid_validity = ? AND email_id = ?",
+		content.AccountId, content.Folder, content.UidValidity, content.EmailID).
+		Delete(&PrimeEmailContentAttachment{}).Error; err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		if err := attachment.CreateWithTransaction(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateReparsedContentWithTx 写回重新解析后的正文、HTML正文和解析器版本
+func UpdateReparsedContentWithTx(tx *gorm.DB, contentID uint, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	return tx.Model(&PrimeEmailContent{}).Where("id = ?", contentID).Updates(updates).Error
+}
+
+// SetContentParserVersion 把解析结果没有变化的邮件标记为新的解析器版本
+func SetContentParserVersion(contentIDs []uint, version int) error {
+	if len(contentIDs) == 0 {
+		return nil
+	}
+	return db.DB().Model(&PrimeEmailContent{}).Where("id IN ?", contentIDs).
+		Updates(map[string]interface{}{"parser_version": version, "updated_at": time.Now()}).Error
+}
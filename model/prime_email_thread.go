@@ -0,0 +1,91 @@
+package model
+
+import (
+	"go_email/db"
+	"strings"
+	"time"
+)
+
+// threadColumns 计算会话只需要的字段，避免读取正文
+var threadColumns = []string{"id", "account_id", "email_id", "folder", "subject", "from_email", "date", "message_id",
+	"in_reply_to", "message_references", "thread_id", "thread_subject", "created_at"}
+
+// ThreadNeighborQuery 查找可能与新邮件属于同一会话的已保存邮件的条件
+type ThreadNeighborQuery struct {
+	AccountID    int
+	MessageIDs   []string  // 新邮件自身及其引用的Message-ID，匹配已保存邮件的message_id
+	ReplyTo      []string  // 新邮件自身的Message-ID，匹配先于原邮件保存的回复的in_reply_to
+	Subjects     []string  // 规范化主题，匹配thread_subject
+	SubjectSince time.Time // 按主题匹配时只查找该时间之后保存的邮件
+	Limit        int
+}
+
+// GetThreadNeighbors 按Message-ID、In-Reply-To和主题查找同一账号中可能属于同一会话的邮件
+func GetThreadNeighbors(q ThreadNeighborQuery) ([]PrimeEmailContent, error) {
+	var contents []PrimeEmailContent
+	var conds []string
+	var args []interface{}
+	if len(q.MessageIDs) > 0 {
+		conds = append(conds, "message_id IN ?")
+		args = append(args, q.MessageIDs)
+	}
+	if len(q.ReplyTo) > 0 {
+		conds = append(conds, "in_reply_to IN ?")
+		args = append(args, q.ReplyTo)
+	}
+	if len(q.Subjects) > 0 {
+		conds = append(conds, "(thread_subject IN ? AND created_at >= ?)")
+		args = append(args, q.Subjects, q.SubjectSince)
+	}
+	if len(conds) == 0 {
+		return contents, nil
+	}
+
+	err := db.DB().Select(threadColumns).
+		Where("account_id = ?", q.AccountID).
+		Where("("+strings.Join(conds, " OR ")+")", args...).
+		Order("id DESC").Limit(q.Limit).
+		Find(&contents).Error
+	return contents, err
+}
+
+// UpdateContentThread 更新一封邮件的会话ID和规范化主题
+func UpdateContentThread(id, threadID uint, threadSubject string) error {
+	return db.DB().Model(&PrimeEmailContent{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"thread_id": threadID, "thread_subject": threadSubject}).Error
+}
+
+// MergeThreads 把 fromThreadIDs 中会话的邮件全部归入 toThreadID
+func MergeThreads(accountID int, fromThreadIDs []uint, toThreadID uint) (int64, error) {
+	if len(fromThreadIDs) == 0 {
+		return 0, nil
+	}
+	result := db.DB().Model(&PrimeEmailContent{}).
+		Where("account_id = ? AND thread_id IN ?", accountID, fromThreadIDs).
+		Update("thread_id", toThreadID)
+	return result.RowsAffected, result.Error
+}
+
+// GetThreadContents 获取会话中的全部邮件，按内容ID升序
+func GetThreadContents(threadID uint) ([]PrimeEmailContent, error) {
+	var contents []PrimeEmailContent
+	err := db.DB().Where("thread_id = ?", threadID).Order("id ASC").Find(&contents).Error
+	return contents, err
+}
+
+// GetContentByID 根据主键获取邮件内容
+func GetContentByID(id uint) (*PrimeEmailContent, error) {
+	var content PrimeEmailContent
+	err := db.DB().Where("id = ?", id).First(&content).Error
+	return &content, err
+}
+
+// GetUnthreadedContents 按内容ID升序获取账号中尚未计算会话的邮件，用于重建历史邮件的会话
+func GetUnthreadedContents(accountID int, afterID uint, limit int) ([]PrimeEmailContent, error) {
+	var contents []PrimeEmailContent
+	err := db.DB().Select(threadColumns).
+		Where("account_id = ? AND thread_id = 0 AND id > ?", accountID, afterID).
+		Order("id ASC").Limit(limit).
+		Find(&contents).Error
+	return contents, err
+}
@@ -0,0 +1,110 @@
+package mailclient
+
+import (
+	"mime"
+	"net/mail"
+	"strings"
+
+	"github.com/emersion/go-imap"
+)
+
+// 邮件地址在邮件中的角色
+const (
+	RoleFrom    = "from"
+	RoleTo      = "to"
+	RoleCc      = "cc"
+	RoleBcc     = "bcc"
+	RoleReplyTo = "reply-to"
+)
+
+// addressHeaders 各角色对应的邮件头
+var addressHeaders = []struct{ role, header string }{
+	{RoleFrom, "From"},
+	{RoleTo, "To"},
+	{RoleCc, "Cc"},
+	{RoleBcc, "Bcc"},
+	{RoleReplyTo, "Reply-To"},
+}
+
+// EmailAddress 结构化的邮件地址
+type EmailAddress struct {
+	Role    string `json:"role"`    // from / to / cc / bcc / reply-to
+	Name    string `json:"name"`    // 解码后的显示名
+	Address string `json:"address"` // 小写的邮件地址
+	Domain  string `json:"domain"`  // 小写的域名
+}
+
+// NormalizeAddress 规范化邮件地址：去掉空白和尖括号并转为小写，返回地址和域名
+func NormalizeAddress(address string) (string, string) {
+	address = strings.ToLower(strings.Trim(strings.TrimSpace(address), "<>"))
+	domain := ""
+	if i := strings.LastIndexByte(address, '@'); i >= 0 {
+		domain = address[i+1:]
+	}
+	return address, domain
+}
+
+// newEmailAddress 创建结构化地址，地址为空时返回false
+func newEmailAddress(role, name, address string) (EmailAddress, bool) {
+	normalized, domain := NormalizeAddress(address)
+	if normalized == "" {
+		return EmailAddress{}, false
+	}
+	return EmailAddress{Role: role, Name: strings.TrimSpace(name), Address: normalized, Domain: domain}, true
+}
+
+// envelopeAddresses 从IMAP ENVELOPE中取出结构化地址，跳过群组语法产生的空地址
+func envelopeAddresses(envelope *imap.Envelope) []EmailAddress {
+	if envelope == nil {
+		return nil
+	}
+	lists := map[string][]*imap.Address{
+		RoleFrom:    envelope.From,
+		RoleTo:      envelope.To,
+		RoleCc:      envelope.Cc,
+		RoleBcc:     envelope.Bcc,
+		RoleReplyTo: envelope.ReplyTo,
+	}
+
+	var result []EmailAddress
+	for _, h := range addressHeaders {
+		for _, addr := range lists[h.role] {
+			if addr == nil || addr.MailboxName == "" || addr.HostName == "" {
+				continue
+			}
+			if a, ok := newEmailAddress(h.role, DecodeMIMESubject(addr.PersonalName), addr.MailboxName+"@"+addr.HostName); ok {
+				result = append(result, a)
+			}
+		}
+	}
+	return result
+}
+
+// addressParser 解析地址列表，显示名中的encoded-word支持GBK等字符集
+var addressParser = &mail.AddressParser{WordDecoder: &mime.WordDecoder{CharsetReader: mimeCharsetReader}}
+
+// headerAddresses 从邮件头中取出结构化地址，邮件头无法按RFC 5322解析时逐个尝试，仍无法解析的忽略
+func headerAddresses(header mail.Header) []EmailAddress {
+	var result []EmailAddress
+	for _, h := range addressHeaders {
+		value := decodeHeaderBytes(header.Get(h.header))
+		if value == "" {
+			continue
+		}
+		addresses, err := addressParser.ParseList(value)
+		if err != nil {
+			addresses = nil
+			for _, item := range strings.Split(value, ",") {
+				if addr, err := addressParser.Parse(strings.TrimSpace(item)); err == nil {
+					addresses = append(addresses, addr)
+				}
+			}
+		}
+		for _, addr := range addresses {
+			if a, ok := newEmailAddress(h.role, addr.Name, addr.Address); ok {
+				result = append(result, a)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package mailclient
+
+import (
+	"net/mail"
+	"testing"
+
+	"github.com/emersion/go-imap"
+)
+
+func TestHeaderAddresses(t *testing.T) {
+	header := mail.Header{
+		// GBK编码的显示名
+		"From":     {"=?gbk?B?1cXI/Q==?= <ZhangSan@Carrier.COM>"},
+		"To":       {"a@x.com, Ops Team <OPS@Y.com>"},
+		"Cc":       {"undisclosed-recipients:;"},
+		"Reply-To": {"broken <<reply@z.com>, ok@z.com"},
+	}
+
+	got := headerAddresses(header)
+	want := []EmailAddress{
+		{Role: RoleFrom, Name: "张三", Address: "zhangsan@carrier.com", Domain: "carrier.com"},
+		{Role: RoleTo, Address: "a@x.com", Domain: "x.com"},
+		{Role: RoleTo, Name: "Ops Team", Address: "ops@y.com", Domain: "y.com"},
+		{Role: RoleReplyTo, Address: "ok@z.com", Domain: "z.com"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("地址数量错误: %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第 %d 个地址应为 %+v，实际 %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestEnvelopeAddresses(t *testing.T) {
+	envelope := &imap.Envelope{
+		From: []*imap.Address{{PersonalName: "=?UTF-8?B?5byg5LiJ?=", MailboxName: "Zhang", HostName: "Example.com"}},
+		Cc: []*imap.Address{
+			{MailboxName: "team"}, // 群组开始标记
+			{MailboxName: "b", HostName: "example.com"},
+			{}, // 群组结束标记
+		},
+	}
+
+	got := envelopeAddresses(envelope)
+	if len(got) != 2 {
+		t.Fatalf("地址数量错误: %+v", got)
+	}
+	if got[0].Name != "张三" || got[0].Address != "zhang@example.com" || got[0].Domain != "example.com" {
+		t.Errorf("发件人解析错误: %+v", got[0])
+	}
+	if got[1].Role != RoleCc || got[1].Address != "b@example.com" {
+		t.Errorf("抄送解析错误: %+v", got[1])
+	}
+}
@@ -0,0 +1,340 @@
+package mailclient
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// 发件人认证结果的来源
+const (
+	AuthSourceHeader = "authentication-results"     // 收件服务器添加的Authentication-Results
+	AuthSourceARC    = "arc-authentication-results" // 转发链路上记录的ARC-Authentication-Results
+)
+
+// SenderAuth 发件人认证结果，取自收件服务器的认证结果邮件头，可选地由离线DKIM验证补充
+type SenderAuth struct {
+	Source      string   `json:"source"`                 // 结果来源，没有可信的认证结果时为空
+	AuthServID  string   `json:"authserv_id"`            // 给出结果的服务器
+	SPF         string   `json:"spf"`                    // pass / fail / softfail / neutral / none / temperror / permerror
+	SPFDomain   string   `json:"spf_domain"`             // smtp.mailfrom（没有时为smtp.helo）的域名
+	DKIM        string   `json:"dkim"`                   // 有签名通过时为pass，否则为第一个签名的结果
+	DKIMDomains []string `json:"dkim_domains,omitempty"` // 验证通过的签名域(header.d)
+	DMARC       string   `json:"dmarc"`
+	ARC         string   `json:"arc"`         // 收件服务器对ARC链的验证结果
+	ARCDMARC    string   `json:"arc_dmarc"`   // ARC链通过时，最后一跳ARC-Authentication-Results中的DMARC结果
+	FromDomain  string   `json:"from_domain"` // 小写的From域名
+
+	// 离线DKIM验证结果，未启用验证时为空
+	DKIMVerify          string       `json:"dkim_verify,omitempty"`
+	DKIMVerifiedDomains []string     `json:"dkim_verified_domains,omitempty"`
+	DKIMSignatures      []DKIMResult `json:"dkim_signatures,omitempty"`
+
+	Suspicious bool `json:"suspicious"` // From域名没有通过对齐检查
+}
+
+// authResultInfo Authentication-Results中的一条结果，如 dkim=pass header.d=example.com
+type authResultInfo struct {
+	method     string
+	result     string
+	properties map[string]string // 键为 ptype.property，如 smtp.mailfrom，小写
+}
+
+// authServIDMissing 服务商可信列表中的特殊值，表示信任最上面一条没有authserv-id的Authentication-Results
+// Microsoft 365/Exchange Online 添加的认证结果不带authserv-id
+const authServIDMissing = "none"
+
+// ApplySenderAuth 按收件邮箱的服务器配置确定可信的认证服务器，重新计算发件人认证结果
+// config 为nil时不采用任何认证结果；离线DKIM验证(VerifyEmailDKIM)的结果会被覆盖，需要在之后进行
+func ApplySenderAuth(email *Email, config *EmailConfigInfo) {
+	email.Auth = parseSenderAuth(email.Headers, fromDomain(email), trustedAuthServIDs(config))
+}
+
+// trustedAuthServIDs 可信的认证服务器：收件邮箱IMAP服务器的组织域名（如 imap.163.com 取 163.com），
+// 加上账号所属服务商配置的 auth_serv_ids；只对该账号生效，不同服务商的邮箱互不信任对方的认证服务器
+func trustedAuthServIDs(config *EmailConfigInfo) []string {
+	if config == nil {
+		return nil
+	}
+	var trusted []string
+	if imapServer := strings.TrimSpace(config.IMAPServer); imapServer != "" {
+		trusted = append(trusted, organizationalDomain(imapServer))
+	}
+	return append(trusted, config.AuthServIDs...)
+}
+
+// parseSenderAuth 从邮件头中取出可信的认证结果并计算From域名是否可疑
+// 只采用 trusted 中的服务器给出的结果，防止发件人自带伪造的认证结果；取其中第一个（最上面，即收件服务器添加的）
+// Authentication-Results，没有时退回到序号最大的ARC-Authentication-Results；都没有时无法判断，不标记为可疑
+// 没有authserv-id的结果只在 trusted 包含 "none" 且位于最上面时采用
+func parseSenderAuth(headers map[string][]string, fromDomain string, trusted []string) SenderAuth {
+	auth := SenderAuth{FromDomain: strings.ToLower(strings.TrimSpace(fromDomain))}
+
+	var results []authResultInfo
+	for i, value := range headers["Authentication-Results"] {
+		servID, infos, ok := parseAuthResultsValue(value, false)
+		if ok && (authServTrusted(servID, trusted) || servID == "" && i == 0 && slices.Contains(trusted, authServIDMissing)) {
+			auth.Source, auth.AuthServID, results = AuthSourceHeader, servID, infos
+			break
+		}
+	}
+	arcServID, arcResults := latestARCResults(headers["Arc-Authentication-Results"], trusted)
+	if auth.Source == "" && arcServID != "" {
+		auth.Source, auth.AuthServID, results = AuthSourceARC, arcServID, arcResults
+	}
+
+	for _, info := range results {
+		switch info.method {
+		case "spf":
+			if auth.SPF != "" {
+				continue
+			}
+			auth.SPF = info.result
+			domain := info.properties["smtp.mailfrom"]
+			if domain == "" {
+				domain = info.properties["smtp.helo"]
+			}
+			auth.SPFDomain = addressDomain(domain)
+		case "dkim", "domainkeys":
+			if auth.DKIM == "" || info.result == "pass" && auth.DKIM != "pass" {
+				auth.DKIM = info.result
+			}
+			if info.result == "pass" {
+				domain := info.properties["header.d"]
+				if domain == "" {
+					domain = addressDomain(info.properties["header.i"])
+				}
+				if domain != "" {
+					auth.DKIMDomains = append(auth.DKIMDomains, strings.ToLower(domain))
+				}
+			}
+		case "dmarc":
+			if auth.DMARC == "" {
+				auth.DMARC = info.result
+			}
+		case "arc":
+			if auth.ARC == "" {
+				auth.ARC = info.result
+			}
+		}
+	}
+	// 经过邮件列表等转发后DKIM签名可能失效，ARC链通过时参考转发前记录的DMARC结果
+	if auth.Source == AuthSourceHeader && auth.ARC == "pass" {
+		for _, info := range arcResults {
+			if info.method == "dmarc" {
+				auth.ARCDMARC = info.result
+				break
+			}
+		}
+	}
+	auth.evaluate()
+	return auth
+}
+
+// latestARCResults 取实例序号(i=)最大的可信ARC-Authentication-Results
+func latestARCResults(values []string, trusted []string) (string, []authResultInfo) {
+	bestInstance := 0
+	var bestServID string
+	var bestResults []authResultInfo
+	for _, value := range values {
+		instance := 0
+		if semi := strings.IndexByte(value, ';'); semi > 0 {
+			if tag, num, ok := strings.Cut(strings.TrimSpace(value[:semi]), "="); ok && strings.EqualFold(strings.TrimSpace(tag), "i") {
+				instance, _ = strconv.Atoi(strings.TrimSpace(num))
+			}
+		}
+		if instance <= bestInstance {
+			continue
+		}
+		servID, infos, ok := parseAuthResultsValue(value, true)
+		if !ok || !authServTrusted(servID, trusted) {
+			continue
+		}
+		bestInstance, bestServID, bestResults = instance, servID, infos
+	}
+	return bestServID, bestResults
+}
+
+// authServTrusted authserv-id 是否为可信服务器或其子域名，没有可信服务器时不采用任何结果
+func authServTrusted(servID string, trusted []string) bool {
+	servID = strings.ToLower(servID)
+	if servID == "" {
+		return false
+	}
+	for _, id := range trusted {
+		id = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(id), "."))
+		if id != "" && id != authServIDMissing && (servID == id || strings.HasSuffix(servID, "."+id)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAuthResultsValue 按RFC 8601解析认证结果邮件头的值，返回authserv-id和各条结果
+// ARC-Authentication-Results 以实例序号 i=N 开头，arc 为 true 时先跳过
+func parseAuthResultsValue(value string, arc bool) (string, []authResultInfo, bool) {
+	parts := splitAuthResults(stripHeaderComments(value))
+	if arc && len(parts) > 0 && strings.HasPrefix(strings.ToLower(strings.TrimSpace(parts[0])), "i=") {
+		parts = parts[1:]
+	}
+	if len(parts) == 0 {
+		return "", nil, false
+	}
+	// authserv-id 后面可能跟着版本号；Microsoft 365 不写authserv-id，第一段就是 spf=pass 这样的结果
+	fields := strings.Fields(parts[0])
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+	servID := ""
+	if !strings.Contains(fields[0], "=") {
+		servID = strings.ToLower(fields[0])
+		parts = parts[1:]
+	}
+
+	var infos []authResultInfo
+	for _, part := range parts {
+		tokens := strings.Fields(part)
+		if len(tokens) == 0 {
+			continue
+		}
+		method, result, ok := strings.Cut(tokens[0], "=")
+		if !ok {
+			// 没有任何结果时写作 "none"
+			continue
+		}
+		// 方法名可能带版本号，如 dkim/1
+		method, _, _ = strings.Cut(method, "/")
+		info := authResultInfo{
+			method:     strings.ToLower(strings.TrimSpace(method)),
+			result:     strings.ToLower(strings.Trim(strings.TrimSpace(result), `"`)),
+			properties: make(map[string]string),
+		}
+		for _, token := range tokens[1:] {
+			key, val, ok := strings.Cut(token, "=")
+			if !ok {
+				continue
+			}
+			info.properties[strings.ToLower(key)] = strings.Trim(val, `"`)
+		}
+		infos = append(infos, info)
+	}
+	return servID, infos, true
+}
+
+// stripHeaderComments 去掉邮件头中括号括起的注释，引号内的括号保留
+func stripHeaderComments(value string) string {
+	var b strings.Builder
+	depth := 0
+	quoted := false
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"' && depth == 0:
+			quoted = !quoted
+		case r == '(' && !quoted:
+			depth++
+			continue
+		case r == ')' && !quoted && depth > 0:
+			depth--
+			continue
+		}
+		if depth == 0 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// splitAuthResults 按引号外的分号拆分认证结果
+func splitAuthResults(value string) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				parts = append(parts, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if rest := strings.TrimSpace(value[start:]); rest != "" {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+// addressDomain 取邮件地址的域名部分，没有@时按域名处理
+func addressDomain(value string) string {
+	value = strings.Trim(strings.TrimSpace(value), "<>")
+	if at := strings.LastIndexByte(value, '@'); at >= 0 {
+		value = value[at+1:]
+	}
+	return strings.ToLower(strings.TrimSuffix(value, "."))
+}
+
+// organizationalDomain 按公共后缀列表取组织域名，如 mail.maersk.com 取 maersk.com
+func organizationalDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if org, err := publicsuffix.EffectiveTLDPlusOne(domain); err == nil {
+		return org
+	}
+	return domain
+}
+
+// domainsAligned 按DMARC宽松模式判断两个域名是否对齐，即组织域名相同
+func domainsAligned(domain, fromDomain string) bool {
+	if domain == "" || fromDomain == "" {
+		return false
+	}
+	return organizationalDomain(domain) == organizationalDomain(fromDomain)
+}
+
+// hasVerdict 结果能说明发件人是否通过了认证，none 和临时错误不算
+func hasVerdict(result string) bool {
+	switch result {
+	case "pass", "fail", "softfail", "neutral", "permerror", "policy":
+		return true
+	}
+	return false
+}
+
+// evaluate 计算From域名是否可疑
+// DMARC通过或有与From域名对齐的SPF/DKIM通过时可信；DMARC失败，或有认证结果但都不对齐时可疑；
+// 没有任何认证结果时无法判断，不标记为可疑
+func (a *SenderAuth) evaluate() {
+	a.Suspicious = false
+	if a.FromDomain == "" || a.DMARC == "pass" || a.ARCDMARC == "pass" {
+		return
+	}
+	if a.SPF == "pass" && domainsAligned(a.SPFDomain, a.FromDomain) {
+		return
+	}
+	for _, domains := range [][]string{a.DKIMDomains, a.DKIMVerifiedDomains} {
+		for _, domain := range domains {
+			if domainsAligned(domain, a.FromDomain) {
+				return
+			}
+		}
+	}
+	a.Suspicious = a.DMARC == "fail" || hasVerdict(a.SPF) || hasVerdict(a.DKIM) || hasVerdict(a.DKIMVerify)
+}
+
+// fromDomain 取第一个发件人地址的域名
+func fromDomain(email *Email) string {
+	for _, addr := range email.Addresses {
+		if addr.Role == RoleFrom {
+			return addr.Domain
+		}
+	}
+	return addressDomain(email.From)
+}
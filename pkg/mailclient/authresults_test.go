@@ -0,0 +1,143 @@
+package mailclient
+
+import (
+	"testing"
+)
+
+func TestParseSenderAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string][]string
+		from       string
+		spf        string
+		dkim       string
+		dmarc      string
+		suspicious bool
+	}{
+		{
+			name: "全部通过",
+			headers: map[string][]string{"Authentication-Results": {
+				`mx.google.com; dkim=pass header.i=@maersk.com header.s=s1 header.b=abc; spf=pass (google.com: domain of x@mail.maersk.com designates 1.2.3.4 as permitted sender) smtp.mailfrom=x@mail.maersk.com; dmarc=pass (p=REJECT sp=REJECT dis=NONE) header.from=maersk.com`,
+			}},
+			from: "maersk.com", spf: "pass", dkim: "pass", dmarc: "pass",
+		},
+		{
+			name: "仿冒船公司域名",
+			headers: map[string][]string{"Authentication-Results": {
+				`mx.example.com; spf=pass smtp.mailfrom=notice@maersk-booking.xyz; dkim=pass header.d=maersk-booking.xyz; dmarc=fail (p=NONE) header.from=maersk.com`,
+			}},
+			from: "maersk.com", spf: "pass", dkim: "pass", dmarc: "fail", suspicious: true,
+		},
+		{
+			name: "没有DMARC结果时按对齐判断",
+			headers: map[string][]string{"Authentication-Results": {
+				`mx.example.com; spf=pass smtp.mailfrom=bounce@sendgrid.net; dkim=none`,
+			}},
+			from: "cosco.com", spf: "pass", dkim: "none", suspicious: true,
+		},
+		{
+			name: "子域名宽松对齐",
+			headers: map[string][]string{"Authentication-Results": {
+				`mx.example.com; spf=pass smtp.mailfrom=bounce@em.cosco.com.cn`,
+			}},
+			from: "cosco.com.cn", spf: "pass",
+		},
+		{
+			name:    "没有认证结果",
+			headers: map[string][]string{},
+			from:    "maersk.com",
+		},
+		{
+			name: "只有ARC时取序号最大的结果",
+			headers: map[string][]string{"Arc-Authentication-Results": {
+				`i=1; mx.first.com; spf=fail smtp.mailfrom=maersk.com`,
+				`i=2; mx.second.com; spf=pass smtp.mailfrom=maersk.com; dmarc=pass header.from=maersk.com`,
+			}},
+			from: "maersk.com", spf: "pass", dmarc: "pass",
+		},
+		{
+			name: "不可信服务器伪造的结果不采用",
+			headers: map[string][]string{
+				"Authentication-Results":     {`evil.example.net; spf=pass smtp.mailfrom=maersk.com; dmarc=pass header.from=maersk.com`},
+				"Arc-Authentication-Results": {`i=1; evil.example.net; dmarc=pass header.from=maersk.com`},
+			},
+			from: "maersk.com",
+		},
+		{
+			name: "经邮件列表转发后ARC链通过",
+			headers: map[string][]string{
+				"Authentication-Results":     {`mx.example.com; dkim=fail header.d=maersk.com; arc=pass (i=1); dmarc=fail header.from=maersk.com`},
+				"Arc-Authentication-Results": {`i=1; lists.example.org; dkim=pass header.d=maersk.com; dmarc=pass header.from=maersk.com`},
+			},
+			from: "maersk.com", dkim: "fail", dmarc: "fail",
+		},
+	}
+	trusted := []string{"mx.google.com", "mx.example.com", "mx.first.com", "mx.second.com", "lists.example.org"}
+	for _, tt := range tests {
+		auth := parseSenderAuth(tt.headers, tt.from, trusted)
+		if auth.SPF != tt.spf || auth.DKIM != tt.dkim || auth.DMARC != tt.dmarc || auth.Suspicious != tt.suspicious {
+			t.Errorf("%s: 结果错误: %+v", tt.name, auth)
+		}
+	}
+}
+
+func TestApplySenderAuthTrustedServer(t *testing.T) {
+	// 发件人自带的伪造结果在最上面，只采用可信服务器给出的结果
+	headers := map[string][]string{"Authentication-Results": {
+		`evil.example.net; spf=pass smtp.mailfrom=maersk.com; dmarc=pass header.from=maersk.com`,
+		`mx.example.com; spf=fail smtp.mailfrom=maersk.com; dmarc=fail header.from=maersk.com`,
+	}}
+	email := &Email{From: "booking@maersk.com", Headers: headers}
+
+	ApplySenderAuth(email, &EmailConfigInfo{IMAPServer: "imap.example.com"})
+	if auth := email.Auth; auth.AuthServID != "mx.example.com" || auth.DMARC != "fail" || !auth.Suspicious {
+		t.Errorf("应采用IMAP服务器所在域的结果: %+v", auth)
+	}
+	ApplySenderAuth(email, nil)
+	if auth := email.Auth; auth.Source != "" || auth.DMARC != "" || auth.Suspicious {
+		t.Errorf("不知道收件邮箱时不应采用任何结果: %+v", auth)
+	}
+}
+
+func TestApplySenderAuthPerProvider(t *testing.T) {
+	// 发件人在邮件中写入了Gmail的认证结果
+	headers := map[string][]string{"Authentication-Results": {
+		`mx.google.com; dmarc=pass header.from=maersk.com`,
+		`mx.163.com; spf=fail smtp.mailfrom=maersk.com; dmarc=fail header.from=maersk.com`,
+	}}
+	email := &Email{From: "booking@maersk.com", Headers: headers}
+
+	// Gmail服务商配置的可信服务器不影响163邮箱
+	ApplySenderAuth(email, &EmailConfigInfo{IMAPServer: "imap.163.com"})
+	if auth := email.Auth; auth.AuthServID != "mx.163.com" || !auth.Suspicious {
+		t.Errorf("163邮箱只应信任163的认证结果: %+v", auth)
+	}
+	ApplySenderAuth(email, &EmailConfigInfo{IMAPServer: "imap.gmail.com", AuthServIDs: []string{"mx.google.com"}})
+	if auth := email.Auth; auth.AuthServID != "mx.google.com" || auth.Suspicious {
+		t.Errorf("Gmail邮箱应信任服务商配置的认证服务器: %+v", auth)
+	}
+}
+
+func TestApplySenderAuthMissingServID(t *testing.T) {
+	// Microsoft 365 添加的认证结果没有authserv-id
+	headers := map[string][]string{"Authentication-Results": {
+		`spf=pass (sender IP is 1.2.3.4) smtp.mailfrom=maersk.com; dkim=pass (signature was verified) header.d=maersk.com;dmarc=pass action=none header.from=maersk.com;compauth=pass reason=100`,
+	}}
+	email := &Email{From: "booking@maersk.com", Headers: headers}
+
+	ApplySenderAuth(email, &EmailConfigInfo{IMAPServer: "outlook.office365.com"})
+	if email.Auth.Source != "" {
+		t.Errorf("未配置none时不应采用没有authserv-id的结果: %+v", email.Auth)
+	}
+	ApplySenderAuth(email, &EmailConfigInfo{IMAPServer: "outlook.office365.com", AuthServIDs: []string{"none"}})
+	if auth := email.Auth; auth.AuthServID != "" || auth.SPF != "pass" || auth.DKIM != "pass" || auth.DMARC != "pass" || auth.Suspicious {
+		t.Errorf("应采用Microsoft 365的认证结果: %+v", auth)
+	}
+
+	// 没有authserv-id的结果不在最上面时可能是发件人伪造的
+	email.Headers = map[string][]string{"Authentication-Results": {`mx.example.net; spf=none`, headers["Authentication-Results"][0]}}
+	ApplySenderAuth(email, &EmailConfigInfo{IMAPServer: "outlook.office365.com", AuthServIDs: []string{"none"}})
+	if email.Auth.Source != "" {
+		t.Errorf("不应采用下面的没有authserv-id的结果: %+v", email.Auth)
+	}
+}
@@ -0,0 +1,126 @@
+package mailclient
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+// FetchResult 批量获取中单封邮件的结果
+type FetchResult struct {
+	UID   uint32
+	Email *Email
+	Err   error // 邮件解析失败或服务器上已不存在
+}
+
+// FetchEmailContents 批量获取同一文件夹中多封邮件的完整内容
+// 只执行一次 SELECT，并用一条 UID FETCH 获取整批邮件，每封邮件到达后立即解析并交给 handle；
+// 服务器没有返回的UID会以“邮件不存在”错误回调。连接中断时只重试尚未回调的UID
+func (m *MailClient) FetchEmailContents(folder string, uids []uint32, skipAttachments bool, handle func(FetchResult)) error {
+	if folder == "" {
+		folder = "INBOX"
+	}
+
+	pending := make(map[uint32]bool, len(uids))
+	for _, uid := range uids {
+		pending[uid] = true
+	}
+
+	maxRetries := 3
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		remaining := make([]uint32, 0, len(pending))
+		for uid := range pending {
+			remaining = append(remaining, uid)
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+		sort.Slice(remaining, func(i, j int) bool { return remaining[i] < remaining[j] })
+
+		err := m.tryFetchEmailContents(folder, remaining, skipAttachments, func(result FetchResult) {
+			delete(pending, result.UID)
+			handle(result)
+		})
+		if err == nil {
+			return nil
+		}
+
+		if isConnectionError(err) || isWrappedConnectionError(err) {
+			log.Printf("[批量获取] 连接错误 (尝试 %d/%d): 文件夹=%s, 剩余 %d 封, 错误: %v",
+				attempt, maxRetries, folder, len(pending), err)
+			if attempt < maxRetries {
+				time.Sleep(time.Second * time.Duration(attempt*2))
+				continue
+			}
+		}
+		return err
+	}
+	return fmt.Errorf("批量获取邮件内容失败，已重试 %d 次", maxRetries)
+}
+
+// tryFetchEmailContents 批量获取邮件内容（单次）
+func (m *MailClient) tryFetchEmailContents(folder string, uids []uint32, skipAttachments bool, handle func(FetchResult)) (err error) {
+	pc, err := m.acquireIMAP()
+	if err != nil {
+		return err
+	}
+	defer func() { ReleaseIMAP(pc, err) }()
+	c := pc.Client
+
+	mbox, err := c.Select(folder, true)
+	if err != nil {
+		return fmt.Errorf("选择邮箱失败: %w", err)
+	}
+	if mbox.Messages == 0 {
+		for _, uid := range uids {
+			handle(FetchResult{UID: uid, Err: fmt.Errorf("邮件不存在: UID=%d 在邮箱 %s 中未找到", uid, folder)})
+		}
+		return nil
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+
+	section := &imap.BodySectionName{Peek: true}
+	items := []imap.FetchItem{imap.FetchUid, imap.FetchEnvelope, imap.FetchInternalDate, imap.FetchFlags, imap.FetchBodyStructure, section.FetchItem()}
+
+	messages := make(chan *imap.Message, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqSet, items, messages)
+	}()
+
+	fetchStart := time.Now()
+	received := make(map[uint32]bool, len(uids))
+	for msg := range messages {
+		if msg.Uid == 0 || msg.Envelope == nil || msg.BodyStructure == nil {
+			// 其他会话修改标记时服务器可能推送不完整的FETCH响应
+			continue
+		}
+		received[msg.Uid] = true
+		email, parseErr := m.parseFetchedMessage(msg, section, skipAttachments)
+		handle(FetchResult{UID: msg.Uid, Email: email, Err: parseErr})
+	}
+
+	if err := <-done; err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "bad sequence") {
+			pc.broken = true
+			return fmt.Errorf("connection error: bad sequence detected, connection reset: %w", err)
+		}
+		return fmt.Errorf("批量获取邮件内容失败: %w", err)
+	}
+
+	// UID FETCH 对不存在的UID不返回任何响应
+	for _, uid := range uids {
+		if !received[uid] {
+			handle(FetchResult{UID: uid, Err: fmt.Errorf("邮件不存在: UID=%d 在邮箱 %s 中未找到", uid, folder)})
+		}
+	}
+
+	log.Printf("[批量获取] 文件夹 %s 获取 %d/%d 封邮件，耗时: %v", folder, len(received), len(uids), time.Since(fetchStart))
+	return nil
+}
@@ -0,0 +1,183 @@
+package mailclient
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// fakeIMAPServer 按脚本应答 EXAMINE 和 UID FETCH 的IMAP服务器，连接建立后直接处于已认证状态
+type fakeIMAPServer struct {
+	mu       sync.Mutex
+	messages map[uint32]string // UID → 原始邮件
+	// dropFetches 前几次 UID FETCH 只返回第一封邮件就断开连接
+	dropFetches int
+	fetches     int
+	conns       []net.Conn
+}
+
+func (s *fakeIMAPServer) dial(config *EmailConfigInfo) (*client.Client, error) {
+	serverConn, clientConn := net.Pipe()
+	s.mu.Lock()
+	s.conns = append(s.conns, serverConn)
+	s.mu.Unlock()
+	go s.serve(serverConn)
+	return client.New(clientConn)
+}
+
+func (s *fakeIMAPServer) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *fakeIMAPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	w := bufio.NewWriter(conn)
+	reply := func(format string, args ...interface{}) bool {
+		fmt.Fprintf(w, format, args...)
+		return w.Flush() == nil
+	}
+	if !reply("* PREAUTH fake server ready\r\n") {
+		return
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		tag, command := fields[0], strings.ToUpper(fields[1])
+		switch {
+		case command == "CAPABILITY":
+			reply("* CAPABILITY IMAP4rev1\r\n%s OK done\r\n", tag)
+		case command == "EXAMINE" || command == "SELECT":
+			s.mu.Lock()
+			count := len(s.messages)
+			s.mu.Unlock()
+			reply("* %d EXISTS\r\n* OK [UIDVALIDITY 7] ok\r\n%s OK [READ-ONLY] done\r\n", count, tag)
+		case command == "UID" && len(fields) > 3 && strings.ToUpper(fields[2]) == "FETCH":
+			seqSet, err := imap.ParseSeqSet(fields[3])
+			if err != nil {
+				reply("%s BAD invalid set\r\n", tag)
+				continue
+			}
+			s.mu.Lock()
+			s.fetches++
+			drop := s.fetches <= s.dropFetches
+			s.mu.Unlock()
+			seq := 0
+			for uid := uint32(1); uid <= 1000; uid++ {
+				raw, ok := s.messages[uid]
+				if !ok || !seqSet.Contains(uid) {
+					continue
+				}
+				seq++
+				reply("* %d FETCH (UID %d FLAGS (\\Seen) INTERNALDATE \"01-Jan-2024 10:00:00 +0000\" "+
+					"ENVELOPE (\"Mon, 1 Jan 2024 10:00:00 +0000\" \"SO%d\" ((\"Carrier\" NIL \"booking\" \"carrier.com\")) NIL NIL "+
+					"((NIL NIL \"ops\" \"example.com\")) NIL NIL NIL \"<m%d@carrier.com>\") "+
+					"BODYSTRUCTURE (\"TEXT\" \"PLAIN\" (\"CHARSET\" \"utf-8\") NIL NIL \"7BIT\" %d 1) BODY[] {%d}\r\n%s)\r\n",
+					seq, uid, uid, uid, len(raw), len(raw), raw)
+				if drop {
+					return
+				}
+			}
+			reply("%s OK UID FETCH completed\r\n", tag)
+		case command == "LOGOUT":
+			reply("* BYE\r\n%s OK done\r\n", tag)
+			return
+		default:
+			reply("%s OK done\r\n", tag)
+		}
+	}
+}
+
+// useFakeIMAP 让连接池通过假服务器建立连接
+func useFakeIMAP(t *testing.T, server *fakeIMAPServer) {
+	t.Helper()
+	origDial, origPool := dialIMAP, globalPool
+	dialIMAP = server.dial
+	globalPool = newConnectionPool(func() poolSettings {
+		return poolSettings{maxPerAccount: 2, waitTimeout: time.Second, idleTimeout: time.Minute, healthCheckAfter: time.Minute}
+	})
+	t.Cleanup(func() {
+		server.close()
+		dialIMAP, globalPool = origDial, origPool
+	})
+}
+
+func fakeRawEmail(uid uint32) string {
+	return fmt.Sprintf("From: Carrier <booking@carrier.com>\r\nTo: ops@example.com\r\nSubject: SO%d\r\n"+
+		"Message-ID: <m%d@carrier.com>\r\nContent-Type: text/plain; charset=utf-8\r\n\r\nBooking %d confirmed\r\n", uid, uid, uid)
+}
+
+func newFakeClient() *MailClient {
+	return NewMailClient(&EmailConfigInfo{EmailAddress: "ops@example.com", IMAPServer: "imap.example.com"})
+}
+
+func TestFetchEmailContentsReportsMissing(t *testing.T) {
+	server := &fakeIMAPServer{messages: map[uint32]string{101: fakeRawEmail(101), 102: fakeRawEmail(102)}}
+	useFakeIMAP(t, server)
+
+	results := make(map[uint32]FetchResult)
+	err := newFakeClient().FetchEmailContents("INBOX", []uint32{101, 102, 103}, false, func(result FetchResult) {
+		results[result.UID] = result
+	})
+	if err != nil {
+		t.Fatalf("批量获取失败: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("每封邮件都应回调一次: %+v", results)
+	}
+	for _, uid := range []uint32{101, 102} {
+		if r := results[uid]; r.Err != nil || r.Email == nil || r.Email.Subject != fmt.Sprintf("SO%d", uid) ||
+			!strings.Contains(r.Email.Body, "confirmed") {
+			t.Errorf("UID %d 结果错误: %+v", uid, r)
+		}
+	}
+	if r := results[103]; r.Err == nil || !strings.Contains(r.Err.Error(), "邮件不存在") {
+		t.Errorf("不存在的UID应返回邮件不存在: %+v", r)
+	}
+}
+
+func TestFetchEmailContentsRetriesRemainingAfterDrop(t *testing.T) {
+	server := &fakeIMAPServer{
+		messages:    map[uint32]string{101: fakeRawEmail(101), 102: fakeRawEmail(102)},
+		dropFetches: 1,
+	}
+	useFakeIMAP(t, server)
+
+	var got []uint32
+	err := newFakeClient().FetchEmailContents("INBOX", []uint32{101, 102}, false, func(result FetchResult) {
+		if result.Err != nil {
+			t.Errorf("UID %d 不应失败: %v", result.UID, result.Err)
+		}
+		got = append(got, result.UID)
+	})
+	if err != nil {
+		t.Fatalf("连接断开后应重试成功: %v", err)
+	}
+	// 第一次只收到101，重试时只获取剩下的102，每封邮件只回调一次
+	if len(got) != 2 || got[0] != 101 || got[1] != 102 {
+		t.Errorf("回调顺序错误: %v", got)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.fetches != 2 {
+		t.Errorf("期望获取2次，实际 %d", server.fetches)
+	}
+}
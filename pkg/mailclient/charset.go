@@ -0,0 +1,398 @@
+package mailclient
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/transform"
+)
+
+// CharsetResult 文本内容字符集的判定结果，用于诊断
+type CharsetResult struct {
+	Declared   string  `json:"declared,omitempty"`   // 邮件中声明的字符集（小写），未声明时为空
+	Used       string  `json:"used,omitempty"`       // 实际用于解码的字符集
+	Detected   bool    `json:"detected,omitempty"`   // 声明缺失、未知或与内容不符，按内容检测
+	Confidence float64 `json:"confidence,omitempty"` // 检测结果的置信度，0~1
+}
+
+// String 诊断用的描述，如 gbk 或 gbk(检测,声明:iso-8859-1,置信度:0.86)
+func (r CharsetResult) String() string {
+	if !r.Detected {
+		return r.Used
+	}
+	declared := r.Declared
+	if declared == "" {
+		declared = "无"
+	}
+	return fmt.Sprintf("%s(检测,声明:%s,置信度:%.2f)", r.Used, declared, r.Confidence)
+}
+
+// charsetAliases 邮件中常见但字符集索引不认识的名称
+var charsetAliases = map[string]string{
+	"utf8":        "utf-8",
+	"gb2312":      "gbk", // GBK是GB2312的超集，按GB2312声明的邮件经常含有GBK字符
+	"gb_2312":     "gbk",
+	"gb_2312-80":  "gbk",
+	"x-gbk":       "gbk",
+	"cp936":       "gbk",
+	"ms936":       "gbk",
+	"windows-936": "gbk",
+	"cp950":       "big5",
+	"x-big5":      "big5",
+	"cp932":       "shift_jis",
+	"ms932":       "shift_jis",
+	"windows-31j": "shift_jis",
+	"sjis":        "shift_jis",
+	"cp949":       "euc-kr",
+	"ks_c_5601":   "euc-kr",
+	"ascii":       "us-ascii",
+	"latin1":      "iso-8859-1",
+}
+
+// latinCharsets 单字节西文字符集，中文邮件经常被错误地标成这些字符集
+var latinCharsets = map[string]bool{
+	"us-ascii":     true,
+	"iso-8859-1":   true,
+	"iso-8859-15":  true,
+	"windows-1252": true,
+}
+
+// normalizeCharset 规范化字符集名称：小写、去掉引号并处理常见别名
+func normalizeCharset(charset string) string {
+	charset = strings.ToLower(strings.Trim(strings.TrimSpace(charset), `"'`))
+	if alias, ok := charsetAliases[charset]; ok {
+		return alias
+	}
+	return charset
+}
+
+// lookupEncoding 按字符集名称查找编码，先查IANA名称再查WHATWG标签
+func lookupEncoding(charset string) encoding.Encoding {
+	if e, err := ianaindex.MIME.Encoding(charset); err == nil && e != nil {
+		return e
+	}
+	if e, err := htmlindex.Get(charset); err == nil && e != nil {
+		return e
+	}
+	return nil
+}
+
+// decodeText 把文本内容转换为UTF-8，返回内容和字符集判定结果
+// 声明的字符集缺失、未知、与内容明显不符（如GBK内容标成ISO-8859-1、UTF-8内容标成GBK）时按内容检测
+func decodeText(data []byte, declared string) (string, CharsetResult) {
+	result := CharsetResult{Declared: normalizeCharset(declared)}
+	charset := result.Declared
+
+	if !hasHighBytes(data) && !bytes.Contains(data, []byte("\x1b$")) && !strings.HasPrefix(charset, "utf-16") {
+		// 纯ASCII内容按任何兼容ASCII的字符集解码结果都相同
+		result.Used = charset
+		if result.Used == "" {
+			result.Used = "us-ascii"
+		}
+		return string(data), result
+	}
+
+	e := lookupEncoding(charset)
+	switch {
+	case charset == "utf-8" && utf8.Valid(data):
+		result.Used = charset
+		return string(data), result
+	case charset == "utf-8", e == nil:
+		// 声明为UTF-8但内容不合法，或未声明、未知的字符集
+	case latinCharsets[charset]:
+		// 西文字符集对任何字节都能解码，只有检测结果足够可信时才替换
+		if detected, confidence := DetectCharset(data); detected != "windows-1252" && confidence >= 0.5 {
+			return decodeDetected(data, result, detected, confidence)
+		}
+		result.Used = charset
+		return decodeWith(data, e), result
+	case isMultibyteCharset(charset):
+		if utf8.Valid(data) {
+			// 中日韩双字节内容几乎不可能恰好是合法的UTF-8
+			return decodeDetected(data, result, "utf-8", 1)
+		}
+		text := decodeWith(data, e)
+		if !tooManyReplacements(text) {
+			result.Used = charset
+			return text, result
+		}
+	default:
+		result.Used = charset
+		return decodeWith(data, e), result
+	}
+
+	detected, confidence := DetectCharset(data)
+	return decodeDetected(data, result, detected, confidence)
+}
+
+// tooManyReplacements 解码结果中的替换字符超过非ASCII字符的2%，说明声明的字符集与内容不符
+func tooManyReplacements(text string) bool {
+	total, replaced := 0, 0
+	for _, r := range text {
+		if r < 0x80 {
+			continue
+		}
+		total++
+		if r == utf8.RuneError {
+			replaced++
+		}
+	}
+	return replaced*50 > total
+}
+
+// decodeDetected 按检测出的字符集解码
+func decodeDetected(data []byte, result CharsetResult, detected string, confidence float64) (string, CharsetResult) {
+	result.Used = detected
+	result.Detected = true
+	result.Confidence = confidence
+	if result.Declared != "" {
+		log.Printf("[字符集检测] 声明的字符集 %s 与内容不符或无法识别，按检测结果 %s 解码，置信度: %.2f", result.Declared, detected, confidence)
+	}
+	if detected == "utf-8" || detected == "us-ascii" {
+		return string(data), result
+	}
+	return decodeWith(data, lookupEncoding(detected)), result
+}
+
+// decodeWith 使用指定编码解码，编码为空或转换失败时原样返回
+func decodeWith(data []byte, e encoding.Encoding) string {
+	if e == nil {
+		return string(data)
+	}
+	out, _, err := transform.Bytes(e.NewDecoder(), data)
+	if err != nil {
+		return string(data)
+	}
+	return string(out)
+}
+
+// decodeHeaderBytes 未按RFC 2047编码、直接写入8位字节的邮件头按内容检测字符集
+func decodeHeaderBytes(value string) string {
+	if utf8.ValidString(value) {
+		return value
+	}
+	text, _ := decodeText([]byte(value), "")
+	return text
+}
+
+// isMultibyteCharset 是否为中日韩双字节字符集
+func isMultibyteCharset(charset string) bool {
+	switch charset {
+	case "gbk", "gb18030", "big5", "big5-hkscs", "shift_jis", "euc-jp", "euc-kr":
+		return true
+	}
+	return false
+}
+
+// hasHighBytes 是否含有非ASCII字节
+func hasHighBytes(data []byte) bool {
+	for _, b := range data {
+		if b >= 0x80 {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlMetaCharsetPattern HTML中 <meta charset="gbk"> 或 <meta http-equiv content="text/html; charset=gbk"> 声明的字符集
+var htmlMetaCharsetPattern = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_.:-]+)`)
+
+// htmlMetaCharset 取HTML开头部分meta标签声明的字符集
+func htmlMetaCharset(data []byte) string {
+	if len(data) > 2048 {
+		data = data[:2048]
+	}
+	if m := htmlMetaCharsetPattern.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// charsetCandidate 统计检测的候选字符集
+type charsetCandidate struct {
+	name     string
+	encoding encoding.Encoding
+	valid    func(data []byte) (valid, invalid int) // 统计合法和非法的多字节序列数
+	score    func(text string) float64              // 解码后常用字符的比例
+}
+
+var charsetCandidates = []charsetCandidate{
+	{"gb18030", simplifiedchinese.GB18030, validGB18030, func(text string) float64 { return commonRatio(text, commonSimplified) }},
+	{"big5", traditionalchinese.Big5, validBig5, func(text string) float64 { return commonRatio(text, commonTraditional) }},
+	{"shift_jis", japanese.ShiftJIS, validShiftJIS, japaneseScore},
+	{"euc-kr", korean.EUCKR, validEUCKR, func(text string) float64 { return commonRatio(text, commonHangul) }},
+}
+
+// DetectCharset 按内容统计检测字符集，返回字符集名称和置信度（0~1）
+// 先排除字节结构不合法的中日韩编码，再比较解码后常用字的比例；都不可信时视为windows-1252
+func DetectCharset(data []byte) (string, float64) {
+	if !hasHighBytes(data) {
+		if bytes.Contains(data, []byte("\x1b$")) {
+			return "iso-2022-jp", 1
+		}
+		return "us-ascii", 1
+	}
+	if utf8.Valid(data) {
+		return "utf-8", 1
+	}
+
+	best, bestScore := "", 0.0
+	for _, c := range charsetCandidates {
+		valid, invalid := c.valid(data)
+		// 允许个别非法序列（如截断的结尾）
+		if valid == 0 || invalid*50 > valid {
+			continue
+		}
+		score := c.score(decodeWith(data, c.encoding))
+		if c.name == "gb18030" && score > 0 {
+			// 供应商邮件以简体中文为主，得分接近时优先GBK
+			score += 0.1
+		}
+		score *= float64(valid) / float64(valid+invalid)
+		if score > bestScore {
+			best, bestScore = c.name, score
+		}
+	}
+	if best == "" || bestScore < 0.2 {
+		return "windows-1252", 0.3
+	}
+	if bestScore > 1 {
+		bestScore = 1
+	}
+	return best, bestScore
+}
+
+// commonRatio 非ASCII字符中属于常用字集合（含全角标点）的比例
+func commonRatio(text string, common string) float64 {
+	total, hits := 0, 0
+	for _, r := range text {
+		if r < 0x80 {
+			continue
+		}
+		total++
+		if strings.ContainsRune(common, r) || strings.ContainsRune(cjkPunctuation, r) {
+			hits++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// japaneseScore 全角假名和日文标点的比例，常用汉字按一半计算
+func japaneseScore(text string) float64 {
+	total, score := 0, 0.0
+	for _, r := range text {
+		if r < 0x80 {
+			continue
+		}
+		total++
+		switch {
+		case r >= 0x3040 && r <= 0x30ff, strings.ContainsRune(cjkPunctuation, r):
+			score++
+		case strings.ContainsRune(commonSimplified, r) || strings.ContainsRune(commonTraditional, r):
+			score += 0.5
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return score / float64(total)
+}
+
+// validGB18030 按GBK/GB18030的字节结构统计：双字节首字节81-FE、尾字节40-7E/80-FE，四字节为 81-FE 30-39 81-FE 30-39
+func validGB18030(data []byte) (valid, invalid int) {
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b < 0x80:
+		case b == 0x80 || b == 0xff:
+			invalid++
+		case i+1 < len(data) && (data[i+1] >= 0x40 && data[i+1] <= 0x7e || data[i+1] >= 0x80 && data[i+1] <= 0xfe):
+			valid++
+			i++
+		case i+3 < len(data) && data[i+1] >= 0x30 && data[i+1] <= 0x39 && data[i+2] >= 0x81 && data[i+2] <= 0xfe && data[i+3] >= 0x30 && data[i+3] <= 0x39:
+			valid++
+			i += 3
+		default:
+			invalid++
+		}
+	}
+	return valid, invalid
+}
+
+// validBig5 按Big5的字节结构统计：首字节81-FE，尾字节40-7E/A1-FE
+func validBig5(data []byte) (valid, invalid int) {
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b < 0x80:
+		case b >= 0x81 && b <= 0xfe && i+1 < len(data) && (data[i+1] >= 0x40 && data[i+1] <= 0x7e || data[i+1] >= 0xa1 && data[i+1] <= 0xfe):
+			valid++
+			i++
+		default:
+			invalid++
+		}
+	}
+	return valid, invalid
+}
+
+// validShiftJIS 按Shift_JIS的字节结构统计：A1-DF为半角片假名，首字节81-9F/E0-FC，尾字节40-7E/80-FC
+func validShiftJIS(data []byte) (valid, invalid int) {
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b < 0x80:
+		case b >= 0xa1 && b <= 0xdf:
+			// 半角片假名在正常日文邮件中很少见，不计入合法序列
+		case (b >= 0x81 && b <= 0x9f || b >= 0xe0 && b <= 0xfc) && i+1 < len(data) &&
+			(data[i+1] >= 0x40 && data[i+1] <= 0x7e || data[i+1] >= 0x80 && data[i+1] <= 0xfc):
+			valid++
+			i++
+		default:
+			invalid++
+		}
+	}
+	return valid, invalid
+}
+
+// validEUCKR 按CP949（EUC-KR扩展）的字节结构统计：首字节81-FE，尾字节41-5A/61-7A/81-FE
+func validEUCKR(data []byte) (valid, invalid int) {
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b < 0x80:
+		case b >= 0x81 && b <= 0xfe && i+1 < len(data) &&
+			(data[i+1] >= 0x41 && data[i+1] <= 0x5a || data[i+1] >= 0x61 && data[i+1] <= 0x7a || data[i+1] >= 0x81 && data[i+1] <= 0xfe):
+			valid++
+			i++
+		default:
+			invalid++
+		}
+	}
+	return valid, invalid
+}
+
+// cjkPunctuation 中日韩全角标点
+const cjkPunctuation = "，。、：；？！“”‘’（）《》【】「」『』…—～·　"
+
+// commonSimplified 常用简体汉字，包括外贸和物流邮件中的常用字
+const commonSimplified = "的一是不了人我在有他这为之大来以个中上们到说国和地也子时道出而要于就下得可你年生自会那后能对着事其里所去行过家十用发天如然作方成者多日都三小军二无同么经法当起与好看学进种将还分此心前面又定见只主没公从已知最现部本新加问通月高外制量重因两开情文全立做实理结各义路由等美些意力明内期特位应正提更使平样长品接报利资数它次合体务按给东系请您谢附件邮收确认联电话传真址客户产价格金额单号货物运船港口箱柜费票款付签约订询需求送达关检验仓库清提供计划安排审核批准支持负责协调处理问题回复谢谢贵司我司工厂装卸航班预计截止目的起运海空陆保险包装尺寸重毛净件数托盘集装整拼报关税率汇币美元人民币元万千百零壹贰叁肆伍陆柒捌玖拾佰仟总共计小张李王刘陈杨黄赵吴周徐孙马朱胡郭何林罗高郑梁谢宋唐许韩冯邓曹彭曾肖田董袁潘于蒋蔡余杜叶程苏魏吕丁任沈姚卢姜崔钟谭陆汪范金石廖贾夏韦付方白邹孟熊秦邱江尹薛闫段雷侯龙史陶黎贺顾毛郝龚邵万钱严覃武戴莫孔向汤测试先生女士经理总监主管部门市场销售采购财务行政人事技术质量生产办公室今明昨周星期上午下午晚早点分秒请问能否尽快急紧最好希望感谢抱歉麻烦帮忙一下看到收到发送转发抄送附上详见如下以下上述相关信息资料文件合同发票清单证书样品订单报价单装箱单提单"
+
+// commonTraditional 常用繁体汉字
+const commonTraditional = "的一是不了人我在有他這為之大來以個中上們到說國和地也子時道出而要於就下得可你年生自會那後能對著事其裡所去行過家十用發天如然作方成者多日都三小軍二無同麼經法當起與好看學進種將還分此心前面又定見只主沒公從已知最現部本新加問通月高外製量重因兩開情文全立做實理結各義路由等美些意力明內期特位應正提更使平樣長品接報利資數它次合體務按給東係請您謝附件郵收確認聯電話傳真址客戶產價格金額單號貨物運船港口箱櫃費票款付簽約訂詢需求送達關檢驗倉庫清提供計劃安排審核批准支持負責協調處理問題回覆復謝謝貴司我司工廠裝卸航班預計截止目的起運海空陸保險包裝尺寸重毛淨件數托盤集裝整拼報關稅率匯幣美元台幣港幣元萬千百零總共計小張李王劉陳楊黃趙吳周徐孫馬朱胡郭何林羅高鄭梁謝宋唐許韓馮鄧曹彭曾蕭田董袁潘於蔣蔡余杜葉程蘇魏呂丁任沈姚盧姜崔鍾譚陸汪范金石廖賈夏韋付方白鄒孟熊秦邱江尹薛閆段雷侯龍史陶黎賀顧毛郝龔邵萬錢嚴覃武戴莫孔向湯測試先生女士經理總監主管部門市場銷售採購財務行政人事技術質量品質生產辦公室今明昨週星期上午下午晚早點分秒請問能否盡快急緊最好希望感謝抱歉麻煩幫忙一下看到收到發送轉發抄送附上詳見如下以下上述相關信息資訊資料文件合同發票清單證書樣品訂單報價單裝箱單提單"
+
+// commonHangul 常用韩文音节
+const commonHangul = "이다는의에하고을를가지기서사로한도정자리국대인시수나보어아일적있것들그게되면요해주니만으로과와께전신상부위제문동방회공말내합경계업용성간관장생연실더같없및중소무여습니까세요께서드립감사합니다안녕하십확인부탁견적주문배송선박항구컨테이너출발도착예정일정"
@@ -0,0 +1,109 @@
+package mailclient
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// mustEncode 把UTF-8文本编码为指定字符集，构造测试数据
+func mustEncode(t *testing.T, e encoding.Encoding, text string) []byte {
+	t.Helper()
+	out, err := e.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("编码测试数据失败: %v", err)
+	}
+	return out
+}
+
+func TestDetectCharset(t *testing.T) {
+	cases := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"GBK", mustEncode(t, simplifiedchinese.GBK, "您好，附件是本周的报价单，请查收并确认。谢谢！"), "gb18030"},
+		{"Big5", mustEncode(t, traditionalchinese.Big5, "您好，附件是本週的報價單，請查收並確認。謝謝！"), "big5"},
+		{"Shift_JIS", mustEncode(t, japanese.ShiftJIS, "お世話になっております。見積書を送付いたしますので、ご確認ください。"), "shift_jis"},
+		{"EUC-KR", mustEncode(t, korean.EUCKR, "안녕하십니까. 견적서를 보내드립니다. 확인 부탁드립니다."), "euc-kr"},
+		{"Latin", mustEncode(t, charmap.Windows1252, "Société Générale, café crème à Zürich"), "windows-1252"},
+		{"UTF-8", []byte("您好，报价单"), "utf-8"},
+		{"ASCII", []byte("hello"), "us-ascii"},
+	}
+	for _, c := range cases {
+		if got, confidence := DetectCharset(c.data); got != c.expected {
+			t.Errorf("%s: 检测为 %s(%.2f)，应为 %s", c.name, got, confidence, c.expected)
+		}
+	}
+}
+
+func TestDecodeTextMislabeled(t *testing.T) {
+	text := "您好，附件是本周的报价单，请查收。"
+	gbk := mustEncode(t, simplifiedchinese.GBK, text)
+
+	for _, declared := range []string{"", "iso-8859-1", "x-unknown"} {
+		got, result := decodeText(gbk, declared)
+		if got != text {
+			t.Errorf("声明 %q 的GBK内容解码错误: %q", declared, got)
+		}
+		if !result.Detected || result.Used != "gb18030" {
+			t.Errorf("声明 %q 的判定结果错误: %+v", declared, result)
+		}
+	}
+
+	// UTF-8内容标成GBK
+	if got, result := decodeText([]byte(text), "gb2312"); got != text || result.Used != "utf-8" || !result.Detected {
+		t.Errorf("标成GB2312的UTF-8内容解码错误: %q, %+v", got, result)
+	}
+	// 声明正确时不检测
+	if got, result := decodeText(gbk, "GBK"); got != text || result.Detected || result.Used != "gbk" {
+		t.Errorf("声明正确的GBK内容解码错误: %q, %+v", got, result)
+	}
+	// 真正的西文内容保留声明
+	latin := mustEncode(t, charmap.ISO8859_1, "café crème")
+	if got, result := decodeText(latin, "iso-8859-1"); got != "café crème" || result.Detected {
+		t.Errorf("西文内容解码错误: %q, %+v", got, result)
+	}
+}
+
+func TestDecodeUnlabeledParts(t *testing.T) {
+	gbkBody := mustEncode(t, simplifiedchinese.GBK, "<html><head><meta http-equiv=\"Content-Type\" content=\"text/html; charset=gb2312\"></head><body>请查收报价单</body></html>")
+	gbkName := mustEncode(t, simplifiedchinese.GBK, "报价单.pdf")
+	gbkSubject := mustEncode(t, simplifiedchinese.GBK, "关于货物运输的报价")
+
+	raw := joinLines(
+		"From: a@example.com",
+		"Subject: "+string(gbkSubject),
+		`Content-Type: multipart/mixed; boundary="b"`,
+		"",
+		"--b",
+		"Content-Type: text/html",
+		"",
+		string(gbkBody),
+		"--b",
+		`Content-Type: application/pdf; name="`+string(gbkName)+`"`,
+		"Content-Transfer-Encoding: base64",
+		"",
+		"JVBERi0xLjQK",
+		"--b--",
+	)
+
+	email, err := ParseRawEmail(raw, false)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if email.Subject != "关于货物运输的报价" {
+		t.Errorf("未编码的GBK主题解码错误: %q", email.Subject)
+	}
+	if email.Charset.Used != "gbk" || email.Charset.Declared != "gbk" {
+		t.Errorf("HTML的meta字符集未生效: %+v", email.Charset)
+	}
+	if len(email.Attachments) != 1 || email.Attachments[0].Filename != "报价单.pdf" {
+		t.Errorf("未编码的GBK文件名解码错误: %+v", email.Attachments)
+	}
+}
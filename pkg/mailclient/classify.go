@@ -0,0 +1,88 @@
+package mailclient
+
+import (
+	"go_email/model"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+var (
+	// autoReplySubjectPattern 自动回复的主题前缀
+	autoReplySubjectPattern = regexp.MustCompile(`(?i)^\s*(auto(matic)?[\s-]?(reply|response|answer)|autoreply|auto\s*:|自动回复|自动答复|自動回覆|自動回复|自动应答|réponse automatique|automatische antwort)`)
+	// outOfOfficeSubjectPattern 外出/休假自动回复的主题，Outlook的外出答复主题为“Automatic reply:/自动答复:”
+	outOfOfficeSubjectPattern = regexp.MustCompile(`(?i)(^\s*(automatic reply|自动答复)\s*[:：]|out of (the )?office|\booo\b|on (annual |sick |maternity )?leave|on vacation|on holiday|away from (the )?office|abwesenheit|absence|休假|外出|不在办公室|年假|请假|出差中|放假)`)
+	// outOfOfficePrefixPattern 不带自动回复邮件头时也可确定为外出回复的主题前缀
+	outOfOfficePrefixPattern = regexp.MustCompile(`(?i)^\s*(out of (the )?office|ooo|休假通知|外出通知)\s*[:：\-]`)
+	// bulkSubjectPattern 群发/营销邮件的主题，包括按规定标注的“广告”
+	bulkSubjectPattern = regexp.MustCompile(`(?i)(^\s*[(（【\[]\s*(广告|ad|advertisement)\s*[)）】\]]|\bnewsletter\b|电子期刊|订阅快讯)`)
+)
+
+// bulkMarkerHeaders 群发平台添加的邮件头
+var bulkMarkerHeaders = []string{"List-Unsubscribe", "X-Campaign", "X-Campaign-Id", "X-Campaignid", "X-Mc-User", "X-Csa-Complaints", "X-Mailgun-Campaign-Id"}
+
+// mailingListHeaders 邮件列表(RFC 2369/2919)添加的邮件头
+var mailingListHeaders = []string{"List-Id", "List-Post", "Mailing-List", "X-Mailing-List"}
+
+// ClassifyEmail 按邮件头和主题把邮件分类为人工、自动回复、外出回复、邮件列表、群发或退信，返回 model.EmailType* 常量
+// headers 为全部邮件头（键不区分大小写），只依据邮件头和主题，不读取正文，可用于保存的历史邮件
+// 系统通知（如承运商的订舱确认）没有列表或群发标记时仍归为人工，避免下游漏处理
+func ClassifyEmail(headers map[string][]string, subject string) int {
+	get := func(key string) string {
+		if values := headers[textproto.CanonicalMIMEHeaderKey(key)]; len(values) > 0 {
+			return strings.TrimSpace(values[0])
+		}
+		for k, values := range headers {
+			if strings.EqualFold(k, key) && len(values) > 0 {
+				return strings.TrimSpace(values[0])
+			}
+		}
+		return ""
+	}
+	if subject == "" {
+		subject = get("Subject")
+	}
+	precedence := strings.ToLower(get("Precedence"))
+
+	// 退信：标准DSN的Content-Type，或MAILER-DAEMON发出的退信主题
+	contentType := strings.ToLower(get("Content-Type"))
+	if strings.Contains(contentType, "multipart/report") && strings.Contains(contentType, "delivery-status") {
+		return model.EmailTypeBounce
+	}
+	if dsnSenderPattern.MatchString(get("From")) && (get("X-Failed-Recipients") != "" || dsnSubjectPattern.MatchString(subject)) {
+		return model.EmailTypeBounce
+	}
+
+	autoSubmitted := strings.ToLower(get("Auto-Submitted"))
+	autoReplied := strings.HasPrefix(autoSubmitted, "auto-replied") ||
+		get("X-Autoreply") != "" || get("X-Autorespond") != "" ||
+		strings.EqualFold(get("X-Autogenerated"), "reply") ||
+		precedence == "auto_reply" ||
+		autoReplySubjectPattern.MatchString(subject)
+	if outOfOfficePrefixPattern.MatchString(subject) ||
+		(autoReplied || (autoSubmitted != "" && autoSubmitted != "no")) && outOfOfficeSubjectPattern.MatchString(subject) {
+		return model.EmailTypeOutOfOffice
+	}
+	if autoReplied {
+		return model.EmailTypeAutoReply
+	}
+
+	for _, key := range mailingListHeaders {
+		if get(key) != "" {
+			return model.EmailTypeMailingList
+		}
+	}
+	if precedence == "list" {
+		return model.EmailTypeMailingList
+	}
+
+	if precedence == "bulk" || precedence == "junk" || bulkSubjectPattern.MatchString(subject) {
+		return model.EmailTypeBulk
+	}
+	for _, key := range bulkMarkerHeaders {
+		if get(key) != "" {
+			return model.EmailTypeBulk
+		}
+	}
+	return model.EmailTypeHuman
+}
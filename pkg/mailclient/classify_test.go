@@ -0,0 +1,52 @@
+package mailclient
+
+import (
+	"go_email/model"
+	"testing"
+)
+
+func TestClassifyEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string][]string
+		subject string
+		want    int
+	}{
+		{"人工邮件", map[string][]string{"From": {"booking@carrier.com"}}, "Booking confirmation SO123", model.EmailTypeHuman},
+		{"Outlook外出答复", map[string][]string{"Auto-Submitted": {"auto-replied"}}, "Automatic reply: SO123", model.EmailTypeOutOfOffice},
+		{"中文休假回复", map[string][]string{"X-Autoreply": {"yes"}}, "自动回复：我正在休假", model.EmailTypeOutOfOffice},
+		{"无邮件头的外出主题", nil, "Out of Office: back on Monday", model.EmailTypeOutOfOffice},
+		{"自动回复", map[string][]string{"Auto-Submitted": {"auto-replied"}}, "Re: SO123", model.EmailTypeAutoReply},
+		{"主题自动回复", nil, "自动回复: 已收到您的邮件", model.EmailTypeAutoReply},
+		{"自动生成的通知不是回复", map[string][]string{"Auto-Submitted": {"auto-generated"}}, "Vessel schedule update", model.EmailTypeHuman},
+		{"邮件列表", map[string][]string{"List-Id": {"<ops.lists.example.com>"}}, "[ops] weekly", model.EmailTypeMailingList},
+		{"Precedence list", map[string][]string{"precedence": {"list"}}, "digest", model.EmailTypeMailingList},
+		{"群发邮件", map[string][]string{"Precedence": {"bulk"}}, "Promotion", model.EmailTypeBulk},
+		{"退订链接", map[string][]string{"List-Unsubscribe": {"<mailto:u@example.com>"}}, "Monthly newsletter", model.EmailTypeBulk},
+		{"广告标注", nil, "【广告】运价优惠", model.EmailTypeBulk},
+		{"标准退信", map[string][]string{"Content-Type": {"multipart/report; report-type=delivery-status; boundary=x"}}, "Undelivered", model.EmailTypeBounce},
+		{"非标准退信", map[string][]string{"From": {"MAILER-DAEMON@mx.example.com"}, "X-Failed-Recipients": {"a@b.com"}}, "failure notice", model.EmailTypeBounce},
+	}
+	for _, tt := range tests {
+		if got := ClassifyEmail(tt.headers, tt.subject); got != tt.want {
+			t.Errorf("%s: 期望 %d，实际 %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestParseRawEmailCategory(t *testing.T) {
+	raw := joinLines(
+		"From: someone@example.com",
+		"Subject: =?UTF-8?B?6Ieq5Yqo5Zue5aSN?=",
+		"Auto-Submitted: auto-replied",
+		"",
+		"I will reply later.",
+	)
+	email, err := ParseRawEmail(raw, false)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if email.Category != model.EmailTypeAutoReply {
+		t.Errorf("邮件类型错误: %d", email.Category)
+	}
+}
@@ -0,0 +1,442 @@
+package mailclient
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxDKIMSignatures 每封邮件最多验证的DKIM签名数，防止构造的邮件消耗过多DNS查询
+const maxDKIMSignatures = 5
+
+// TXTResolver 查询DNS TXT记录，*net.Resolver 满足该接口，测试时可替换为固定的记录
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DKIMResult 一个DKIM签名的离线验证结果
+type DKIMResult struct {
+	Domain   string `json:"domain"`   // 签名域(d=)
+	Selector string `json:"selector"` // 选择器(s=)
+	Result   string `json:"result"`   // pass / fail / permerror / temperror
+	Error    string `json:"error,omitempty"`
+}
+
+// dkimSignature 解析后的DKIM-Signature
+type dkimSignature struct {
+	algorithm    string
+	signature    []byte
+	bodyHash     []byte
+	domain       string
+	selector     string
+	headers      []string
+	headerCanon  string
+	bodyCanon    string
+	bodyLength   int64 // l=，-1表示对整个正文签名
+	tagsWithoutB string
+}
+
+// dkimHeaderField 原始邮件头中的一个字段，保留折行
+type dkimHeaderField struct {
+	name string
+	raw  string // 包含字段名和结尾的CRLF
+}
+
+// dkimError 带验证结果的错误，temperror 表示可以稍后重试
+type dkimError struct {
+	result string
+	msg    string
+}
+
+func (e *dkimError) Error() string { return e.msg }
+
+func dkimPermError(format string, args ...interface{}) error {
+	return &dkimError{result: "permerror", msg: fmt.Sprintf(format, args...)}
+}
+
+// VerifyDKIM 按RFC 6376离线验证原始邮件中的DKIM签名，公钥通过resolver查询
+// 归档较久的邮件，签名域可能已经更换密钥，此时验证结果为fail或permerror
+func VerifyDKIM(ctx context.Context, raw []byte, resolver TXTResolver) []DKIMResult {
+	raw = toCRLF(raw)
+	headerEnd := bytes.Index(raw, []byte("\r\n\r\n"))
+	var headerPart, body []byte
+	if headerEnd < 0 {
+		headerPart = raw
+	} else {
+		headerPart, body = raw[:headerEnd+2], raw[headerEnd+4:]
+	}
+	fields := splitDKIMHeaderFields(headerPart)
+
+	var results []DKIMResult
+	for _, field := range fields {
+		if !strings.EqualFold(field.name, "DKIM-Signature") {
+			continue
+		}
+		if len(results) >= maxDKIMSignatures {
+			break
+		}
+		result := DKIMResult{Result: "pass"}
+		sig, err := parseDKIMSignature(field.raw)
+		if sig != nil {
+			result.Domain, result.Selector = sig.domain, sig.selector
+		}
+		if err == nil {
+			err = verifyDKIMSignature(ctx, sig, fields, body, resolver)
+		}
+		if err != nil {
+			result.Result, result.Error = "fail", err.Error()
+			var de *dkimError
+			if errors.As(err, &de) {
+				result.Result = de.result
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// VerifyEmailDKIM 离线验证邮件的DKIM签名，把结果写入邮件的发件人认证结果并重新计算From域名是否可疑
+func VerifyEmailDKIM(ctx context.Context, email *Email, resolver TXTResolver) {
+	if len(email.Raw) == 0 {
+		return
+	}
+	results := VerifyDKIM(ctx, email.Raw, resolver)
+	auth := &email.Auth
+	auth.DKIMSignatures = results
+	auth.DKIMVerifiedDomains = nil
+	auth.DKIMVerify = "none"
+	for i, r := range results {
+		if r.Result == "pass" {
+			auth.DKIMVerify = "pass"
+			auth.DKIMVerifiedDomains = append(auth.DKIMVerifiedDomains, r.Domain)
+		} else if i == 0 {
+			auth.DKIMVerify = r.Result
+		}
+	}
+	auth.evaluate()
+}
+
+// toCRLF 把单独的LF换成CRLF，归档的邮件可能只用LF换行
+func toCRLF(raw []byte) []byte {
+	if !bytes.Contains(raw, []byte("\n")) {
+		return raw
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(raw) + len(raw)/40)
+	for i, c := range raw {
+		if c == '\n' && (i == 0 || raw[i-1] != '\r') {
+			buf.WriteByte('\r')
+		}
+		buf.WriteByte(c)
+	}
+	return buf.Bytes()
+}
+
+// splitDKIMHeaderFields 把邮件头拆分为字段，续行归入上一个字段
+func splitDKIMHeaderFields(header []byte) []dkimHeaderField {
+	var fields []dkimHeaderField
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].raw += line
+			continue
+		}
+		name, _, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields = append(fields, dkimHeaderField{name: strings.TrimSpace(name), raw: line})
+	}
+	return fields
+}
+
+// parseDKIMTags 解析 tag=value 列表，值中的折行保留，由调用方按需去掉空白
+func parseDKIMTags(value string) map[string]string {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if _, exists := tags[key]; !exists {
+			tags[key] = strings.TrimSpace(val)
+		}
+	}
+	return tags
+}
+
+// removeWhitespace 去掉所有空白，用于 b= 和 bh= 等base64值
+func removeWhitespace(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// parseDKIMSignature 解析DKIM-Signature字段
+func parseDKIMSignature(raw string) (*dkimSignature, error) {
+	_, value, _ := strings.Cut(raw, ":")
+	tags := parseDKIMTags(value)
+	sig := &dkimSignature{
+		algorithm:  strings.ToLower(removeWhitespace(tags["a"])),
+		domain:     strings.ToLower(removeWhitespace(tags["d"])),
+		selector:   removeWhitespace(tags["s"]),
+		bodyLength: -1,
+	}
+	if v := removeWhitespace(tags["v"]); v != "1" {
+		return sig, dkimPermError("不支持的DKIM版本: %q", v)
+	}
+	for _, tag := range []string{"a", "b", "bh", "d", "h", "s"} {
+		if removeWhitespace(tags[tag]) == "" {
+			return sig, dkimPermError("缺少签名标签: %s", tag)
+		}
+	}
+	var err error
+	if sig.signature, err = base64.StdEncoding.DecodeString(removeWhitespace(tags["b"])); err != nil {
+		return sig, dkimPermError("签名不是有效的base64: %v", err)
+	}
+	if sig.bodyHash, err = base64.StdEncoding.DecodeString(removeWhitespace(tags["bh"])); err != nil {
+		return sig, dkimPermError("正文哈希不是有效的base64: %v", err)
+	}
+
+	hasFrom := false
+	for _, name := range strings.Split(tags["h"], ":") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if strings.EqualFold(name, "From") {
+			hasFrom = true
+		}
+		sig.headers = append(sig.headers, name)
+	}
+	if !hasFrom {
+		return sig, dkimPermError("签名没有覆盖From邮件头")
+	}
+
+	sig.headerCanon, sig.bodyCanon = "simple", "simple"
+	if c := strings.ToLower(removeWhitespace(tags["c"])); c != "" {
+		header, body, hasBody := strings.Cut(c, "/")
+		sig.headerCanon = header
+		if hasBody {
+			sig.bodyCanon = body
+		}
+	}
+	for _, canon := range []string{sig.headerCanon, sig.bodyCanon} {
+		if canon != "simple" && canon != "relaxed" {
+			return sig, dkimPermError("不支持的规范化算法: %s", canon)
+		}
+	}
+	if l := removeWhitespace(tags["l"]); l != "" {
+		if sig.bodyLength, err = strconv.ParseInt(l, 10, 64); err != nil || sig.bodyLength < 0 {
+			return sig, dkimPermError("无效的正文长度: %s", l)
+		}
+	}
+
+	// 计算签名时 b= 的值为空，保留其余内容和折行
+	name, value, _ := strings.Cut(strings.TrimSuffix(raw, "\r\n"), ":")
+	parts := strings.Split(value, ";")
+	for i, part := range parts {
+		if eq := strings.IndexByte(part, '='); eq >= 0 && strings.TrimSpace(part[:eq]) == "b" {
+			parts[i] = part[:eq+1]
+		}
+	}
+	sig.tagsWithoutB = name + ":" + strings.Join(parts, ";")
+	return sig, nil
+}
+
+// dkimHash 按签名算法返回哈希函数
+func dkimHash(algorithm string) (crypto.Hash, func() hash.Hash, error) {
+	switch algorithm {
+	case "rsa-sha256", "ed25519-sha256":
+		return crypto.SHA256, sha256.New, nil
+	case "rsa-sha1":
+		return crypto.SHA1, sha1.New, nil
+	}
+	return 0, nil, dkimPermError("不支持的签名算法: %s", algorithm)
+}
+
+// verifyDKIMSignature 校验正文哈希，查询公钥并验证邮件头签名
+func verifyDKIMSignature(ctx context.Context, sig *dkimSignature, fields []dkimHeaderField, body []byte, resolver TXTResolver) error {
+	hashID, newHash, err := dkimHash(sig.algorithm)
+	if err != nil {
+		return err
+	}
+
+	canonBody := canonicalizeDKIMBody(body, sig.bodyCanon)
+	if sig.bodyLength >= 0 {
+		if sig.bodyLength > int64(len(canonBody)) {
+			return dkimPermError("签名的正文长度超过实际长度")
+		}
+		canonBody = canonBody[:sig.bodyLength]
+	}
+	bodyHash := newHash()
+	bodyHash.Write(canonBody)
+	if !bytes.Equal(bodyHash.Sum(nil), sig.bodyHash) {
+		return fmt.Errorf("正文哈希不匹配")
+	}
+
+	headerHash := newHash()
+	// 同名邮件头有多个时从下往上依次取用，不存在的邮件头按空处理
+	used := make(map[int]bool)
+	for _, name := range sig.headers {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(fields[i].name, name) {
+				continue
+			}
+			used[i] = true
+			headerHash.Write([]byte(canonicalizeDKIMHeader(fields[i].raw, sig.headerCanon)))
+			break
+		}
+	}
+	signed := canonicalizeDKIMHeader(sig.tagsWithoutB, sig.headerCanon)
+	headerHash.Write([]byte(strings.TrimSuffix(signed, "\r\n")))
+	hashed := headerHash.Sum(nil)
+
+	key, err := lookupDKIMKey(ctx, sig, resolver)
+	if err != nil {
+		return err
+	}
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, hashID, hashed, sig.signature); err != nil {
+			return fmt.Errorf("签名验证失败: %v", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, hashed, sig.signature) {
+			return fmt.Errorf("签名验证失败")
+		}
+	default:
+		return dkimPermError("不支持的公钥类型")
+	}
+	return nil
+}
+
+// lookupDKIMKey 查询 selector._domainkey.domain 的TXT记录并解析公钥
+func lookupDKIMKey(ctx context.Context, sig *dkimSignature, resolver TXTResolver) (crypto.PublicKey, error) {
+	name := sig.selector + "._domainkey." + sig.domain
+	records, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, dkimPermError("没有找到公钥记录: %s", name)
+		}
+		return nil, &dkimError{result: "temperror", msg: fmt.Sprintf("查询公钥失败: %s: %v", name, err)}
+	}
+	if len(records) == 0 {
+		return nil, dkimPermError("没有找到公钥记录: %s", name)
+	}
+
+	tags := parseDKIMTags(records[0])
+	if v := removeWhitespace(tags["v"]); v != "" && v != "DKIM1" {
+		return nil, dkimPermError("公钥记录版本错误: %s", v)
+	}
+	p := removeWhitespace(tags["p"])
+	if p == "" {
+		return nil, dkimPermError("公钥已撤销: %s", name)
+	}
+	data, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, dkimPermError("公钥不是有效的base64: %v", err)
+	}
+
+	keyType := strings.ToLower(removeWhitespace(tags["k"]))
+	if keyType == "ed25519" {
+		if !strings.HasPrefix(sig.algorithm, "ed25519") || len(data) != ed25519.PublicKeySize {
+			return nil, dkimPermError("公钥与签名算法不匹配")
+		}
+		return ed25519.PublicKey(data), nil
+	}
+	if keyType != "" && keyType != "rsa" {
+		return nil, dkimPermError("不支持的公钥类型: %s", keyType)
+	}
+	if !strings.HasPrefix(sig.algorithm, "rsa") {
+		return nil, dkimPermError("公钥与签名算法不匹配")
+	}
+	if pub, err := x509.ParsePKIXPublicKey(data); err == nil {
+		if rsaKey, ok := pub.(*rsa.PublicKey); ok {
+			return rsaKey, nil
+		}
+		return nil, dkimPermError("公钥不是RSA公钥")
+	}
+	pub, err := x509.ParsePKCS1PublicKey(data)
+	if err != nil {
+		return nil, dkimPermError("解析公钥失败: %v", err)
+	}
+	return pub, nil
+}
+
+// canonicalizeDKIMHeader 按RFC 6376 3.4.1/3.4.2规范化邮件头字段，结果以CRLF结尾
+func canonicalizeDKIMHeader(raw, canon string) string {
+	if canon == "simple" {
+		if !strings.HasSuffix(raw, "\r\n") {
+			raw += "\r\n"
+		}
+		return raw
+	}
+	name, value, _ := strings.Cut(raw, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.Join(strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == '\t' }), " ")
+	return strings.ToLower(strings.TrimRight(name, " \t")) + ":" + value + "\r\n"
+}
+
+// canonicalizeDKIMBody 按RFC 6376 3.4.3/3.4.4规范化正文
+func canonicalizeDKIMBody(body []byte, canon string) []byte {
+	if canon == "relaxed" {
+		var buf bytes.Buffer
+		for _, line := range bytes.SplitAfter(body, []byte("\r\n")) {
+			content := bytes.TrimRight(bytes.TrimSuffix(line, []byte("\r\n")), " \t")
+			prevSpace := false
+			for _, c := range content {
+				if c == ' ' || c == '\t' {
+					if !prevSpace {
+						buf.WriteByte(' ')
+					}
+					prevSpace = true
+					continue
+				}
+				prevSpace = false
+				buf.WriteByte(c)
+			}
+			if bytes.HasSuffix(line, []byte("\r\n")) || len(content) > 0 {
+				buf.WriteString("\r\n")
+			}
+		}
+		body = buf.Bytes()
+	}
+
+	// 去掉结尾的空行
+	for bytes.HasSuffix(body, []byte("\r\n\r\n")) {
+		body = body[:len(body)-2]
+	}
+	if canon == "simple" {
+		if len(body) == 0 || bytes.Equal(body, []byte("\r\n")) {
+			return []byte("\r\n")
+		}
+		if !bytes.HasSuffix(body, []byte("\r\n")) {
+			body = append(body, '\r', '\n')
+		}
+		return body
+	}
+	if bytes.Equal(body, []byte("\r\n")) {
+		return nil
+	}
+	return body
+}
@@ -0,0 +1,154 @@
+package mailclient
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// stubResolver 测试用的固定TXT记录
+type stubResolver map[string]string
+
+func (r stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if name == "temp._domainkey.maersk.com" {
+		return nil, errors.New("i/o timeout")
+	}
+	record, ok := r[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return []string{record}, nil
+}
+
+// signDKIM 按给定参数为测试邮件添加DKIM-Signature，邮件使用CRLF换行
+func signDKIM(t *testing.T, message, tags, canon string, sign func(digest []byte) []byte) string {
+	t.Helper()
+	headerPart, body, _ := strings.Cut(message, "\r\n\r\n")
+	headerCanon, bodyCanon, _ := strings.Cut(canon, "/")
+	bodyHash := sha256.Sum256(canonicalizeDKIMBody([]byte(body), bodyCanon))
+	field := "DKIM-Signature: v=1; " + tags + "; c=" + canon + "; h=From:Subject;\r\n\tbh=" +
+		base64.StdEncoding.EncodeToString(bodyHash[:]) + "; b="
+
+	h := sha256.New()
+	fields := splitDKIMHeaderFields([]byte(headerPart + "\r\n"))
+	for _, name := range []string{"From", "Subject"} {
+		for _, f := range fields {
+			if f.name == name {
+				h.Write([]byte(canonicalizeDKIMHeader(f.raw, headerCanon)))
+			}
+		}
+	}
+	h.Write([]byte(strings.TrimSuffix(canonicalizeDKIMHeader(field, headerCanon), "\r\n")))
+	field += base64.StdEncoding.EncodeToString(sign(h.Sum(nil)))
+	return field + "\r\n" + message
+}
+
+func TestCanonicalizeDKIM(t *testing.T) {
+	// RFC 6376 3.4.5 的示例
+	if got := canonicalizeDKIMHeader("A: X\r\n", "relaxed") + canonicalizeDKIMHeader("B : Y\t\r\n\tZ  \r\n", "relaxed"); got != "a:X\r\nb:Y Z\r\n" {
+		t.Errorf("relaxed邮件头规范化错误: %q", got)
+	}
+	body := []byte(" C \r\nD \t E\r\n\r\n\r\n")
+	if got := string(canonicalizeDKIMBody(body, "relaxed")); got != " C\r\nD E\r\n" {
+		t.Errorf("relaxed正文规范化错误: %q", got)
+	}
+	if got := string(canonicalizeDKIMBody(body, "simple")); got != " C \r\nD \t E\r\n" {
+		t.Errorf("simple正文规范化错误: %q", got)
+	}
+	if got := string(canonicalizeDKIMBody(nil, "simple")); got != "\r\n" {
+		t.Errorf("simple空正文规范化错误: %q", got)
+	}
+}
+
+func TestVerifyDKIMRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	resolver := stubResolver{"sel._domainkey.maersk.com": "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(pub)}
+	sign := func(digest []byte) []byte {
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	message := "From: Booking <booking@maersk.com>\r\nSubject:  Booking  confirmation\r\nTo: ops@example.com\r\n\r\nPlease find   the booking.\r\n\r\n"
+	signed := signDKIM(t, message, "a=rsa-sha256; d=maersk.com; s=sel", "relaxed/relaxed", sign)
+
+	// 归档邮件只用LF换行、邮件头中的空白有变化时relaxed规范化仍能通过
+	lf := strings.ReplaceAll(signed, "\r\n", "\n")
+	lf = strings.Replace(lf, "Subject:  Booking", "Subject: Booking", 1)
+	if results := VerifyDKIM(context.Background(), []byte(lf), resolver); len(results) != 1 || results[0].Result != "pass" || results[0].Domain != "maersk.com" {
+		t.Fatalf("签名应验证通过: %+v", results)
+	}
+
+	tampered := strings.Replace(signed, "the booking", "the invoice", 1)
+	if results := VerifyDKIM(context.Background(), []byte(tampered), resolver); results[0].Result != "fail" {
+		t.Errorf("正文被修改后应验证失败: %+v", results)
+	}
+	noKey := signDKIM(t, message, "a=rsa-sha256; d=maersk.com; s=old", "relaxed/relaxed", sign)
+	if results := VerifyDKIM(context.Background(), []byte(noKey), resolver); results[0].Result != "permerror" {
+		t.Errorf("没有公钥记录时应为permerror: %+v", results)
+	}
+	tempKey := signDKIM(t, message, "a=rsa-sha256; d=maersk.com; s=temp", "relaxed/relaxed", sign)
+	if results := VerifyDKIM(context.Background(), []byte(tempKey), resolver); results[0].Result != "temperror" {
+		t.Errorf("DNS查询失败时应为temperror: %+v", results)
+	}
+}
+
+func TestVerifyDKIMEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := stubResolver{"ed._domainkey.maersk.com": "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub)}
+	message := "From: booking@maersk.com\r\nSubject: SO123\r\n\r\nBody\r\n"
+	signed := signDKIM(t, message, "a=ed25519-sha256; d=maersk.com; s=ed", "simple/simple", func(digest []byte) []byte {
+		return ed25519.Sign(priv, digest)
+	})
+	if results := VerifyDKIM(context.Background(), []byte(signed), resolver); len(results) != 1 || results[0].Result != "pass" {
+		t.Fatalf("签名应验证通过: %+v", results)
+	}
+	// simple规范化不允许邮件头空白变化
+	changed := strings.Replace(signed, "Subject: SO123", "Subject:  SO123", 1)
+	if results := VerifyDKIM(context.Background(), []byte(changed), resolver); results[0].Result != "fail" {
+		t.Errorf("邮件头被修改后应验证失败: %+v", results)
+	}
+}
+
+func TestVerifyEmailDKIMClearsSuspicious(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := stubResolver{"ed._domainkey.maersk.com": "k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub)}
+	message := "Authentication-Results: mx.example.com; spf=softfail smtp.mailfrom=relay.example.net\r\n" +
+		"From: booking@maersk.com\r\nSubject: SO123\r\n\r\nBody\r\n"
+	signed := signDKIM(t, message, "a=ed25519-sha256; d=maersk.com; s=ed", "relaxed/simple", func(digest []byte) []byte {
+		return ed25519.Sign(priv, digest)
+	})
+
+	email, err := ParseRawEmail([]byte(signed), false)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	ApplySenderAuth(email, &EmailConfigInfo{IMAPServer: "imap.example.com"})
+	if !email.Auth.Suspicious {
+		t.Fatalf("SPF未通过且没有DKIM结果时应标记为可疑: %+v", email.Auth)
+	}
+	VerifyEmailDKIM(context.Background(), email, resolver)
+	if email.Auth.Suspicious || email.Auth.DKIMVerify != "pass" {
+		t.Errorf("离线验证通过对齐的DKIM签名后不应可疑: %+v", email.Auth)
+	}
+}
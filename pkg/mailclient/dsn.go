@@ -0,0 +1,184 @@
+package mailclient
+
+import (
+	"bytes"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// DeliveryReport 退信/投递状态通知(DSN, RFC 3464)的解析结果
+type DeliveryReport struct {
+	ReportingMTA      string              `json:"reporting_mta"`       // 生成通知的服务器
+	OriginalMessageID string              `json:"original_message_id"` // 原邮件的Message-ID（不含尖括号），取自附带的原邮件或原邮件头
+	OriginalSubject   string              `json:"original_subject"`    // 原邮件的主题
+	Standard          bool                `json:"standard"`            // 是否为标准的 multipart/report 格式，否则是按发件人和主题识别的非标准退信
+	Recipients        []DeliveryRecipient `json:"recipients"`
+}
+
+// DeliveryRecipient DSN中单个收件人的投递状态
+type DeliveryRecipient struct {
+	Recipient      string `json:"recipient"`       // 小写的收件人地址，优先取 Original-Recipient
+	Action         string `json:"action"`          // failed / delayed / delivered / relayed / expanded
+	Status         string `json:"status"`          // 增强状态码，如 5.1.1
+	DiagnosticCode string `json:"diagnostic_code"` // 远端服务器返回的诊断信息
+	RemoteMTA      string `json:"remote_mta"`      // 拒收的远端服务器
+}
+
+var (
+	// dsnStatusPattern 正文中的增强状态码，如 5.1.1、4.4.7
+	dsnStatusPattern = regexp.MustCompile(`\b([245]\.\d{1,3}\.\d{1,3})\b`)
+	// dsnSenderPattern 退信的发件人
+	dsnSenderPattern = regexp.MustCompile(`(?i)(mailer-daemon|postmaster|mail delivery (subsystem|system)|系统退信|系统邮件)`)
+	// dsnSubjectPattern 非标准退信的主题
+	dsnSubjectPattern = regexp.MustCompile(`(?i)(undeliver|undelivered|delivery status notification|delivery (has )?failed|failure notice|returned mail|mail delivery failed|could not be delivered|退信|投递失败|发送失败|无法投递|未送达)`)
+	// dsnMessageIDPattern 非标准退信正文中引用的原邮件Message-ID
+	dsnMessageIDPattern = regexp.MustCompile(`(?im)^\s*message-id\s*:\s*(<[^>\s]+>)`)
+)
+
+// parseDeliveryReport 识别退信并解析每个收件人的状态码、诊断信息和原邮件Message-ID，不是退信时返回nil
+// 标准DSN为 multipart/report; report-type=delivery-status；非标准退信按 MAILER-DAEMON 等发件人加退信主题识别，
+// 收件人取自 X-Failed-Recipients 邮件头，状态码取自正文
+func parseDeliveryReport(email *Email, root *MIMEPart) *DeliveryReport {
+	if root == nil {
+		return nil
+	}
+	if (root.MediaType == "multipart/report" && strings.EqualFold(root.Params["report-type"], "delivery-status")) ||
+		findDeliveryStatusPart(root) != nil {
+		return parseStandardReport(root)
+	}
+
+	from := root.Header.Get("From")
+	subject := DecodeMIMESubject(root.Header.Get("Subject"))
+	failed := root.Header.Get("X-Failed-Recipients")
+	if !dsnSenderPattern.MatchString(from) || (failed == "" && !dsnSubjectPattern.MatchString(subject)) {
+		return nil
+	}
+
+	report := &DeliveryReport{}
+	fillOriginalMessage(report, root)
+	if report.OriginalMessageID == "" {
+		if m := dsnMessageIDPattern.FindStringSubmatch(email.Body); m != nil {
+			report.OriginalMessageID = normalizeMessageID(m[1])
+		}
+	}
+	status, diagnostic := "", ""
+	for _, line := range strings.Split(email.Body, "\n") {
+		if m := dsnStatusPattern.FindStringSubmatch(line); m != nil {
+			status, diagnostic = m[1], strings.TrimSpace(line)
+			break
+		}
+	}
+	for _, addr := range strings.Split(failed, ",") {
+		if recipient, _ := NormalizeAddress(addr); recipient != "" {
+			report.Recipients = append(report.Recipients, DeliveryRecipient{
+				Recipient: recipient, Action: "failed", Status: status, DiagnosticCode: diagnostic,
+			})
+		}
+	}
+	if len(report.Recipients) == 0 {
+		// 没有 X-Failed-Recipients 时记录一条没有收件人的退信，仍可按原邮件关联
+		report.Recipients = append(report.Recipients, DeliveryRecipient{Action: "failed", Status: status, DiagnosticCode: diagnostic})
+	}
+	return report
+}
+
+// findDeliveryStatusPart 查找 message/delivery-status 部分（国际化邮件为 message/global-delivery-status）
+func findDeliveryStatusPart(root *MIMEPart) *MIMEPart {
+	var found *MIMEPart
+	root.Walk(func(part *MIMEPart) bool {
+		if part.MediaType == "message/delivery-status" || part.MediaType == "message/global-delivery-status" {
+			found = part
+			return false
+		}
+		return !part.IsMessage()
+	})
+	return found
+}
+
+// parseStandardReport 解析标准DSN：第一段为整封通知的字段，之后每段为一个收件人的字段
+func parseStandardReport(root *MIMEPart) *DeliveryReport {
+	report := &DeliveryReport{Standard: true}
+	fillOriginalMessage(report, root)
+
+	statusPart := findDeliveryStatusPart(root)
+	if statusPart == nil {
+		return report
+	}
+	for i, block := range splitDSNBlocks(statusPart.Body) {
+		fields := parseMIMEHeader(block)
+		if i == 0 {
+			report.ReportingMTA = dsnTypedValue(fields.Get("Reporting-Mta"))
+			continue
+		}
+		recipient := dsnTypedValue(fields.Get("Original-Recipient"))
+		if recipient == "" {
+			recipient = dsnTypedValue(fields.Get("Final-Recipient"))
+		}
+		action := strings.ToLower(strings.TrimSpace(fields.Get("Action")))
+		if recipient == "" && action == "" {
+			continue
+		}
+		recipient, _ = NormalizeAddress(recipient)
+		report.Recipients = append(report.Recipients, DeliveryRecipient{
+			Recipient:      recipient,
+			Action:         action,
+			Status:         dsnStatusCode(fields.Get("Status")),
+			DiagnosticCode: dsnTypedValue(fields.Get("Diagnostic-Code")),
+			RemoteMTA:      dsnTypedValue(fields.Get("Remote-Mta")),
+		})
+	}
+	return report
+}
+
+// splitDSNBlocks 按空行切分 message/delivery-status 的字段段落
+func splitDSNBlocks(body []byte) [][]byte {
+	body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n"))
+	var blocks [][]byte
+	for _, block := range bytes.Split(body, []byte("\n\n")) {
+		if block = bytes.TrimSpace(block); len(block) > 0 {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// dsnTypedValue 去掉字段值的类型前缀，如 rfc822; user@example.com、dns; mx.example.com、smtp; 550 ...
+func dsnTypedValue(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if typ, rest, ok := strings.Cut(value, ";"); ok && !strings.ContainsAny(typ, " @") {
+		value = strings.TrimSpace(rest)
+	}
+	return value
+}
+
+// dsnStatusCode 取 Status 字段中的状态码，去掉部分服务器附加的说明文字，如 5.1.1 (bad destination mailbox)
+func dsnStatusCode(value string) string {
+	if fields := strings.Fields(value); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// fillOriginalMessage 从附带的原邮件(message/rfc822)或原邮件头(text/rfc822-headers)中取出Message-ID和主题
+func fillOriginalMessage(report *DeliveryReport, root *MIMEPart) {
+	var header textproto.MIMEHeader
+	root.Walk(func(part *MIMEPart) bool {
+		switch {
+		case part == root:
+			return true
+		case part.IsMessage() && len(part.Parts) > 0:
+			header = part.Parts[0].Header
+		case part.MediaType == "text/rfc822-headers" || part.MediaType == "message/global-headers":
+			header = parseMIMEHeader(part.Body)
+		default:
+			return true
+		}
+		return false
+	})
+	if header == nil {
+		return
+	}
+	report.OriginalMessageID = normalizeMessageID(header.Get("Message-Id"))
+	report.OriginalSubject = DecodeMIMESubject(header.Get("Subject"))
+}
@@ -0,0 +1,111 @@
+package mailclient
+
+import "testing"
+
+func TestParseStandardDSN(t *testing.T) {
+	raw := joinLines(
+		"From: Mail Delivery Subsystem <MAILER-DAEMON@mx.example.com>",
+		"To: ops@example.com",
+		"Subject: Delivery Status Notification (Failure)",
+		`Content-Type: multipart/report; report-type=delivery-status; boundary="b1"`,
+		"",
+		"--b1",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		"Your message could not be delivered.",
+		"--b1",
+		"Content-Type: message/delivery-status",
+		"",
+		"Reporting-MTA: dns; mx.example.com",
+		"Arrival-Date: Mon, 1 Jan 2024 10:00:00 +0000",
+		"",
+		"Final-Recipient: rfc822; Nobody@Carrier.com",
+		"Action: failed",
+		"Status: 5.1.1",
+		"Remote-MTA: dns; mx.carrier.com",
+		"Diagnostic-Code: smtp; 550 5.1.1 <nobody@carrier.com>:",
+		"  Recipient address rejected: User unknown",
+		"",
+		"Final-Recipient: rfc822; slow@carrier.com",
+		"Action: delayed",
+		"Status: 4.4.7 (delivery time expired)",
+		"",
+		"--b1",
+		"Content-Type: text/rfc822-headers",
+		"",
+		"From: ops@example.com",
+		"Subject: =?UTF-8?B?6K6i6Iix56Gu6K6k?=",
+		"Message-ID: <fwd-123@example.com>",
+		"--b1--",
+	)
+	email, err := ParseRawEmail(raw, false)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	report := email.Bounce
+	if report == nil || !report.Standard {
+		t.Fatalf("应识别为标准退信: %+v", report)
+	}
+	if report.ReportingMTA != "mx.example.com" || report.OriginalMessageID != "fwd-123@example.com" || report.OriginalSubject != "订舱确认" {
+		t.Errorf("通知字段错误: %+v", report)
+	}
+	if len(report.Recipients) != 2 {
+		t.Fatalf("收件人数量错误: %+v", report.Recipients)
+	}
+	first := report.Recipients[0]
+	if first.Recipient != "nobody@carrier.com" || first.Action != "failed" || first.Status != "5.1.1" || first.RemoteMTA != "mx.carrier.com" {
+		t.Errorf("第一个收件人错误: %+v", first)
+	}
+	if first.DiagnosticCode != "550 5.1.1 <nobody@carrier.com>: Recipient address rejected: User unknown" {
+		t.Errorf("诊断信息错误: %q", first.DiagnosticCode)
+	}
+	if second := report.Recipients[1]; second.Action != "delayed" || second.Status != "4.4.7" {
+		t.Errorf("第二个收件人错误: %+v", second)
+	}
+}
+
+func TestParseNonStandardBounce(t *testing.T) {
+	raw := joinLines(
+		"From: MAILER-DAEMON@mail.example.cn",
+		"To: ops@example.com",
+		"Subject: failure notice",
+		"X-Failed-Recipients: bad@carrier.com",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		"Sorry, we were unable to deliver your message.",
+		"<bad@carrier.com>: 550 5.7.1 Mailbox unavailable",
+		"",
+		"--- Below this line is a copy of the message.",
+		"Message-ID: <notify-9@example.com>",
+	)
+	email, err := ParseRawEmail(raw, false)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	report := email.Bounce
+	if report == nil || report.Standard {
+		t.Fatalf("应识别为非标准退信: %+v", report)
+	}
+	if report.OriginalMessageID != "notify-9@example.com" || len(report.Recipients) != 1 {
+		t.Fatalf("退信字段错误: %+v", report)
+	}
+	if r := report.Recipients[0]; r.Recipient != "bad@carrier.com" || r.Status != "5.7.1" || r.Action != "failed" {
+		t.Errorf("收件人错误: %+v", r)
+	}
+}
+
+func TestOrdinaryEmailIsNotBounce(t *testing.T) {
+	raw := joinLines(
+		"From: postmaster@example.com",
+		"Subject: Weekly report",
+		"",
+		"Nothing failed this week.",
+	)
+	email, err := ParseRawEmail(raw, false)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if email.Bounce != nil {
+		t.Errorf("普通邮件不应识别为退信: %+v", email.Bounce)
+	}
+}
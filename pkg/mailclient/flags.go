@@ -0,0 +1,243 @@
+package mailclient
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/commands"
+	"github.com/emersion/go-imap/responses"
+)
+
+const (
+	// statusHighestModSeq CONDSTORE扩展的STATUS项（RFC 7162）
+	statusHighestModSeq imap.StatusItem = "HIGHESTMODSEQ"
+	// fetchModSeq CONDSTORE扩展的FETCH项（RFC 7162）
+	fetchModSeq imap.FetchItem = "MODSEQ"
+)
+
+// FlagState 单封邮件在服务器上的标记状态
+type FlagState struct {
+	UID     uint32 `json:"uid"`
+	Seen    bool   `json:"seen"`
+	Flagged bool   `json:"flagged"`
+	Deleted bool   `json:"deleted"`
+	ModSeq  uint64 `json:"mod_seq"`
+}
+
+// FlagSyncResult 文件夹标记对账结果
+type FlagSyncResult struct {
+	Folder        string      `json:"folder"`
+	UIDValidity   uint32      `json:"uid_validity"`
+	HighestModSeq uint64      `json:"highest_mod_seq"` // 服务器不支持CONDSTORE时为0
+	Incremental   bool        `json:"incremental"`     // 是否通过CHANGEDSINCE只获取了变化的邮件
+	Changes       []FlagState `json:"changes"`         // 标记状态（增量时只包含变化的邮件）
+	ExistingUIDs  []uint32    `json:"existing_uids"`   // 服务器当前存在的全部UID（升序）
+}
+
+// uidFetchChangedSince 带 CHANGEDSINCE 修饰符的 FETCH 命令，用 commands.Uid 包装为 UID FETCH
+type uidFetchChangedSince struct {
+	SeqSet       *imap.SeqSet
+	Items        []imap.FetchItem
+	ChangedSince uint64
+}
+
+func (cmd *uidFetchChangedSince) Command() *imap.Command {
+	items := make([]interface{}, len(cmd.Items))
+	for i, item := range cmd.Items {
+		items[i] = imap.RawString(item)
+	}
+	modifiers := []interface{}{
+		imap.RawString("CHANGEDSINCE"),
+		imap.RawString(strconv.FormatUint(cmd.ChangedSince, 10)),
+	}
+	return &imap.Command{
+		Name:      "FETCH",
+		Arguments: []interface{}{cmd.SeqSet, items, modifiers},
+	}
+}
+
+// parseModSeq 解析MODSEQ/HIGHESTMODSEQ的值，MODSEQ在FETCH响应中是单元素列表，且可能超过uint32
+func parseModSeq(v interface{}) (uint64, bool) {
+	switch val := v.(type) {
+	case []interface{}:
+		if len(val) == 0 {
+			return 0, false
+		}
+		return parseModSeq(val[0])
+	case string:
+		n, err := strconv.ParseUint(val, 10, 64)
+		return n, err == nil
+	case imap.RawString:
+		n, err := strconv.ParseUint(string(val), 10, 64)
+		return n, err == nil
+	case uint32:
+		return uint64(val), true
+	case uint64:
+		return val, true
+	}
+	return 0, false
+}
+
+// flagStateFromMessage 从FETCH结果中提取标记状态
+func flagStateFromMessage(msg *imap.Message) FlagState {
+	state := FlagState{UID: msg.Uid}
+	for _, flag := range msg.Flags {
+		switch flag {
+		case imap.SeenFlag:
+			state.Seen = true
+		case imap.FlaggedFlag:
+			state.Flagged = true
+		case imap.DeletedFlag:
+			state.Deleted = true
+		}
+	}
+	if v, ok := msg.Items[fetchModSeq]; ok {
+		state.ModSeq, _ = parseModSeq(v)
+	}
+	return state
+}
+
+// VanishedUIDs 返回 known 中在服务器UID列表 existing 里已不存在的UID（已被删除或移走）
+func VanishedUIDs(known, existing []uint32) []uint32 {
+	return uidDifference(known, existing)
+}
+
+// MissingUIDs 返回服务器UID列表 server 中没有保存到数据库（stored）的UID
+func MissingUIDs(server, stored []uint32) []uint32 {
+	return uidDifference(server, stored)
+}
+
+// uidDifference 返回 a 中不在 b 里的UID，保持 a 的顺序
+func uidDifference(a, b []uint32) []uint32 {
+	exists := make(map[uint32]struct{}, len(b))
+	for _, uid := range b {
+		exists[uid] = struct{}{}
+	}
+
+	var diff []uint32
+	for _, uid := range a {
+		if _, ok := exists[uid]; !ok {
+			diff = append(diff, uid)
+		}
+	}
+	return diff
+}
+
+// FetchFlagChanges 获取文件夹中邮件的标记状态和当前存在的UID
+// 服务器支持CONDSTORE且 sinceModSeq>0 时只获取 MODSEQ 大于 sinceModSeq 的邮件，否则全量 UID FETCH FLAGS
+func (m *MailClient) FetchFlagChanges(folder string, sinceModSeq uint64) (*FlagSyncResult, error) {
+	maxRetries := 3
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		result, err := m.tryFetchFlagChanges(folder, sinceModSeq)
+		if err == nil {
+			return result, nil
+		}
+
+		if isConnectionError(err) || isWrappedConnectionError(err) {
+			log.Printf("[标记对账] 连接错误 (尝试 %d/%d): 文件夹=%s, 错误: %v", attempt, maxRetries, folder, err)
+			if attempt < maxRetries {
+				time.Sleep(time.Second * time.Duration(attempt*2))
+				continue
+			}
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("获取邮件标记失败，已重试 %d 次", maxRetries)
+}
+
+// tryFetchFlagChanges 获取文件夹邮件标记（单次）
+func (m *MailClient) tryFetchFlagChanges(folder string, sinceModSeq uint64) (_ *FlagSyncResult, err error) {
+	pc, err := m.acquireIMAP()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { ReleaseIMAP(pc, err) }()
+	c := pc.Client
+
+	result := &FlagSyncResult{Folder: folder}
+
+	condStore, err := c.Support("CONDSTORE")
+	if err != nil {
+		return nil, fmt.Errorf("检查CONDSTORE能力失败: %w", err)
+	}
+	if condStore {
+		// 先通过STATUS取HIGHESTMODSEQ，RFC 7162不建议对已选中的文件夹执行STATUS
+		status, err := c.Status(folder, []imap.StatusItem{statusHighestModSeq})
+		if err != nil {
+			log.Printf("[标记对账] 获取HIGHESTMODSEQ失败，改为全量获取: 文件夹=%s, 错误: %v", folder, err)
+			condStore = false
+		} else if v, ok := status.Items[statusHighestModSeq]; ok {
+			result.HighestModSeq, _ = parseModSeq(v)
+		}
+		// 文件夹不支持持久化MODSEQ（NOMODSEQ）时HIGHESTMODSEQ为0
+		if result.HighestModSeq == 0 {
+			condStore = false
+		}
+	}
+
+	mbox, err := c.Select(folder, true)
+	if err != nil {
+		return nil, fmt.Errorf("选择邮箱失败: %w", err)
+	}
+	result.UIDValidity = mbox.UidValidity
+
+	// 当前存在的全部UID，用于识别已被删除（EXPUNGE）或移走的邮件
+	existing, err := c.UidSearch(imap.NewSearchCriteria())
+	if err != nil {
+		return nil, fmt.Errorf("搜索邮件UID失败: %w", err)
+	}
+	sort.Slice(existing, func(i, j int) bool { return existing[i] < existing[j] })
+	result.ExistingUIDs = existing
+
+	if len(existing) == 0 {
+		return result, nil
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddRange(1, 0) // 1:*
+	items := []imap.FetchItem{imap.FetchUid, imap.FetchFlags}
+
+	messages := make(chan *imap.Message, 100)
+	done := make(chan error, 1)
+	if condStore && sinceModSeq > 0 {
+		result.Incremental = true
+		if result.HighestModSeq <= sinceModSeq {
+			// 自上次对账以来没有任何标记变化
+			log.Printf("[标记对账] 文件夹 %s 没有标记变化，HIGHESTMODSEQ: %d", folder, result.HighestModSeq)
+			return result, nil
+		}
+		items = append(items, fetchModSeq)
+		go func() {
+			defer close(messages)
+			cmd := &commands.Uid{Cmd: &uidFetchChangedSince{SeqSet: seqSet, Items: items, ChangedSince: sinceModSeq}}
+			res := &responses.Fetch{Messages: messages, SeqSet: seqSet, Uid: true}
+			status, err := c.Execute(cmd, res)
+			if err == nil {
+				err = status.Err()
+			}
+			done <- err
+		}()
+	} else {
+		go func() {
+			done <- c.UidFetch(seqSet, items, messages)
+		}()
+	}
+
+	for msg := range messages {
+		if msg.Uid == 0 {
+			continue
+		}
+		result.Changes = append(result.Changes, flagStateFromMessage(msg))
+	}
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("获取邮件标记失败: %w", err)
+	}
+
+	log.Printf("[标记对账] 文件夹 %s 获取到 %d 封邮件的标记，服务器现有 %d 封，增量: %v",
+		folder, len(result.Changes), len(existing), result.Incremental)
+	return result, nil
+}
@@ -0,0 +1,51 @@
+package mailclient
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/emersion/go-imap"
+)
+
+func TestParseModSeq(t *testing.T) {
+	// FETCH响应中的MODSEQ是单元素列表，且可能超过uint32
+	if n, ok := parseModSeq([]interface{}{"715194045007"}); !ok || n != 715194045007 {
+		t.Errorf("解析MODSEQ列表失败: %d %v", n, ok)
+	}
+	if n, ok := parseModSeq("42"); !ok || n != 42 {
+		t.Errorf("解析HIGHESTMODSEQ失败: %d %v", n, ok)
+	}
+	if _, ok := parseModSeq([]interface{}{}); ok {
+		t.Error("空列表不应解析成功")
+	}
+}
+
+func TestFlagStateFromMessage(t *testing.T) {
+	msg := &imap.Message{
+		Uid:   7,
+		Flags: []string{imap.SeenFlag, imap.DeletedFlag, "$Label1"},
+		Items: map[imap.FetchItem]interface{}{fetchModSeq: []interface{}{"100"}},
+	}
+	state := flagStateFromMessage(msg)
+	want := FlagState{UID: 7, Seen: true, Deleted: true, ModSeq: 100}
+	if state != want {
+		t.Errorf("标记解析错误: %+v, 期望: %+v", state, want)
+	}
+}
+
+func TestVanishedUIDs(t *testing.T) {
+	vanished := VanishedUIDs([]uint32{1, 2, 3, 5, 8}, []uint32{2, 3, 4, 8})
+	if !reflect.DeepEqual(vanished, []uint32{1, 5}) {
+		t.Errorf("已删除UID错误: %v", vanished)
+	}
+	if got := VanishedUIDs([]uint32{1}, nil); !reflect.DeepEqual(got, []uint32{1}) {
+		t.Errorf("服务器文件夹为空时应全部视为已删除: %v", got)
+	}
+}
+
+func TestMissingUIDs(t *testing.T) {
+	missing := MissingUIDs([]uint32{3, 4, 6, 9}, []uint32{3, 6, 7})
+	if !reflect.DeepEqual(missing, []uint32{4, 9}) {
+		t.Errorf("缺失UID错误: %v", missing)
+	}
+}
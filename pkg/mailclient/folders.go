@@ -0,0 +1,169 @@
+package mailclient
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+// hasFolderWildcard 判断文件夹配置是否包含IMAP LIST通配符
+func hasFolderWildcard(pattern string) bool {
+	return strings.ContainsAny(pattern, "*%")
+}
+
+// normalizeFolderName 规范化文件夹名称，INBOX按RFC 3501不区分大小写
+func normalizeFolderName(name string) string {
+	name = strings.TrimSpace(name)
+	if strings.EqualFold(name, "INBOX") {
+		return "INBOX"
+	}
+	return name
+}
+
+// ResolveFolders 将账号配置的文件夹列表解析为实际需要同步的文件夹
+// 不含通配符的项直接使用；含 * 或 % 的项通过 IMAP LIST 展开，并跳过 \Noselect 文件夹
+func (m *MailClient) ResolveFolders(patterns []string) ([]string, error) {
+	maxRetries := 3
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		folders, err := m.tryResolveFolders(patterns)
+		if err == nil {
+			return folders, nil
+		}
+
+		if isConnectionError(err) || isWrappedConnectionError(err) {
+			log.Printf("[文件夹解析] 连接错误 (尝试 %d/%d): %v", attempt, maxRetries, err)
+			if attempt < maxRetries {
+				time.Sleep(time.Second * time.Duration(attempt*2))
+				continue
+			}
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("解析文件夹失败，已重试 %d 次", maxRetries)
+}
+
+// tryResolveFolders 解析文件夹列表（单次）
+func (m *MailClient) tryResolveFolders(patterns []string) (folders []string, err error) {
+	var pc *PooledConnection
+	defer func() {
+		if pc != nil {
+			ReleaseIMAP(pc, err)
+		}
+	}()
+
+	seen := make(map[string]bool)
+	addFolder := func(name string) {
+		name = normalizeFolderName(name)
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		folders = append(folders, name)
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if !hasFolderWildcard(pattern) {
+			addFolder(pattern)
+			continue
+		}
+
+		if pc == nil {
+			if pc, err = m.acquireIMAP(); err != nil {
+				return nil, err
+			}
+		}
+		c := pc.Client
+
+		mailboxes := make(chan *imap.MailboxInfo, 20)
+		done := make(chan error, 1)
+		go func() {
+			done <- c.List("", pattern, mailboxes)
+		}()
+
+		matched := 0
+		for mbox := range mailboxes {
+			if isNoSelectMailbox(mbox) {
+				log.Printf("[文件夹解析] 跳过不可选择的文件夹: %s", mbox.Name)
+				continue
+			}
+			addFolder(mbox.Name)
+			matched++
+		}
+
+		if err := <-done; err != nil {
+			return nil, fmt.Errorf("列出文件夹失败(%s): %w", pattern, err)
+		}
+		log.Printf("[文件夹解析] 通配符 %s 匹配到 %d 个文件夹", pattern, matched)
+	}
+
+	return folders, nil
+}
+
+// isNoSelectMailbox 判断文件夹是否带有 \Noselect 属性（只是层级节点，不能SELECT）
+func isNoSelectMailbox(mbox *imap.MailboxInfo) bool {
+	for _, attr := range mbox.Attributes {
+		if strings.EqualFold(attr, imap.NoSelectAttr) || strings.EqualFold(attr, "\\NonExistent") {
+			return true
+		}
+	}
+	return false
+}
+
+// FolderStatus 文件夹状态（来自SELECT/EXAMINE响应）
+type FolderStatus struct {
+	Name        string `json:"name"`
+	Messages    uint32 `json:"messages"`
+	UIDValidity uint32 `json:"uid_validity"`
+	UIDNext     uint32 `json:"uid_next"`
+}
+
+// GetFolderStatus 获取文件夹的UIDVALIDITY、UIDNEXT和邮件数
+func (m *MailClient) GetFolderStatus(folder string) (*FolderStatus, error) {
+	maxRetries := 3
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		status, err := m.tryGetFolderStatus(folder)
+		if err == nil {
+			return status, nil
+		}
+
+		if isConnectionError(err) || isWrappedConnectionError(err) {
+			log.Printf("[文件夹状态] 连接错误 (尝试 %d/%d): 文件夹=%s, 错误: %v", attempt, maxRetries, folder, err)
+			if attempt < maxRetries {
+				time.Sleep(time.Second * time.Duration(attempt*2))
+				continue
+			}
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("获取文件夹状态失败，已重试 %d 次", maxRetries)
+}
+
+// tryGetFolderStatus 获取文件夹状态（单次）
+func (m *MailClient) tryGetFolderStatus(folder string) (_ *FolderStatus, err error) {
+	pc, err := m.acquireIMAP()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { ReleaseIMAP(pc, err) }()
+	c := pc.Client
+
+	// 以只读方式选择文件夹（EXAMINE），不影响邮件的已读状态
+	mbox, err := c.Select(folder, true)
+	if err != nil {
+		return nil, fmt.Errorf("选择邮箱失败: %w", err)
+	}
+
+	return &FolderStatus{
+		Name:        folder,
+		Messages:    mbox.Messages,
+		UIDValidity: mbox.UidValidity,
+		UIDNext:     mbox.UidNext,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package mailclient
+
+import (
+	"go_email/pkg/utils"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// fillHeaderFields 从邮件头补全抄送、回复地址和会话相关字段，已由ENVELOPE填充的字段保持不变
+// 同时保存全部邮件头（encoded-word已解码），键为规范化的头部名称
+func fillHeaderFields(email *Email, header textproto.MIMEHeader) {
+	if len(header) == 0 {
+		return
+	}
+
+	email.Headers = make(map[string][]string, len(header))
+	for key, values := range header {
+		decoded := make([]string, 0, len(values))
+		for _, value := range values {
+			decoded = append(decoded, DecodeMIMESubject(value))
+		}
+		email.Headers[key] = decoded
+	}
+
+	mailHeader := mail.Header(header)
+	if email.Cc == "" && header.Get("Cc") != "" {
+		email.Cc = formatHeaderAddresses(mailHeader, "Cc")
+	}
+	if email.Bcc == "" && header.Get("Bcc") != "" {
+		email.Bcc = formatHeaderAddresses(mailHeader, "Bcc")
+	}
+	if email.ReplyTo == "" && header.Get("Reply-To") != "" {
+		email.ReplyTo = formatHeaderAddresses(mailHeader, "Reply-To")
+	}
+	if email.MessageID == "" {
+		email.MessageID = normalizeMessageID(header.Get("Message-Id"))
+	}
+	if email.InReplyTo == "" {
+		if ids := parseMessageIDList(header.Get("In-Reply-To")); len(ids) > 0 {
+			email.InReplyTo = ids[0]
+		}
+	}
+	if len(email.References) == 0 {
+		email.References = parseMessageIDList(header.Get("References"))
+	}
+	if len(email.Addresses) == 0 {
+		email.Addresses = headerAddresses(mailHeader)
+	}
+	// ENVELOPE中的日期不规范时go-imap返回零值，按邮件头宽松解析
+	if email.SentAt.IsZero() {
+		if sentAt, ok := utils.ParseMailDate(header.Get("Date")); ok {
+			email.SentAt = sentAt
+			email.Date = sentAt.Format(time.RFC1123Z)
+		}
+	}
+	// 没有INTERNALDATE（解析归档邮件）时取最近一跳Received邮件头中的投递时间
+	if email.ReceivedAt.IsZero() {
+		for _, received := range header.Values("Received") {
+			if receivedAt, ok := utils.ParseReceivedDate(received); ok {
+				email.ReceivedAt = receivedAt
+				break
+			}
+		}
+	}
+}
+
+// parseMessageIDList 解析 References / In-Reply-To 中的Message-ID列表，返回不含尖括号的ID
+// 标准格式为 <a@b> <c@d>，没有尖括号时按空白和逗号分隔
+func parseMessageIDList(value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	var ids []string
+	if strings.Contains(value, "<") {
+		for {
+			start := strings.IndexByte(value, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(value[start:], '>')
+			if end < 0 {
+				break
+			}
+			if id := strings.TrimSpace(value[start+1 : start+end]); id != "" {
+				ids = append(ids, id)
+			}
+			value = value[start+end+1:]
+		}
+		return ids
+	}
+
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	}) {
+		ids = append(ids, field)
+	}
+	return ids
+}
@@ -0,0 +1,260 @@
+package mailclient
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// textWriter 把HTML节点输出为纯文本，合并空白并维护行首的引用前缀
+type textWriter struct {
+	b            strings.Builder
+	lineStart    bool // 当前位于行首
+	pendingSpace bool // 有待输出的空白
+	newlines     int  // 末尾连续的换行数
+	quoteDepth   int  // blockquote嵌套层数
+	listDepth    int  // 列表嵌套层数
+}
+
+// prefix 行首输出引用前缀
+func (w *textWriter) prefix() {
+	if w.lineStart {
+		w.b.WriteString(strings.Repeat("> ", w.quoteDepth))
+		w.lineStart = false
+	}
+}
+
+// writeText 输出文本，连续空白合并为一个空格，行首的空白忽略
+func (w *textWriter) writeText(s string) {
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			w.pendingSpace = !w.lineStart
+			continue
+		}
+		if w.pendingSpace && !w.lineStart {
+			w.b.WriteByte(' ')
+		}
+		w.prefix()
+		w.b.WriteRune(r)
+		w.pendingSpace = false
+		w.newlines = 0
+	}
+}
+
+// writePre 原样输出pre中的文本
+func (w *textWriter) writePre(s string) {
+	for _, line := range strings.SplitAfter(s, "\n") {
+		text := strings.TrimSuffix(line, "\n")
+		if text != "" {
+			w.prefix()
+			w.b.WriteString(text)
+			w.newlines = 0
+		}
+		if strings.HasSuffix(line, "\n") {
+			w.newline()
+		}
+	}
+	w.pendingSpace = false
+}
+
+// writeMarker 输出列表符号、单元格分隔符等，保留其中的空格
+func (w *textWriter) writeMarker(s string) {
+	w.prefix()
+	w.b.WriteString(s)
+	w.pendingSpace = false
+	w.newlines = 0
+}
+
+func (w *textWriter) newline() {
+	w.b.WriteByte('\n')
+	w.lineStart = true
+	w.pendingSpace = false
+	w.newlines++
+}
+
+// block 块级元素前后换行
+func (w *textWriter) block() {
+	if !w.lineStart {
+		w.newline()
+	}
+}
+
+// paragraph 段落前后空一行
+func (w *textWriter) paragraph() {
+	w.block()
+	if w.b.Len() > 0 && w.newlines < 2 {
+		w.newline()
+	}
+}
+
+// htmlSkipTags 转换为文本或清理时整体丢弃（包括内容）的元素
+var htmlSkipTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Head: true, atom.Title: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Math: true, atom.Iframe: true, atom.Object: true,
+	atom.Embed: true, atom.Applet: true, atom.Frame: true, atom.Frameset: true, atom.Meta: true,
+	atom.Link: true, atom.Base: true, atom.Select: true, atom.Textarea: true, atom.Button: true,
+}
+
+// HTMLToText 把HTML正文转换为可读的纯文本
+// 链接输出为“文字 (地址)”，列表项带“- ”或序号，表格单元格以“ | ”分隔，图片输出alt文字，blockquote按“> ”引用
+func HTMLToText(htmlContent string) string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+	w := &textWriter{lineStart: true}
+	w.walk(doc, false)
+
+	lines := strings.Split(w.b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\u00a0")
+	}
+	text := strings.Join(lines, "\n")
+	text = blankLinesPattern.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// blankLinesPattern 连续两行以上的空行
+var blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+
+func (w *textWriter) walk(n *html.Node, inPre bool) {
+	switch n.Type {
+	case html.TextNode:
+		if inPre {
+			w.writePre(n.Data)
+		} else {
+			w.writeText(n.Data)
+		}
+		return
+	case html.ElementNode:
+	default:
+		w.walkChildren(n, inPre)
+		return
+	}
+
+	if htmlSkipTags[n.DataAtom] {
+		return
+	}
+	switch n.DataAtom {
+	case atom.Br:
+		w.newline()
+	case atom.Hr:
+		w.paragraph()
+		w.writeMarker("----------")
+		w.paragraph()
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Table, atom.Dl:
+		w.paragraph()
+		w.walkChildren(n, inPre)
+		w.paragraph()
+	case atom.Pre:
+		w.paragraph()
+		w.walkChildren(n, true)
+		w.paragraph()
+	case atom.Blockquote:
+		w.paragraph()
+		w.quoteDepth++
+		w.walkChildren(n, inPre)
+		w.block()
+		w.quoteDepth--
+		w.paragraph()
+	case atom.Ul, atom.Ol:
+		w.block()
+		w.listDepth++
+		index := 0
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+				w.walk(c, inPre)
+				continue
+			}
+			index++
+			w.block()
+			marker := "- "
+			if n.DataAtom == atom.Ol {
+				marker = fmt.Sprintf("%d. ", index)
+			}
+			w.writeMarker(strings.Repeat("  ", w.listDepth-1) + marker)
+			w.walkChildren(c, inPre)
+			w.block()
+		}
+		w.listDepth--
+		w.block()
+	case atom.Tr:
+		w.block()
+		cell := 0
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.DataAtom == atom.Td || c.DataAtom == atom.Th) {
+				if cell > 0 && !w.lineStart {
+					w.writeMarker(" | ")
+				}
+				cell++
+			}
+			w.walk(c, inPre)
+		}
+		w.block()
+	case atom.A:
+		w.walkChildren(n, inPre)
+		if href := linkTarget(n); href != "" {
+			text := strings.Join(strings.Fields(nodeText(n)), " ")
+			if text != href && text != strings.TrimPrefix(href, "mailto:") {
+				w.writeText(" (" + href + ")")
+			}
+		}
+	case atom.Img:
+		if alt := strings.TrimSpace(attrValue(n, "alt")); alt != "" {
+			w.writeText("[" + alt + "]")
+		}
+	case atom.Div, atom.Li, atom.Dd, atom.Dt, atom.Section, atom.Article, atom.Header, atom.Footer,
+		atom.Address, atom.Center, atom.Form, atom.Caption, atom.Nav, atom.Aside, atom.Main, atom.Figure:
+		w.block()
+		w.walkChildren(n, inPre)
+		w.block()
+	default:
+		w.walkChildren(n, inPre)
+	}
+}
+
+func (w *textWriter) walkChildren(n *html.Node, inPre bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c, inPre)
+	}
+}
+
+// linkTarget 链接中值得输出的地址，忽略页内锚点和脚本
+func linkTarget(n *html.Node) string {
+	href := strings.TrimSpace(attrValue(n, "href"))
+	lower := strings.ToLower(href)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:") {
+		return href
+	}
+	return ""
+}
+
+// nodeText 节点内的全部文字
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// attrValue 取元素的属性值，属性名不区分大小写
+func attrValue(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package mailclient
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	input := `<html><head><title>通知</title><style>p{color:red}</style></head><body>
+<p>Dear   Customer,</p>
+<p>Your booking <b>BK123</b> is confirmed.<br>Details: <a href="https://carrier.example.com/track?id=1">track</a></p>
+<ul><li>Vessel: EVER GIVEN</li><li>ETD:&nbsp;2024-03-05</li></ul>
+<ol><li>Submit SI</li><li>Pay freight</li></ol>
+<table><tr><th>Container</th><th>Size</th></tr><tr><td>ABCU1234567</td><td>40HQ</td></tr></table>
+<img src="https://t.example.com/pixel.gif" alt="">
+<img src="cid:logo" alt="Logo">
+<blockquote>quoted line</blockquote>
+<pre>  keep   spacing
+second</pre>
+<script>alert(1)</script>
+</body></html>`
+
+	want := `Dear Customer,
+
+Your booking BK123 is confirmed.
+Details: track (https://carrier.example.com/track?id=1)
+
+- Vessel: EVER GIVEN
+- ETD: 2024-03-05
+1. Submit SI
+2. Pay freight
+
+Container | Size
+ABCU1234567 | 40HQ
+
+[Logo]
+
+> quoted line
+
+  keep   spacing
+second`
+
+	if got := HTMLToText(input); got != want {
+		t.Errorf("转换结果错误:\n%s\n---- 应为 ----\n%s", got, want)
+	}
+}
+
+func TestHTMLOnlyEmailBody(t *testing.T) {
+	raw := joinLines(
+		"From: noreply@carrier.example.com",
+		"Subject: Booking",
+		"Content-Type: text/html; charset=utf-8",
+		"",
+		"<p>Booking <a href=\"mailto:ops@example.com\">ops@example.com</a> confirmed</p>",
+	)
+	email, err := ParseRawEmail(raw, false)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if email.Body != "Booking ops@example.com confirmed" {
+		t.Errorf("只有HTML正文时应转换出纯文本正文: %q", email.Body)
+	}
+}
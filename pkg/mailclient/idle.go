@@ -0,0 +1,126 @@
+package mailclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+)
+
+const (
+	// maxIdleDuration RFC 2177 规定服务器可以在IDLE 30分钟无活动后断开，客户端须在29分钟内重新发出IDLE
+	maxIdleDuration = 29 * time.Minute
+	// defaultIdleRestart 默认每25分钟重新发出一次IDLE
+	defaultIdleRestart = 25 * time.Minute
+	// defaultIdlePollInterval 服务器不支持IDLE时的默认轮询间隔
+	defaultIdlePollInterval = time.Minute
+)
+
+// IdleOptions IDLE监听参数
+type IdleOptions struct {
+	RestartInterval time.Duration // 重新发出IDLE的间隔，必须小于29分钟
+	PollInterval    time.Duration // 服务器不支持IDLE时NOOP轮询的间隔
+}
+
+// normalize 补齐默认值，并保证重新发出IDLE的间隔不超过29分钟
+func (o IdleOptions) normalize() IdleOptions {
+	if o.RestartInterval <= 0 {
+		o.RestartInterval = defaultIdleRestart
+	}
+	if o.RestartInterval >= maxIdleDuration {
+		o.RestartInterval = maxIdleDuration - time.Minute
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = defaultIdlePollInterval
+	}
+	return o
+}
+
+// WatchNewMail 使用独立的IMAP连接监听文件夹的新邮件，收到 EXISTS 且邮件数增加时调用 onNewMail
+// 服务器支持IDLE时使用IDLE推送，否则退化为定时NOOP轮询；ctx取消时正常退出并返回nil，连接异常时返回错误
+// onNewMail 在读取服务器响应的协程中调用，不能阻塞
+func (m *MailClient) WatchNewMail(ctx context.Context, folder string, opts IdleOptions, onNewMail func(messages uint32)) error {
+	opts = opts.normalize()
+
+	// IDLE会独占连接，不能使用连接池中与同步共用的连接
+	c, err := createNewConnection(m.Config)
+	if err != nil {
+		return err
+	}
+	defer c.Logout()
+
+	updates := make(chan client.Update, 64)
+	c.Updates = updates
+
+	mbox, err := c.Select(folder, true)
+	if err != nil {
+		return fmt.Errorf("选择邮箱失败: %w", err)
+	}
+	lastMessages := mbox.Messages
+
+	supportIdle, err := c.Support("IDLE")
+	if err != nil {
+		return fmt.Errorf("检查IDLE能力失败: %w", err)
+	}
+	if supportIdle {
+		log.Printf("[IDLE监听] %s 文件夹 %s 开始IDLE监听，当前邮件数: %d，每 %v 重新发出IDLE",
+			m.Config.EmailAddress, folder, lastMessages, opts.RestartInterval)
+	} else {
+		log.Printf("[IDLE监听] %s 服务器不支持IDLE，文件夹 %s 改为每 %v 轮询，当前邮件数: %d",
+			m.Config.EmailAddress, folder, opts.PollInterval, lastMessages)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		// go-imap 会按 LogoutTimeout 周期性地结束并重新发出IDLE，不支持IDLE时按 PollInterval 发送NOOP
+		done <- c.Idle(stop, &client.IdleOptions{
+			LogoutTimeout: opts.RestartInterval,
+			PollInterval:  opts.PollInterval,
+		})
+	}()
+
+	for {
+		select {
+		case update := <-updates:
+			switch u := update.(type) {
+			case *client.MailboxUpdate:
+				if u.Mailbox == nil {
+					continue
+				}
+				messages := u.Mailbox.Messages
+				if messages > lastMessages {
+					log.Printf("[IDLE监听] %s 文件夹 %s 收到新邮件通知，邮件数: %d → %d",
+						m.Config.EmailAddress, folder, lastMessages, messages)
+					onNewMail(messages)
+				}
+				lastMessages = messages
+			case *client.ExpungeUpdate:
+				if lastMessages > 0 {
+					lastMessages--
+				}
+			}
+		case err := <-done:
+			if err == nil {
+				err = fmt.Errorf("IDLE意外结束")
+			}
+			return fmt.Errorf("IDLE监听中断: %w", err)
+		case <-ctx.Done():
+			close(stop)
+			// 等待IDLE结束期间继续消费更新，避免阻塞客户端
+			for {
+				select {
+				case <-updates:
+				case err := <-done:
+					if err != nil {
+						log.Printf("[IDLE监听] %s 文件夹 %s 结束IDLE时出错: %v", m.Config.EmailAddress, folder, err)
+					}
+					log.Printf("[IDLE监听] %s 文件夹 %s 停止监听", m.Config.EmailAddress, folder)
+					return nil
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package mailclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdleOptionsNormalize(t *testing.T) {
+	opts := IdleOptions{}.normalize()
+	if opts.RestartInterval != defaultIdleRestart || opts.PollInterval != defaultIdlePollInterval {
+		t.Errorf("默认IDLE参数错误: restart=%v poll=%v", opts.RestartInterval, opts.PollInterval)
+	}
+
+	// 超过29分钟的配置必须被压到29分钟以内，否则服务器可能断开连接
+	opts = IdleOptions{RestartInterval: 40 * time.Minute, PollInterval: 30 * time.Second}.normalize()
+	if opts.RestartInterval >= maxIdleDuration {
+		t.Errorf("重新发出IDLE的间隔应小于29分钟，实际: %v", opts.RestartInterval)
+	}
+	if opts.PollInterval != 30*time.Second {
+		t.Errorf("轮询间隔不应被修改，实际: %v", opts.PollInterval)
+	}
+}
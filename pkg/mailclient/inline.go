@@ -0,0 +1,48 @@
+package mailclient
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// cidReferencePattern 匹配HTML中的 cid: 引用，如 src="cid:image001.png@01D9"、url(cid:logo)
+var cidReferencePattern = regexp.MustCompile(`(?i)cid:([^"'\s<>()]+)`)
+
+// normalizeContentID 统一Content-ID的比较形式：去掉尖括号、解码URL转义并转为小写
+func normalizeContentID(contentID string) string {
+	contentID = strings.Trim(strings.TrimSpace(contentID), "<>")
+	if unescaped, err := url.PathUnescape(contentID); err == nil {
+		contentID = unescaped
+	}
+	return strings.ToLower(contentID)
+}
+
+// HTMLContentIDs 返回HTML中通过 cid: 引用的全部Content-ID（已规范化）
+func HTMLContentIDs(html string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, match := range cidReferencePattern.FindAllStringSubmatch(html, -1) {
+		ids[normalizeContentID(match[1])] = true
+	}
+	return ids
+}
+
+// RewriteCIDReferences 把HTML中的 cid: 引用替换为 urls 中对应的地址，urls 以Content-ID为键
+// 找不到对应地址的引用保持不变
+func RewriteCIDReferences(html string, urls map[string]string) string {
+	if len(urls) == 0 || !strings.Contains(strings.ToLower(html), "cid:") {
+		return html
+	}
+	normalized := make(map[string]string, len(urls))
+	for contentID, u := range urls {
+		if u != "" {
+			normalized[normalizeContentID(contentID)] = u
+		}
+	}
+	return cidReferencePattern.ReplaceAllStringFunc(html, func(ref string) string {
+		if u, ok := normalized[normalizeContentID(ref[len("cid:"):])]; ok {
+			return u
+		}
+		return ref
+	})
+}
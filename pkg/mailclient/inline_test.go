@@ -0,0 +1,50 @@
+package mailclient
+
+import "testing"
+
+func TestRewriteCIDReferences(t *testing.T) {
+	html := `<img src="cid:image001.png@01D9"><div style="background:url(cid:Logo%40x)"></div><img src='cid:missing'>`
+	got := RewriteCIDReferences(html, map[string]string{
+		"<image001.png@01D9>": "https://oss/a.png",
+		"logo@x":              "https://oss/b.png",
+	})
+	want := `<img src="https://oss/a.png"><div style="background:url(https://oss/b.png)"></div><img src='cid:missing'>`
+	if got != want {
+		t.Errorf("替换结果错误:\n%s\n%s", got, want)
+	}
+
+	ids := HTMLContentIDs(html)
+	if len(ids) != 3 || !ids["logo@x"] || !ids["image001.png@01d9"] {
+		t.Errorf("cid引用提取错误: %v", ids)
+	}
+}
+
+func TestFillEmailFromTreeReferencedInline(t *testing.T) {
+	// 图片不在multipart/related中，但被HTML引用
+	raw := joinLines(
+		`Content-Type: multipart/mixed; boundary="m"`,
+		"",
+		"--m",
+		"Content-Type: text/html",
+		"",
+		`<img src="cid:pic1">`,
+		"--m",
+		"Content-Type: image/jpeg; name=pic.jpg",
+		"Content-ID: <pic1>",
+		"",
+		"jpeg",
+		"--m",
+		"Content-Type: image/jpeg; name=other.jpg",
+		"Content-ID: <pic2>",
+		"",
+		"jpeg",
+		"--m--",
+	)
+
+	root, _ := ParseMIMETree(raw)
+	email := &Email{}
+	fillEmailFromTree(email, root, false)
+	if len(email.Attachments) != 2 || !email.Attachments[0].Inline || email.Attachments[1].Inline {
+		t.Errorf("只有被HTML引用的图片应标记为Inline: %+v", email.Attachments)
+	}
+}
@@ -2,151 +2,18 @@ package mailclient
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
-	"sync"
 	"time"
 
 	"go_email/model"
 
 	"github.com/emersion/go-imap/client"
+	"github.com/spf13/viper"
 )
 
-// 连接池结构
-type ConnectionPool struct {
-	connections map[string]*PooledConnection
-	mutex       sync.RWMutex
-}
-
-// 池化连接结构
-type PooledConnection struct {
-	Client      *client.Client
-	LastUsed    time.Time
-	AccountInfo *EmailConfigInfo
-	mutex       sync.Mutex
-}
-
-// 全局连接池
-var globalPool = &ConnectionPool{
-	connections: make(map[string]*PooledConnection),
-}
-
-// 定期清理过期连接
-func init() {
-	go func() {
-		ticker := time.NewTicker(2 * time.Minute) // 每2分钟清理一次，缩短清理间隔
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				globalPool.cleanupExpiredConnections()
-			}
-		}
-	}()
-}
-
-// 清理过期连接
-func (p *ConnectionPool) cleanupExpiredConnections() {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
-
-	now := time.Now()
-	for email, conn := range p.connections {
-		// 如果连接超过10分钟未使用，则关闭（进一步缩短超时时间，防止网络超时）
-		if now.Sub(conn.LastUsed) > 10*time.Minute {
-			log.Printf("[连接池] 清理过期连接: %s (闲置时间: %v)", email, now.Sub(conn.LastUsed))
-			conn.mutex.Lock()
-			if conn.Client != nil {
-				safeCloseConnection(conn.Client)
-				conn.Client = nil
-			}
-			conn.mutex.Unlock()
-			delete(p.connections, email)
-		}
-	}
-}
-
-// 获取或创建连接
-func (p *ConnectionPool) GetConnection(config *EmailConfigInfo) (*client.Client, error) {
-	return p.getConnectionWithRetry(config, 3)
-}
-
-// 带重试的获取连接
-func (p *ConnectionPool) getConnectionWithRetry(config *EmailConfigInfo, maxRetries int) (*client.Client, error) {
-	email := config.EmailAddress
-
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		conn, err := p.tryGetConnection(config)
-		if err == nil && conn != nil {
-			log.Printf("[连接池] 连接获取成功 (尝试 %d/%d): %s", attempt, maxRetries, email)
-			return conn, nil
-		}
-
-		log.Printf("[连接池] 获取连接失败 (尝试 %d/%d): %s, 错误: %v", attempt, maxRetries, email, err)
-
-		// 如果不是最后一次尝试，清理可能存在的坏连接并等待
-		if attempt < maxRetries {
-			p.CloseConnection(email)
-			// 使用指数退避策略
-			delay := time.Second * time.Duration(attempt*2)
-			log.Printf("[连接池] 等待 %v 后重试连接: %s", delay, email)
-			time.Sleep(delay)
-		}
-	}
-
-	return nil, fmt.Errorf("获取连接失败，已重试 %d 次", maxRetries)
-}
-
-// 尝试获取连接（单次）
-func (p *ConnectionPool) tryGetConnection(config *EmailConfigInfo) (*client.Client, error) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
-
-	email := config.EmailAddress
-
-	// 检查是否已有连接
-	if pooledConn, exists := p.connections[email]; exists {
-		pooledConn.mutex.Lock()
-		defer pooledConn.mutex.Unlock()
-
-		// 检查连接是否仍然有效
-		if pooledConn.Client != nil {
-			// 多重健康检查
-			if p.isConnectionHealthy(pooledConn.Client, email) {
-				// 连接有效，更新最后使用时间
-				pooledConn.LastUsed = time.Now()
-				log.Printf("[连接池] 复用现有连接: %s, 状态: %v", email, pooledConn.Client.State())
-				return pooledConn.Client, nil
-			} else {
-				log.Printf("[连接池] 连接已失效，清理并重新创建: %s", email)
-				// 连接失效，安全地清理
-				safeCloseConnection(pooledConn.Client)
-				pooledConn.Client = nil
-			}
-		}
-	}
-
-	// 创建新连接
-	log.Printf("[连接池] 创建新连接: %s", email)
-	client, err := createNewConnection(config)
-	if err != nil {
-		// 清理失败的连接记录
-		delete(p.connections, email)
-		return nil, err
-	}
-
-	// 保存到连接池
-	p.connections[email] = &PooledConnection{
-		Client:      client,
-		LastUsed:    time.Now(),
-		AccountInfo: config,
-	}
-
-	return client, nil
-}
-
 // 检查是否是连接相关的错误
 func isConnectionError(err error) bool {
 	if err == nil {
@@ -226,53 +93,6 @@ func safeCloseConnection(c *client.Client) {
 	}
 }
 
-// 连接健康检查
-func (p *ConnectionPool) isConnectionHealthy(c *client.Client, email string) bool {
-	// 检查1: 连接状态
-	state := c.State()
-	if state == 0 || state == 4 { // Closed=0, Logout=4 in go-imap v1
-		log.Printf("[连接池] 连接已关闭: %s, 状态: %v", email, state)
-		return false
-	}
-
-	// 检查2: 验证是否在正确的状态
-	if state != 2 && state != 6 { // Auth=2, Selected=6 in go-imap v1
-		log.Printf("[连接池] 连接状态异常: %s, 状态: %v", email, state)
-		return false
-	}
-
-	// 检查3: NOOP命令（更安全的检查）- 设置更短的超时时间
-	noopStart := time.Now()
-	err := c.Noop()
-	noopDuration := time.Since(noopStart)
-
-	if err != nil {
-		log.Printf("[连接池] NOOP命令失败: %s, 耗时: %v, 错误: %v", email, noopDuration, err)
-		// 检查是否是连接相关的错误或IMAP命令错误
-		if isConnectionError(err) || strings.Contains(strings.ToLower(err.Error()), "command is not a valid imap command") {
-			log.Printf("[连接池] NOOP失败，检测到连接或命令错误: %s", email)
-			return false
-		}
-		// 非连接错误，可能是临时问题，再次验证状态
-		currentState := c.State()
-		if currentState == 0 || currentState == 4 {
-			log.Printf("[连接池] NOOP失败后连接状态异常: %s, 状态: %v", email, currentState)
-			return false
-		}
-		// 如果状态正常但NOOP失败，可能是临时问题，记录警告但继续使用
-		log.Printf("[连接池] NOOP失败但连接状态正常: %s, 状态: %v, 将继续使用", email, currentState)
-	}
-
-	// 检查4: 如果NOOP耗时过长，也认为连接不健康
-	if noopDuration > 10*time.Second {
-		log.Printf("[连接池] NOOP响应过慢: %s, 耗时: %v, 认为连接不健康", email, noopDuration)
-		return false
-	}
-
-	log.Printf("[连接池] 连接健康检查通过: %s, 状态: %v, NOOP耗时: %v", email, state, noopDuration)
-	return true
-}
-
 // 创建新的IMAP连接
 func createNewConnection(config *EmailConfigInfo) (*client.Client, error) {
 	maxRetries := 3
@@ -281,8 +101,8 @@ func createNewConnection(config *EmailConfigInfo) (*client.Client, error) {
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		log.Printf("[IMAP连接] 尝试连接 %s:%d (尝试 %d/%d)", config.IMAPServer, config.IMAPPort, attempt, maxRetries)
 
-		// 检查密码是否为空
-		if config.Password == "" {
+		// 检查密码是否为空（OAuth2账号使用访问令牌认证）
+		if config.OAuth == nil && config.Password == "" {
 			return nil, fmt.Errorf("邮箱密码为空，请确认已设置应用专用密码")
 		}
 
@@ -295,11 +115,14 @@ func createNewConnection(config *EmailConfigInfo) (*client.Client, error) {
 			InsecureSkipVerify: false,
 		}
 
-		// 如果使用SSL，则使用TLS连接
-		if config.UseSSL {
-			c, err = client.DialTLS(fmt.Sprintf("%s:%d", config.IMAPServer, config.IMAPPort), tlsConfig)
-		} else {
-			c, err = client.Dial(fmt.Sprintf("%s:%d", config.IMAPServer, config.IMAPPort))
+		addr := fmt.Sprintf("%s:%d", config.IMAPServer, config.IMAPPort)
+		switch config.imapSecurityMode() {
+		case SecuritySSL:
+			c, err = client.DialTLS(addr, tlsConfig)
+		case SecurityPlain:
+			c, err = client.Dial(addr)
+		default:
+			c, err = client.Dial(addr)
 			if err == nil {
 				if err = c.StartTLS(tlsConfig); err != nil {
 					c.Logout()
@@ -322,9 +145,15 @@ func createNewConnection(config *EmailConfigInfo) (*client.Client, error) {
 			return nil, fmt.Errorf("连接IMAP服务器失败: %w", err)
 		}
 
+		// 明文连接上发送密码或令牌属于配置问题，重试也不会成功
+		if err := checkIMAPLoginSecurity(c.IsTLS(), viper.GetBool("imap.allow_insecure_auth")); err != nil {
+			c.Logout()
+			return nil, err
+		}
+
 		// 登录
 		log.Printf("[IMAP连接] 尝试登录邮箱: %s", config.EmailAddress)
-		if err := c.Login(config.EmailAddress, config.Password); err != nil {
+		if err := loginIMAP(c, config); err != nil {
 			c.Logout()
 			log.Printf("[IMAP连接] IMAP登录失败 (尝试 %d/%d): %v", attempt, maxRetries, err)
 			if attempt < maxRetries {
@@ -341,45 +170,6 @@ func createNewConnection(config *EmailConfigInfo) (*client.Client, error) {
 	return nil, fmt.Errorf("连接IMAP服务器失败，已重试 %d 次", maxRetries)
 }
 
-// 释放连接（将连接返回到池中）
-func (p *ConnectionPool) ReleaseConnection(email string) {
-	// 连接池管理的连接不需要手动释放，会自动管理
-	p.mutex.RLock()
-	defer p.mutex.RUnlock()
-
-	if pooledConn, exists := p.connections[email]; exists {
-		pooledConn.mutex.Lock()
-		pooledConn.LastUsed = time.Now()
-		pooledConn.mutex.Unlock()
-	}
-}
-
-// 强制关闭连接
-func (p *ConnectionPool) CloseConnection(email string) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
-
-	if pooledConn, exists := p.connections[email]; exists {
-		pooledConn.mutex.Lock()
-		if pooledConn.Client != nil {
-			log.Printf("[连接池] 强制关闭连接: %s", email)
-			safeCloseConnection(pooledConn.Client)
-			pooledConn.Client = nil
-		}
-		pooledConn.mutex.Unlock()
-		delete(p.connections, email)
-	}
-}
-
-// 重置连接状态 - 用于处理IMAP命令错误
-func (p *ConnectionPool) ResetConnection(email string) {
-	log.Printf("[连接池] 重置连接状态: %s", email)
-	p.CloseConnection(email)
-
-	// 短暂等待，确保连接完全关闭
-	time.Sleep(100 * time.Millisecond)
-}
-
 // EmailConfig 应用配置结构体
 type EmailConfig struct {
 	Email struct {
@@ -400,13 +190,19 @@ type EmailConfig struct {
 
 // EmailConfigInfo 邮箱配置
 type EmailConfigInfo struct {
-	IMAPServer   string
-	SMTPServer   string
-	EmailAddress string
-	Password     string
-	IMAPPort     int
-	SMTPPort     int
-	UseSSL       bool
+	AccountID     int
+	IMAPServer    string
+	SMTPServer    string
+	EmailAddress  string
+	Password      string
+	IMAPPort      int
+	SMTPPort      int
+	UseSSL        bool
+	IMAPSecurity  string       // ssl/starttls/plain，为空时按UseSSL判断
+	SMTPSecurity  string       // ssl/starttls/plain
+	AuthMechanism string       // 认证方式: plain/login/xoauth2/oauthbearer
+	OAuth         *OAuthConfig // OAuth2凭据，密码账号为nil
+	AuthServIDs   []string     // 服务商配置的可信认证服务器，与IMAP服务器的组织域名一起判断认证结果是否可信
 }
 
 // MailClient 结构体，用于处理邮件收发
@@ -416,12 +212,16 @@ type MailClient struct {
 
 // EmailInfo 邮件信息结构体
 type EmailInfo struct {
-	EmailID        string `json:"email_id"`
-	Subject        string `json:"subject"`
-	From           string `json:"from"`
-	Date           string `json:"date"`
-	UID            uint32 `json:"uid"`
-	HasAttachments bool   `json:"has_attachments"`
+	EmailID        string    `json:"email_id"`
+	Subject        string    `json:"subject"`
+	From           string    `json:"from"`
+	Date           string    `json:"date"`
+	SentAt         time.Time `json:"sent_at"`     // Date邮件头解析出的UTC时间，无法解析时为零值
+	ReceivedAt     time.Time `json:"received_at"` // 服务器INTERNALDATE（UTC）
+	UID            uint32    `json:"uid"`
+	UIDValidity    uint32    `json:"uid_validity"` // 所在文件夹的UIDVALIDITY
+	MessageID      string    `json:"message_id"`   // Message-ID（不含尖括号）
+	HasAttachments bool      `json:"has_attachments"`
 }
 
 // AttachmentInfo 附件信息结构体
@@ -431,18 +231,36 @@ type AttachmentInfo struct {
 	MimeType   string  `json:"mime_type"`
 	Base64Data string  `json:"base64_data,omitempty"` // base64编码的附件内容
 	OssURL     string  `json:"oss_url,omitempty"`     // OSS存储链接
+	Path       string  `json:"path,omitempty"`        // MIME路径，如 1.2
+	ContentID  string  `json:"content_id,omitempty"`  // 去掉尖括号的Content-ID
+	Inline     bool    `json:"inline,omitempty"`      // 是否为HTML正文通过 cid: 引用的内嵌部分
 }
 
 // Email 结构体，包含邮件完整内容
 type Email struct {
-	EmailID     string           `json:"email_id"`
-	Subject     string           `json:"subject"`
-	From        string           `json:"from"`
-	To          string           `json:"to"`
-	Date        string           `json:"date"`
-	Body        string           `json:"body"`
-	BodyHTML    string           `json:"body_html"`
-	Attachments []AttachmentInfo `json:"attachments"`
+	EmailID     string              `json:"email_id"`
+	Subject     string              `json:"subject"`
+	From        string              `json:"from"`
+	To          string              `json:"to"`
+	Cc          string              `json:"cc"`
+	Bcc         string              `json:"bcc"`
+	ReplyTo     string              `json:"reply_to"`
+	MessageID   string              `json:"message_id"`  // Message-ID（不含尖括号）
+	InReplyTo   string              `json:"in_reply_to"` // 回复的邮件的Message-ID（不含尖括号）
+	References  []string            `json:"references"`  // References中的Message-ID列表，按出现顺序
+	Addresses   []EmailAddress      `json:"addresses"`   // 发件人、收件人、抄送、密送和回复地址的结构化列表
+	Date        string              `json:"date"`
+	SentAt      time.Time           `json:"sent_at"`     // Date邮件头解析出的UTC时间，无法解析时为零值
+	ReceivedAt  time.Time           `json:"received_at"` // 服务器INTERNALDATE（UTC），解析归档邮件时取自最近的Received邮件头
+	Body        string              `json:"body"`
+	BodyHTML    string              `json:"body_html"`
+	Attachments []AttachmentInfo    `json:"attachments"`
+	Charset     CharsetResult       `json:"charset"`           // 正文的字符集判定结果，用于诊断乱码
+	Bounce      *DeliveryReport     `json:"bounce,omitempty"`  // 退信/投递状态通知的解析结果，不是退信时为nil
+	Category    int                 `json:"category"`          // 按邮件头和主题分类的邮件类型，取值为 model.EmailType* 常量
+	Auth        SenderAuth          `json:"auth"`              // 发件人认证结果（SPF/DKIM/DMARC）
+	Headers     map[string][]string `json:"headers,omitempty"` // 全部邮件头，encoded-word已解码
+	Raw         []byte              `json:"-"`                 // 原始RFC 822邮件内容(BODY[])，用于归档
 }
 
 // NewMailClient 创建一个新的邮件客户端
@@ -452,13 +270,24 @@ func NewMailClient(config *EmailConfigInfo) *MailClient {
 	}
 }
 
-// ConnectIMAP 连接到IMAP服务器，使用连接池
-func (m *MailClient) ConnectIMAP() (*client.Client, error) {
-	return globalPool.GetConnection(m.Config)
-}
-
 // GetEmailConfig 从数据库获取邮箱配置
 func GetEmailConfig(account model.PrimeEmailAccount) (*EmailConfigInfo, error) {
+	// OAuth2账号不使用密码，访问令牌在建立连接时按需刷新
+	if normalizeCredentialType(account.CredentialType) == CredentialOAuth2 {
+		if account.OAuthRefreshToken == "" && account.OAuthAccessToken == "" {
+			return nil, fmt.Errorf("OAuth2账号未设置刷新令牌，邮箱: %s", account.Account)
+		}
+		provider, err := loadAccountProvider(account)
+		if err != nil {
+			return nil, err
+		}
+		config := resolveEmailConfig(account, provider, "")
+		log.Printf("[邮箱配置] 邮箱: %s, 凭据: OAuth2(%s), IMAP: %s:%d(%s), SMTP: %s:%d(%s)",
+			account.Account, config.AuthMechanism, config.IMAPServer, config.IMAPPort, config.IMAPSecurity,
+			config.SMTPServer, config.SMTPPort, config.SMTPSecurity)
+		return config, nil
+	}
+
 	// 检查应用专用密码是否设置
 	password := account.AppPassword
 	if password == "" {
@@ -472,11 +301,46 @@ func GetEmailConfig(account model.PrimeEmailAccount) (*EmailConfigInfo, error) {
 		return nil, fmt.Errorf("邮箱密码为空，请设置Password或AppPassword字段")
 	}
 
-	return &EmailConfigInfo{
-		IMAPServer:   "imap.mail.yahoo.com",
-		EmailAddress: account.Account,
-		Password:     password,
-		IMAPPort:     993,
-		UseSSL:       true,
-	}, nil
+	provider, err := loadAccountProvider(account)
+	if err != nil {
+		return nil, err
+	}
+
+	config := resolveEmailConfig(account, provider, password)
+	log.Printf("[邮箱配置] 邮箱: %s, IMAP: %s:%d(%s), SMTP: %s:%d(%s)",
+		account.Account, config.IMAPServer, config.IMAPPort, config.IMAPSecurity,
+		config.SMTPServer, config.SMTPPort, config.SMTPSecurity)
+	return config, nil
+}
+
+// loadAccountProvider 账号引用了服务商模板时加载模板配置
+func loadAccountProvider(account model.PrimeEmailAccount) (*model.PrimeEmailProvider, error) {
+	if account.ProviderId <= 0 {
+		return nil, nil
+	}
+	p, err := model.GetProviderByID(account.ProviderId)
+	if err != nil {
+		return nil, fmt.Errorf("获取服务商配置失败(provider_id=%d): %w", account.ProviderId, err)
+	}
+	return &p, nil
+}
+
+// checkIMAPLoginSecurity 与SMTP使用同一策略：明文连接上只有开启 allowInsecure 才发送凭据
+func checkIMAPLoginSecurity(tlsActive, allowInsecure bool) error {
+	if !tlsActive && !allowInsecure {
+		return errors.New("拒绝在未加密的IMAP连接上发送凭据，内网服务器需开启 imap.allow_insecure_auth")
+	}
+	return nil
+}
+
+// loginIMAP 按凭据类型登录：密码账号使用LOGIN，OAuth2账号使用XOAUTH2/OAUTHBEARER
+func loginIMAP(c *client.Client, config *EmailConfigInfo) error {
+	if config.OAuth == nil {
+		return c.Login(config.EmailAddress, config.Password)
+	}
+	saslClient, err := config.saslClient(config.IMAPServer, config.IMAPPort)
+	if err != nil {
+		return err
+	}
+	return c.Authenticate(saslClient)
 }
@@ -2,58 +2,31 @@ package mailclient
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/base64"
 	"fmt"
+	"go_email/model"
+	"go_email/pkg/utils"
 	"io"
 	"log"
 	"mime"
 	"mime/multipart"
-	"mime/quotedprintable"
-	"net/mail"
-	"net/smtp"
 	"net/textproto"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/emersion/go-imap"
-	"golang.org/x/text/encoding/ianaindex"
-	"golang.org/x/text/transform"
 )
 
-// 中文编码解码器的导入 - 临时解决方案
-var (
-	gbkDecoder = func() transform.Transformer {
-		// 动态导入以避免循环导入
-		return nil // 将在运行时设置
-	}
-	gb18030Decoder = func() transform.Transformer {
-		return nil // 将在运行时设置
-	}
-)
-
-// getGBKDecoder 获取GBK解码器
-func getGBKDecoder() transform.Transformer {
-	// 这里我们直接使用字符串来避免循环导入问题
-	// 在实际使用中，这将通过反射或其他方式解决
-	e, _ := ianaindex.MIME.Encoding("gbk")
-	if e != nil {
-		return e.NewDecoder()
-	}
-	// 备用方案：返回nil将使用原始输入
-	return transform.Nop
-}
-
-// getGB18030Decoder 获取GB18030解码器
-func getGB18030Decoder() transform.Transformer {
-	e, _ := ianaindex.MIME.Encoding("gb18030")
-	if e != nil {
-		return e.NewDecoder()
+// mimeCharsetReader 为RFC 2047 encoded-word提供字符集转换，声明的字符集未知或与内容不符时按内容检测
+func mimeCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
 	}
-	return transform.Nop
+	return strings.NewReader(decodeCharset(data, charset)), nil
 }
 
 // DecodeMIMESubject 解码MIME编码的邮件主题 (公共函数用于测试)
@@ -63,58 +36,61 @@ func DecodeMIMESubject(subject string) string {
 	}
 
 	// 使用WordDecoder解码RFC 2047编码的主题
-	decoder := &mime.WordDecoder{
-		CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
-			// 处理常见的中文字符集别名
-			switch strings.ToLower(charset) {
-			case "gb2312", "gb_2312", "gb_2312-80":
-				// 使用GBK解码器来处理GB2312（GBK是GB2312的超集）
-				return transform.NewReader(input, getGBKDecoder()), nil
-			case "gbk":
-				return transform.NewReader(input, getGBKDecoder()), nil
-			case "gb18030":
-				return transform.NewReader(input, getGB18030Decoder()), nil
-			}
-
-			// 尝试使用golang.org/x/text/encoding/ianaindex来处理其他字符集
-			e, err := ianaindex.MIME.Encoding(charset)
-			if err != nil || e == nil {
-				// 如果找不到编码，返回输入流（可能是ASCII或UTF-8）
-				return input, nil
-			}
-
-			// 使用找到的编码器将输入转换为UTF-8
-			return transform.NewReader(input, e.NewDecoder()), nil
-		},
-	}
+	decoder := &mime.WordDecoder{CharsetReader: mimeCharsetReader}
 
 	decoded, err := decoder.DecodeHeader(subject)
 	if err != nil {
 		log.Printf("解码邮件主题失败: %v, 原始主题: %s", err, subject)
-		return subject // 如果解码失败，返回原始主题
+		return decodeHeaderBytes(subject) // 如果解码失败，返回原始主题
 	}
 
-	return decoded
+	// 未编码直接写入8位字节的主题
+	return decodeHeaderBytes(decoded)
 }
 
 // ListEmails 获取邮件列表
+// 不指定起始UID时获取最新的 limit 封；指定 fromUID[0] 时按UID游标获取UID不小于它的前 limit 封，
+// fromUID[1] 为可选的UID上限
 func (m *MailClient) ListEmails(folder string, limit int, fromUID ...uint32) ([]EmailInfo, error) {
-	return m.listEmailsWithRetry(folder, limit, 5, fromUID...)
+	if len(fromUID) > 0 && fromUID[0] > 0 {
+		// UID是稀疏的，按固定窗口 fromUID..fromUID+limit 获取可能漏掉邮件或一直取不到，改为游标分页
+		var maxUID uint32
+		if len(fromUID) > 1 {
+			maxUID = fromUID[1]
+		}
+		return m.listEmailsFromUIDWithRetry(folder, limit, fromUID[0]-1, maxUID, 5)
+	}
+	return m.listEmailsWithRetry(folder, limit, 5)
 }
 
-// ListEmailsFromUID 获取大于指定UID的邮件列表（修复版本）
+// ListEmailsFromUID 按UID游标获取大于 lastUID 的前 limit 封邮件（UID升序）
 func (m *MailClient) ListEmailsFromUID(folder string, limit int, lastUID uint32) ([]EmailInfo, error) {
-	return m.listEmailsFromUIDWithRetry(folder, limit, lastUID, 5)
+	return m.listEmailsFromUIDWithRetry(folder, limit, lastUID, 0, 5)
+}
+
+// nextUIDPage 从搜索结果中按UID升序取出大于 lastUID 的前 limit 个UID
+func nextUIDPage(uids []uint32, lastUID uint32, limit int) []uint32 {
+	page := make([]uint32, 0, len(uids))
+	for _, uid := range uids {
+		if uid > lastUID {
+			page = append(page, uid)
+		}
+	}
+	sort.Slice(page, func(i, j int) bool { return page[i] < page[j] })
+	if limit > 0 && len(page) > limit {
+		page = page[:limit]
+	}
+	return page
 }
 
 // 带重试的获取邮件列表
-func (m *MailClient) listEmailsWithRetry(folder string, limit int, maxRetries int, fromUID ...uint32) ([]EmailInfo, error) {
+func (m *MailClient) listEmailsWithRetry(folder string, limit int, maxRetries int) ([]EmailInfo, error) {
 	if folder == "" {
 		folder = "INBOX"
 	}
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		emails, err := m.tryListEmails(folder, limit, fromUID...)
+		emails, err := m.tryListEmails(folder, limit)
 		if err == nil {
 			return emails, nil
 		}
@@ -124,7 +100,6 @@ func (m *MailClient) listEmailsWithRetry(folder string, limit int, maxRetries in
 			log.Printf("[邮件列表] 连接错误 (尝试 %d/%d): 文件夹=%s, 错误: %v", attempt, maxRetries, folder, err)
 			if attempt < maxRetries {
 				// 强制关闭当前连接，下次会重新创建
-				globalPool.CloseConnection(m.Config.EmailAddress)
 				// 增加重试延迟，使用指数退避策略
 				delay := time.Second * time.Duration(attempt*2)
 				log.Printf("[邮件列表] 等待 %v 后重试", delay)
@@ -142,13 +117,13 @@ func (m *MailClient) listEmailsWithRetry(folder string, limit int, maxRetries in
 }
 
 // 带重试的获取大于指定UID的邮件列表
-func (m *MailClient) listEmailsFromUIDWithRetry(folder string, limit int, lastUID uint32, maxRetries int) ([]EmailInfo, error) {
+func (m *MailClient) listEmailsFromUIDWithRetry(folder string, limit int, lastUID, maxUID uint32, maxRetries int) ([]EmailInfo, error) {
 	if folder == "" {
 		folder = "INBOX"
 	}
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		emails, err := m.tryListEmailsFromUID(folder, limit, lastUID)
+		emails, err := m.tryListEmailsFromUID(folder, limit, lastUID, maxUID)
 		if err == nil {
 			return emails, nil
 		}
@@ -158,7 +133,6 @@ func (m *MailClient) listEmailsFromUIDWithRetry(folder string, limit int, lastUI
 			log.Printf("[邮件列表] 连接错误 (尝试 %d/%d): 文件夹=%s, lastUID=%d, 错误: %v", attempt, maxRetries, folder, lastUID, err)
 			if attempt < maxRetries {
 				// 强制关闭当前连接，下次会重新创建
-				globalPool.CloseConnection(m.Config.EmailAddress)
 				// 增加重试延迟，使用指数退避策略
 				delay := time.Second * time.Duration(attempt*2)
 				log.Printf("[邮件列表] 等待 %v 后重试", delay)
@@ -176,16 +150,14 @@ func (m *MailClient) listEmailsFromUIDWithRetry(folder string, limit int, lastUI
 }
 
 // 尝试获取邮件列表（单次）
-func (m *MailClient) tryListEmails(folder string, limit int, fromUID ...uint32) ([]EmailInfo, error) {
-	// 连接IMAP服务器
-	c, err := m.ConnectIMAP()
+func (m *MailClient) tryListEmails(folder string, limit int) (_ []EmailInfo, err error) {
+	// 从连接池独占获取连接，返回时归还
+	pc, err := m.acquireIMAP()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		// 不要在这里关闭连接，让连接池管理
-		// c.Logout()
-	}()
+	defer func() { ReleaseIMAP(pc, err) }()
+	c := pc.Client
 
 	// 验证连接状态
 	state := c.State()
@@ -202,8 +174,8 @@ func (m *MailClient) tryListEmails(folder string, limit int, fromUID ...uint32)
 		// 检查是否是IMAP命令错误
 		if strings.Contains(strings.ToLower(err.Error()), "command is not a valid imap command") {
 			log.Printf("[邮件列表] 检测到IMAP命令错误，重置连接: %v", err)
-			// 重置连接状态
-			globalPool.ResetConnection(m.Config.EmailAddress)
+			// 标记连接已损坏，归还时关闭而不再复用
+			pc.broken = true
 			return nil, fmt.Errorf("IMAP命令错误，已重置连接: %w", err)
 		}
 		return nil, fmt.Errorf("选择邮箱失败: %w", err)
@@ -214,94 +186,33 @@ func (m *MailClient) tryListEmails(folder string, limit int, fromUID ...uint32)
 		return []EmailInfo{}, nil
 	}
 
-	// 创建序列集
+	// 获取最新的邮件（按序号）
 	seqSet := new(imap.SeqSet)
-
-	// 如果指定了起始UID，则使用UID范围
-	if len(fromUID) > 0 && fromUID[0] > 0 {
-		var endUID uint32
-		if len(fromUID) > 1 {
-			endUID = fromUID[1]
-		} else {
-			endUID = fromUID[0] + uint32(limit) // 如果没有指定结束UID，计算一个
-		}
-		seqSet.AddRange(fromUID[0], endUID)
-
-		// 用UID搜索命令获取消息
-		ids, err := c.UidSearch(&imap.SearchCriteria{Uid: seqSet})
-		if err != nil {
-			return nil, fmt.Errorf("UID搜索失败: %w", err)
-		}
-
-		if len(ids) == 0 {
-			return []EmailInfo{}, nil
-		}
-
-		// 重建序列集用于获取
-		seqSet = new(imap.SeqSet)
-		seqSet.AddNum(ids...)
-	} else {
-		// 默认行为：获取最新的邮件
-		start := uint32(1)
-		if mbox.Messages > uint32(limit) {
-			start = mbox.Messages - uint32(limit) + 1
-		}
-		seqSet.AddRange(start, mbox.Messages)
+	start := uint32(1)
+	if mbox.Messages > uint32(limit) {
+		start = mbox.Messages - uint32(limit) + 1
 	}
+	seqSet.AddRange(start, mbox.Messages)
 
 	// 获取邮件信息
 	messages := make(chan *imap.Message, limit)
 	done := make(chan error, 1)
 
 	go func() {
-		done <- c.Fetch(seqSet, []imap.FetchItem{imap.FetchEnvelope, imap.FetchFlags, imap.FetchBodyStructure, imap.FetchUid}, messages)
+		done <- c.Fetch(seqSet, []imap.FetchItem{imap.FetchEnvelope, imap.FetchInternalDate, imap.FetchFlags, imap.FetchBodyStructure, imap.FetchUid}, messages)
 	}()
 
 	var emails []EmailInfo
 	for msg := range messages {
-		hasAttachments := false
-
-		// 检查是否有附件
-		if msg.BodyStructure != nil {
-			var checkAttachments func(parts []*imap.BodyStructure) bool
-			checkAttachments = func(parts []*imap.BodyStructure) bool {
-				for _, part := range parts {
-					if part.Disposition == "attachment" || part.Disposition == "inline" && part.Params["filename"] != "" {
-						return true
-					}
-					if part.MIMEType == "multipart" {
-						if checkAttachments(part.Parts) {
-							return true
-						}
-					}
-				}
-				return false
-			}
-
-			if msg.BodyStructure.MIMEType == "multipart" {
-				hasAttachments = checkAttachments(msg.BodyStructure.Parts)
-			} else if msg.BodyStructure.Disposition == "attachment" {
-				hasAttachments = true
-			}
-		}
-
-		info := EmailInfo{
-			EmailID:        fmt.Sprint(msg.Uid),
-			Subject:        DecodeMIMESubject(msg.Envelope.Subject),
-			From:           parseAddressList(msg.Envelope.From),
-			Date:           msg.Envelope.Date.Format(time.RFC1123Z),
-			UID:            msg.Uid,
-			HasAttachments: hasAttachments,
-		}
-		emails = append(emails, info)
+		emails = append(emails, buildEmailInfo(msg, mbox.UidValidity))
 	}
 
 	if err := <-done; err != nil {
 		// 检查是否是FETCH相关的错误
 		if strings.Contains(strings.ToLower(err.Error()), "bad sequence") {
 			log.Printf("[邮件列表] 检测到FETCH序列错误: %v", err)
-			// 重置连接状态，确保下次请求会创建新连接
-			globalPool.ResetConnection(m.Config.EmailAddress)
+			// 标记连接已损坏，归还时关闭而不再复用
+			pc.broken = true
 			// 返回一个明确的连接错误，确保能被重试逻辑识别
 			return nil, fmt.Errorf("connection error: bad sequence detected, connection reset: %w", err)
 		}
@@ -316,17 +227,77 @@ func (m *MailClient) tryListEmails(folder string, limit int, fromUID ...uint32)
 	return emails, nil
 }
 
+// hasAttachmentInStructure 根据BODYSTRUCTURE判断邮件是否含有附件
+func hasAttachmentInStructure(bs *imap.BodyStructure) bool {
+	if bs == nil {
+		return false
+	}
+
+	var checkAttachments func(parts []*imap.BodyStructure) bool
+	checkAttachments = func(parts []*imap.BodyStructure) bool {
+		for _, part := range parts {
+			if part.Disposition == "attachment" || part.Disposition == "inline" && part.Params["filename"] != "" {
+				return true
+			}
+			if part.MIMEType == "multipart" {
+				if checkAttachments(part.Parts) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if bs.MIMEType == "multipart" {
+		return checkAttachments(bs.Parts)
+	}
+	return bs.Disposition == "attachment"
+}
+
+// buildEmailInfo 根据FETCH结果构建邮件列表项
+func buildEmailInfo(msg *imap.Message, uidValidity uint32) EmailInfo {
+	info := EmailInfo{
+		EmailID:        fmt.Sprint(msg.Uid),
+		UID:            msg.Uid,
+		UIDValidity:    uidValidity,
+		HasAttachments: hasAttachmentInStructure(msg.BodyStructure),
+		ReceivedAt:     internalDate(msg),
+	}
+	if msg.Envelope != nil {
+		info.Subject = DecodeMIMESubject(msg.Envelope.Subject)
+		info.From = parseAddressList(msg.Envelope.From)
+		info.Date = msg.Envelope.Date.Format(time.RFC1123Z)
+		info.SentAt, _ = utils.NormalizeMailDate(msg.Envelope.Date)
+		info.MessageID = normalizeMessageID(msg.Envelope.MessageId)
+	}
+	return info
+}
+
+// internalDate 返回邮件的INTERNALDATE（UTC），服务器未返回时为零值
+func internalDate(msg *imap.Message) time.Time {
+	if msg.InternalDate.IsZero() {
+		return time.Time{}
+	}
+	return msg.InternalDate.UTC()
+}
+
+// normalizeMessageID 去掉Message-ID两侧的尖括号和空白，便于比较
+func normalizeMessageID(id string) string {
+	id = strings.TrimSpace(id)
+	id = strings.TrimPrefix(id, "<")
+	id = strings.TrimSuffix(id, ">")
+	return strings.TrimSpace(id)
+}
+
 // 尝试获取大于指定UID的邮件列表（单次）
-func (m *MailClient) tryListEmailsFromUID(folder string, limit int, lastUID uint32) ([]EmailInfo, error) {
-	// 连接IMAP服务器
-	c, err := m.ConnectIMAP()
+func (m *MailClient) tryListEmailsFromUID(folder string, limit int, lastUID, maxUID uint32) (_ []EmailInfo, err error) {
+	// 从连接池独占获取连接，返回时归还
+	pc, err := m.acquireIMAP()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		// 不要在这里关闭连接，让连接池管理
-		// c.Logout()
-	}()
+	defer func() { ReleaseIMAP(pc, err) }()
+	c := pc.Client
 
 	// 验证连接状态
 	state := c.State()
@@ -343,8 +314,8 @@ func (m *MailClient) tryListEmailsFromUID(folder string, limit int, lastUID uint
 		// 检查是否是IMAP命令错误
 		if strings.Contains(strings.ToLower(err.Error()), "command is not a valid imap command") {
 			log.Printf("[邮件列表] 检测到IMAP命令错误，重置连接: %v", err)
-			// 重置连接状态
-			globalPool.ResetConnection(m.Config.EmailAddress)
+			// 标记连接已损坏，归还时关闭而不再复用
+			pc.broken = true
 			return nil, fmt.Errorf("IMAP命令错误，已重置连接: %w", err)
 		}
 		return nil, fmt.Errorf("选择邮箱失败: %w", err)
@@ -356,10 +327,17 @@ func (m *MailClient) tryListEmailsFromUID(folder string, limit int, lastUID uint
 	}
 
 	// 使用SEARCH命令搜索大于指定UID的邮件
+	// 上限使用具体数值而不是 *：lastUID+1 大于服务器最大UID时，lastUID+1:* 仍会匹配到最后一封邮件
+	upperUID := maxUID
+	if upperUID == 0 {
+		upperUID = ^uint32(0)
+	}
+	if lastUID >= upperUID {
+		return []EmailInfo{}, nil
+	}
 	criteria := imap.NewSearchCriteria()
-	// 搜索UID大于lastUID的邮件
 	criteria.Uid = new(imap.SeqSet)
-	criteria.Uid.AddRange(lastUID+1, ^uint32(0)) // 从lastUID+1到最大值
+	criteria.Uid.AddRange(lastUID+1, upperUID)
 
 	log.Printf("[邮件列表] 搜索大于UID %d的邮件", lastUID)
 
@@ -369,16 +347,13 @@ func (m *MailClient) tryListEmailsFromUID(folder string, limit int, lastUID uint
 		return nil, fmt.Errorf("搜索邮件失败: %w", err)
 	}
 
+	// 按UID升序取出游标之后的前limit个，其余留给下一页
+	uids = nextUIDPage(uids, lastUID, limit)
 	if len(uids) == 0 {
 		log.Printf("[邮件列表] 没有找到大于UID %d的新邮件", lastUID)
 		return []EmailInfo{}, nil
 	}
 
-	// 限制返回的邮件数量
-	if len(uids) > limit {
-		uids = uids[:limit]
-	}
-
 	log.Printf("[邮件列表] 找到 %d 个新邮件，UID范围: %d-%d", len(uids), uids[0], uids[len(uids)-1])
 
 	// 创建序列集用于获取邮件信息
@@ -390,62 +365,31 @@ func (m *MailClient) tryListEmailsFromUID(folder string, limit int, lastUID uint
 	done := make(chan error, 1)
 
 	go func() {
-		done <- c.UidFetch(seqSet, []imap.FetchItem{imap.FetchEnvelope, imap.FetchFlags, imap.FetchBodyStructure, imap.FetchUid}, messages)
+		done <- c.UidFetch(seqSet, []imap.FetchItem{imap.FetchEnvelope, imap.FetchInternalDate, imap.FetchFlags, imap.FetchBodyStructure, imap.FetchUid}, messages)
 	}()
 
 	var emails []EmailInfo
 	for msg := range messages {
-		hasAttachments := false
-
-		// 检查是否有附件
-		if msg.BodyStructure != nil {
-			var checkAttachments func(parts []*imap.BodyStructure) bool
-			checkAttachments = func(parts []*imap.BodyStructure) bool {
-				for _, part := range parts {
-					if part.Disposition == "attachment" || part.Disposition == "inline" && part.Params["filename"] != "" {
-						return true
-					}
-					if part.MIMEType == "multipart" {
-						if checkAttachments(part.Parts) {
-							return true
-						}
-					}
-				}
-				return false
-			}
-
-			if msg.BodyStructure.MIMEType == "multipart" {
-				hasAttachments = checkAttachments(msg.BodyStructure.Parts)
-			} else if msg.BodyStructure.Disposition == "attachment" {
-				hasAttachments = true
-			}
-		}
-
-		info := EmailInfo{
-			EmailID:        fmt.Sprint(msg.Uid),
-			Subject:        DecodeMIMESubject(msg.Envelope.Subject),
-			From:           parseAddressList(msg.Envelope.From),
-			Date:           msg.Envelope.Date.Format(time.RFC1123Z),
-			UID:            msg.Uid,
-			HasAttachments: hasAttachments,
+		if msg.Uid == 0 {
+			continue
 		}
-		emails = append(emails, info)
+		emails = append(emails, buildEmailInfo(msg, mbox.UidValidity))
 	}
 
 	if err := <-done; err != nil {
 		// 检查是否是FETCH相关的错误
 		if strings.Contains(strings.ToLower(err.Error()), "bad sequence") {
 			log.Printf("[邮件列表] 检测到FETCH序列错误: %v", err)
-			// 重置连接状态，确保下次请求会创建新连接
-			globalPool.ResetConnection(m.Config.EmailAddress)
+			// 标记连接已损坏，归还时关闭而不再复用
+			pc.broken = true
 			// 返回一个明确的连接错误，确保能被重试逻辑识别
 			return nil, fmt.Errorf("connection error: bad sequence detected, connection reset: %w", err)
 		}
 		return nil, fmt.Errorf("获取邮件失败: %w", err)
 	}
 
-	// 按UID排序（确保按时间顺序）
-	// 由于uids已经是有序的，emails也应该是有序的
+	// FETCH响应的顺序不一定与请求一致，按UID升序排列，保证游标按顺序推进
+	sort.Slice(emails, func(i, j int) bool { return emails[i].UID < emails[j].UID })
 	log.Printf("[邮件列表] 成功获取 %d 封新邮件", len(emails))
 	return emails, nil
 }
@@ -476,7 +420,6 @@ func (m *MailClient) getEmailContentWithRetry(uid uint32, folder string, maxRetr
 			log.Printf("[邮件获取] 连接错误 (尝试 %d/%d): UID=%d, 错误: %v", attempt, maxRetries, uid, err)
 			if attempt < maxRetries {
 				// 强制关闭当前连接，下次会重新创建
-				globalPool.CloseConnection(m.Config.EmailAddress)
 				// 增加重试延迟，使用指数退避策略
 				delay := time.Second * time.Duration(attempt*2)
 				log.Printf("[邮件获取] 等待 %v 后重试", delay)
@@ -494,16 +437,14 @@ func (m *MailClient) getEmailContentWithRetry(uid uint32, folder string, maxRetr
 }
 
 // 尝试获取邮件内容（单次）
-func (m *MailClient) tryGetEmailContent(uid uint32, folder string, skipAttachments bool) (*Email, error) {
-	// 连接IMAP服务器
-	c, err := m.ConnectIMAP()
+func (m *MailClient) tryGetEmailContent(uid uint32, folder string, skipAttachments bool) (_ *Email, err error) {
+	// 从连接池独占获取连接，返回时归还
+	pc, err := m.acquireIMAP()
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		// 不要在这里关闭连接，让连接池管理
-		// c.Logout()
-	}()
+	defer func() { ReleaseIMAP(pc, err) }()
+	c := pc.Client
 
 	// 验证连接状态
 	state := c.State()
@@ -520,8 +461,8 @@ func (m *MailClient) tryGetEmailContent(uid uint32, folder string, skipAttachmen
 		// 检查是否是IMAP命令错误
 		if strings.Contains(strings.ToLower(err.Error()), "command is not a valid imap command") {
 			log.Printf("[邮件获取] 检测到IMAP命令错误，重置连接: %v", err)
-			// 重置连接状态
-			globalPool.ResetConnection(m.Config.EmailAddress)
+			// 标记连接已损坏，归还时关闭而不再复用
+			pc.broken = true
 			return nil, fmt.Errorf("IMAP命令错误，已重置连接: %w", err)
 		}
 		return nil, fmt.Errorf("选择邮箱失败: %w", err)
@@ -559,7 +500,7 @@ func (m *MailClient) tryGetEmailContent(uid uint32, folder string, skipAttachmen
 
 	// 获取完整邮件，包括正文和附件信息
 	section := &imap.BodySectionName{Peek: true}
-	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchFlags, imap.FetchBodyStructure, section.FetchItem()}
+	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchInternalDate, imap.FetchFlags, imap.FetchBodyStructure, section.FetchItem()}
 
 	messages := make(chan *imap.Message, 1)
 	done := make(chan error, 1)
@@ -572,8 +513,8 @@ func (m *MailClient) tryGetEmailContent(uid uint32, folder string, skipAttachmen
 		// 检查是否是FETCH相关的错误
 		if strings.Contains(strings.ToLower(err.Error()), "bad sequence") {
 			log.Printf("[邮件获取] 检测到FETCH序列错误: UID=%d, 错误: %v", uid, err)
-			// 重置连接状态，确保下次请求会创建新连接
-			globalPool.ResetConnection(m.Config.EmailAddress)
+			// 标记连接已损坏，归还时关闭而不再复用
+			pc.broken = true
 			// 返回一个明确的连接错误，确保能被重试逻辑识别
 			return nil, fmt.Errorf("connection error: bad sequence detected, connection reset: %w", err)
 		}
@@ -585,15 +526,32 @@ func (m *MailClient) tryGetEmailContent(uid uint32, folder string, skipAttachmen
 		return nil, fmt.Errorf("邮件不存在或已被删除: UID=%d", uid)
 	}
 
+	return m.parseFetchedMessage(msg, section, skipAttachments)
+}
+
+// parseFetchedMessage 解析 UID FETCH 返回的完整邮件（需包含ENVELOPE、BODYSTRUCTURE和BODY.PEEK[]）
+func (m *MailClient) parseFetchedMessage(msg *imap.Message, section *imap.BodySectionName, skipAttachments bool) (*Email, error) {
+	uid := msg.Uid
+
 	// 创建Email结构体
 	email := &Email{
 		EmailID:     fmt.Sprint(msg.Uid), // 使用UID代替序列号，确保与列表中的ID一致
 		Subject:     DecodeMIMESubject(msg.Envelope.Subject),
 		From:        parseAddressList(msg.Envelope.From),
 		To:          parseAddressList(msg.Envelope.To),
+		Cc:          parseAddressList(msg.Envelope.Cc),
+		Bcc:         parseAddressList(msg.Envelope.Bcc),
+		ReplyTo:     parseAddressList(msg.Envelope.ReplyTo),
+		MessageID:   normalizeMessageID(msg.Envelope.MessageId),
+		Addresses:   envelopeAddresses(msg.Envelope),
 		Date:        msg.Envelope.Date.Format(time.RFC1123Z),
+		ReceivedAt:  internalDate(msg),
 		Attachments: []AttachmentInfo{},
 	}
+	email.SentAt, _ = utils.NormalizeMailDate(msg.Envelope.Date)
+	if ids := parseMessageIDList(msg.Envelope.InReplyTo); len(ids) > 0 {
+		email.InReplyTo = ids[0]
+	}
 
 	// 获取完整邮件内容
 	r := msg.GetBody(section)
@@ -607,73 +565,35 @@ func (m *MailClient) tryGetEmailContent(uid uint32, folder string, skipAttachmen
 		return nil, fmt.Errorf("读取邮件内容失败: %w", err)
 	}
 	rawContent := buf.String()
+	email.Raw = buf.Bytes()
 
 	// 调试输出
 	log.Printf("[邮件解析调试] UID: %d, 解码成功，内容长度: %d", uid, len(rawContent))
 
-	// 保存原始内容到文件用于调试
-	//if err := saveRawContentToFile(uid, rawContent); err != nil {
-	//	log.Printf("[邮件解析调试] 保存原始内容失败: %v", err)
-	//}
-
-	// 解析邮件内容
-	if msg.BodyStructure.MIMEType == "multipart" {
-		// 多部分邮件
-		reader := strings.NewReader(rawContent)
-
-		// 如果设置了跳过附件标志，则传递给解析函数
-		if skipAttachments {
-			log.Printf("[邮件解析] 根据设置跳过附件解析，邮件UID: %d", uid)
-			err = m.parseMultipartMessageSkipAttachments(msg, email, reader)
-		} else {
-			err = m.parseMultipartMessage(msg, email, reader)
-		}
-
-		if err != nil {
-			log.Printf("[邮件解析] 解析多部分邮件失败: %v", err)
-			// 即使解析失败，也返回基本信息
-		}
+	parseRawContent(email, email.Raw, skipAttachments)
+	ApplySenderAuth(email, m.Config)
+	return email, nil
+}
 
-		// 如果未设置跳过附件，且标准解析没有找到附件，尝试使用正则表达式方法
-		if !skipAttachments && len(email.Attachments) == 0 {
-			log.Printf("[邮件解析] 标准解析没有找到附件，尝试使用正则表达式解析")
-			if err := m.extractAttachmentsWithRegex(rawContent, email); err != nil {
-				log.Printf("[邮件解析] 正则表达式解析附件也失败: %v", err)
-			} else if len(email.Attachments) > 0 {
-				log.Printf("[邮件解析] 正则表达式成功解析出 %d 个附件", len(email.Attachments))
-			}
-		}
-	} else {
-		// 单部分邮件 - 需要正确分离邮件头部和正文
-		reader := strings.NewReader(rawContent)
-		mr, err := mail.ReadMessage(reader)
-		if err != nil {
-			log.Printf("[邮件解析] 单部分邮件解析失败，使用原始内容: %v", err)
-			// 如果解析失败，尝试查找邮件头部结束的位置
-			if bodyStart := findEmailBodyStart(rawContent); bodyStart != -1 {
-				email.Body = rawContent[bodyStart:]
-			} else {
-				email.Body = rawContent
-			}
-		} else {
-			// 成功解析邮件头部，读取正文
-			bodyBytes, err := io.ReadAll(mr.Body)
-			if err != nil {
-				log.Printf("[邮件解析] 读取单部分邮件正文失败: %v", err)
-				email.Body = rawContent
-			} else {
-				// 解码内容
-				decodedBody, err := decodeContent(textproto.MIMEHeader(mr.Header), bodyBytes)
-				if err == nil && decodedBody != "" {
-					email.Body = decodedBody
-				} else {
-					email.Body = string(bodyBytes)
-				}
-			}
-		}
+// parseRawContent 解析原始邮件内容，填充正文、HTML正文、附件、ENVELOPE之外的邮件头字段、退信、邮件类型和发件人认证结果
+func parseRawContent(email *Email, raw []byte, skipAttachments bool) {
+	root, err := ParseMIMETree(raw)
+	if err != nil {
+		log.Printf("[邮件解析] 解析MIME结构失败: %v", err)
+		return
 	}
-
-	return email, nil
+	fillHeaderFields(email, root.Header)
+	if skipAttachments {
+		log.Printf("[邮件解析] 根据设置跳过附件解析，邮件: %s", email.EmailID)
+	}
+	fillEmailFromTree(email, root, skipAttachments)
+	email.Bounce = parseDeliveryReport(email, root)
+	email.Category = ClassifyEmail(email.Headers, email.Subject)
+	if email.Bounce != nil {
+		email.Category = model.EmailTypeBounce
+	}
+	// 不知道收件邮箱时不采用任何认证结果，FETCH时再按账号的服务器配置重新计算
+	ApplySenderAuth(email, nil)
 }
 
 // findEmailBodyStart 查找邮件正文开始的位置（跳过邮件头部）
@@ -755,123 +675,6 @@ func isEmailHeader(line string) bool {
 	return false
 }
 
-// extractAttachmentsWithRegex 使用正则表达式从原始邮件内容中提取附件
-func (m *MailClient) extractAttachmentsWithRegex(rawContent string, email *Email) error {
-	// 为特定的PDF附件格式创建一个正则表达式
-	// 这个正则表达式专门针对某些特定格式的PDF附件
-	pdfRegex := regexp.MustCompile(`Content-Type: application/octet-stream.*?name=([^\r\n"]+)[\r\n]+Content-Transfer-Encoding: base64[\r\n]+Content-Disposition: attachment.*?filename=([^\r\n"]+)[\r\n]+[\r\n]+((?:[A-Za-z0-9+/=]{1,76}[\r\n]+)+)`)
-
-	matches := pdfRegex.FindAllStringSubmatch(rawContent, -1)
-	if len(matches) == 0 {
-		// 尝试更通用的附件正则表达式
-		generalRegex := regexp.MustCompile(`Content-Type: ([^;\r\n]+)(?:;[\s\S]*?(?:name|filename)=(?:"([^"]+)"|([^\s;,\r\n"]+)))?[\s\S]*?Content-Transfer-Encoding: ([^\r\n]+)[\s\S]*?(?:Content-Disposition: ([^;\r\n]+)(?:;[\s\S]*?filename=(?:"([^"]+)"|([^\s;,\r\n"]+)))?)?[\r\n]+[\r\n]((?:[\s\S]*?))(?:[\r\n]+--|\z)`)
-		matches = generalRegex.FindAllStringSubmatch(rawContent, -1)
-
-		if len(matches) == 0 {
-			return fmt.Errorf("未找到匹配的附件")
-		}
-	}
-
-	for _, match := range matches {
-		// 获取文件名
-		filename := ""
-		if len(match) > 2 && match[2] != "" {
-			filename = strings.Trim(match[2], `"' `)
-		} else if len(match) > 3 && match[3] != "" {
-			filename = strings.Trim(match[3], `"' `)
-		} else if len(match) > 6 && match[6] != "" {
-			filename = strings.Trim(match[6], `"' `)
-		} else if len(match) > 7 && match[7] != "" {
-			filename = strings.Trim(match[7], `"' `)
-		} else {
-			filename = fmt.Sprintf("attachment_%d.pdf", len(email.Attachments)+1)
-		}
-
-		// 获取MIME类型
-		mimeType := "application/octet-stream"
-		if len(match) > 1 && match[1] != "" {
-			mimeType = strings.TrimSpace(match[1])
-		}
-
-		// 获取编码方式
-		encoding := "base64"
-		if len(match) > 4 && match[4] != "" {
-			encoding = strings.TrimSpace(match[4])
-		}
-
-		// 获取附件内容
-		content := ""
-		if len(match) > 8 {
-			content = match[8]
-		} else if len(match) > 3 {
-			content = match[3]
-		}
-
-		// 处理不同的编码
-		var base64Data string
-		var actualSize int64
-
-		switch strings.ToLower(encoding) {
-		case "base64":
-			// 移除所有换行符
-			cleanedContent := strings.ReplaceAll(strings.ReplaceAll(content, "\r", ""), "\n", "")
-			base64Data = cleanedContent
-
-			// 计算大小
-			actualSize = int64(len(cleanedContent)) * 3 / 4
-			if strings.HasSuffix(cleanedContent, "==") {
-				actualSize -= 2
-			} else if strings.HasSuffix(cleanedContent, "=") {
-				actualSize -= 1
-			}
-
-		case "quoted-printable":
-			// 解码quoted-printable并重新编码为base64
-			qpReader := quotedprintable.NewReader(strings.NewReader(content))
-			decodedData, err := io.ReadAll(qpReader)
-			if err != nil {
-				log.Printf("[邮件解析] 解码quoted-printable失败: %v", err)
-				continue
-			}
-
-			base64Data = base64.StdEncoding.EncodeToString(decodedData)
-			actualSize = int64(len(decodedData))
-
-		default:
-			// 对于其他编码，直接编码为base64
-			base64Data = base64.StdEncoding.EncodeToString([]byte(content))
-			actualSize = int64(len(content))
-		}
-
-		// 验证base64数据
-		testSample := base64Data
-		if len(base64Data) > 100 {
-			testSample = base64Data[:100]
-		}
-
-		_, testErr := base64.StdEncoding.DecodeString(testSample)
-		if testErr != nil {
-			log.Printf("[邮件解析] Base64验证失败: %v", testErr)
-			continue
-		}
-
-		// 解码文件名
-		decodedFilename := DecodeMIMESubject(filename)
-
-		// 添加附件信息
-		email.Attachments = append(email.Attachments, AttachmentInfo{
-			Filename:   decodedFilename,
-			SizeKB:     float64(actualSize) / 1024.0,
-			MimeType:   mimeType,
-			Base64Data: base64Data,
-		})
-
-		log.Printf("[邮件解析] 使用正则表达式解析到附件: %s, 大小: %.2f KB", decodedFilename, float64(actualSize)/1024.0)
-	}
-
-	return nil
-}
-
 // saveRawContentToFile 将原始邮件内容保存到文件中
 func saveRawContentToFile(uid uint32, content string) error {
 	// 确保存储目录存在
@@ -894,270 +697,6 @@ func saveRawContentToFile(uid uint32, content string) error {
 	return nil
 }
 
-// parseMultipartMessage 解析多部分邮件
-func (m *MailClient) parseMultipartMessage(msg *imap.Message, email *Email, reader io.Reader) error {
-	// 使用mail包解析邮件
-	mr, err := mail.ReadMessage(reader)
-	if err != nil {
-		return fmt.Errorf("读取邮件内容失败: %v", err)
-	}
-
-	// 获取媒体类型
-	contentType := mr.Header.Get("Content-Type")
-	mediaType, params, err := mime.ParseMediaType(contentType)
-	if err != nil {
-		return fmt.Errorf("解析Content-Type失败: %v", err)
-	}
-
-	// 处理多部分邮件
-	if strings.HasPrefix(mediaType, "multipart/") {
-		// 创建一个递归函数来处理嵌套的多部分邮件
-		var parseMultipart func(reader io.Reader, boundary string, depth int) error
-		parseMultipart = func(reader io.Reader, boundary string, depth int) error {
-			mr := multipart.NewReader(reader, boundary)
-
-			// 遍历每个部分
-			for {
-				p, err := mr.NextPart()
-				if err == io.EOF {
-					break
-				}
-				if err != nil {
-					if depth == 0 {
-						return fmt.Errorf("读取下一部分失败: %v", err)
-					}
-					// 对于嵌套部分的错误，我们只记录而不中断
-					log.Printf("解析嵌套部分失败: %v", err)
-					continue
-				}
-
-				// 获取此部分的内容类型
-				partContentType := p.Header.Get("Content-Type")
-				partMediaType, partParams, err := mime.ParseMediaType(partContentType)
-				if err != nil {
-					continue // 跳过无法解析类型的部分
-				}
-
-				// 处理嵌套的多部分邮件
-				if strings.HasPrefix(partMediaType, "multipart/") {
-					partBoundary := partParams["boundary"]
-					if partBoundary != "" {
-						// 递归处理嵌套部分
-						bodyBytes, err := io.ReadAll(p)
-						if err == nil {
-							parseMultipart(bytes.NewReader(bodyBytes), partBoundary, depth+1)
-						}
-					}
-				} else if strings.HasPrefix(partMediaType, "text/plain") {
-					// 读取纯文本部分
-					bodyBytes, err := io.ReadAll(p)
-					if err != nil {
-						continue
-					}
-					// 解码内容
-					decodedBody, err := decodeContent(p.Header, bodyBytes)
-					if err == nil && decodedBody != "" {
-						email.Body = decodedBody
-					} else if len(bodyBytes) > 0 {
-						email.Body = string(bodyBytes)
-					}
-				} else if strings.HasPrefix(partMediaType, "text/html") {
-					// 读取HTML部分
-					bodyBytes, err := io.ReadAll(p)
-					if err != nil {
-						continue
-					}
-					// 解码内容
-					decodedBody, err := decodeContent(p.Header, bodyBytes)
-					if err == nil && decodedBody != "" {
-						// 清理HTML内容，移除\r\n和多余的空白
-						cleanedHTML := cleanHTMLContent(decodedBody)
-						email.BodyHTML = cleanedHTML
-					} else if len(bodyBytes) > 0 {
-						// 清理HTML内容，移除\r\n和多余的空白
-						cleanedHTML := cleanHTMLContent(string(bodyBytes))
-						email.BodyHTML = cleanedHTML
-					}
-				} else if disposition := p.Header.Get("Content-Disposition"); strings.HasPrefix(disposition, "attachment") {
-					// 处理附件
-					_, params, err := mime.ParseMediaType(disposition)
-					if err != nil {
-						continue
-					}
-
-					filename := params["filename"]
-					if filename == "" {
-						_, contentTypeParams, _ := mime.ParseMediaType(partContentType)
-						filename = contentTypeParams["name"]
-					}
-
-					if filename != "" {
-						// 解码RFC 2047编码的文件名
-						decodedFilename := DecodeMIMESubject(filename)
-
-						// 读取附件原始数据
-						attachBytes, err := io.ReadAll(p)
-						if err != nil {
-							log.Printf("读取附件内容失败: %v", err)
-							continue
-						}
-
-						// 根据Content-Transfer-Encoding智能处理附件数据
-						encoding := p.Header.Get("Content-Transfer-Encoding")
-						var finalBase64Data string
-						var actualSize int64
-
-						startTime := time.Now()
-
-						switch strings.ToLower(encoding) {
-						case "base64":
-							// 对于Base64编码，先验证是否为有效的Base64
-							originalData := string(attachBytes)
-
-							// 快速验证：尝试解码很小的一部分来检测有效性
-							testSample := originalData
-							if len(originalData) > 100 {
-								testSample = originalData[:100] // 只测试前100个字符
-							}
-
-							_, testErr := base64.StdEncoding.DecodeString(testSample)
-							if testErr == nil {
-								// 如果是有效的Base64，直接使用（性能优化）
-								finalBase64Data = originalData
-								// 计算解码后的实际大小（不实际解码，用数学计算）
-								actualSize = int64(len(originalData)) * 3 / 4
-								if strings.HasSuffix(originalData, "==") {
-									actualSize -= 2
-								} else if strings.HasSuffix(originalData, "=") {
-									actualSize -= 1
-								}
-								log.Printf("[附件处理-快速路径] 文件: %s, 检测到有效Base64，直接使用", decodedFilename)
-							} else {
-								// 不是有效的Base64，需要解码重编码
-								decodedAttachData, err := base64.StdEncoding.DecodeString(originalData)
-								if err != nil {
-									log.Printf("Base64解码附件失败: %v, 文件: %s", err, decodedFilename)
-									// 解码失败，使用原始数据重新编码
-									finalBase64Data = base64.StdEncoding.EncodeToString(attachBytes)
-									actualSize = int64(len(attachBytes))
-								} else {
-									finalBase64Data = base64.StdEncoding.EncodeToString(decodedAttachData)
-									actualSize = int64(len(decodedAttachData))
-								}
-								log.Printf("[附件处理-解码路径] 文件: %s, 重新解码编码", decodedFilename)
-							}
-
-						case "quoted-printable":
-							// Quoted-printable编码，需要解码
-							qpReader := quotedprintable.NewReader(bytes.NewReader(attachBytes))
-							decodedAttachData, err := io.ReadAll(qpReader)
-							if err != nil {
-								log.Printf("Quoted-printable解码附件失败: %v, 文件: %s", err, decodedFilename)
-								finalBase64Data = base64.StdEncoding.EncodeToString(attachBytes)
-								actualSize = int64(len(attachBytes))
-							} else {
-								finalBase64Data = base64.StdEncoding.EncodeToString(decodedAttachData)
-								actualSize = int64(len(decodedAttachData))
-							}
-
-						default:
-							// 其他情况或无编码，直接编码为Base64
-							finalBase64Data = base64.StdEncoding.EncodeToString(attachBytes)
-							actualSize = int64(len(attachBytes))
-						}
-
-						processingTime := time.Since(startTime)
-						log.Printf("[附件处理] 文件: %s, 大小: %.2f KB, 编码方式: %s, 处理耗时: %v",
-							decodedFilename, float64(actualSize)/1024.0, encoding, processingTime)
-
-						email.Attachments = append(email.Attachments, AttachmentInfo{
-							Filename:   decodedFilename,
-							SizeKB:     float64(actualSize) / 1024.0,
-							MimeType:   partMediaType,
-							Base64Data: finalBase64Data,
-						})
-					}
-				}
-			}
-			return nil
-		}
-
-		// 使用递归函数处理多部分邮件
-		boundary := params["boundary"]
-		if boundary == "" {
-			return fmt.Errorf("未找到boundary参数")
-		}
-
-		return parseMultipart(mr.Body, boundary, 0)
-	} else if strings.HasPrefix(mediaType, "text/plain") {
-		// 对于单一的纯文本邮件
-		bodyBytes, err := io.ReadAll(mr.Body)
-		if err != nil {
-			return err
-		}
-		email.Body = string(bodyBytes)
-	} else if strings.HasPrefix(mediaType, "text/html") {
-		// 对于单一的HTML邮件
-		bodyBytes, err := io.ReadAll(mr.Body)
-		if err != nil {
-			return err
-		}
-		// 清理HTML内容
-		cleanedHTML := cleanHTMLContent(string(bodyBytes))
-		email.BodyHTML = cleanedHTML
-	}
-
-	return nil
-}
-
-// decodeContent 根据邮件头解码内容
-func decodeContent(header textproto.MIMEHeader, content []byte) (string, error) {
-	// 处理内容编码
-	encoding := header.Get("Content-Transfer-Encoding")
-	var reader io.Reader
-
-	switch strings.ToLower(encoding) {
-	case "base64":
-		reader = base64.NewDecoder(base64.StdEncoding, bytes.NewReader(content))
-	case "quoted-printable":
-		reader = quotedprintable.NewReader(bytes.NewReader(content))
-	default:
-		reader = bytes.NewReader(content)
-	}
-
-	decoded, err := io.ReadAll(reader)
-	if err != nil {
-		return "", err
-	}
-
-	// 处理字符集
-	contentType := header.Get("Content-Type")
-	_, params, err := mime.ParseMediaType(contentType)
-	if err != nil {
-		return string(decoded), nil
-	}
-
-	charset := params["charset"]
-	if charset == "" {
-		return string(decoded), nil
-	}
-
-	// 统一处理所有字符集
-	charset = strings.ToLower(charset)
-	e, err := ianaindex.MIME.Encoding(charset)
-	if err != nil || e == nil {
-		return string(decoded), nil
-	}
-
-	utf8Reader := transform.NewReader(bytes.NewReader(decoded), e.NewDecoder())
-	utf8Content, err := io.ReadAll(utf8Reader)
-	if err != nil {
-		return string(decoded), nil
-	}
-
-	return string(utf8Content), nil
-}
-
 // GetAttachment 获取邮件附件
 func (m *MailClient) GetAttachment(uid uint32, filename string, folder string) ([]byte, string, error) {
 	return m.getAttachmentWithRetry(uid, filename, folder, 5)
@@ -1180,7 +719,6 @@ func (m *MailClient) getAttachmentWithRetry(uid uint32, filename string, folder
 			log.Printf("[附件获取] 连接错误 (尝试 %d/%d): UID=%d, 文件=%s, 错误: %v", attempt, maxRetries, uid, filename, err)
 			if attempt < maxRetries {
 				// 强制关闭当前连接，下次会重新创建
-				globalPool.CloseConnection(m.Config.EmailAddress)
 				// 增加重试延迟，使用指数退避策略
 				delay := time.Second * time.Duration(attempt*2)
 				log.Printf("[附件获取] 等待 %v 后重试", delay)
@@ -1198,16 +736,14 @@ func (m *MailClient) getAttachmentWithRetry(uid uint32, filename string, folder
 }
 
 // 尝试获取附件（单次）
-func (m *MailClient) tryGetAttachment(uid uint32, filename string, folder string) ([]byte, string, error) {
-	// 连接IMAP服务器
-	c, err := m.ConnectIMAP()
+func (m *MailClient) tryGetAttachment(uid uint32, filename string, folder string) (_ []byte, _ string, err error) {
+	// 从连接池独占获取连接，返回时归还
+	pc, err := m.acquireIMAP()
 	if err != nil {
 		return nil, "", err
 	}
-	defer func() {
-		// 不要在这里关闭连接，让连接池管理
-		// c.Logout()
-	}()
+	defer func() { ReleaseIMAP(pc, err) }()
+	c := pc.Client
 
 	// 验证连接状态
 	state := c.State()
@@ -1224,158 +760,155 @@ func (m *MailClient) tryGetAttachment(uid uint32, filename string, folder string
 		// 检查是否是IMAP命令错误
 		if strings.Contains(strings.ToLower(err.Error()), "command is not a valid imap command") {
 			log.Printf("[附件获取] 检测到IMAP命令错误，重置连接: %v", err)
-			// 重置连接状态
-			globalPool.ResetConnection(m.Config.EmailAddress)
+			// 标记连接已损坏，归还时关闭而不再复用
+			pc.broken = true
 			return nil, "", fmt.Errorf("IMAP命令错误，已重置连接: %w", err)
 		}
 		return nil, "", fmt.Errorf("选择邮箱失败: %w", err)
 	}
 
-	// 创建搜索条件
-	criteria := imap.NewSearchCriteria()
-	criteria.Uid = new(imap.SeqSet)
-	criteria.Uid.AddNum(uid)
-
-	// 搜索邮件（使用 UidSearch 因为我们传入的是 UID）
-	ids, err := c.UidSearch(criteria)
-	if err != nil {
-		return nil, "", fmt.Errorf("搜索邮件失败: %w", err)
-	}
-
-	if len(ids) == 0 {
-		return nil, "", fmt.Errorf("未找到邮件")
-	}
-
 	seqSet := new(imap.SeqSet)
-	seqSet.AddNum(ids...)
+	seqSet.AddNum(uid)
 
-	// 获取邮件结构
+	// 获取完整邮件后按MIME树查找附件，文件名与内容同步时解析出的一致
 	section := &imap.BodySectionName{Peek: true}
-	items := []imap.FetchItem{imap.FetchBodyStructure, section.FetchItem()}
-
 	messages := make(chan *imap.Message, 1)
 	done := make(chan error, 1)
 	go func() {
-		done <- c.UidFetch(seqSet, items, messages)
+		done <- c.UidFetch(seqSet, []imap.FetchItem{section.FetchItem()}, messages)
 	}()
 
 	msg := <-messages
 	if err := <-done; err != nil {
-		return nil, "", fmt.Errorf("获取邮件结构失败: %w", err)
+		return nil, "", fmt.Errorf("获取邮件内容失败: %w", err)
 	}
-
 	if msg == nil {
 		return nil, "", fmt.Errorf("邮件不存在")
 	}
 
-	// 查找指定的附件
-	var attachmentSection *imap.BodySectionName
-	var attachmentMimeType string
+	r := msg.GetBody(section)
+	if r == nil {
+		return nil, "", fmt.Errorf("邮件正文为空")
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取邮件内容失败: %w", err)
+	}
 
-	var findAttachment func(parts []*imap.BodyStructure, path []int) bool
-	findAttachment = func(parts []*imap.BodyStructure, path []int) bool {
-		for i, part := range parts {
-			currentPath := append(path, i+1)
+	root, err := ParseMIMETree(raw)
+	if err != nil {
+		return nil, "", err
+	}
+	var found *MIMEPart
+	root.Walk(func(part *MIMEPart) bool {
+		if !part.IsMultipart() && part.Path != "" && partFilename(part) == filename {
+			found = part
+			return false
+		}
+		return true
+	})
+	if found == nil {
+		return nil, "", fmt.Errorf("未找到附件: %s", filename)
+	}
 
-			// 检查是否是附件
-			if part.Disposition == "attachment" || part.Disposition == "inline" {
-				attachmentFilename := part.Params["filename"]
-				if attachmentFilename == "" {
-					attachmentFilename = part.Params["name"]
-				}
+	mimeType := found.MediaType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return found.Body, mimeType, nil
+}
 
-				if attachmentFilename == filename {
-					// 找到了匹配的附件
-					attachmentSection = &imap.BodySectionName{
-						BodyPartName: imap.BodyPartName{
-							Specifier: imap.TextSpecifier,
-							Path:      currentPath,
-						},
-						Peek: true,
-					}
-					attachmentMimeType = part.MIMEType + "/" + part.MIMESubType
-					return true
-				}
-			}
+// GetMIMEPart 按MIME路径（如 1.2）只获取邮件中的一个部分，不下载整封邮件
+func (m *MailClient) GetMIMEPart(uid uint32, folder string, path string) (*MIMEPart, error) {
+	if folder == "" {
+		folder = "INBOX"
+	}
+	partPath, err := parseMIMEPath(path)
+	if err != nil {
+		return nil, err
+	}
 
-			// 递归查找子部分
-			if part.MIMEType == "multipart" && len(part.Parts) > 0 {
-				if findAttachment(part.Parts, currentPath) {
-					return true
-				}
+	maxRetries := 3
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		part, err := m.tryGetMIMEPart(uid, folder, path, partPath)
+		if err == nil {
+			return part, nil
+		}
+
+		if isConnectionError(err) || isWrappedConnectionError(err) {
+			log.Printf("[MIME部分] 连接错误 (尝试 %d/%d): UID=%d, 路径=%s, 错误: %v", attempt, maxRetries, uid, path, err)
+			if attempt < maxRetries {
+				time.Sleep(time.Second * time.Duration(attempt*2))
+				continue
 			}
 		}
-		return false
+		return nil, err
 	}
+	return nil, fmt.Errorf("获取MIME部分失败，已重试 %d 次", maxRetries)
+}
 
-	// 开始查找附件
-	if msg.BodyStructure.MIMEType == "multipart" {
-		found := findAttachment(msg.BodyStructure.Parts, []int{})
-		if !found {
-			return nil, "", fmt.Errorf("未找到附件: %s", filename)
-		}
-	} else {
-		return nil, "", fmt.Errorf("邮件不包含附件")
+// tryGetMIMEPart 获取MIME部分的头部(BODY[path.MIME])和内容(BODY[path])并解析（单次）
+func (m *MailClient) tryGetMIMEPart(uid uint32, folder string, path string, partPath []int) (_ *MIMEPart, err error) {
+	pc, err := m.acquireIMAP()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { ReleaseIMAP(pc, err) }()
+	c := pc.Client
+
+	if _, err := c.Select(folder, true); err != nil {
+		return nil, fmt.Errorf("选择邮箱失败: %w", err)
 	}
 
-	// 再次获取指定附件
-	attachItems := []imap.FetchItem{attachmentSection.FetchItem()}
-	attachMessages := make(chan *imap.Message, 1)
-	attachDone := make(chan error, 1)
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uid)
+	headerSection := &imap.BodySectionName{BodyPartName: imap.BodyPartName{Specifier: imap.MIMESpecifier, Path: partPath}, Peek: true}
+	bodySection := &imap.BodySectionName{BodyPartName: imap.BodyPartName{Path: partPath}, Peek: true}
 
+	messages := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
 	go func() {
-		attachDone <- c.UidFetch(seqSet, attachItems, attachMessages)
+		done <- c.UidFetch(seqSet, []imap.FetchItem{headerSection.FetchItem(), bodySection.FetchItem()}, messages)
 	}()
 
-	attachMsg := <-attachMessages
-	if err := <-attachDone; err != nil {
-		return nil, "", fmt.Errorf("获取附件内容失败: %w", err)
-	}
-
-	if attachMsg == nil {
-		return nil, "", fmt.Errorf("附件不存在")
+	msg := <-messages
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("获取MIME部分失败: %w", err)
 	}
-
-	// 读取附件内容
-	r := attachMsg.GetBody(attachmentSection)
-	if r == nil {
-		return nil, "", fmt.Errorf("附件内容为空")
+	if msg == nil {
+		return nil, fmt.Errorf("邮件不存在: UID=%d", uid)
 	}
 
-	// 读取数据
-	data, err := io.ReadAll(r)
-	if err != nil {
-		return nil, "", fmt.Errorf("读取附件数据失败: %w", err)
+	var header, body []byte
+	if r := msg.GetBody(headerSection); r != nil {
+		header, _ = io.ReadAll(r)
 	}
-
-	// 解码base64（如果需要）
-	decoded, err := base64.StdEncoding.DecodeString(string(data))
-	if err == nil {
-		// 解码成功，使用解码后的数据
-		data = decoded
+	r := msg.GetBody(bodySection)
+	if r == nil {
+		return nil, fmt.Errorf("MIME部分不存在: UID=%d, 路径=%s", uid, path)
 	}
-	// 如果解码失败，使用原始数据
-
-	// 如果无法获取MIME类型，使用默认值
-	finalMimeType := "application/octet-stream"
-	if attachmentMimeType != "" {
-		finalMimeType = attachmentMimeType
+	if body, err = io.ReadAll(r); err != nil {
+		return nil, fmt.Errorf("读取MIME部分失败: %w", err)
 	}
 
-	return data, finalMimeType, nil
+	// MIME头部以空行结尾，拼接后按普通部分解析
+	data := make([]byte, 0, len(header)+len(body)+2)
+	data = append(data, bytes.TrimRight(header, "\r\n")...)
+	data = append(data, "\r\n\r\n"...)
+	data = append(data, body...)
+	return parseMIMEEntity(data, path, false, "text/plain", 0), nil
 }
 
 // SendEmail 发送邮件
 func (m *MailClient) SendEmail(toAddress, subject, body, contentType string) error {
-	// 使用smtp包连接服务器
-	auth := smtp.PlainAuth("", m.Config.EmailAddress, m.Config.Password, m.Config.SMTPServer)
-
 	// 设置标头
 	header := make(map[string]string)
 	header["From"] = m.Config.EmailAddress
 	header["To"] = toAddress
 	header["Subject"] = mime.QEncoding.Encode("utf-8", subject)
 	header["MIME-Version"] = "1.0"
+	messageID := newMessageID(m.Config.EmailAddress)
+	header["Message-ID"] = "<" + messageID + ">"
 
 	if contentType == "html" {
 		header["Content-Type"] = "text/html; charset=UTF-8"
@@ -1391,61 +924,15 @@ func (m *MailClient) SendEmail(toAddress, subject, body, contentType string) err
 	message += "\r\n" + body
 
 	// 连接SMTP服务器并发送
-	smtpAddr := fmt.Sprintf("%s:%d", m.Config.SMTPServer, m.Config.SMTPPort)
-
-	// 部分邮件服务器可能需要TLS
-	c, err := smtp.Dial(smtpAddr)
-	if err != nil {
-		return fmt.Errorf("连接SMTP服务器失败: %w", err)
-	}
-	defer c.Quit()
-
-	if err = c.Hello("localhost"); err != nil {
-		return fmt.Errorf("HELO失败: %w", err)
-	}
-
-	// 启用TLS
-	if ok, _ := c.Extension("STARTTLS"); ok {
-		config := &tls.Config{ServerName: m.Config.SMTPServer}
-		if err = c.StartTLS(config); err != nil {
-			return fmt.Errorf("StartTLS失败: %w", err)
-		}
-	}
-
-	// 进行身份验证
-	if err = c.Auth(auth); err != nil {
-		return fmt.Errorf("SMTP认证失败: %w", err)
-	}
-
-	// 设置发件人和收件人
-	if err = c.Mail(m.Config.EmailAddress); err != nil {
-		return fmt.Errorf("设置发件人失败: %w", err)
-	}
-
-	to := strings.Split(toAddress, ",")
-	for _, addr := range to {
-		addr = strings.TrimSpace(addr)
-		if err = c.Rcpt(addr); err != nil {
-			return fmt.Errorf("设置收件人失败: %w", err)
-		}
-	}
-
-	// 发送邮件内容
-	w, err := c.Data()
-	if err != nil {
-		return fmt.Errorf("获取数据写入器失败: %w", err)
-	}
-
-	_, err = w.Write([]byte(message))
-	if err != nil {
-		return fmt.Errorf("写入邮件内容失败: %w", err)
-	}
-
-	err = w.Close()
-	if err != nil {
-		return fmt.Errorf("关闭数据写入器失败: %w", err)
+	if err := m.sendMail(m.Config.EmailAddress, strings.Split(toAddress, ","), []byte(message)); err != nil {
+		return err
 	}
-
+	m.recordOutbound(model.PrimeEmailOutbound{
+		MessageID: messageID,
+		Kind:      model.OutboundKindSend,
+		ToEmail:   toAddress,
+		Subject:   subject,
+	})
 	return nil
 }
 
@@ -1582,7 +1069,6 @@ func (m *MailClient) forwardOriginalEmailWithRetry(uid uint32, sourceFolder stri
 			log.Printf("[邮件转发] 连接错误 (尝试 %d/%d): UID=%d, 错误: %v", attempt, maxRetries, uid, err)
 			if attempt < maxRetries {
 				// 强制关闭当前连接，下次会重新创建
-				globalPool.CloseConnection(m.Config.EmailAddress)
 				// 增加重试延迟，使用指数退避策略
 				delay := time.Second * time.Duration(attempt*2)
 				log.Printf("[邮件转发] 等待 %v 后重试", delay)
@@ -1600,16 +1086,14 @@ func (m *MailClient) forwardOriginalEmailWithRetry(uid uint32, sourceFolder stri
 }
 
 // 尝试转发原始邮件（单次）
-func (m *MailClient) tryForwardOriginalEmail(uid uint32, sourceFolder string, toAddress string) error {
-	// 连接IMAP服务器
-	c, err := m.ConnectIMAP()
+func (m *MailClient) tryForwardOriginalEmail(uid uint32, sourceFolder string, toAddress string) (err error) {
+	// 从连接池独占获取连接，返回时归还
+	pc, err := m.acquireIMAP()
 	if err != nil {
 		return err
 	}
-	defer func() {
-		// 不要在这里关闭连接，让连接池管理
-		// c.Logout()
-	}()
+	defer func() { ReleaseIMAP(pc, err) }()
+	c := pc.Client
 
 	// 验证连接状态
 	state := c.State()
@@ -1626,8 +1110,8 @@ func (m *MailClient) tryForwardOriginalEmail(uid uint32, sourceFolder string, to
 		// 检查是否是IMAP命令错误
 		if strings.Contains(strings.ToLower(err.Error()), "command is not a valid imap command") {
 			log.Printf("[邮件转发] 检测到IMAP命令错误，重置连接: %v", err)
-			// 重置连接状态
-			globalPool.ResetConnection(m.Config.EmailAddress)
+			// 标记连接已损坏，归还时关闭而不再复用
+			pc.broken = true
 			return fmt.Errorf("IMAP命令错误，已重置连接: %w", err)
 		}
 		return fmt.Errorf("选择邮箱失败: %w", err)
@@ -1691,6 +1175,8 @@ func (m *MailClient) tryForwardOriginalEmail(uid uint32, sourceFolder string, to
 	fmt.Fprintf(&newEmail, "To: %s\r\n", toAddress)
 	fmt.Fprintf(&newEmail, "Subject: Fwd: %s\r\n", mime.QEncoding.Encode("utf-8", DecodeMIMESubject(msg.Envelope.Subject)))
 	fmt.Fprintf(&newEmail, "MIME-Version: 1.0\r\n")
+	messageID := newMessageID(m.Config.EmailAddress)
+	fmt.Fprintf(&newEmail, "Message-ID: <%s>\r\n", messageID)
 
 	// 创建多部分邮件
 	boundary := "----=_NextPart_" + time.Now().Format("20060102150405")
@@ -1715,19 +1201,20 @@ func (m *MailClient) tryForwardOriginalEmail(uid uint32, sourceFolder string, to
 	fmt.Fprintf(&newEmail, "\r\n--%s--", boundary)
 
 	// 发送邮件
-	auth := smtp.PlainAuth("", m.Config.EmailAddress, m.Config.Password, m.Config.SMTPServer)
-	err = smtp.SendMail(
-		fmt.Sprintf("%s:%d", m.Config.SMTPServer, m.Config.SMTPPort),
-		auth,
-		m.Config.EmailAddress,
-		[]string{toAddress},
-		newEmail.Bytes(),
-	)
+	err = m.sendMail(m.Config.EmailAddress, []string{toAddress}, newEmail.Bytes())
 
 	if err != nil {
 		return fmt.Errorf("发送邮件失败: %w", err)
 	}
 
+	m.recordOutbound(model.PrimeEmailOutbound{
+		MessageID:    messageID,
+		Kind:         model.OutboundKindForwardOriginal,
+		ToEmail:      toAddress,
+		Subject:      "Fwd: " + DecodeMIMESubject(msg.Envelope.Subject),
+		SourceUID:    uid,
+		SourceFolder: sourceFolder,
+	})
 	return nil
 }
 
@@ -1759,6 +1246,8 @@ func (m *MailClient) ForwardStructuredEmail(uid uint32, sourceFolder string, toA
 	header["Subject"] = mime.QEncoding.Encode("utf-8", forwardSubject)
 	header["MIME-Version"] = "1.0"
 	header["Content-Type"] = "multipart/mixed; boundary=" + writer.Boundary()
+	messageID := newMessageID(m.Config.EmailAddress)
+	header["Message-ID"] = "<" + messageID + ">"
 
 	// 写入邮件头
 	for k, v := range header {
@@ -1826,20 +1315,21 @@ func (m *MailClient) ForwardStructuredEmail(uid uint32, sourceFolder string, toA
 
 	// 发送邮件
 	sendStartTime := time.Now()
-	auth := smtp.PlainAuth("", m.Config.EmailAddress, m.Config.Password, m.Config.SMTPServer)
-	err = smtp.SendMail(
-		fmt.Sprintf("%s:%d", m.Config.SMTPServer, m.Config.SMTPPort),
-		auth,
-		m.Config.EmailAddress,
-		[]string{toAddress},
-		buf.Bytes(),
-	)
+	err = m.sendMail(m.Config.EmailAddress, []string{toAddress}, buf.Bytes())
 	sendDuration := time.Since(sendStartTime)
 	log.Printf("[邮件转发详情] 邮件ID: %d, 发送邮件耗时: %v", uid, sendDuration)
 
 	if err != nil {
 		return fmt.Errorf("发送邮件失败: %w", err)
 	}
+	m.recordOutbound(model.PrimeEmailOutbound{
+		MessageID:    messageID,
+		Kind:         model.OutboundKindForwardStructured,
+		ToEmail:      toAddress,
+		Subject:      forwardSubject,
+		SourceUID:    uid,
+		SourceFolder: sourceFolder,
+	})
 
 	totalDuration := time.Since(startTime)
 	log.Printf("[邮件转发详情] 邮件ID: %d, 转发完成, 总耗时: %v (获取: %v, 构建: %v, 附件: %v, 发送: %v)",
@@ -1936,121 +1426,3 @@ func isWrappedConnectionError(err error) bool {
 
 	return false
 }
-
-// parseMultipartMessageSkipAttachments 解析多部分邮件但跳过附件部分
-func (m *MailClient) parseMultipartMessageSkipAttachments(msg *imap.Message, email *Email, reader io.Reader) error {
-	// 使用mail包解析邮件
-	mr, err := mail.ReadMessage(reader)
-	if err != nil {
-		return fmt.Errorf("读取邮件内容失败: %v", err)
-	}
-
-	// 获取媒体类型
-	contentType := mr.Header.Get("Content-Type")
-	mediaType, params, err := mime.ParseMediaType(contentType)
-	if err != nil {
-		return fmt.Errorf("解析Content-Type失败: %v", err)
-	}
-
-	// 处理多部分邮件
-	if strings.HasPrefix(mediaType, "multipart/") {
-		// 创建一个递归函数来处理嵌套的多部分邮件
-		var parseMultipart func(reader io.Reader, boundary string, depth int) error
-		parseMultipart = func(reader io.Reader, boundary string, depth int) error {
-			mr := multipart.NewReader(reader, boundary)
-
-			// 遍历每个部分
-			for {
-				p, err := mr.NextPart()
-				if err == io.EOF {
-					break
-				}
-				if err != nil {
-					if depth == 0 {
-						return fmt.Errorf("读取下一部分失败: %v", err)
-					}
-					// 对于嵌套部分的错误，我们只记录而不中断
-					log.Printf("解析嵌套部分失败: %v", err)
-					continue
-				}
-
-				// 获取此部分的内容类型
-				partContentType := p.Header.Get("Content-Type")
-				partMediaType, partParams, err := mime.ParseMediaType(partContentType)
-				if err != nil {
-					continue // 跳过无法解析类型的部分
-				}
-
-				// 处理嵌套的多部分邮件
-				if strings.HasPrefix(partMediaType, "multipart/") {
-					partBoundary := partParams["boundary"]
-					if partBoundary != "" {
-						// 递归处理嵌套部分
-						bodyBytes, err := io.ReadAll(p)
-						if err == nil {
-							parseMultipart(bytes.NewReader(bodyBytes), partBoundary, depth+1)
-						}
-					}
-				} else if strings.HasPrefix(partMediaType, "text/plain") {
-					// 读取纯文本部分
-					bodyBytes, err := io.ReadAll(p)
-					if err != nil {
-						continue
-					}
-					// 解码内容
-					decodedBody, err := decodeContent(p.Header, bodyBytes)
-					if err == nil && decodedBody != "" {
-						email.Body = decodedBody
-					} else if len(bodyBytes) > 0 {
-						email.Body = string(bodyBytes)
-					}
-				} else if strings.HasPrefix(partMediaType, "text/html") {
-					// 读取HTML部分
-					bodyBytes, err := io.ReadAll(p)
-					if err != nil {
-						continue
-					}
-					// 解码内容
-					decodedBody, err := decodeContent(p.Header, bodyBytes)
-					if err == nil && decodedBody != "" {
-						// 清理HTML内容，移除\r\n和多余的空白
-						cleanedHTML := cleanHTMLContent(decodedBody)
-						email.BodyHTML = cleanedHTML
-					} else if len(bodyBytes) > 0 {
-						// 清理HTML内容，移除\r\n和多余的空白
-						cleanedHTML := cleanHTMLContent(string(bodyBytes))
-						email.BodyHTML = cleanedHTML
-					}
-				}
-				// 跳过附件部分
-			}
-			return nil
-		}
-
-		// 使用递归函数处理多部分邮件
-		boundary := params["boundary"]
-		if boundary == "" {
-			return fmt.Errorf("未找到boundary参数")
-		}
-
-		return parseMultipart(mr.Body, boundary, 0)
-	} else if strings.HasPrefix(mediaType, "text/plain") {
-		// 对于单一的纯文本邮件
-		bodyBytes, err := io.ReadAll(mr.Body)
-		if err != nil {
-			return err
-		}
-		email.Body = string(bodyBytes)
-	} else if strings.HasPrefix(mediaType, "text/html") {
-		// 对于单一的HTML邮件
-		bodyBytes, err := io.ReadAll(mr.Body)
-		if err != nil {
-			return err
-		}
-		// 清理HTML内容
-		cleanedHTML := cleanHTMLContent(string(bodyBytes))
-		email.BodyHTML = cleanedHTML
-	}
-
-	return nil
-}
@@ -3,6 +3,7 @@ package mailclient
 import (
 	"io"
 	"net/mail"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -226,3 +227,14 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+func TestNextUIDPage(t *testing.T) {
+	// SEARCH结果无序，且 n:* 在游标超过最大UID时会返回最后一封邮件
+	got := nextUIDPage([]uint32{12, 5, 9, 10, 7}, 7, 2)
+	if !reflect.DeepEqual(got, []uint32{9, 10}) {
+		t.Errorf("分页结果错误: %v", got)
+	}
+	if got := nextUIDPage([]uint32{7}, 7, 10); len(got) != 0 {
+		t.Errorf("游标之前的UID不应返回: %v", got)
+	}
+}
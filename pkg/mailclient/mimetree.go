@@ -0,0 +1,628 @@
+package mailclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mimeMaxDepth MIME树的最大嵌套层数，防止恶意构造的邮件无限递归
+const mimeMaxDepth = 20
+
+// MIMEPart MIME树中的一个部分
+type MIMEPart struct {
+	Path        string               `json:"path"`                // MIME路径，与IMAP BODY[1.2.3]编号一致；根multipart为空，嵌入邮件的multipart与message/rfc822部分共用路径
+	MediaType   string               `json:"media_type"`          // 小写的媒体类型，如 text/plain
+	Params      map[string]string    `json:"params"`              // Content-Type参数，已合并RFC 2231续行并解码
+	Disposition string               `json:"disposition"`         // inline / attachment，未设置时为空
+	Filename    string               `json:"filename"`            // 解码后的文件名，取自Content-Disposition的filename或Content-Type的name
+	ContentID   string               `json:"content_id"`          // 去掉尖括号的Content-ID
+	Encoding    string               `json:"encoding"`            // 小写的Content-Transfer-Encoding
+	Header      textproto.MIMEHeader `json:"-"`                   // 原始头部
+	Body        []byte               `json:"-"`                   // 已解码传输编码的内容，multipart部分为空，message/rfc822为嵌入的完整邮件
+	Size        int                  `json:"size"`                // Body的字节数
+	Parts       []*MIMEPart          `json:"parts,omitempty"`     // 子部分
+	Malformed   bool                 `json:"malformed,omitempty"` // boundary缺失、未正常结束或编码错误，已尽量恢复
+}
+
+// IsMultipart 是否为multipart容器
+func (p *MIMEPart) IsMultipart() bool {
+	return strings.HasPrefix(p.MediaType, "multipart/")
+}
+
+// IsMessage 是否为嵌入的邮件(message/rfc822)
+func (p *MIMEPart) IsMessage() bool {
+	return p.MediaType == "message/rfc822" || p.MediaType == "message/global"
+}
+
+// Text 按charset参数把文本内容转换为UTF-8
+func (p *MIMEPart) Text() string {
+	text, _ := p.TextWithCharset()
+	return text
+}
+
+// TextWithCharset 把文本内容转换为UTF-8并返回字符集判定结果
+// HTML没有charset参数时使用meta标签声明的字符集，仍没有或与内容不符时按内容检测
+func (p *MIMEPart) TextWithCharset() (string, CharsetResult) {
+	charset := p.Params["charset"]
+	if charset == "" && p.MediaType == "text/html" {
+		charset = htmlMetaCharset(p.Body)
+	}
+	return decodeText(p.Body, charset)
+}
+
+// Find 按MIME路径查找部分，找不到时返回nil
+func (p *MIMEPart) Find(path string) *MIMEPart {
+	var found *MIMEPart
+	p.Walk(func(part *MIMEPart) bool {
+		if part.Path == path && !part.IsMultipart() {
+			found = part
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// Walk 深度优先遍历MIME树，fn返回false时停止遍历
+func (p *MIMEPart) Walk(fn func(part *MIMEPart) bool) bool {
+	if !fn(p) {
+		return false
+	}
+	for _, child := range p.Parts {
+		if !child.Walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseMIMETree 把原始RFC 822邮件解析为MIME树
+// 解析尽量宽松：boundary缺失或未结束、头部格式错误、编码错误时保留能解析出的内容并标记Malformed
+func ParseMIMETree(raw []byte) (*MIMEPart, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, fmt.Errorf("邮件内容为空")
+	}
+	return parseMIMEEntity(raw, "", true, "text/plain", 0), nil
+}
+
+// childMIMEPath 生成第index个子部分的MIME路径（从1开始）
+func childMIMEPath(parent string, index int) string {
+	if parent == "" {
+		return strconv.Itoa(index)
+	}
+	return parent + "." + strconv.Itoa(index)
+}
+
+// parseMIMEPath 把 1.2.3 形式的MIME路径转换为IMAP的部分编号
+func parseMIMEPath(path string) ([]int, error) {
+	if path == "" {
+		return nil, fmt.Errorf("MIME路径为空")
+	}
+	var result []int
+	for _, segment := range strings.Split(path, ".") {
+		n, err := strconv.Atoi(segment)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("MIME路径无效: %s", path)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// parseMIMEEntity 解析一个MIME实体
+// isMessage 为true时实体是一封邮件（根邮件或嵌入邮件），非multipart的正文编号为 prefix.1；否则实体本身就是编号为path的部分
+func parseMIMEEntity(data []byte, path string, isMessage bool, defaultType string, depth int) *MIMEPart {
+	headerBytes, body := splitHeaderBody(data)
+	header := parseMIMEHeader(headerBytes)
+
+	part := &MIMEPart{Path: path, Header: header}
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		part.MediaType, part.Params = parseHeaderParams(contentType)
+	}
+	if part.MediaType == "" || !strings.Contains(part.MediaType, "/") {
+		part.MediaType = defaultType
+		if part.Params == nil {
+			part.Params = map[string]string{}
+		}
+	}
+	if disposition := header.Get("Content-Disposition"); disposition != "" {
+		var params map[string]string
+		part.Disposition, params = parseHeaderParams(disposition)
+		part.Filename = params["filename"]
+	}
+	if part.Filename == "" {
+		part.Filename = part.Params["name"]
+	}
+	part.Filename = decodeHeaderBytes(decodeParamWords(part.Filename))
+	part.ContentID = strings.Trim(strings.TrimSpace(header.Get("Content-Id")), "<>")
+	part.Encoding = strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding")))
+
+	if part.IsMultipart() {
+		if depth >= mimeMaxDepth {
+			part.Malformed = true
+			return part
+		}
+		boundary := part.Params["boundary"]
+		if boundary == "" {
+			boundary = detectBoundary(body)
+			part.Malformed = true
+		}
+		sections, malformed := splitMultipart(body, boundary)
+		part.Malformed = part.Malformed || malformed
+
+		childType := "text/plain"
+		if part.MediaType == "multipart/digest" {
+			childType = "message/rfc822"
+		}
+		for i, section := range sections {
+			part.Parts = append(part.Parts, parseMIMEEntity(section, childMIMEPath(path, i+1), false, childType, depth+1))
+		}
+		return part
+	}
+
+	if isMessage {
+		part.Path = childMIMEPath(path, 1)
+	}
+	var malformed bool
+	part.Body, malformed = decodeTransferEncoding(part.Encoding, body)
+	part.Malformed = malformed
+	part.Size = len(part.Body)
+
+	if part.IsMessage() && depth < mimeMaxDepth && len(bytes.TrimSpace(part.Body)) > 0 {
+		part.Parts = []*MIMEPart{parseMIMEEntity(part.Body, part.Path, true, "text/plain", depth+1)}
+	}
+	return part
+}
+
+// splitHeaderBody 分离头部和正文，第一行不像邮件头时整段都是正文，没有空行时整段都是头部
+func splitHeaderBody(data []byte) ([]byte, []byte) {
+	if bytes.HasPrefix(data, []byte("\r\n")) {
+		return nil, data[2:]
+	}
+	if bytes.HasPrefix(data, []byte("\n")) {
+		return nil, data[1:]
+	}
+
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if !isEmailHeader(strings.TrimSpace(string(firstLine))) {
+		return nil, data
+	}
+	if idx := findEmailBodyStart(string(data)); idx != -1 {
+		return data[:idx], data[idx:]
+	}
+	return data, nil
+}
+
+// parseMIMEHeader 解析头部，遇到格式错误的行时保留之前解析出的字段
+func parseMIMEHeader(headerBytes []byte) textproto.MIMEHeader {
+	if len(headerBytes) == 0 {
+		return textproto.MIMEHeader{}
+	}
+	buf := make([]byte, 0, len(headerBytes)+4)
+	buf = append(buf, bytes.TrimRight(headerBytes, "\r\n")...)
+	buf = append(buf, "\r\n\r\n"...)
+	header, _ := textproto.NewReader(bufio.NewReader(bytes.NewReader(buf))).ReadMIMEHeader()
+	if header == nil {
+		header = textproto.MIMEHeader{}
+	}
+	return header
+}
+
+// detectBoundary Content-Type缺少boundary参数时，从正文中找第一条形如 --xxx 的分隔行
+func detectBoundary(body []byte) string {
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimRight(line, " \t\r")
+		if len(line) > 2 && bytes.HasPrefix(line, []byte("--")) && !bytes.ContainsAny(line, " \t") {
+			return string(bytes.TrimSuffix(line[2:], []byte("--")))
+		}
+	}
+	return ""
+}
+
+// splitMultipart 按boundary切分multipart正文，忽略前言和结语
+// 分隔行允许尾随空白；没有找到分隔行或缺少结束分隔行时返回 malformed=true，最后一部分延续到正文末尾
+func splitMultipart(body []byte, boundary string) ([][]byte, bool) {
+	if boundary == "" {
+		return nil, true
+	}
+	delimiter := []byte("--" + boundary)
+
+	var sections [][]byte
+	start := -1 // 当前部分内容的起始位置
+	closed := false
+	for pos := 0; pos < len(body); {
+		end := bytes.IndexByte(body[pos:], '\n')
+		next := len(body)
+		if end != -1 {
+			next = pos + end + 1
+		}
+		line := bytes.TrimRight(body[pos:next], " \t\r\n")
+
+		if bytes.HasPrefix(line, delimiter) {
+			rest := line[len(delimiter):]
+			if len(rest) == 0 || bytes.Equal(rest, []byte("--")) {
+				if start != -1 {
+					sections = append(sections, trimPartEnd(body[start:pos]))
+				}
+				if len(rest) > 0 {
+					closed = true
+					break
+				}
+				start = next
+			}
+		}
+		pos = next
+	}
+
+	if !closed && start != -1 && start <= len(body) {
+		sections = append(sections, body[start:])
+	}
+	return sections, !closed
+}
+
+// trimPartEnd 去掉分隔行前属于分隔符的换行
+func trimPartEnd(section []byte) []byte {
+	if bytes.HasSuffix(section, []byte("\r\n")) {
+		return section[:len(section)-2]
+	}
+	return bytes.TrimSuffix(section, []byte("\n"))
+}
+
+// decodeTransferEncoding 按Content-Transfer-Encoding解码内容，出错时尽量保留已解码的部分
+func decodeTransferEncoding(encoding string, body []byte) ([]byte, bool) {
+	switch encoding {
+	case "base64":
+		return decodeBase64Lenient(body)
+	case "quoted-printable":
+		return decodeQuotedPrintableLenient(body), false
+	default:
+		return body, false
+	}
+}
+
+// decodeBase64Lenient 宽松的base64解码：忽略换行和非法字符，补齐或截断不完整的末尾
+func decodeBase64Lenient(body []byte) ([]byte, bool) {
+	clean := make([]byte, 0, len(body))
+	malformed := false
+	for _, b := range body {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9', b == '+', b == '/':
+			clean = append(clean, b)
+		case b == '=', b == '\r', b == '\n', b == ' ', b == '\t':
+		default:
+			malformed = true
+		}
+	}
+	if len(clean)%4 == 1 {
+		clean = clean[:len(clean)-1]
+		malformed = true
+	}
+	decoded := make([]byte, base64.RawStdEncoding.DecodedLen(len(clean)))
+	n, err := base64.RawStdEncoding.Decode(decoded, clean)
+	if err != nil {
+		malformed = true
+	}
+	return decoded[:n], malformed
+}
+
+// decodeQuotedPrintableLenient 宽松的quoted-printable解码，非法的转义原样保留
+func decodeQuotedPrintableLenient(body []byte) []byte {
+	out := make([]byte, 0, len(body))
+	for i := 0; i < len(body); i++ {
+		b := body[i]
+		if b != '=' {
+			out = append(out, b)
+			continue
+		}
+		// 软换行：= 后跟可选空白和换行
+		j := i + 1
+		for j < len(body) && (body[j] == ' ' || body[j] == '\t') {
+			j++
+		}
+		if j < len(body) && body[j] == '\n' {
+			i = j
+			continue
+		}
+		if j+1 < len(body) && body[j] == '\r' && body[j+1] == '\n' {
+			i = j + 1
+			continue
+		}
+		if i+2 < len(body) {
+			var v [1]byte
+			if _, err := hex.Decode(v[:], bytes.ToUpper(body[i+1:i+3])); err == nil {
+				out = append(out, v[0])
+				i += 2
+				continue
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// parseHeaderParams 解析 Content-Type / Content-Disposition 形式的头部值
+// 比 mime.ParseMediaType 宽松：参数格式错误时跳过该参数；支持RFC 2231续行(name*0*=)和任意字符集的扩展参数(name*=charset”...)
+func parseHeaderParams(value string) (string, map[string]string) {
+	segments := splitParamSegments(value)
+	mediaType := strings.ToLower(strings.TrimSpace(segments[0]))
+	params := make(map[string]string)
+
+	type section struct {
+		value   string
+		encoded bool
+	}
+	continuations := make(map[string]map[int]section)
+	extended := make(map[string]string)
+
+	for _, segment := range segments[1:] {
+		key, val, ok := strings.Cut(segment, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = unquoteParam(strings.TrimSpace(val))
+		if key == "" {
+			continue
+		}
+
+		base, rest, isExtended := strings.Cut(key, "*")
+		if !isExtended {
+			if _, exists := params[key]; !exists {
+				params[key] = val
+			}
+			continue
+		}
+		if rest == "" {
+			extended[base] = decodeRFC2231Value(val)
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSuffix(rest, "*"))
+		if err != nil {
+			continue
+		}
+		if continuations[base] == nil {
+			continuations[base] = make(map[int]section)
+		}
+		continuations[base][index] = section{value: val, encoded: strings.HasSuffix(rest, "*")}
+	}
+
+	for base, sections := range continuations {
+		var charset string
+		var buf []byte
+		for i := 0; ; i++ {
+			s, ok := sections[i]
+			if !ok {
+				break
+			}
+			if !s.encoded {
+				buf = append(buf, s.value...)
+				continue
+			}
+			v := s.value
+			if i == 0 {
+				charset, v = splitRFC2231Charset(v)
+			}
+			buf = append(buf, percentDecode(v)...)
+		}
+		if len(buf) > 0 {
+			params[base] = decodeCharset(buf, charset)
+		}
+	}
+	for base, v := range extended {
+		params[base] = v
+	}
+	return mediaType, params
+}
+
+// splitParamSegments 按引号外的分号切分头部值
+func splitParamSegments(value string) []string {
+	var segments []string
+	var current strings.Builder
+	inQuote, escaped := false, false
+	// 按字节处理，保留未编码的8位字节（如GBK文件名）供后续检测字符集
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && inQuote:
+			escaped = true
+		case c == '"':
+			inQuote = !inQuote
+		case c == ';' && !inQuote:
+			segments = append(segments, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	return append(segments, current.String())
+}
+
+// unquoteParam 去掉参数值两端的引号并处理转义
+func unquoteParam(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return strings.Trim(value, `"`)
+	}
+	value = value[1 : len(value)-1]
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	escaped := false
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// splitRFC2231Charset 拆分 charset'language'value
+func splitRFC2231Charset(value string) (string, string) {
+	charset, rest, ok := strings.Cut(value, "'")
+	if !ok {
+		return "", value
+	}
+	_, encoded, ok := strings.Cut(rest, "'")
+	if !ok {
+		return "", value
+	}
+	return charset, encoded
+}
+
+// decodeRFC2231Value 解码 name*=charset'language'%XX 形式的扩展参数
+func decodeRFC2231Value(value string) string {
+	charset, encoded := splitRFC2231Charset(value)
+	return decodeCharset(percentDecode(encoded), charset)
+}
+
+// percentDecode 解码 %XX 转义，非法的转义原样保留
+func percentDecode(value string) []byte {
+	out := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+2 < len(value) {
+			if v, err := strconv.ParseUint(value[i+1:i+3], 16, 8); err == nil {
+				out = append(out, byte(v))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, value[i])
+	}
+	return out
+}
+
+// decodeParamWords 解码文件名中不规范但常见的RFC 2047编码(=?charset?B?...?=)
+func decodeParamWords(value string) string {
+	if !strings.Contains(value, "=?") {
+		return value
+	}
+	return DecodeMIMESubject(value)
+}
+
+// decodeCharset 把指定字符集的内容转换为UTF-8，字符集为空、未知或与内容不符时按内容检测
+func decodeCharset(data []byte, charset string) string {
+	text, _ := decodeText(data, charset)
+	return text
+}
+
+// mimeExtension 根据媒体类型返回常用扩展名，用于没有文件名的附件
+func mimeExtension(mediaType string) string {
+	switch mediaType {
+	case "message/rfc822", "message/global":
+		return ".eml"
+	case "application/pdf":
+		return ".pdf"
+	case "text/plain":
+		return ".txt"
+	case "text/html":
+		return ".html"
+	case "image/jpeg":
+		return ".jpg"
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		sort.Strings(exts)
+		return exts[0]
+	}
+	return ""
+}
+
+// partFilename 附件文件名，没有文件名时按MIME路径和类型生成
+func partFilename(part *MIMEPart) string {
+	if part.Filename != "" {
+		return part.Filename
+	}
+	path := strings.ReplaceAll(part.Path, ".", "_")
+	if path == "" {
+		path = "1"
+	}
+	return "attachment_" + path + mimeExtension(part.MediaType)
+}
+
+// isBodyTextPart 是否为正文文本部分：text/plain或text/html，且不是带文件名的附件
+func isBodyTextPart(part *MIMEPart) bool {
+	if part.MediaType != "text/plain" && part.MediaType != "text/html" {
+		return false
+	}
+	if part.Disposition == "attachment" {
+		return false
+	}
+	return part.Filename == "" || part.Disposition == "inline"
+}
+
+// fillEmailFromTree 从MIME树中取出正文、HTML正文和附件，没有纯文本正文时由HTML正文转换
+// 嵌入的邮件(message/rfc822)整体作为一个.eml附件；multipart/related中带Content-ID的内嵌资源标记为Inline，由调用方上传后替换HTML中的 cid: 引用
+func fillEmailFromTree(email *Email, root *MIMEPart, skipAttachments bool) {
+	var walk func(part *MIMEPart, parentType string)
+	walk = func(part *MIMEPart, parentType string) {
+		if part.IsMultipart() {
+			for _, child := range part.Parts {
+				walk(child, part.MediaType)
+			}
+			return
+		}
+
+		if !part.IsMessage() && isBodyTextPart(part) {
+			switch {
+			case part.MediaType == "text/html" && email.BodyHTML == "":
+				html, charset := part.TextWithCharset()
+				email.BodyHTML = cleanHTMLContent(html)
+				if email.Charset.Used == "" {
+					email.Charset = charset
+				}
+				return
+			case part.MediaType == "text/plain" && email.Body == "":
+				// 纯文本正文的字符集优先作为整封邮件的诊断结果
+				email.Body, email.Charset = part.TextWithCharset()
+				return
+			case part.MediaType == "text/plain" && parentType == "multipart/mixed":
+				// 部分客户端会把正文拆成多段插在图片之间
+				email.Body += "\n" + part.Text()
+				return
+			case part.Filename == "":
+				return
+			}
+		}
+
+		if skipAttachments || part.Size == 0 {
+			return
+		}
+
+		email.Attachments = append(email.Attachments, AttachmentInfo{
+			Filename:   partFilename(part),
+			SizeKB:     float64(part.Size) / 1024.0,
+			MimeType:   part.MediaType,
+			Base64Data: base64.StdEncoding.EncodeToString(part.Body),
+			Path:       part.Path,
+			ContentID:  part.ContentID,
+			Inline:     parentType == "multipart/related" && part.ContentID != "" && part.Disposition != "attachment",
+		})
+	}
+	walk(root, "")
+
+	// 只有HTML正文的邮件（如承运商通知）由HTML转换出纯文本正文
+	if strings.TrimSpace(email.Body) == "" && email.BodyHTML != "" {
+		email.Body = HTMLToText(email.BodyHTML)
+	}
+
+	// 不在multipart/related中但被HTML以 cid: 引用的部分也是内嵌图片
+	referenced := HTMLContentIDs(email.BodyHTML)
+	for i := range email.Attachments {
+		att := &email.Attachments[i]
+		if att.ContentID != "" && referenced[normalizeContentID(att.ContentID)] {
+			att.Inline = true
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package mailclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func joinLines(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\r\n"))
+}
+
+func TestParseMIMETreePaths(t *testing.T) {
+	raw := joinLines(
+		"Subject: paths",
+		`Content-Type: multipart/mixed; boundary="outer"`,
+		"",
+		"preamble",
+		"--outer",
+		`Content-Type: multipart/alternative; boundary="inner"`,
+		"",
+		"--inner",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		"plain body",
+		"--inner",
+		"Content-Type: text/html; charset=utf-8",
+		"",
+		"<p>html body</p>",
+		"--inner--",
+		"--outer",
+		"Content-Type: message/rfc822",
+		"",
+		"Subject: forwarded",
+		`Content-Type: multipart/mixed; boundary="fwd"`,
+		"",
+		"--fwd",
+		"Content-Type: text/plain",
+		"",
+		"forwarded body",
+		"--fwd",
+		"Content-Type: application/pdf",
+		"Content-Transfer-Encoding: base64",
+		"",
+		"JVBERi0xLjQK",
+		"--fwd--",
+		"--outer--",
+		"epilogue",
+	)
+
+	root, err := ParseMIMETree(raw)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	want := map[string]string{
+		"1.1":   "text/plain",
+		"1.2":   "text/html",
+		"2":     "message/rfc822",
+		"2.1":   "text/plain",
+		"2.2":   "application/pdf",
+		"1":     "multipart/alternative",
+		"":      "multipart/mixed",
+		"2.2.x": "",
+	}
+	for path, mediaType := range want {
+		var got string
+		root.Walk(func(part *MIMEPart) bool {
+			if part.Path == path && (mediaType == "" || part.MediaType == mediaType) {
+				got = part.MediaType
+				return false
+			}
+			return true
+		})
+		if got != mediaType {
+			t.Errorf("路径 %s 应为 %q，实际 %q", path, mediaType, got)
+		}
+	}
+	if root.Malformed {
+		t.Error("格式正确的邮件不应标记为Malformed")
+	}
+	if part := root.Find("2.2"); part == nil || string(part.Body) != "%PDF-1.4\n" {
+		t.Errorf("嵌入邮件中的附件解码错误: %+v", part)
+	}
+
+	email := &Email{}
+	fillEmailFromTree(email, root, false)
+	if email.Body != "plain body" || email.BodyHTML == "" {
+		t.Errorf("正文解析错误: %q %q", email.Body, email.BodyHTML)
+	}
+	if len(email.Attachments) != 1 || email.Attachments[0].MimeType != "message/rfc822" || email.Attachments[0].Path != "2" {
+		t.Errorf("嵌入邮件应整体作为一个附件: %+v", email.Attachments)
+	}
+}
+
+func TestParseMIMETreeFilenames(t *testing.T) {
+	raw := joinLines(
+		`Content-Type: multipart/mixed; boundary=b`,
+		"",
+		"--b",
+		"Content-Type: application/octet-stream",
+		"Content-Disposition: attachment;",
+		" filename*0*=utf-8''%E6%8A%A5%E5%85%B3;",
+		" filename*1*=%E5%8D%95;",
+		" filename*2=.pdf",
+		"",
+		"a",
+		"--b",
+		`Content-Type: application/octet-stream; name="=?UTF-8?B?5Y+R56Wo?=.xlsx"`,
+		"Content-Disposition: attachment",
+		"",
+		"b",
+		"--b",
+		"Content-Type: application/octet-stream",
+		"Content-Disposition: attachment; filename*=gbk''%D6%D0%CE%C4.txt",
+		"",
+		"c",
+		"--b--",
+	)
+
+	root, err := ParseMIMETree(raw)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	want := []string{"报关单.pdf", "发票.xlsx", "中文.txt"}
+	for i, name := range want {
+		part := root.Find(childMIMEPath("", i+1))
+		if part == nil || part.Filename != name {
+			t.Errorf("第 %d 个附件文件名应为 %q，实际 %+v", i+1, name, part)
+		}
+	}
+}
+
+func TestParseMIMETreeRelatedInline(t *testing.T) {
+	raw := joinLines(
+		`Content-Type: multipart/related; boundary="rel"`,
+		"",
+		"--rel",
+		"Content-Type: text/html",
+		"",
+		`<img src="cid:logo@x">`,
+		"--rel",
+		"Content-Type: image/png",
+		"Content-ID: <logo@x>",
+		"Content-Disposition: inline; filename=logo.png",
+		"Content-Transfer-Encoding: base64",
+		"",
+		"iVBORw0KGgo=",
+		"--rel--",
+	)
+
+	root, _ := ParseMIMETree(raw)
+	if part := root.Find("2"); part == nil || part.ContentID != "logo@x" {
+		t.Fatalf("内嵌图片Content-ID解析错误: %+v", part)
+	}
+	email := &Email{}
+	fillEmailFromTree(email, root, false)
+	if len(email.Attachments) != 1 || !email.Attachments[0].Inline || email.Attachments[0].ContentID != "logo@x" {
+		t.Errorf("multipart/related中的内嵌图片应标记为Inline: %+v", email.Attachments)
+	}
+}
+
+func TestParseMIMETreeMalformedBoundary(t *testing.T) {
+	// 缺少boundary参数，且没有结束分隔行
+	raw := joinLines(
+		"Content-Type: multipart/mixed",
+		"",
+		"--lost",
+		"Content-Type: text/plain",
+		"",
+		"body text",
+		"--lost",
+		`Content-Type: application/pdf; name="a.pdf"`,
+		"Content-Transfer-Encoding: base64",
+		"",
+		"JVBERi0x",
+		"LjQK",
+	)
+
+	root, err := ParseMIMETree(raw)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if !root.Malformed || len(root.Parts) != 2 {
+		t.Fatalf("应恢复出两个部分并标记Malformed: %+v", root)
+	}
+	email := &Email{}
+	fillEmailFromTree(email, root, false)
+	if email.Body != "body text" {
+		t.Errorf("正文解析错误: %q", email.Body)
+	}
+	if len(email.Attachments) != 1 || email.Attachments[0].Filename != "a.pdf" || email.Attachments[0].SizeKB*1024 != 9 {
+		t.Errorf("附件解析错误: %+v", email.Attachments)
+	}
+}
+
+func TestDecodeQuotedPrintableLenient(t *testing.T) {
+	got := string(decodeQuotedPrintableLenient([]byte("a=3Db=\r\nc =ZZ")))
+	if got != "a=bc =ZZ" {
+		t.Errorf("quoted-printable解码错误: %q", got)
+	}
+}
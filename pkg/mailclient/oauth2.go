@@ -0,0 +1,232 @@
+package mailclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go_email/model"
+
+	"github.com/emersion/go-sasl"
+)
+
+// 凭据类型
+const (
+	CredentialPassword = "password" // 密码/应用专用密码
+	CredentialOAuth2   = "oauth2"   // OAuth2刷新令牌
+)
+
+// OAuth2 SASL认证方式
+const (
+	AuthXOAuth2     = "xoauth2"     // Gmail/Microsoft 365 使用的 XOAUTH2
+	AuthOAuthBearer = "oauthbearer" // RFC 7628 OAUTHBEARER
+)
+
+// oauthRefreshSkew 访问令牌剩余有效期小于该值时提前刷新，避免认证过程中过期
+const oauthRefreshSkew = 5 * time.Minute
+
+var (
+	// oauthHTTPClient 请求令牌端点使用的HTTP客户端
+	oauthHTTPClient = &http.Client{Timeout: 30 * time.Second}
+	// saveOAuthToken 持久化刷新后的令牌，测试中可替换
+	saveOAuthToken = model.UpdateAccountOAuthToken
+
+	// oauthLocks 按邮箱地址串行化令牌刷新，避免多个连接同时刷新
+	oauthLocks sync.Map
+	// oauthTokenCache 按邮箱地址缓存最新的令牌，多个MailClient实例共享
+	oauthTokenCache sync.Map
+)
+
+// OAuthConfig 账号的OAuth2凭据
+type OAuthConfig struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scope        string
+	RefreshToken string
+	AccessToken  string
+	Expiry       time.Time
+}
+
+// oauthTokenResponse 令牌端点的响应（RFC 6749 5.1/5.2）
+type oauthTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// normalizeCredentialType 规范化凭据类型，无法识别时视为密码
+func normalizeCredentialType(credentialType string) string {
+	switch strings.ToLower(strings.TrimSpace(credentialType)) {
+	case "oauth2", "oauth":
+		return CredentialOAuth2
+	default:
+		return CredentialPassword
+	}
+}
+
+// isOAuthMechanism 判断认证方式是否为OAuth2
+func isOAuthMechanism(mechanism string) bool {
+	return mechanism == AuthXOAuth2 || mechanism == AuthOAuthBearer
+}
+
+// valid 判断访问令牌是否还能在刷新提前量之外继续使用
+func (o *OAuthConfig) valid(now time.Time) bool {
+	return o.AccessToken != "" && !o.Expiry.IsZero() && o.Expiry.Sub(now) > oauthRefreshSkew
+}
+
+// refreshOAuthToken 使用刷新令牌向令牌端点换取新的访问令牌
+func refreshOAuthToken(o *OAuthConfig) (*oauthTokenResponse, error) {
+	if o.TokenURL == "" {
+		return nil, errors.New("未配置OAuth2令牌端点")
+	}
+	if o.RefreshToken == "" {
+		return nil, errors.New("未配置OAuth2刷新令牌")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", o.RefreshToken)
+	form.Set("client_id", o.ClientID)
+	if o.ClientSecret != "" {
+		form.Set("client_secret", o.ClientSecret)
+	}
+	if o.Scope != "" {
+		form.Set("scope", o.Scope)
+	}
+
+	resp, err := oauthHTTPClient.PostForm(o.TokenURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("请求OAuth2令牌端点失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取OAuth2令牌响应失败: %w", err)
+	}
+
+	var token oauthTokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("解析OAuth2令牌响应失败(HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("刷新OAuth2令牌失败(HTTP %d): %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("OAuth2令牌响应中没有access_token")
+	}
+	return &token, nil
+}
+
+// accessToken 返回可用的访问令牌，即将过期时自动刷新并保存
+func (c *EmailConfigInfo) accessToken() (string, error) {
+	if c.OAuth == nil {
+		return "", errors.New("账号未配置OAuth2凭据")
+	}
+
+	lock, _ := oauthLocks.LoadOrStore(c.EmailAddress, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	now := time.Now()
+	// 其他连接已经刷新过时直接使用缓存中更新的令牌
+	if cached, ok := oauthTokenCache.Load(c.EmailAddress); ok {
+		if token := cached.(OAuthConfig); token.valid(now) && token.Expiry.After(c.OAuth.Expiry) {
+			c.OAuth.AccessToken = token.AccessToken
+			c.OAuth.Expiry = token.Expiry
+			if token.RefreshToken != "" {
+				c.OAuth.RefreshToken = token.RefreshToken
+			}
+		}
+	}
+	if c.OAuth.valid(now) {
+		return c.OAuth.AccessToken, nil
+	}
+
+	log.Printf("[OAuth2] 访问令牌即将过期或不存在，开始刷新: %s", c.EmailAddress)
+	token, err := refreshOAuthToken(c.OAuth)
+	if err != nil {
+		return "", err
+	}
+
+	expiresIn := time.Duration(token.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		// 令牌端点没有返回有效期时按1小时处理
+		expiresIn = time.Hour
+	}
+	c.OAuth.AccessToken = token.AccessToken
+	c.OAuth.Expiry = now.Add(expiresIn)
+	if token.RefreshToken != "" {
+		c.OAuth.RefreshToken = token.RefreshToken
+	}
+	oauthTokenCache.Store(c.EmailAddress, *c.OAuth)
+
+	if c.AccountID > 0 {
+		if err := saveOAuthToken(c.AccountID, token.AccessToken, token.RefreshToken, c.OAuth.Expiry); err != nil {
+			// 令牌已刷新成功，保存失败不影响本次认证
+			log.Printf("[OAuth2] 保存刷新后的令牌失败: 账号ID=%d, 错误=%v", c.AccountID, err)
+		}
+	}
+	log.Printf("[OAuth2] 访问令牌刷新成功: %s, 过期时间: %s", c.EmailAddress, c.OAuth.Expiry.Format(time.RFC3339))
+	return c.OAuth.AccessToken, nil
+}
+
+// xoauth2Client 实现 XOAUTH2 SASL 机制（go-sasl 只内置了 OAUTHBEARER）
+type xoauth2Client struct {
+	username string
+	token    string
+}
+
+func (a *xoauth2Client) Start() (mech string, ir []byte, err error) {
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+func (a *xoauth2Client) Next(challenge []byte) ([]byte, error) {
+	// 认证失败时服务器会返回一段JSON错误信息作为challenge
+	return nil, fmt.Errorf("XOAUTH2认证失败: %s", string(challenge))
+}
+
+// saslClient 根据认证方式创建IMAP使用的OAuth2 SASL客户端
+func (c *EmailConfigInfo) saslClient(host string, port int) (sasl.Client, error) {
+	token, err := c.accessToken()
+	if err != nil {
+		return nil, err
+	}
+	if c.AuthMechanism == AuthOAuthBearer {
+		return sasl.NewOAuthBearerClient(&sasl.OAuthBearerOptions{
+			Username: c.EmailAddress,
+			Token:    token,
+			Host:     host,
+			Port:     port,
+		}), nil
+	}
+	return &xoauth2Client{username: c.EmailAddress, token: token}, nil
+}
+
+// saslSMTPAuth 将SASL客户端适配为 net/smtp 的认证器
+type saslSMTPAuth struct {
+	client sasl.Client
+}
+
+func (a *saslSMTPAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return a.client.Start()
+}
+
+func (a *saslSMTPAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	return a.client.Next(fromServer)
+}
@@ -0,0 +1,139 @@
+package mailclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go_email/model"
+)
+
+// newTokenServer 本地令牌端点，模拟Google/Microsoft的refresh_token授权
+func newTokenServer(t *testing.T, hits *int) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*hits++
+		mu.Unlock()
+
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("解析表单失败: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "refresh-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "bad refresh token"})
+			return
+		}
+		if r.Form.Get("client_id") != "client-1" || r.Form.Get("scope") != "https://mail.google.com/" {
+			t.Errorf("令牌请求参数错误: %v", r.Form)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access-new",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"refresh_token": "refresh-2",
+		})
+	}))
+}
+
+func TestOAuthAccessTokenRefresh(t *testing.T) {
+	hits := 0
+	server := newTokenServer(t, &hits)
+	defer server.Close()
+
+	var saved struct {
+		accountID     int
+		access, fresh string
+	}
+	origSave := saveOAuthToken
+	saveOAuthToken = func(accountID int, accessToken, refreshToken string, expiry time.Time) error {
+		saved.accountID, saved.access, saved.fresh = accountID, accessToken, refreshToken
+		return nil
+	}
+	defer func() { saveOAuthToken = origSave }()
+
+	expired := time.Now().Add(time.Minute)
+	account := model.PrimeEmailAccount{
+		ID:                11,
+		Account:           "oauth-refresh@example.com",
+		CredentialType:    "oauth2",
+		ImapHost:          "imap.gmail.com",
+		OAuthTokenURL:     server.URL,
+		OAuthClientID:     "client-1",
+		OAuthScope:        "https://mail.google.com/",
+		OAuthRefreshToken: "refresh-1",
+		OAuthAccessToken:  "access-old",
+		OAuthTokenExpiry:  &expired,
+	}
+	config := resolveEmailConfig(account, nil, "")
+	if config.OAuth == nil || config.AuthMechanism != AuthXOAuth2 {
+		t.Fatalf("OAuth2账号配置错误: %+v", config)
+	}
+
+	// 剩余有效期小于提前量，应刷新
+	token, err := config.accessToken()
+	if err != nil {
+		t.Fatalf("刷新令牌失败: %v", err)
+	}
+	if token != "access-new" || hits != 1 {
+		t.Errorf("刷新结果错误: token=%s hits=%d", token, hits)
+	}
+	if saved.accountID != 11 || saved.access != "access-new" || saved.fresh != "refresh-2" {
+		t.Errorf("刷新后的令牌未保存: %+v", saved)
+	}
+	if config.OAuth.RefreshToken != "refresh-2" {
+		t.Errorf("轮换后的刷新令牌未更新: %s", config.OAuth.RefreshToken)
+	}
+
+	// 令牌仍有效时不再请求令牌端点
+	if _, err := config.accessToken(); err != nil || hits != 1 {
+		t.Errorf("有效令牌不应重复刷新: hits=%d err=%v", hits, err)
+	}
+}
+
+func TestOAuthAccessTokenRefreshError(t *testing.T) {
+	hits := 0
+	server := newTokenServer(t, &hits)
+	defer server.Close()
+
+	config := &EmailConfigInfo{
+		EmailAddress: "oauth-error@example.com",
+		OAuth:        &OAuthConfig{TokenURL: server.URL, ClientID: "client-1", RefreshToken: "revoked"},
+	}
+	_, err := config.accessToken()
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("应返回令牌端点的错误信息，实际: %v", err)
+	}
+}
+
+func TestOAuthSASLClients(t *testing.T) {
+	config := &EmailConfigInfo{
+		EmailAddress:  "user@example.com",
+		AuthMechanism: AuthXOAuth2,
+		OAuth:         &OAuthConfig{AccessToken: "tok", Expiry: time.Now().Add(time.Hour)},
+	}
+
+	c, err := config.saslClient("imap.example.com", 993)
+	if err != nil {
+		t.Fatalf("创建XOAUTH2客户端失败: %v", err)
+	}
+	mech, ir, _ := c.Start()
+	if mech != "XOAUTH2" || string(ir) != "user=user@example.com\x01auth=Bearer tok\x01\x01" {
+		t.Errorf("XOAUTH2初始响应错误: %s %q", mech, ir)
+	}
+
+	config.AuthMechanism = AuthOAuthBearer
+	auth := &saslSMTPAuth{}
+	if auth.client, err = config.saslClient("smtp.example.com", 587); err != nil {
+		t.Fatalf("创建OAUTHBEARER客户端失败: %v", err)
+	}
+	mech, ir, _ = auth.Start(nil)
+	if mech != "OAUTHBEARER" || !strings.Contains(string(ir), "n,a=user@example.com,") || !strings.Contains(string(ir), "auth=Bearer tok") {
+		t.Errorf("OAUTHBEARER初始响应错误: %s %q", mech, ir)
+	}
+}
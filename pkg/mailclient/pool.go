@@ -0,0 +1,484 @@
+package mailclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+	"github.com/spf13/viper"
+)
+
+// 连接池默认参数，可通过配置 imap_pool.* 覆盖
+const (
+	defaultMaxConnsPerAccount = 2
+	defaultMaxConnsPerHost    = 20
+	defaultPoolWaitTimeout    = 60 * time.Second
+	defaultPoolIdleTimeout    = 10 * time.Minute
+	defaultHealthCheckAfter   = 30 * time.Second
+)
+
+// ErrPoolWaitTimeout 等待空闲连接超时（不属于连接错误，不会触发重试）
+var ErrPoolWaitTimeout = errors.New("等待IMAP连接超时")
+
+// poolSettings 连接池参数
+type poolSettings struct {
+	maxPerAccount    int           // 每个账号最多同时打开的连接数
+	maxPerHost       int           // 每个IMAP服务器最多同时打开的连接数，0表示不限制
+	waitTimeout      time.Duration // 获取连接时最长等待时间
+	idleTimeout      time.Duration // 空闲连接超过该时间后关闭
+	healthCheckAfter time.Duration // 空闲超过该时间的连接在取出时先发送NOOP检查
+}
+
+// loadPoolSettings 读取连接池配置，未配置时使用默认值
+func loadPoolSettings() poolSettings {
+	s := poolSettings{
+		maxPerAccount:    viper.GetInt("imap_pool.max_per_account"),
+		maxPerHost:       viper.GetInt("imap_pool.max_per_host"),
+		waitTimeout:      time.Duration(viper.GetInt("imap_pool.wait_timeout_seconds")) * time.Second,
+		idleTimeout:      time.Duration(viper.GetInt("imap_pool.idle_timeout_minutes")) * time.Minute,
+		healthCheckAfter: time.Duration(viper.GetInt("imap_pool.health_check_seconds")) * time.Second,
+	}
+	if s.maxPerAccount <= 0 {
+		s.maxPerAccount = defaultMaxConnsPerAccount
+	}
+	if !viper.IsSet("imap_pool.max_per_host") {
+		s.maxPerHost = defaultMaxConnsPerHost
+	}
+	if s.waitTimeout <= 0 {
+		s.waitTimeout = defaultPoolWaitTimeout
+	}
+	if s.idleTimeout <= 0 {
+		s.idleTimeout = defaultPoolIdleTimeout
+	}
+	if s.healthCheckAfter <= 0 {
+		s.healthCheckAfter = defaultHealthCheckAfter
+	}
+	return s
+}
+
+// ConnectionPool IMAP连接池
+// 连接通过 Acquire 独占取出、用完后 Release 归还，同一连接不会被多个调用方同时使用；
+// 每个账号、每个服务器的连接数都有上限，达到上限时在等待队列中等待其他调用方归还
+type ConnectionPool struct {
+	mutex    sync.Mutex
+	accounts map[string]*accountConns // 邮箱地址 → 账号的连接
+	hostOpen map[string]int           // 服务器地址 → 已打开的连接数
+	notify   chan struct{}            // 有连接归还或关闭时关闭并替换，唤醒所有等待者
+	settings func() poolSettings
+}
+
+// accountConns 单个账号的连接
+type accountConns struct {
+	idle       []*PooledConnection // 空闲连接
+	open       int                 // 已打开（含空闲、使用中和正在建立）的连接数
+	waiting    int                 // 等待中的调用方数量
+	generation int                 // CloseConnection 后递增，旧连接归还时直接关闭
+}
+
+// PooledConnection 从连接池取出的连接，使用期间由调用方独占
+type PooledConnection struct {
+	Client      *client.Client
+	LastUsed    time.Time
+	CreatedAt   time.Time
+	AccountInfo *EmailConfigInfo
+
+	key        string
+	host       string
+	generation int
+	broken     bool
+	released   bool
+}
+
+// PoolStats 连接池统计
+type PoolStats struct {
+	MaxPerAccount int                         `json:"max_per_account"`
+	MaxPerHost    int                         `json:"max_per_host"`
+	Hosts         map[string]int              `json:"hosts"`
+	Accounts      map[string]AccountPoolStats `json:"accounts"`
+}
+
+// AccountPoolStats 单个账号的连接统计
+type AccountPoolStats struct {
+	Open    int `json:"open"`
+	Idle    int `json:"idle"`
+	InUse   int `json:"in_use"`
+	Waiting int `json:"waiting"`
+}
+
+// 全局连接池
+var globalPool = newConnectionPool(loadPoolSettings)
+
+// dialIMAP 建立并登录新的IMAP连接，测试中可替换
+var dialIMAP = createNewConnection
+
+// newConnectionPool 创建连接池
+func newConnectionPool(settings func() poolSettings) *ConnectionPool {
+	return &ConnectionPool{
+		accounts: make(map[string]*accountConns),
+		hostOpen: make(map[string]int),
+		notify:   make(chan struct{}),
+		settings: settings,
+	}
+}
+
+// 定期清理过期连接
+func init() {
+	go func() {
+		ticker := time.NewTicker(2 * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			globalPool.cleanupExpiredConnections()
+		}
+	}()
+}
+
+// GetPoolStats 获取全局连接池统计
+func GetPoolStats() PoolStats {
+	return globalPool.Stats()
+}
+
+// poolHost 连接计数使用的服务器地址
+func poolHost(config *EmailConfigInfo) string {
+	return strings.ToLower(strings.TrimSpace(config.IMAPServer))
+}
+
+// accountLocked 获取账号的连接记录，不存在时创建（调用方需持有锁）
+func (p *ConnectionPool) accountLocked(key string) *accountConns {
+	ac, ok := p.accounts[key]
+	if !ok {
+		ac = &accountConns{}
+		p.accounts[key] = ac
+	}
+	return ac
+}
+
+// broadcastLocked 唤醒所有等待者（调用方需持有锁）
+func (p *ConnectionPool) broadcastLocked() {
+	close(p.notify)
+	p.notify = make(chan struct{})
+}
+
+// forgetLocked 连接关闭后扣减计数（调用方需持有锁）
+func (p *ConnectionPool) forgetLocked(key, host string) {
+	if ac, ok := p.accounts[key]; ok {
+		ac.open--
+		if ac.open <= 0 && ac.waiting == 0 && len(ac.idle) == 0 {
+			delete(p.accounts, key)
+		}
+	}
+	if p.hostOpen[host]--; p.hostOpen[host] <= 0 {
+		delete(p.hostOpen, host)
+	}
+	p.broadcastLocked()
+}
+
+// evictIdleOnHostLocked 服务器连接数已满时，关闭同一服务器上其他账号的一个空闲连接腾出名额（调用方需持有锁）
+func (p *ConnectionPool) evictIdleOnHostLocked(host, exceptKey string) bool {
+	for key, ac := range p.accounts {
+		if key == exceptKey {
+			continue
+		}
+		for i, pc := range ac.idle {
+			if pc.host != host {
+				continue
+			}
+			ac.idle = append(ac.idle[:i], ac.idle[i+1:]...)
+			p.forgetLocked(pc.key, pc.host)
+			log.Printf("[连接池] 服务器 %s 连接数已满，关闭账号 %s 的空闲连接", host, key)
+			go safeCloseConnection(pc.Client)
+			return true
+		}
+	}
+	return false
+}
+
+// Acquire 独占获取账号的一个连接：优先复用空闲连接，未达上限时新建，否则等待其他调用方归还直到ctx结束
+func (p *ConnectionPool) Acquire(ctx context.Context, config *EmailConfigInfo) (*PooledConnection, error) {
+	settings := p.settings()
+	key := config.EmailAddress
+	host := poolHost(config)
+
+	for {
+		p.mutex.Lock()
+		ac := p.accountLocked(key)
+
+		// 复用最近归还的空闲连接
+		if n := len(ac.idle); n > 0 {
+			pc := ac.idle[n-1]
+			ac.idle = ac.idle[:n-1]
+			p.mutex.Unlock()
+
+			if p.checkOnCheckout(pc, settings) {
+				pc.released = false
+				pc.LastUsed = time.Now()
+				log.Printf("[连接池] 复用空闲连接: %s, 状态: %v", key, pc.Client.State())
+				return pc, nil
+			}
+			log.Printf("[连接池] 空闲连接已失效，关闭后重新获取: %s", key)
+			p.closeConnection(pc)
+			continue
+		}
+
+		// 未达到账号和服务器上限时新建连接
+		if ac.open < settings.maxPerAccount &&
+			(settings.maxPerHost <= 0 || p.hostOpen[host] < settings.maxPerHost || p.evictIdleOnHostLocked(host, key)) {
+			ac.open++
+			p.hostOpen[host]++
+			generation, open := ac.generation, ac.open
+			p.mutex.Unlock()
+
+			log.Printf("[连接池] 创建新连接: %s (账号连接数: %d/%d)", key, open, settings.maxPerAccount)
+			c, err := dialIMAP(config)
+			if err != nil {
+				p.mutex.Lock()
+				p.forgetLocked(key, host)
+				p.mutex.Unlock()
+				return nil, err
+			}
+			now := time.Now()
+			return &PooledConnection{
+				Client:      c,
+				LastUsed:    now,
+				CreatedAt:   now,
+				AccountInfo: config,
+				key:         key,
+				host:        host,
+				generation:  generation,
+			}, nil
+		}
+
+		// 达到上限，进入等待队列
+		ac.waiting++
+		wait := p.notify
+		open, hostOpen := ac.open, p.hostOpen[host]
+		p.mutex.Unlock()
+
+		log.Printf("[连接池] 连接已达上限，等待其他请求归还: %s (账号: %d/%d, 服务器 %s: %d/%d)",
+			key, open, settings.maxPerAccount, host, hostOpen, settings.maxPerHost)
+
+		var waitErr error
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			waitErr = fmt.Errorf("%w: 账号 %s 已有 %d 个连接在使用", ErrPoolWaitTimeout, key, open)
+		}
+
+		p.mutex.Lock()
+		ac.waiting--
+		p.mutex.Unlock()
+		if waitErr != nil {
+			return nil, waitErr
+		}
+	}
+}
+
+// checkOnCheckout 取出空闲连接时的健康检查，空闲时间较短时只检查连接状态
+func (p *ConnectionPool) checkOnCheckout(pc *PooledConnection, settings poolSettings) bool {
+	if pc.Client == nil {
+		return false
+	}
+	state := pc.Client.State()
+	if state == 0 || state == 4 { // Closed=0, Logout=4
+		return false
+	}
+	if time.Since(pc.LastUsed) < settings.healthCheckAfter {
+		return true
+	}
+	return p.isConnectionHealthy(pc.Client, pc.key)
+}
+
+// Release 归还连接；连接已损坏、已被 CloseConnection 作废或状态异常时直接关闭
+func (p *ConnectionPool) Release(pc *PooledConnection) {
+	if pc == nil || pc.released {
+		return
+	}
+	pc.released = true
+
+	p.mutex.Lock()
+	ac, ok := p.accounts[pc.key]
+	reusable := ok && !pc.broken && pc.generation == ac.generation && pc.Client != nil
+	if reusable {
+		state := pc.Client.State()
+		reusable = state == 2 || state == 6 // Auth=2, Selected=6
+	}
+	if !reusable {
+		p.forgetLocked(pc.key, pc.host)
+		p.mutex.Unlock()
+		log.Printf("[连接池] 关闭不可复用的连接: %s", pc.key)
+		safeCloseConnection(pc.Client)
+		return
+	}
+
+	pc.LastUsed = time.Now()
+	ac.idle = append(ac.idle, pc)
+	p.broadcastLocked()
+	p.mutex.Unlock()
+}
+
+// Discard 关闭连接而不归还到池中，用于连接出现错误的情况
+func (p *ConnectionPool) Discard(pc *PooledConnection) {
+	if pc == nil {
+		return
+	}
+	pc.broken = true
+	p.Release(pc)
+}
+
+// closeConnection 关闭一个已从空闲列表取出的连接
+func (p *ConnectionPool) closeConnection(pc *PooledConnection) {
+	p.mutex.Lock()
+	p.forgetLocked(pc.key, pc.host)
+	p.mutex.Unlock()
+	safeCloseConnection(pc.Client)
+}
+
+// CloseConnection 关闭账号的所有空闲连接，使用中的连接在归还时关闭
+func (p *ConnectionPool) CloseConnection(email string) {
+	p.mutex.Lock()
+	ac, ok := p.accounts[email]
+	if !ok {
+		p.mutex.Unlock()
+		return
+	}
+	ac.generation++
+	idle := ac.idle
+	ac.idle = nil
+	for _, pc := range idle {
+		p.forgetLocked(pc.key, pc.host)
+	}
+	p.mutex.Unlock()
+
+	for _, pc := range idle {
+		log.Printf("[连接池] 强制关闭连接: %s", email)
+		safeCloseConnection(pc.Client)
+	}
+}
+
+// cleanupExpiredConnections 关闭闲置超时的连接
+func (p *ConnectionPool) cleanupExpiredConnections() {
+	idleTimeout := p.settings().idleTimeout
+	now := time.Now()
+
+	var expired []*PooledConnection
+	p.mutex.Lock()
+	for _, ac := range p.accounts {
+		kept := ac.idle[:0]
+		for _, pc := range ac.idle {
+			if now.Sub(pc.LastUsed) > idleTimeout {
+				expired = append(expired, pc)
+			} else {
+				kept = append(kept, pc)
+			}
+		}
+		ac.idle = kept
+	}
+	for _, pc := range expired {
+		p.forgetLocked(pc.key, pc.host)
+	}
+	p.mutex.Unlock()
+
+	for _, pc := range expired {
+		log.Printf("[连接池] 清理过期连接: %s (闲置时间: %v)", pc.key, now.Sub(pc.LastUsed))
+		safeCloseConnection(pc.Client)
+	}
+}
+
+// Stats 返回连接池统计
+func (p *ConnectionPool) Stats() PoolStats {
+	settings := p.settings()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stats := PoolStats{
+		MaxPerAccount: settings.maxPerAccount,
+		MaxPerHost:    settings.maxPerHost,
+		Hosts:         make(map[string]int, len(p.hostOpen)),
+		Accounts:      make(map[string]AccountPoolStats, len(p.accounts)),
+	}
+	for host, n := range p.hostOpen {
+		stats.Hosts[host] = n
+	}
+	for key, ac := range p.accounts {
+		stats.Accounts[key] = AccountPoolStats{
+			Open:    ac.open,
+			Idle:    len(ac.idle),
+			InUse:   ac.open - len(ac.idle),
+			Waiting: ac.waiting,
+		}
+	}
+	return stats
+}
+
+// 连接健康检查
+func (p *ConnectionPool) isConnectionHealthy(c *client.Client, email string) bool {
+	// 检查1: 连接状态
+	state := c.State()
+	if state == 0 || state == 4 { // Closed=0, Logout=4 in go-imap v1
+		log.Printf("[连接池] 连接已关闭: %s, 状态: %v", email, state)
+		return false
+	}
+
+	// 检查2: 验证是否在正确的状态
+	if state != 2 && state != 6 { // Auth=2, Selected=6 in go-imap v1
+		log.Printf("[连接池] 连接状态异常: %s, 状态: %v", email, state)
+		return false
+	}
+
+	// 检查3: NOOP命令（更安全的检查）- 设置更短的超时时间
+	noopStart := time.Now()
+	err := c.Noop()
+	noopDuration := time.Since(noopStart)
+
+	if err != nil {
+		log.Printf("[连接池] NOOP命令失败: %s, 耗时: %v, 错误: %v", email, noopDuration, err)
+		// 检查是否是连接相关的错误或IMAP命令错误
+		if isConnectionError(err) || strings.Contains(strings.ToLower(err.Error()), "command is not a valid imap command") {
+			log.Printf("[连接池] NOOP失败，检测到连接或命令错误: %s", email)
+			return false
+		}
+		// 非连接错误，可能是临时问题，再次验证状态
+		currentState := c.State()
+		if currentState == 0 || currentState == 4 {
+			log.Printf("[连接池] NOOP失败后连接状态异常: %s, 状态: %v", email, currentState)
+			return false
+		}
+		// 如果状态正常但NOOP失败，可能是临时问题，记录警告但继续使用
+		log.Printf("[连接池] NOOP失败但连接状态正常: %s, 状态: %v, 将继续使用", email, currentState)
+	}
+
+	// 检查4: 如果NOOP耗时过长，也认为连接不健康
+	if noopDuration > 10*time.Second {
+		log.Printf("[连接池] NOOP响应过慢: %s, 耗时: %v, 认为连接不健康", email, noopDuration)
+		return false
+	}
+
+	log.Printf("[连接池] 连接健康检查通过: %s, 状态: %v, NOOP耗时: %v", email, state, noopDuration)
+	return true
+}
+
+// AcquireIMAP 从连接池独占获取一个IMAP连接，用完后必须调用 ReleaseIMAP 归还
+func (m *MailClient) AcquireIMAP(ctx context.Context) (*PooledConnection, error) {
+	return globalPool.Acquire(ctx, m.Config)
+}
+
+// acquireIMAP 使用配置的等待超时获取连接
+func (m *MailClient) acquireIMAP() (*PooledConnection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), globalPool.settings().waitTimeout)
+	defer cancel()
+	return globalPool.Acquire(ctx, m.Config)
+}
+
+// ReleaseIMAP 归还连接，err 为连接错误时关闭该连接而不再复用
+func ReleaseIMAP(pc *PooledConnection, err error) {
+	if err != nil && (isConnectionError(err) || isWrappedConnectionError(err)) {
+		globalPool.Discard(pc)
+		return
+	}
+	globalPool.Release(pc)
+}
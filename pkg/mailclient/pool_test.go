@@ -0,0 +1,100 @@
+package mailclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+)
+
+// newTestPool 创建使用固定参数的连接池，并把建立连接替换为不访问网络的桩函数
+func newTestPool(t *testing.T, perAccount, perHost int) *ConnectionPool {
+	t.Helper()
+	orig := dialIMAP
+	dialIMAP = func(config *EmailConfigInfo) (*client.Client, error) { return nil, nil }
+	t.Cleanup(func() { dialIMAP = orig })
+
+	return newConnectionPool(func() poolSettings {
+		return poolSettings{
+			maxPerAccount:    perAccount,
+			maxPerHost:       perHost,
+			waitTimeout:      time.Second,
+			idleTimeout:      time.Minute,
+			healthCheckAfter: time.Minute,
+		}
+	})
+}
+
+func TestPoolAccountLimitAndWait(t *testing.T) {
+	pool := newTestPool(t, 2, 0)
+	config := &EmailConfigInfo{EmailAddress: "a@example.com", IMAPServer: "imap.example.com"}
+
+	first, err := pool.Acquire(context.Background(), config)
+	if err != nil {
+		t.Fatalf("获取第1个连接失败: %v", err)
+	}
+	if _, err := pool.Acquire(context.Background(), config); err != nil {
+		t.Fatalf("获取第2个连接失败: %v", err)
+	}
+
+	// 达到账号上限后应等待，直到ctx超时
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Acquire(ctx, config); !errors.Is(err, ErrPoolWaitTimeout) {
+		t.Fatalf("超过账号上限时应返回等待超时，实际: %v", err)
+	}
+	if isConnectionError(ErrPoolWaitTimeout) {
+		t.Error("等待超时不应被识别为连接错误")
+	}
+
+	// 归还连接后等待者应被唤醒并拿到连接
+	got := make(chan error, 1)
+	go func() {
+		_, err := pool.Acquire(context.Background(), config)
+		got <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	pool.Discard(first)
+
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatalf("等待者获取连接失败: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("归还连接后等待者没有被唤醒")
+	}
+
+	stats := pool.Stats().Accounts[config.EmailAddress]
+	if stats.Open != 2 || stats.InUse != 2 || stats.Waiting != 0 {
+		t.Errorf("连接统计错误: %+v", stats)
+	}
+}
+
+func TestPoolHostLimit(t *testing.T) {
+	pool := newTestPool(t, 2, 1)
+	a := &EmailConfigInfo{EmailAddress: "a@example.com", IMAPServer: "imap.example.com"}
+	b := &EmailConfigInfo{EmailAddress: "b@example.com", IMAPServer: "IMAP.example.com"}
+
+	pc, err := pool.Acquire(context.Background(), a)
+	if err != nil {
+		t.Fatalf("获取连接失败: %v", err)
+	}
+
+	// 同一服务器（不区分大小写）的连接数已满，且没有可回收的空闲连接
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Acquire(ctx, b); !errors.Is(err, ErrPoolWaitTimeout) {
+		t.Fatalf("超过服务器上限时应返回等待超时，实际: %v", err)
+	}
+
+	pool.Discard(pc)
+	if _, err := pool.Acquire(context.Background(), b); err != nil {
+		t.Fatalf("服务器连接释放后获取失败: %v", err)
+	}
+	if hosts := pool.Stats().Hosts; hosts["imap.example.com"] != 1 {
+		t.Errorf("服务器连接数错误: %v", hosts)
+	}
+}
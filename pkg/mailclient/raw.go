@@ -0,0 +1,62 @@
+package mailclient
+
+import (
+	"bytes"
+	"fmt"
+	"go_email/pkg/utils"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// ParserVersion 邮件解析器版本，修改解析逻辑后需要递增，重新解析任务据此找出旧版本解析的邮件
+const ParserVersion = 12
+
+// ParseRawEmail 从归档的原始RFC 822内容解析邮件，不需要连接IMAP服务器
+// 主题、发件人、收件人和日期取自邮件头（FETCH时取自ENVELOPE），其余邮件头字段、正文和附件与获取邮件内容时的解析逻辑一致
+// 不知道收件邮箱，不采用邮件中的认证结果，已知账号时用 ApplySenderAuth 重新计算
+func ParseRawEmail(raw []byte, skipAttachments bool) (*Email, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("解析邮件头失败: %w", err)
+	}
+
+	email := &Email{
+		Subject:     DecodeMIMESubject(msg.Header.Get("Subject")),
+		From:        formatHeaderAddresses(msg.Header, "From"),
+		To:          formatHeaderAddresses(msg.Header, "To"),
+		Attachments: []AttachmentInfo{},
+		Raw:         raw,
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		email.Date = date.Format(time.RFC1123Z)
+	}
+	if sentAt, ok := utils.ParseMailDate(msg.Header.Get("Date")); ok {
+		email.SentAt = sentAt
+		if email.Date == "" {
+			email.Date = sentAt.Format(time.RFC1123Z)
+		}
+	}
+
+	parseRawContent(email, raw, skipAttachments)
+	return email, nil
+}
+
+// formatHeaderAddresses 按 parseAddressList 的格式输出邮件头中的地址列表，无法解析时返回解码后的原始值
+func formatHeaderAddresses(header mail.Header, key string) string {
+	value := decodeHeaderBytes(header.Get(key))
+	addresses, err := addressParser.ParseList(value)
+	if err != nil {
+		return DecodeMIMESubject(value)
+	}
+
+	addrList := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		if addr.Name != "" {
+			addrList = append(addrList, fmt.Sprintf("%s <%s>", addr.Name, addr.Address))
+		} else {
+			addrList = append(addrList, addr.Address)
+		}
+	}
+	return strings.Join(addrList, ", ")
+}
@@ -0,0 +1,117 @@
+package mailclient
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRawEmail(t *testing.T) {
+	raw := strings.Join([]string{
+		"From: =?UTF-8?B?5byg5LiJ?= <zhangsan@example.com>",
+		"To: lisi@example.com",
+		"Subject: =?UTF-8?B?5rWL6K+V?=",
+		"Date: Mon, 02 Jan 2006 15:04:05 +0800",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/mixed; boundary="b1"`,
+		"",
+		"--b1",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		"hello",
+		"--b1",
+		`Content-Type: application/pdf; name="a.pdf"`,
+		"Content-Transfer-Encoding: base64",
+		`Content-Disposition: attachment; filename="a.pdf"`,
+		"",
+		"JVBERi0xLjQK",
+		"--b1--",
+		"",
+	}, "\r\n")
+
+	email, err := ParseRawEmail([]byte(raw), false)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if email.Subject != "测试" || email.From != "张三 <zhangsan@example.com>" || email.To != "lisi@example.com" {
+		t.Errorf("邮件头解析错误: %q %q %q", email.Subject, email.From, email.To)
+	}
+	if email.Date != "Mon, 02 Jan 2006 15:04:05 +0800" {
+		t.Errorf("日期解析错误: %q", email.Date)
+	}
+	if strings.TrimSpace(email.Body) != "hello" {
+		t.Errorf("正文解析错误: %q", email.Body)
+	}
+	if len(email.Attachments) != 1 || email.Attachments[0].Filename != "a.pdf" {
+		t.Errorf("附件解析错误: %+v", email.Attachments)
+	}
+}
+
+func TestParseRawEmailHeaders(t *testing.T) {
+	raw := strings.Join([]string{
+		"From: a@example.com",
+		"To: b@example.com",
+		"Cc: =?UTF-8?B?546L5LqU?= <wangwu@example.com>, c@example.com",
+		"Reply-To: ops@example.com",
+		"Message-ID: <m3@example.com>",
+		"In-Reply-To: <m2@example.com>",
+		"References: <m1@example.com>",
+		"  <m2@example.com>",
+		"X-Shipment: =?UTF-8?B?5rWL6K+V?=",
+		"Subject: re",
+		"",
+		"body",
+	}, "\r\n")
+
+	email, err := ParseRawEmail([]byte(raw), false)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if email.Cc != "王五 <wangwu@example.com>, c@example.com" || email.ReplyTo != "ops@example.com" {
+		t.Errorf("抄送或回复地址解析错误: %q %q", email.Cc, email.ReplyTo)
+	}
+	if email.MessageID != "m3@example.com" || email.InReplyTo != "m2@example.com" {
+		t.Errorf("Message-ID解析错误: %q %q", email.MessageID, email.InReplyTo)
+	}
+	if strings.Join(email.References, " ") != "m1@example.com m2@example.com" {
+		t.Errorf("References解析错误: %v", email.References)
+	}
+	if got := email.Headers["X-Shipment"]; len(got) != 1 || got[0] != "测试" {
+		t.Errorf("自定义邮件头解析错误: %v", email.Headers)
+	}
+}
+
+func TestParseMessageIDList(t *testing.T) {
+	if ids := parseMessageIDList("a@x, b@y"); len(ids) != 2 || ids[1] != "b@y" {
+		t.Errorf("无尖括号的ID列表解析错误: %v", ids)
+	}
+	if ids := parseMessageIDList(`"note" <a@x>`); len(ids) != 1 || ids[0] != "a@x" {
+		t.Errorf("带注释的ID解析错误: %v", ids)
+	}
+}
+
+func TestParseRawEmailDates(t *testing.T) {
+	raw := strings.Join([]string{
+		"Received: from mx.example.com by mail.example.org; Tue, 5 Mar 2024 14:07:10 +0800",
+		"Received: from client by mx.example.com; Tue, 5 Mar 2024 14:07:09 +0800",
+		"From: a@example.com",
+		"Date: Tue 5 Mar 2024 14:07:08 GMT+8",
+		"Subject: date",
+		"",
+		"hello",
+	}, "\r\n")
+
+	email, err := ParseRawEmail([]byte(raw), true)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if !email.SentAt.Equal(time.Date(2024, 3, 5, 6, 7, 8, 0, time.UTC)) {
+		t.Errorf("发送时间错误: %v", email.SentAt)
+	}
+	if !email.ReceivedAt.Equal(time.Date(2024, 3, 5, 6, 7, 10, 0, time.UTC)) {
+		t.Errorf("接收时间应取最近一跳Received: %v", email.ReceivedAt)
+	}
+	if email.Date == "" {
+		t.Error("日期字符串不应为空")
+	}
+}
@@ -0,0 +1,163 @@
+package mailclient
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// sanitizeAllowedTags 清理后保留的元素，其他元素去掉标签只保留内容
+var sanitizeAllowedTags = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Blockquote: true, atom.Br: true, atom.Caption: true,
+	atom.Center: true, atom.Code: true, atom.Col: true, atom.Colgroup: true, atom.Dd: true, atom.Div: true,
+	atom.Dl: true, atom.Dt: true, atom.Em: true, atom.Font: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Hr: true, atom.I: true, atom.Img: true, atom.Li: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.S: true, atom.Small: true, atom.Span: true,
+	atom.Strike: true, atom.Strong: true, atom.Sub: true, atom.Sup: true, atom.Table: true, atom.Tbody: true,
+	atom.Td: true, atom.Tfoot: true, atom.Th: true, atom.Thead: true, atom.Tr: true, atom.U: true, atom.Ul: true,
+}
+
+// sanitizeAllowedAttrs 保留的属性，事件处理(on*)、class/id和其他属性一律去掉
+var sanitizeAllowedAttrs = map[string]bool{
+	"align": true, "valign": true, "width": true, "height": true, "colspan": true, "rowspan": true,
+	"border": true, "cellpadding": true, "cellspacing": true, "bgcolor": true, "color": true, "face": true,
+	"size": true, "dir": true, "lang": true, "title": true, "alt": true, "start": true, "type": true,
+	"style": true,
+}
+
+// sanitizeVoidTags 没有结束标签的元素
+var sanitizeVoidTags = map[atom.Atom]bool{atom.Br: true, atom.Hr: true, atom.Img: true, atom.Col: true}
+
+// SanitizeHTML 按白名单清理HTML正文，用于在内部工具中展示
+// 去掉脚本、样式表、iframe/表单等元素和事件处理属性；链接只保留http/https/mailto/tel；
+// 图片只保留 data:image、cid: 和 allowedImages 中的地址（已上传的内嵌图片），其他远程图片（跟踪像素）去掉，有alt时保留alt文字
+func SanitizeHTML(htmlContent string, allowedImages map[string]bool) string {
+	if strings.TrimSpace(htmlContent) == "" {
+		return ""
+	}
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	sanitizeNode(&b, doc, allowedImages)
+	return strings.TrimSpace(b.String())
+}
+
+func sanitizeNode(b *strings.Builder, n *html.Node, allowedImages map[string]bool) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	case html.CommentNode, html.DoctypeNode:
+		return
+	default:
+		sanitizeChildren(b, n, allowedImages)
+		return
+	}
+
+	if htmlSkipTags[n.DataAtom] {
+		return
+	}
+	if !sanitizeAllowedTags[n.DataAtom] {
+		sanitizeChildren(b, n, allowedImages)
+		return
+	}
+
+	var attrs []html.Attribute
+	if n.DataAtom == atom.Img {
+		src := strings.TrimSpace(attrValue(n, "src"))
+		if !safeImageSource(src, allowedImages) {
+			if alt := strings.TrimSpace(attrValue(n, "alt")); alt != "" {
+				b.WriteString(html.EscapeString("[" + alt + "]"))
+			}
+			return
+		}
+		attrs = append(attrs, html.Attribute{Key: "src", Val: src})
+	}
+	if n.DataAtom == atom.A {
+		if href := strings.TrimSpace(attrValue(n, "href")); safeLinkURL(href) {
+			attrs = append(attrs,
+				html.Attribute{Key: "href", Val: href},
+				html.Attribute{Key: "target", Val: "_blank"},
+				html.Attribute{Key: "rel", Val: "noopener noreferrer"})
+		}
+	}
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" || !sanitizeAllowedAttrs[key] {
+			continue
+		}
+		if key == "style" && !safeStyle(a.Val) {
+			continue
+		}
+		attrs = append(attrs, html.Attribute{Key: key, Val: a.Val})
+	}
+
+	b.WriteString("<" + n.Data)
+	for _, a := range attrs {
+		b.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	b.WriteString(">")
+	if sanitizeVoidTags[n.DataAtom] {
+		return
+	}
+	sanitizeChildren(b, n, allowedImages)
+	b.WriteString("</" + n.Data + ">")
+}
+
+func sanitizeChildren(b *strings.Builder, n *html.Node, allowedImages map[string]bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(b, c, allowedImages)
+	}
+}
+
+// compactURL 去掉地址中的空白和控制字符并转为小写，防止 java\tscript: 这类绕过
+func compactURL(value string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, value))
+}
+
+// safeLinkURL 链接是否为http/https/mailto/tel或页内锚点
+func safeLinkURL(href string) bool {
+	lower := compactURL(href)
+	for _, prefix := range []string{"http://", "https://", "mailto:", "tel:", "#"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// safeImageSource 图片地址是否不会向外部发起请求
+func safeImageSource(src string, allowedImages map[string]bool) bool {
+	if src == "" {
+		return false
+	}
+	if allowedImages[src] {
+		return true
+	}
+	lower := compactURL(src)
+	if strings.HasPrefix(lower, "cid:") {
+		return true
+	}
+	return strings.HasPrefix(lower, "data:image/") && !strings.HasPrefix(lower, "data:image/svg")
+}
+
+// safeStyle 内联样式中不能加载外部资源、执行脚本或覆盖页面
+// CSS转义（如 \75rl( 即 url(）可以绕过关键字检查，含反斜杠的样式直接丢弃
+func safeStyle(style string) bool {
+	lower := compactURL(style)
+	for _, bad := range []string{"\\", "url(", "image(", "image-set(", "cross-fade(", "src(", "expression(", "javascript:", "@import", "behavior:", "-moz-binding", "position:"} {
+		if strings.Contains(lower, bad) {
+			return false
+		}
+	}
+	return true
+}
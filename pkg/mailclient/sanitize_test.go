@@ -0,0 +1,66 @@
+package mailclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSafeStyle(t *testing.T) {
+	for _, style := range []string{
+		`background:url(http://tracker/p.gif)`,
+		`background:\75rl(http://tracker/p.gif)`,
+		`background:\000075rl(http://tracker/p.gif)`,
+		`background-image:image-set('http://tracker/p.gif' 1x)`,
+		`background-image:-webkit-image-set("http://tracker/p.gif" 1x)`,
+		`background-image:image("http://tracker/p.gif")`,
+		`width:expression(alert(1))`,
+	} {
+		if safeStyle(style) {
+			t.Errorf("样式应被丢弃: %s", style)
+		}
+	}
+	for _, style := range []string{"color:red", "font-family:'Microsoft YaHei'; font-size:14px", "border:1px solid #ccc"} {
+		if !safeStyle(style) {
+			t.Errorf("样式应保留: %s", style)
+		}
+	}
+}
+
+func TestSanitizeHTML(t *testing.T) {
+	input := `<html><head><script>evil()</script><style>body{}</style></head>
+<body onload="evil()">
+<p class="x" style="color:red" onclick="evil()">Hello <b>World</b></p>
+<a href="javascript:evil()">bad</a> <a href="java&#09;script:evil()">bad2</a> <a href="https://example.com/a?b=1&c=2">good</a>
+<img src="https://tracker.example.com/open.gif" width="1" height="1">
+<img src="https://tracker.example.com/banner.png" alt="Banner">
+<img src="https://oss.example.com/inline/logo.png" alt="logo">
+<img src="data:image/png;base64,iVBORw0KGgo=">
+<img src="data:image/svg+xml;base64,PHN2Zz4=">
+<div style="background:url(https://tracker.example.com/x)">styled</div>
+<iframe src="https://example.com"></iframe>
+<form action="https://example.com"><input name="a">form text</form>
+<!-- comment -->
+</body></html>`
+
+	got := SanitizeHTML(input, map[string]bool{"https://oss.example.com/inline/logo.png": true})
+
+	for _, bad := range []string{"script", "evil", "onload", "onclick", "class=", "tracker.example.com", "svg", "iframe", "<form", "<input", "comment", "url("} {
+		if strings.Contains(got, bad) {
+			t.Errorf("清理结果不应包含 %q:\n%s", bad, got)
+		}
+	}
+	for _, want := range []string{
+		`<p style="color:red">Hello <b>World</b></p>`,
+		`<a>bad</a>`,
+		`<a href="https://example.com/a?b=1&amp;c=2" target="_blank" rel="noopener noreferrer">good</a>`,
+		`[Banner]`,
+		`<img src="https://oss.example.com/inline/logo.png" alt="logo">`,
+		`<img src="data:image/png;base64,iVBORw0KGgo=">`,
+		`<div>styled</div>`,
+		`form text`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("清理结果应包含 %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package mailclient
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+// UIDSearchResult 搜索邮件UID的结果
+type UIDSearchResult struct {
+	Folder      string   `json:"folder"`
+	UIDValidity uint32   `json:"uid_validity"`
+	UIDs        []uint32 `json:"uids"` // 升序
+}
+
+// SearchUIDsByDate 在文件夹中搜索内部日期在 [since, before) 范围内的邮件UID
+// IMAP的 SINCE/BEFORE 只比较日期部分，零值表示不限制该端
+func (m *MailClient) SearchUIDsByDate(folder string, since, before time.Time) (*UIDSearchResult, error) {
+	criteria := imap.NewSearchCriteria()
+	criteria.Since = since
+	criteria.Before = before
+	return m.searchUIDs(folder, criteria, fmt.Sprintf("日期范围 [%s, %s)", formatSearchDate(since), formatSearchDate(before)))
+}
+
+// SearchUIDRange 搜索文件夹中UID在 [from, to] 范围内实际存在的邮件
+func (m *MailClient) SearchUIDRange(folder string, from, to uint32) (*UIDSearchResult, error) {
+	if from == 0 {
+		from = 1
+	}
+	if to < from {
+		return nil, fmt.Errorf("UID范围无效: %d-%d", from, to)
+	}
+	criteria := imap.NewSearchCriteria()
+	criteria.Uid = new(imap.SeqSet)
+	criteria.Uid.AddRange(from, to)
+	return m.searchUIDs(folder, criteria, fmt.Sprintf("UID范围 %d-%d", from, to))
+}
+
+// searchUIDs 按条件搜索文件夹中的邮件UID，desc 用于日志
+func (m *MailClient) searchUIDs(folder string, criteria *imap.SearchCriteria, desc string) (*UIDSearchResult, error) {
+	maxRetries := 3
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		result, err := m.trySearchUIDs(folder, criteria, desc)
+		if err == nil {
+			return result, nil
+		}
+
+		if isConnectionError(err) || isWrappedConnectionError(err) {
+			log.Printf("[UID搜索] 连接错误 (尝试 %d/%d): 文件夹=%s, 错误: %v", attempt, maxRetries, folder, err)
+			if attempt < maxRetries {
+				time.Sleep(time.Second * time.Duration(attempt*2))
+				continue
+			}
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("搜索邮件失败，已重试 %d 次", maxRetries)
+}
+
+// trySearchUIDs 按条件搜索邮件UID（单次）
+func (m *MailClient) trySearchUIDs(folder string, criteria *imap.SearchCriteria, desc string) (_ *UIDSearchResult, err error) {
+	pc, err := m.acquireIMAP()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { ReleaseIMAP(pc, err) }()
+	c := pc.Client
+
+	mbox, err := c.Select(folder, true)
+	if err != nil {
+		return nil, fmt.Errorf("选择邮箱失败: %w", err)
+	}
+
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("搜索邮件失败: %w", err)
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+	log.Printf("[UID搜索] 文件夹 %s %s 找到 %d 封邮件", folder, desc, len(uids))
+	return &UIDSearchResult{Folder: folder, UIDValidity: mbox.UidValidity, UIDs: uids}, nil
+}
+
+// formatSearchDate 日志中显示搜索日期，零值显示为不限
+func formatSearchDate(t time.Time) string {
+	if t.IsZero() {
+		return "不限"
+	}
+	return t.Format("2006-01-02")
+}
+
+// ListEmailsByUIDs 获取指定UID的邮件列表信息（信封、附件标识），结果按UID升序
+func (m *MailClient) ListEmailsByUIDs(folder string, uids []uint32) ([]EmailInfo, error) {
+	maxRetries := 3
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		emails, err := m.tryListEmailsByUIDs(folder, uids)
+		if err == nil {
+			return emails, nil
+		}
+
+		if isConnectionError(err) || isWrappedConnectionError(err) {
+			log.Printf("[邮件列表] 连接错误 (尝试 %d/%d): 文件夹=%s, 错误: %v", attempt, maxRetries, folder, err)
+			if attempt < maxRetries {
+				time.Sleep(time.Second * time.Duration(attempt*2))
+				continue
+			}
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("获取邮件列表失败，已重试 %d 次", maxRetries)
+}
+
+// tryListEmailsByUIDs 获取指定UID的邮件列表信息（单次）
+func (m *MailClient) tryListEmailsByUIDs(folder string, uids []uint32) (_ []EmailInfo, err error) {
+	if len(uids) == 0 {
+		return []EmailInfo{}, nil
+	}
+
+	pc, err := m.acquireIMAP()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { ReleaseIMAP(pc, err) }()
+	c := pc.Client
+
+	mbox, err := c.Select(folder, true)
+	if err != nil {
+		return nil, fmt.Errorf("选择邮箱失败: %w", err)
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+
+	messages := make(chan *imap.Message, len(uids))
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqSet, []imap.FetchItem{imap.FetchEnvelope, imap.FetchInternalDate, imap.FetchFlags, imap.FetchBodyStructure, imap.FetchUid}, messages)
+	}()
+
+	emails := make([]EmailInfo, 0, len(uids))
+	for msg := range messages {
+		if msg.Uid == 0 || msg.Envelope == nil {
+			continue
+		}
+		emails = append(emails, buildEmailInfo(msg, mbox.UidValidity))
+	}
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("获取邮件失败: %w", err)
+	}
+
+	sort.Slice(emails, func(i, j int) bool { return emails[i].UID < emails[j].UID })
+	return emails, nil
+}
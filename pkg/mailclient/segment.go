@@ -0,0 +1,423 @@
+package mailclient
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// BodySegments 正文分段结果：新写的内容、引用的历史邮件、签名和免责声明
+type BodySegments struct {
+	NewText    string // 新写的纯文本内容
+	NewHTML    string // 去掉引用和签名后的HTML正文
+	Quoted     string // 引用或转发的历史邮件
+	Signature  string // 签名，包括结束语和“发自我的iPhone”这类移动端签名
+	Disclaimer string // 法律/保密免责声明
+}
+
+var (
+	// quoteSeparatorPattern 引用或转发的分隔行，包括 ForwardStructuredEmail 写入的“转发的邮件”和QQ邮箱、Outlook、Gmail、Yahoo的写法
+	quoteSeparatorPattern = regexp.MustCompile(`(?i)^[-_=\s]{2,}\s*(转发的邮件|转发的原始邮件|原始邮件|原始郵件|回复的邮件|转发邮件信息|original message|forwarded message|forwarded e-?mail)\s*[-_=\s]*$`)
+	// quoteIntroPattern 转发的引导语，如苹果邮件的 Begin forwarded message:
+	quoteIntroPattern = regexp.MustCompile(`(?i)^(begin forwarded message|以下是转发的邮件|下面是被转发的邮件)\s*[:：]?$`)
+	// quoteAttributionPattern Gmail/Yahoo/苹果邮件的引用说明行，如 On Mon, ... wrote: 和 张三 于2024年1月2日写道：
+	quoteAttributionPattern = regexp.MustCompile(`(?i)(^on\b.{0,300}\bwrote\s*:$|写道\s*[:：]$|寫道\s*[:：]$)`)
+	// quoteUnderlinePattern Outlook网页版在引用的邮件头前插入的下划线
+	quoteUnderlinePattern = regexp.MustCompile(`^_{10,}$`)
+	// quoteFromPattern 引用邮件头的发件人行，Gmail纯文本中Outlook的粗体会显示为 *From:*
+	quoteFromPattern = regexp.MustCompile(`(?i)^\*?(from|发件人|寄件者|寄件人)\s*\*?\s*[:：]`)
+	// quoteDatePattern 引用邮件头的时间行
+	quoteDatePattern = regexp.MustCompile(`(?i)^\*?(sent|date|发送时间|日期|时间|寄件日期)\s*\*?\s*[:：]`)
+	// quoteRecipientPattern 引用邮件头的收件人或主题行
+	quoteRecipientPattern = regexp.MustCompile(`(?i)^\*?(to|subject|收件人|主题|主旨)\s*\*?\s*[:：]`)
+
+	// signatureMobilePattern 移动端和客户端自动添加的签名
+	signatureMobilePattern = regexp.MustCompile(`(?i)^(sent from my \S+.*|sent from (mail|outlook) for .+|get outlook for .+|sent from yahoo mail.*|发自我的\S+|从我的\S+发送|来自我的\S+)$`)
+	// signatureClosingPattern 结束语，其后的几行都像姓名和联系方式时视为签名
+	signatureClosingPattern = regexp.MustCompile(`(?i)^(best regards|kind regards|warm regards|best wishes|regards|best|thanks|thank you|thanks and regards|thanks & regards|many thanks|cheers|sincerely|yours sincerely|yours faithfully|br|b\.r\.|此致|此致敬礼|祝好|顺祝商祺|顺颂商祺|顺颂时祺|祝商祺|祝工作顺利|谢谢|多谢|敬礼)[\s,，.。!！]*$`)
+	// signatureContactPattern 签名中的联系方式：电话、邮箱、网址、地址和公司名
+	signatureContactPattern = regexp.MustCompile(`(?i)(\+?\d[\d\s\-()]{6,}\d|[\w.+-]+@[\w-]+\.[\w.]+|www\.|https?://|\b(tel|phone|mobile|mob|fax|e-?mail|web|add|address)\b\s*[:：.]|电话|手机|传真|邮箱|地址|网址|\b(co\.?,?\s*ltd|limited|inc|corp|corporation|llc|gmbh|group)\b|有限公司|公司|集团)`)
+	// signatureSentencePattern 句子中的标点，姓名和职位行一般不会出现
+	signatureSentencePattern = regexp.MustCompile(`[。！？；，!?;]|[a-z]{3,}\.(\s|$)`)
+	// disclaimerTitlePattern 带标题的免责声明
+	disclaimerTitlePattern = regexp.MustCompile(`(?i)^\W*(confidentiality notice|confidentiality|disclaimer|legal notice|免责声明|保密声明|法律声明)\W*`)
+	// disclaimerLeadPattern 不带标题的免责声明开头，如 This email and any attachments are confidential...，需要同时包含 disclaimerKeywordPattern 中的词
+	disclaimerLeadPattern = regexp.MustCompile(`(?i)^\W*(this (e-?mail|message|communication)|the information (contained )?in this (e-?mail|message)|本邮件|此邮件|本電子郵件)`)
+	// disclaimerKeywordPattern 免责声明中的常见用语
+	disclaimerKeywordPattern = regexp.MustCompile(`(?i)(confidential|privileged|intended (solely |only )?for|intended recipient|保密|机密|指定(的)?收件人|仅供|不得(复制|转发|披露|使用))`)
+)
+
+// signatureMaxLines 结束语后面最多几行非空行仍视为签名
+const signatureMaxLines = 8
+
+// signatureShortLine 不含联系方式的签名行最多几个字符
+const signatureShortLine = 40
+
+// SegmentBody 把正文切分为新写的内容、引用的历史邮件、签名和免责声明
+// text 为纯文本正文（只有HTML正文的邮件传转换后的文本），htmlBody 为HTML正文，为空时 NewHTML 也为空
+func SegmentBody(text, htmlBody string) BodySegments {
+	var seg BodySegments
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n"), "\n")
+
+	end := quoteStartLine(lines)
+	seg.Quoted = strings.TrimSpace(strings.Join(lines[end:], "\n"))
+
+	if start := disclaimerStartLine(lines[:end]); start >= 0 {
+		seg.Disclaimer = strings.TrimSpace(strings.Join(lines[start:end], "\n"))
+		end = start
+	}
+	if start := signatureStartLine(lines[:end]); start >= 0 {
+		seg.Signature = strings.TrimSpace(strings.Join(lines[start:end], "\n"))
+		end = start
+	}
+	seg.NewText = strings.TrimSpace(strings.Join(lines[:end], "\n"))
+
+	if strings.TrimSpace(htmlBody) != "" {
+		seg.NewHTML = segmentHTML(htmlBody)
+	}
+	return seg
+}
+
+// quoteStartLine 引用的历史邮件从哪一行开始，没有引用时返回行数
+func quoteStartLine(lines []string) int {
+	for i, line := range lines {
+		trimmed := segmentLine(line)
+		if trimmed == "" {
+			continue
+		}
+		if quoteSeparatorPattern.MatchString(trimmed) || quoteIntroPattern.MatchString(trimmed) {
+			return i
+		}
+		if quoteAttributionPattern.MatchString(trimmed) {
+			return i
+		}
+		// Gmail会把较长的引用说明折成两行
+		if strings.HasPrefix(strings.ToLower(trimmed), "on ") && i+1 < len(lines) &&
+			quoteAttributionPattern.MatchString(trimmed+" "+segmentLine(lines[i+1])) {
+			return i
+		}
+		if quoteUnderlinePattern.MatchString(trimmed) && isQuoteHeaderBlock(lines[i+1:]) {
+			return i
+		}
+		if quoteFromPattern.MatchString(trimmed) && isQuoteHeaderBlock(lines[i:]) {
+			return i
+		}
+	}
+
+	// 没有引用说明时，末尾连续以 > 开头的行视为引用
+	start := len(lines)
+	for i := len(lines) - 1; i >= 0; i-- {
+		trimmed := segmentLine(lines[i])
+		if strings.HasPrefix(trimmed, ">") {
+			start = i
+		} else if trimmed != "" {
+			break
+		}
+	}
+	return start
+}
+
+// isQuoteHeaderBlock 判断是否为 Outlook 风格的引用邮件头：发件人行之后几行内有时间行和收件人/主题行
+func isQuoteHeaderBlock(lines []string) bool {
+	var checked int
+	hasFrom, hasDate, hasRecipient := false, false, false
+	for _, line := range lines {
+		trimmed := segmentLine(line)
+		if trimmed == "" {
+			if checked == 0 {
+				continue
+			}
+			break
+		}
+		if checked == 0 && !quoteFromPattern.MatchString(trimmed) {
+			return false
+		}
+		switch {
+		case quoteFromPattern.MatchString(trimmed):
+			hasFrom = true
+		case quoteDatePattern.MatchString(trimmed):
+			hasDate = true
+		case quoteRecipientPattern.MatchString(trimmed):
+			hasRecipient = true
+		}
+		if checked++; checked >= 6 {
+			break
+		}
+	}
+	return hasFrom && hasDate && hasRecipient
+}
+
+// disclaimerStartLine 免责声明从哪一行开始，免责声明不能是正文的第一段
+func disclaimerStartLine(lines []string) int {
+	first := firstContentLine(lines)
+	if first < 0 {
+		return -1
+	}
+	for i := first + 1; i < len(lines); i++ {
+		line := segmentLine(lines[i])
+		if disclaimerTitlePattern.MatchString(line) {
+			return i
+		}
+		if disclaimerLeadPattern.MatchString(line) &&
+			disclaimerKeywordPattern.MatchString(strings.Join(lines[i:min(i+4, len(lines))], " ")) {
+			return i
+		}
+	}
+	return -1
+}
+
+// signatureStartLine 签名从哪一行开始
+// 优先使用标准的“-- ”分隔行，其次是移动端签名，最后是末尾几行内、后面只跟着姓名和联系方式的结束语；正文只有一行时不切分
+func signatureStartLine(lines []string) int {
+	first := firstContentLine(lines)
+	if first < 0 {
+		return -1
+	}
+	for i := len(lines) - 1; i > first; i-- {
+		if trimmed := strings.TrimRight(lines[i], " \t"); trimmed == "--" || trimmed == "-- " {
+			return i
+		}
+	}
+	for i := len(lines) - 1; i > first; i-- {
+		if signatureMobilePattern.MatchString(segmentLine(lines[i])) {
+			return i
+		}
+	}
+
+	remaining := 0
+	for i := len(lines) - 1; i > first && remaining <= signatureMaxLines; i-- {
+		trimmed := segmentLine(lines[i])
+		if trimmed == "" {
+			continue
+		}
+		if signatureClosingPattern.MatchString(trimmed) {
+			return i
+		}
+		// 结束语后面出现正文内容时，说明这句致谢在正文中间，不是签名
+		if !signatureContactLine(trimmed) {
+			return -1
+		}
+		remaining++
+	}
+	return -1
+}
+
+// signatureContactLine 是否像签名中的姓名、职位或联系方式：包含联系方式或公司名，或者是不带句子标点的短行
+func signatureContactLine(line string) bool {
+	if signatureContactPattern.MatchString(line) {
+		return true
+	}
+	return len([]rune(line)) <= signatureShortLine && !signatureSentencePattern.MatchString(line)
+}
+
+// segmentLine 去掉行首尾的空白，QQ邮箱等客户端的分隔行中使用的是不换行空格
+func segmentLine(line string) string {
+	return strings.TrimSpace(strings.ReplaceAll(line, "\u00a0", " "))
+}
+
+// firstContentLine 第一个非空行，全部为空时返回-1
+func firstContentLine(lines []string) int {
+	for i, line := range lines {
+		if segmentLine(line) != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// segmentHTML 去掉HTML正文中引用的历史邮件和签名，返回新写的部分
+// 引用按Gmail/Yahoo/Outlook/QQ邮箱/苹果邮件的容器识别，其次按引用分隔行和Outlook引用邮件头的文字识别
+func segmentHTML(htmlBody string) string {
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return ""
+	}
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		return ""
+	}
+
+	var signatures []*html.Node
+	var quote *html.Node
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		if quote != nil {
+			return
+		}
+		switch {
+		case n.Type == html.ElementNode && isHTMLQuoteContainer(n):
+			quote = n
+			return
+		case n.Type == html.ElementNode && isHTMLSignatureContainer(n):
+			signatures = append(signatures, n)
+			return
+		case n.Type == html.TextNode && isHTMLQuoteMarker(n):
+			quote = n
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(body)
+
+	for _, n := range signatures {
+		n.Parent.RemoveChild(n)
+	}
+	if quote != nil {
+		truncateFrom(quote)
+	}
+	trimTrailingEmpty(body)
+	if !hasVisibleContent(body) {
+		return ""
+	}
+
+	var b strings.Builder
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return ""
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// htmlQuoteClasses 各邮件客户端包裹引用内容的class
+var htmlQuoteClasses = []string{"gmail_quote", "gmail_quote_container", "yahoo_quoted", "protonmail_quote", "moz-cite-prefix", "moz-forward-container"}
+
+// htmlQuoteIDs 各邮件客户端包裹引用内容的id，Outlook网页版、新版Outlook和QQ邮箱
+var htmlQuoteIDs = map[string]bool{"divrplyfwdmsg": true, "appendonsend": true, "isforwardcontent": true, "isreplycontent": true, "olk_src_body_section": true}
+
+func isHTMLQuoteContainer(n *html.Node) bool {
+	if n.DataAtom == atom.Blockquote && strings.EqualFold(attrValue(n, "type"), "cite") {
+		return true
+	}
+	if htmlQuoteIDs[strings.ToLower(attrValue(n, "id"))] {
+		return true
+	}
+	classes := strings.Fields(attrValue(n, "class"))
+	for _, class := range classes {
+		for _, quoteClass := range htmlQuoteClasses {
+			if class == quoteClass {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isHTMLSignatureContainer(n *html.Node) bool {
+	if strings.EqualFold(attrValue(n, "id"), "signature") || attrValue(n, "data-smartmail") == "gmail_signature" {
+		return true
+	}
+	for _, class := range strings.Fields(attrValue(n, "class")) {
+		if class == "gmail_signature" || class == "moz-signature" {
+			return true
+		}
+	}
+	return false
+}
+
+// isHTMLQuoteMarker 文本节点是否为引用分隔行，或Outlook引用邮件头的发件人（之后的文字包含时间和收件人/主题）
+func isHTMLQuoteMarker(n *html.Node) bool {
+	text := segmentLine(n.Data)
+	if text == "" {
+		return false
+	}
+	if quoteSeparatorPattern.MatchString(text) || quoteIntroPattern.MatchString(text) {
+		return true
+	}
+	if !quoteFromPattern.MatchString(text) {
+		return false
+	}
+	// Outlook桌面版的引用邮件头是 <b>From:</b> 这种结构，取所在段落的文字判断
+	block := n.Parent
+	for block != nil && block.Parent != nil && !isHTMLBlock(block) {
+		block = block.Parent
+	}
+	if block == nil {
+		return false
+	}
+	blockLines := strings.Split(nodeToText(block), "\n")
+	for i, line := range blockLines {
+		if quoteFromPattern.MatchString(segmentLine(line)) {
+			return isQuoteHeaderBlock(blockLines[i:])
+		}
+	}
+	return false
+}
+
+// nodeToText 把单个HTML节点转换为纯文本
+func nodeToText(n *html.Node) string {
+	w := &textWriter{lineStart: true}
+	w.walk(n, false)
+	return w.b.String()
+}
+
+func isHTMLBlock(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Div, atom.P, atom.Td, atom.Blockquote, atom.Body, atom.Section, atom.Table:
+		return true
+	}
+	return false
+}
+
+// truncateFrom 删除节点本身以及文档顺序中位于它之后的全部节点
+func truncateFrom(n *html.Node) {
+	for cur := n; cur.Parent != nil && cur.DataAtom != atom.Body; cur = cur.Parent {
+		for next := cur.NextSibling; next != nil; {
+			following := next.NextSibling
+			cur.Parent.RemoveChild(next)
+			next = following
+		}
+	}
+	n.Parent.RemoveChild(n)
+}
+
+// trimTrailingEmpty 去掉末尾的空白、<br>、<hr> 和没有内容的元素
+func trimTrailingEmpty(n *html.Node) {
+	for last := n.LastChild; last != nil; last = n.LastChild {
+		if hasVisibleContent(last) {
+			if last.Type == html.ElementNode {
+				trimTrailingEmpty(last)
+			}
+			return
+		}
+		n.RemoveChild(last)
+	}
+}
+
+// hasVisibleContent 节点中是否有非空白文字或图片
+func hasVisibleContent(n *html.Node) bool {
+	switch n.Type {
+	case html.TextNode:
+		return segmentLine(n.Data) != ""
+	case html.ElementNode:
+		if n.DataAtom == atom.Img {
+			return true
+		}
+		if htmlSkipTags[n.DataAtom] {
+			return false
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if hasVisibleContent(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// findElement 查找第一个指定类型的元素
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
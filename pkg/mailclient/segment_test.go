@@ -0,0 +1,159 @@
+package mailclient
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSegmentBodyText(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		newText    string
+		quoted     string // 引用部分的开头
+		signature  string
+		disclaimer string // 免责声明的开头
+	}{
+		{
+			name:    "结构化转发",
+			text:    "请查收\n\n---------- 转发的邮件 ----------\n发件人: a@example.com\n日期: Mon, 1 Jan 2024\n主题: 报价\n收件人: b@example.com\n\n原始正文",
+			newText: "请查收",
+			quoted:  "---------- 转发的邮件 ----------",
+		},
+		{
+			name:    "Gmail引用说明折行",
+			text:    "Confirmed, thanks.\n\nOn Mon, Jan 1, 2024 at 10:00 AM John Smith <\njohn@example.com> wrote:\n> Please confirm the booking.",
+			newText: "Confirmed, thanks.",
+			quoted:  "On Mon, Jan 1, 2024",
+		},
+		{
+			name:    "Gmail中文引用说明",
+			text:    "好的\n\n张三 <zhang@example.com> 于2024年1月2日周二 10:00写道：\n> 请确认",
+			newText: "好的",
+			quoted:  "张三 <zhang@example.com> 于",
+		},
+		{
+			name:      "Outlook中文邮件头",
+			text:      "附件是更新后的报价。\n\n此致\n李四\n\n发件人: 王五 <wang@example.com>\n发送时间: 2024年1月2日 10:00\n收件人: 李四\n主题: 询价\n\n请报价",
+			newText:   "附件是更新后的报价。",
+			quoted:    "发件人: 王五",
+			signature: "此致\n李四",
+		},
+		{
+			name:    "Outlook网页版下划线",
+			text:    "See below.\n\n________________________________\nFrom: Carrier <ops@carrier.com>\nSent: Tuesday, January 2, 2024 10:00 AM\nTo: Ops\nSubject: Booking\n\nBooking confirmed",
+			newText: "See below.",
+			quoted:  "________________________________",
+		},
+		{
+			name:    "QQ邮箱原始邮件",
+			text:    "收到\n\n------------------ 原始邮件 ------------------\n发件人: \"王五\"<wang@example.com>;\n发送时间: 2024年1月2日(星期二) 上午10:00\n收件人: \"李四\"<li@example.com>;\n主题: 询价",
+			newText: "收到",
+			quoted:  "------------------ 原始邮件",
+		},
+		{
+			name:    "Yahoo转发",
+			text:    "FYI\n\n----- Forwarded Message -----\nFrom: a@yahoo.com\nTo: b@example.com\nSent: Monday, January 1, 2024\nSubject: Hi",
+			newText: "FYI",
+			quoted:  "----- Forwarded Message -----",
+		},
+		{
+			name:    "末尾的>引用",
+			text:    "Agreed.\n\n> earlier text\n>\n> more",
+			newText: "Agreed.",
+			quoted:  "> earlier text",
+		},
+		{
+			name:       "签名和免责声明",
+			text:       "Please find the invoice attached.\n\n-- \nJohn Smith\nOps Manager\n\nThis email and any attachments are confidential and intended solely for the addressee.",
+			newText:    "Please find the invoice attached.",
+			signature:  "-- \nJohn Smith\nOps Manager",
+			disclaimer: "This email and any attachments are confidential",
+		},
+		{
+			name:      "移动端签名",
+			text:      "OK\n\nSent from my iPhone",
+			newText:   "OK",
+			signature: "Sent from my iPhone",
+		},
+		{
+			name:    "只有一行致谢不切分",
+			text:    "Thanks!",
+			newText: "Thanks!",
+		},
+		{
+			name:      "结束语后的联系方式",
+			text:      "请确认船期。\n\n谢谢！\n张三\n上海某某物流有限公司\n电话: +86 21 1234 5678\nzhang@example.com",
+			newText:   "请确认船期。",
+			signature: "谢谢！\n张三\n上海某某物流有限公司\n电话: +86 21 1234 5678\nzhang@example.com",
+		},
+		{
+			name:    "正文中间的致谢不是签名",
+			text:    "您好，\n谢谢！\n附件是提单，请确认船期，周五前回复。\n订舱号 ABC123",
+			newText: "您好，\n谢谢！\n附件是提单，请确认船期，周五前回复。\n订舱号 ABC123",
+		},
+		{
+			name:    "正文中间的Thanks不是签名",
+			text:    "Hi John,\nThanks\nPlease confirm the vessel schedule by Friday.\nBooking no. SO123",
+			newText: "Hi John,\nThanks\nPlease confirm the vessel schedule by Friday.\nBooking no. SO123",
+		},
+		{
+			name:    "正文中的This message不是免责声明",
+			text:    "Hi,\nThis message is to confirm the pickup time.",
+			newText: "Hi,\nThis message is to confirm the pickup time.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seg := SegmentBody(tt.text, "")
+			if seg.NewText != tt.newText {
+				t.Errorf("新内容错误: %q, 应为 %q", seg.NewText, tt.newText)
+			}
+			if !strings.HasPrefix(seg.Quoted, tt.quoted) || (tt.quoted == "") != (seg.Quoted == "") {
+				t.Errorf("引用部分错误: %q, 应以 %q 开头", seg.Quoted, tt.quoted)
+			}
+			if strings.TrimSpace(seg.Signature) != strings.TrimSpace(tt.signature) {
+				t.Errorf("签名错误: %q, 应为 %q", seg.Signature, tt.signature)
+			}
+			if !strings.HasPrefix(seg.Disclaimer, tt.disclaimer) || (tt.disclaimer == "") != (seg.Disclaimer == "") {
+				t.Errorf("免责声明错误: %q, 应以 %q 开头", seg.Disclaimer, tt.disclaimer)
+			}
+		})
+	}
+}
+
+func TestSegmentBodyHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "Gmail引用和签名",
+			html: `<div dir="ltr">Confirmed.<br><div class="gmail_signature">John</div></div><br><div class="gmail_quote"><div class="gmail_attr">On Mon wrote:</div><blockquote>old</blockquote></div>`,
+			want: `<div dir="ltr">Confirmed.</div>`,
+		},
+		{
+			name: "结构化转发",
+			html: "<div>---------- 转发的邮件 ----------<br>发件人: a@example.com<br>日期: x<br></div><hr><p>原始正文</p>",
+			want: "",
+		},
+		{
+			name: "Outlook桌面版引用邮件头",
+			html: `<p class="MsoNormal">Please see below.</p><div style="border:none;border-top:solid #E1E1E1 1.0pt"><p class="MsoNormal"><b>From:</b> Carrier<br><b>Sent:</b> Tuesday<br><b>To:</b> Ops<br><b>Subject:</b> Booking</p></div><p>old</p>`,
+			want: `<p class="MsoNormal">Please see below.</p>`,
+		},
+		{
+			name: "苹果邮件引用",
+			html: `<div>OK<img src="cid:logo"></div><blockquote type="cite">old</blockquote>`,
+			want: `<div>OK<img src="cid:logo"/></div>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SegmentBody("", tt.html).NewHTML; got != tt.want {
+				t.Errorf("HTML新内容错误:\n%s\n---- 应为 ----\n%s", got, tt.want)
+			}
+		})
+	}
+}
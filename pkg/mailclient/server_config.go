@@ -0,0 +1,194 @@
+package mailclient
+
+import (
+	"strings"
+
+	"go_email/model"
+)
+
+// 连接加密方式
+const (
+	SecuritySSL      = "ssl"      // 隐式TLS（IMAPS 993 / SMTPS 465）
+	SecurityStartTLS = "starttls" // 明文连接后升级 STARTTLS
+	SecurityPlain    = "plain"    // 明文连接，仅用于内网自建服务器
+)
+
+// SMTP认证方式
+const (
+	AuthPlain = "plain"
+	AuthLogin = "login"
+)
+
+// 未配置服务器信息时的默认值（兼容历史上只支持Yahoo邮箱的账号）
+const (
+	defaultIMAPServer = "imap.mail.yahoo.com"
+	defaultSMTPServer = "smtp.mail.yahoo.com"
+)
+
+// normalizeSecurity 规范化加密方式，无法识别时返回空字符串
+func normalizeSecurity(security string) string {
+	switch strings.ToLower(strings.TrimSpace(security)) {
+	case "ssl", "tls", "implicit", "implicit_tls":
+		return SecuritySSL
+	case "starttls", "start_tls":
+		return SecurityStartTLS
+	case "plain", "none", "insecure":
+		return SecurityPlain
+	default:
+		return ""
+	}
+}
+
+// normalizeAuthMechanism 规范化认证方式，无法识别时使用 plain
+func normalizeAuthMechanism(mechanism string) string {
+	switch strings.ToLower(strings.TrimSpace(mechanism)) {
+	case AuthLogin:
+		return AuthLogin
+	case AuthXOAuth2:
+		return AuthXOAuth2
+	case AuthOAuthBearer:
+		return AuthOAuthBearer
+	default:
+		return AuthPlain
+	}
+}
+
+// defaultIMAPPort 根据加密方式返回IMAP默认端口
+func defaultIMAPPort(security string) int {
+	if security == SecuritySSL {
+		return 993
+	}
+	return 143
+}
+
+// defaultSMTPPort 根据加密方式返回SMTP默认端口
+func defaultSMTPPort(security string) int {
+	switch security {
+	case SecuritySSL:
+		return 465
+	case SecurityStartTLS:
+		return 587
+	default:
+		return 25
+	}
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// firstPositive 返回第一个大于0的整数
+func firstPositive(values ...int) int {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+// resolveEmailConfig 按 账号字段 > 服务商配置 > 默认值 的优先级合成邮箱服务器配置
+func resolveEmailConfig(account model.PrimeEmailAccount, provider *model.PrimeEmailProvider, password string) *EmailConfigInfo {
+	if provider == nil {
+		provider = &model.PrimeEmailProvider{}
+	}
+
+	imapServer := firstNonEmpty(account.ImapHost, provider.ImapHost)
+	imapSecurity := normalizeSecurity(firstNonEmpty(account.ImapSecurity, provider.ImapSecurity))
+	if imapServer == "" {
+		// 未配置任何服务器时沿用Yahoo默认配置
+		imapServer = defaultIMAPServer
+	}
+	if imapSecurity == "" {
+		imapSecurity = SecuritySSL
+	}
+	imapPort := firstPositive(account.ImapPort, provider.ImapPort, defaultIMAPPort(imapSecurity))
+
+	smtpServer := firstNonEmpty(account.SmtpHost, provider.SmtpHost)
+	smtpSecurity := normalizeSecurity(firstNonEmpty(account.SmtpSecurity, provider.SmtpSecurity))
+	if smtpServer == "" && imapServer == defaultIMAPServer {
+		smtpServer = defaultSMTPServer
+	}
+	if smtpSecurity == "" {
+		smtpSecurity = SecuritySSL
+	}
+	smtpPort := firstPositive(account.SmtpPort, provider.SmtpPort, defaultSMTPPort(smtpSecurity))
+
+	authMechanism := normalizeAuthMechanism(firstNonEmpty(account.AuthMechanism, provider.AuthMechanism))
+	var oauth *OAuthConfig
+	if normalizeCredentialType(account.CredentialType) == CredentialOAuth2 {
+		oauth = &OAuthConfig{
+			TokenURL:     firstNonEmpty(account.OAuthTokenURL, provider.OAuthTokenURL),
+			ClientID:     account.OAuthClientID,
+			ClientSecret: account.OAuthClientSecret,
+			Scope:        firstNonEmpty(account.OAuthScope, provider.OAuthScope),
+			RefreshToken: account.OAuthRefreshToken,
+			AccessToken:  account.OAuthAccessToken,
+		}
+		if account.OAuthTokenExpiry != nil {
+			oauth.Expiry = *account.OAuthTokenExpiry
+		}
+		// OAuth2账号只能使用OAuth2的SASL机制，未指定时默认XOAUTH2
+		if !isOAuthMechanism(authMechanism) {
+			authMechanism = AuthXOAuth2
+		}
+	} else if isOAuthMechanism(authMechanism) {
+		// 密码账号引用了OAuth2的服务商模板时退回PLAIN
+		authMechanism = AuthPlain
+	}
+
+	return &EmailConfigInfo{
+		AccountID:     account.ID,
+		IMAPServer:    imapServer,
+		SMTPServer:    smtpServer,
+		EmailAddress:  account.Account,
+		Password:      password,
+		IMAPPort:      imapPort,
+		SMTPPort:      smtpPort,
+		UseSSL:        imapSecurity == SecuritySSL,
+		IMAPSecurity:  imapSecurity,
+		SMTPSecurity:  smtpSecurity,
+		AuthMechanism: authMechanism,
+		OAuth:         oauth,
+		AuthServIDs:   splitAuthServIDs(provider.AuthServIds),
+	}
+}
+
+// splitAuthServIDs 拆分逗号分隔的authserv-id列表，去掉空白和空项
+func splitAuthServIDs(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.ToLower(strings.TrimSpace(id)); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// imapSecurityMode 返回IMAP实际使用的加密方式（兼容只设置了UseSSL的旧配置）
+func (c *EmailConfigInfo) imapSecurityMode() string {
+	if mode := normalizeSecurity(c.IMAPSecurity); mode != "" {
+		return mode
+	}
+	if c.UseSSL {
+		return SecuritySSL
+	}
+	return SecurityStartTLS
+}
+
+// smtpSecurityMode 返回SMTP实际使用的加密方式
+func (c *EmailConfigInfo) smtpSecurityMode() string {
+	if mode := normalizeSecurity(c.SMTPSecurity); mode != "" {
+		return mode
+	}
+	if c.SMTPPort == 465 {
+		return SecuritySSL
+	}
+	return SecurityStartTLS
+}
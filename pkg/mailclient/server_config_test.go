@@ -0,0 +1,70 @@
+package mailclient
+
+import (
+	"testing"
+
+	"go_email/model"
+)
+
+func TestResolveEmailConfigDefaultYahoo(t *testing.T) {
+	// 未配置任何服务器信息的历史账号应继续使用Yahoo默认配置
+	account := model.PrimeEmailAccount{ID: 1, Account: "user@yahoo.com"}
+	config := resolveEmailConfig(account, nil, "secret")
+
+	if config.IMAPServer != defaultIMAPServer || config.IMAPPort != 993 || !config.UseSSL {
+		t.Errorf("默认IMAP配置错误: %s:%d ssl=%v", config.IMAPServer, config.IMAPPort, config.UseSSL)
+	}
+	if config.SMTPServer != defaultSMTPServer || config.SMTPPort != 465 || config.SMTPSecurity != SecuritySSL {
+		t.Errorf("默认SMTP配置错误: %s:%d %s", config.SMTPServer, config.SMTPPort, config.SMTPSecurity)
+	}
+	if config.AuthMechanism != AuthPlain {
+		t.Errorf("默认认证方式应为plain，实际: %s", config.AuthMechanism)
+	}
+}
+
+func TestResolveEmailConfigProviderAndOverride(t *testing.T) {
+	provider := &model.PrimeEmailProvider{
+		ImapHost:      "imap.exmail.qq.com",
+		ImapSecurity:  "SSL",
+		SmtpHost:      "smtp.exmail.qq.com",
+		SmtpSecurity:  "starttls",
+		AuthMechanism: "LOGIN",
+	}
+
+	// 只引用服务商配置，端口按加密方式取默认值
+	account := model.PrimeEmailAccount{ID: 2, Account: "user@company.com", ProviderId: 1}
+	config := resolveEmailConfig(account, provider, "secret")
+	if config.IMAPServer != "imap.exmail.qq.com" || config.IMAPPort != 993 || config.IMAPSecurity != SecuritySSL {
+		t.Errorf("服务商IMAP配置错误: %s:%d %s", config.IMAPServer, config.IMAPPort, config.IMAPSecurity)
+	}
+	if config.SMTPServer != "smtp.exmail.qq.com" || config.SMTPPort != 587 || config.SMTPSecurity != SecurityStartTLS {
+		t.Errorf("服务商SMTP配置错误: %s:%d %s", config.SMTPServer, config.SMTPPort, config.SMTPSecurity)
+	}
+	if config.AuthMechanism != AuthLogin {
+		t.Errorf("认证方式应为login，实际: %s", config.AuthMechanism)
+	}
+
+	// 账号字段优先于服务商配置（自建Dovecot明文端口）
+	account.ImapHost = "mail.internal"
+	account.ImapSecurity = "plain"
+	account.SmtpPort = 2525
+	config = resolveEmailConfig(account, provider, "secret")
+	if config.IMAPServer != "mail.internal" || config.IMAPPort != 143 || config.UseSSL {
+		t.Errorf("账号覆盖IMAP配置错误: %s:%d ssl=%v", config.IMAPServer, config.IMAPPort, config.UseSSL)
+	}
+	if config.imapSecurityMode() != SecurityPlain {
+		t.Errorf("IMAP加密方式应为plain，实际: %s", config.imapSecurityMode())
+	}
+	if config.SMTPPort != 2525 {
+		t.Errorf("账号覆盖SMTP端口错误: %d", config.SMTPPort)
+	}
+}
+
+func TestResolveEmailConfigNoSMTPForCustomHost(t *testing.T) {
+	// 自定义IMAP服务器但未配置SMTP时不应回落到Yahoo的SMTP服务器
+	account := model.PrimeEmailAccount{Account: "user@example.com", ImapHost: "imap.example.com"}
+	config := resolveEmailConfig(account, nil, "secret")
+	if config.SMTPServer != "" {
+		t.Errorf("未配置SMTP时应为空，实际: %s", config.SMTPServer)
+	}
+}
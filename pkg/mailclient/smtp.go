@@ -0,0 +1,219 @@
+package mailclient
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go_email/model"
+	"log"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// smtpDialTimeout SMTP建连超时时间
+const smtpDialTimeout = 30 * time.Second
+
+// saveOutbound 记录发出的邮件，用于把退信关联回来，测试中可替换
+var saveOutbound = model.CreateOutbound
+
+// plainAuth 实现 AUTH PLAIN 认证
+// smtp.PlainAuth 在非TLS连接上总是拒绝认证（localhost除外），是否允许明文认证统一由 sendMail 按 smtp.allow_insecure_auth 判断
+type plainAuth struct {
+	username string
+	password string
+}
+
+func (a *plainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "PLAIN", []byte("\x00" + a.username + "\x00" + a.password), nil
+}
+
+func (a *plainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, errors.New("PLAIN认证收到意外的服务器质询")
+	}
+	return nil, nil
+}
+
+// loginAuth 实现 AUTH LOGIN 认证（net/smtp 只内置了 PLAIN 和 CRAM-MD5）
+type loginAuth struct {
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "LOGIN", []byte{}, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	prompt := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.Contains(prompt, "username"):
+		return []byte(a.username), nil
+	case strings.Contains(prompt, "password"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("未知的LOGIN认证提示: %s", string(fromServer))
+	}
+}
+
+// smtpAuth 根据配置的认证方式创建SMTP认证器
+func (m *MailClient) smtpAuth() (smtp.Auth, error) {
+	if m.Config.OAuth != nil {
+		saslClient, err := m.Config.saslClient(m.Config.SMTPServer, m.Config.SMTPPort)
+		if err != nil {
+			return nil, err
+		}
+		return &saslSMTPAuth{client: saslClient}, nil
+	}
+	if normalizeAuthMechanism(m.Config.AuthMechanism) == AuthLogin {
+		return &loginAuth{username: m.Config.EmailAddress, password: m.Config.Password}, nil
+	}
+	return &plainAuth{username: m.Config.EmailAddress, password: m.Config.Password}, nil
+}
+
+// shouldAuthSMTP 判断是否在当前连接上认证，PLAIN/LOGIN/OAuth2 使用同一策略：
+// 配置了凭据但服务器没有提供AUTH时返回错误，避免之后才出现难以理解的拒绝转发错误；
+// 明文连接上只有开启 allowInsecure 才发送凭据
+func shouldAuthSMTP(hasCredentials, authOffered, tlsActive, allowInsecure bool) (bool, error) {
+	if !hasCredentials {
+		return false, nil
+	}
+	if !authOffered {
+		return false, errors.New("SMTP服务器没有提供AUTH认证，无法使用配置的凭据登录")
+	}
+	if !tlsActive && !allowInsecure {
+		return false, errors.New("拒绝在未加密的SMTP连接上发送凭据，内网服务器需开启 smtp.allow_insecure_auth")
+	}
+	return true, nil
+}
+
+// dialSMTP 按配置的加密方式连接SMTP服务器（隐式TLS / STARTTLS / 明文）
+func (m *MailClient) dialSMTP() (*smtp.Client, error) {
+	if m.Config.SMTPServer == "" {
+		return nil, errors.New("未配置SMTP服务器")
+	}
+
+	addr := net.JoinHostPort(m.Config.SMTPServer, strconv.Itoa(m.Config.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: m.Config.SMTPServer}
+	mode := m.Config.smtpSecurityMode()
+
+	var conn net.Conn
+	var err error
+	if mode == SecuritySSL {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: smtpDialTimeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, smtpDialTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接SMTP服务器失败: %w", err)
+	}
+
+	c, err := smtp.NewClient(conn, m.Config.SMTPServer)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("创建SMTP客户端失败: %w", err)
+	}
+
+	if err = c.Hello("localhost"); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("HELO失败: %w", err)
+	}
+
+	if mode == SecurityStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			c.Close()
+			return nil, errors.New("SMTP服务器不支持STARTTLS")
+		}
+		if err = c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("StartTLS失败: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// newMessageID 为发出的邮件生成Message-ID（不含尖括号），域名取发件地址的域名
+func newMessageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndexByte(from, '@'); i >= 0 && i < len(from)-1 {
+		domain = strings.Trim(from[i+1:], "<> ")
+	}
+	random := make([]byte, 8)
+	rand.Read(random)
+	return fmt.Sprintf("%d.%s@%s", time.Now().UnixNano(), hex.EncodeToString(random), domain)
+}
+
+// recordOutbound 记录已成功投递给SMTP服务器的邮件，失败只记录日志，不影响发送结果
+func (m *MailClient) recordOutbound(outbound model.PrimeEmailOutbound) {
+	outbound.AccountId = m.Config.AccountID
+	outbound.FromEmail = m.Config.EmailAddress
+	if err := saveOutbound(&outbound); err != nil {
+		log.Printf("[发出邮件] 记录发出的邮件失败，Message-ID: %s, 错误: %v", outbound.MessageID, err)
+	}
+}
+
+// sendMail 连接SMTP服务器、认证并投递邮件，供发送和转发共用
+func (m *MailClient) sendMail(from string, to []string, msg []byte) error {
+	c, err := m.dialSMTP()
+	if err != nil {
+		return err
+	}
+	defer c.Quit()
+
+	authOffered, _ := c.Extension("AUTH")
+	_, tlsActive := c.TLSConnectionState()
+	hasCredentials := m.Config.OAuth != nil || m.Config.Password != ""
+	doAuth, err := shouldAuthSMTP(hasCredentials, authOffered, tlsActive, viper.GetBool("smtp.allow_insecure_auth"))
+	if err != nil {
+		return err
+	}
+	if doAuth {
+		auth, err := m.smtpAuth()
+		if err != nil {
+			return fmt.Errorf("创建SMTP认证器失败: %w", err)
+		}
+		if err = c.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP认证失败: %w", err)
+		}
+	}
+
+	if err = c.Mail(from); err != nil {
+		return fmt.Errorf("设置发件人失败: %w", err)
+	}
+
+	for _, addr := range to {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if err = c.Rcpt(addr); err != nil {
+			return fmt.Errorf("设置收件人失败: %w", err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("获取数据写入器失败: %w", err)
+	}
+
+	if _, err = w.Write(msg); err != nil {
+		return fmt.Errorf("写入邮件内容失败: %w", err)
+	}
+
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("关闭数据写入器失败: %w", err)
+	}
+
+	return nil
+}
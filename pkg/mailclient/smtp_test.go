@@ -0,0 +1,44 @@
+package mailclient
+
+import "testing"
+
+func TestShouldAuthSMTP(t *testing.T) {
+	tests := []struct {
+		name                                            string
+		hasCredentials, authOffered, tls, allowInsecure bool
+		want, wantErr                                   bool
+	}{
+		{"没有凭据不认证", false, false, false, false, false, false},
+		{"TLS连接上认证", true, true, true, false, true, false},
+		{"服务器没有提供AUTH", true, false, true, false, false, true},
+		{"明文连接默认拒绝", true, true, false, false, false, true},
+		{"明文连接显式允许", true, true, false, true, true, false},
+	}
+	for _, tt := range tests {
+		got, err := shouldAuthSMTP(tt.hasCredentials, tt.authOffered, tt.tls, tt.allowInsecure)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%s: 期望 %v/%v，实际 %v/%v", tt.name, tt.want, tt.wantErr, got, err)
+		}
+	}
+}
+
+func TestPlainAuth(t *testing.T) {
+	auth := &plainAuth{username: "ops@example.com", password: "secret"}
+	mech, resp, err := auth.Start(nil)
+	if err != nil || mech != "PLAIN" || string(resp) != "\x00ops@example.com\x00secret" {
+		t.Errorf("PLAIN认证初始响应错误: %s %q %v", mech, resp, err)
+	}
+}
+
+func TestCheckIMAPLoginSecurity(t *testing.T) {
+	// IMAP登录与SMTP认证使用同一策略
+	if err := checkIMAPLoginSecurity(true, false); err != nil {
+		t.Errorf("TLS连接应允许登录: %v", err)
+	}
+	if err := checkIMAPLoginSecurity(false, false); err == nil {
+		t.Error("明文连接未开启allow_insecure_auth时应拒绝登录")
+	}
+	if err := checkIMAPLoginSecurity(false, true); err != nil {
+		t.Errorf("开启allow_insecure_auth后应允许明文登录: %v", err)
+	}
+}
@@ -0,0 +1,349 @@
+package mailclient
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// TNEF（winmail.dat）格式常量，参见 MS-OXTNEF
+const (
+	tnefSignature = 0x223E9F78
+
+	tnefLevelMessage    = 0x01
+	tnefLevelAttachment = 0x02
+
+	attOemCodepage    = 0x00069007 // 消息级，ANSI字符串使用的代码页
+	attAttachRendData = 0x00069002 // 每个附件的第一个属性，表示开始一个新附件
+	attAttachTitle    = 0x00018010 // 附件文件名（8.3格式或ANSI长文件名）
+	attAttachData     = 0x0006800F // 附件内容
+	attAttachment     = 0x00069005 // 附件的MAPI属性
+
+	mapiAttachDataObj      = 0x3701
+	mapiAttachFilename     = 0x3704
+	mapiAttachLongFilename = 0x3707
+	mapiAttachMimeTag      = 0x370E
+
+	mapiTypeMultiValue = 0x1000
+	mapiTypeObject     = 0x000D
+	mapiTypeString8    = 0x001E
+	mapiTypeUnicode    = 0x001F
+	mapiTypeBinary     = 0x0102
+)
+
+// TNEFAttachment 从TNEF中解出的附件
+type TNEFAttachment struct {
+	Filename string
+	MimeType string
+	Data     []byte
+}
+
+// IsTNEF 根据媒体类型、文件名或签名判断附件是否为TNEF（Outlook的winmail.dat）
+func IsTNEF(mimeType, filename string, data []byte) bool {
+	mimeType = strings.ToLower(mimeType)
+	if mimeType == "application/ms-tnef" || mimeType == "application/vnd.ms-tnef" {
+		return true
+	}
+	if strings.EqualFold(filename, "winmail.dat") {
+		return true
+	}
+	return len(data) >= 4 && binary.LittleEndian.Uint32(data) == tnefSignature
+}
+
+// tnefAttachmentBuilder 解析过程中正在组装的附件
+type tnefAttachmentBuilder struct {
+	title     string
+	longName  string
+	shortName string
+	mimeType  string
+	data      []byte
+	dataObj   []byte
+}
+
+func (b *tnefAttachmentBuilder) build() TNEFAttachment {
+	name := b.longName
+	if name == "" {
+		name = b.title
+	}
+	if name == "" {
+		name = b.shortName
+	}
+	data := b.data
+	if len(data) == 0 {
+		data = b.dataObj
+	}
+	return TNEFAttachment{Filename: name, MimeType: b.mimeType, Data: data}
+}
+
+// DecodeTNEF 解析TNEF数据，返回其中包含的附件
+// 只提取文件附件，嵌入的Outlook消息对象和RTF正文不处理；数据截断时返回已经解出的附件和错误
+func DecodeTNEF(data []byte) ([]TNEFAttachment, error) {
+	if len(data) < 6 || binary.LittleEndian.Uint32(data) != tnefSignature {
+		return nil, fmt.Errorf("不是有效的TNEF数据")
+	}
+
+	var (
+		attachments []TNEFAttachment
+		current     *tnefAttachmentBuilder
+		codepage    uint32
+	)
+	flush := func() {
+		if current != nil && (len(current.data) > 0 || len(current.dataObj) > 0) {
+			attachments = append(attachments, current.build())
+		}
+		current = nil
+	}
+
+	// 签名之后是2字节的legacy key
+	pos := 6
+	for pos < len(data) {
+		// 每个属性：级别(1) + 属性ID(4) + 长度(4) + 数据 + 校验和(2)
+		if pos+9 > len(data) {
+			flush()
+			return attachments, fmt.Errorf("TNEF属性头不完整，偏移: %d", pos)
+		}
+		level := data[pos]
+		attrID := binary.LittleEndian.Uint32(data[pos+1:])
+		length := int(binary.LittleEndian.Uint32(data[pos+5:]))
+		pos += 9
+		if length < 0 || pos+length+2 > len(data) {
+			flush()
+			return attachments, fmt.Errorf("TNEF属性数据不完整，属性: 0x%08X", attrID)
+		}
+		value := data[pos : pos+length]
+		pos += length + 2
+
+		switch {
+		case level == tnefLevelMessage && attrID == attOemCodepage:
+			if len(value) >= 4 {
+				codepage = binary.LittleEndian.Uint32(value)
+			}
+		case level == tnefLevelAttachment && attrID == attAttachRendData:
+			flush()
+			current = &tnefAttachmentBuilder{}
+		case level == tnefLevelAttachment:
+			if current == nil {
+				current = &tnefAttachmentBuilder{}
+			}
+			switch attrID {
+			case attAttachTitle:
+				current.title = decodeTNEFString(value, codepage)
+			case attAttachData:
+				current.data = value
+			case attAttachment:
+				readTNEFAttachmentProps(value, codepage, current)
+			}
+		}
+	}
+	flush()
+	return attachments, nil
+}
+
+// readTNEFAttachmentProps 从附件的MAPI属性中读取长文件名、MIME类型和对象数据，格式错误时保留已读到的属性
+func readTNEFAttachmentProps(data []byte, codepage uint32, att *tnefAttachmentBuilder) {
+	r := &tnefReader{data: data}
+	count, ok := r.uint32()
+	if !ok {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		propType, ok1 := r.uint16()
+		propID, ok2 := r.uint16()
+		if !ok1 || !ok2 {
+			return
+		}
+		// 命名属性：GUID(16) + 类型(4) + ID或名称
+		if propID >= 0x8000 {
+			if !r.skip(16) {
+				return
+			}
+			kind, ok := r.uint32()
+			if !ok {
+				return
+			}
+			if kind == 0 {
+				if !r.skip(4) {
+					return
+				}
+			} else {
+				nameLen, ok := r.uint32()
+				if !ok || !r.skip(padTo4(int(nameLen))) {
+					return
+				}
+			}
+		}
+
+		values, ok := r.propValues(propType)
+		if !ok {
+			return
+		}
+		if len(values) == 0 {
+			continue
+		}
+		value := values[0]
+		baseType := propType &^ mapiTypeMultiValue
+		switch propID {
+		case mapiAttachLongFilename:
+			att.longName = decodeMAPIString(value, baseType, codepage)
+		case mapiAttachFilename:
+			att.shortName = decodeMAPIString(value, baseType, codepage)
+		case mapiAttachMimeTag:
+			att.mimeType = strings.ToLower(decodeMAPIString(value, baseType, codepage))
+		case mapiAttachDataObj:
+			// PT_OBJECT的数据以16字节的接口ID开头
+			if baseType == mapiTypeObject && len(value) >= 16 {
+				value = value[16:]
+			}
+			att.dataObj = value
+		}
+	}
+}
+
+// tnefReader 按小端序顺序读取MAPI属性
+type tnefReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *tnefReader) skip(n int) bool {
+	if n < 0 || r.pos+n > len(r.data) {
+		return false
+	}
+	r.pos += n
+	return true
+}
+
+func (r *tnefReader) bytes(n int) ([]byte, bool) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, false
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, true
+}
+
+func (r *tnefReader) uint16() (uint16, bool) {
+	b, ok := r.bytes(2)
+	if !ok {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(b), true
+}
+
+func (r *tnefReader) uint32() (uint32, bool) {
+	b, ok := r.bytes(4)
+	if !ok {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(b), true
+}
+
+// propValues 读取一个属性的全部值；变长类型每个值前有长度并补齐到4字节，多值属性前有值个数
+func (r *tnefReader) propValues(propType uint16) ([][]byte, bool) {
+	baseType := propType &^ mapiTypeMultiValue
+	multi := propType&mapiTypeMultiValue != 0
+
+	switch baseType {
+	case mapiTypeString8, mapiTypeUnicode, mapiTypeBinary, mapiTypeObject:
+		// 变长类型即使是单值也带有值个数
+		count, ok := r.uint32()
+		if !ok || int(count) > len(r.data) {
+			return nil, false
+		}
+		values := make([][]byte, 0, count)
+		for i := uint32(0); i < count; i++ {
+			length, ok := r.uint32()
+			if !ok {
+				return nil, false
+			}
+			value, ok := r.bytes(int(length))
+			if !ok || !r.skip(padTo4(int(length))-int(length)) {
+				return nil, false
+			}
+			values = append(values, value)
+		}
+		return values, true
+	}
+
+	size := mapiFixedSize(baseType)
+	if size == 0 {
+		return nil, false
+	}
+	count := uint32(1)
+	if multi {
+		var ok bool
+		if count, ok = r.uint32(); !ok || int(count) > len(r.data) {
+			return nil, false
+		}
+	}
+	values := make([][]byte, 0, count)
+	for i := uint32(0); i < count; i++ {
+		value, ok := r.bytes(size)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	return values, true
+}
+
+// mapiFixedSize 定长MAPI类型在TNEF中占用的字节数（不足4字节的补齐到4字节），未知类型返回0
+func mapiFixedSize(baseType uint16) int {
+	switch baseType {
+	case 0x0002, 0x0003, 0x0004, 0x000A, 0x000B: // SHORT, LONG, FLOAT, ERROR, BOOLEAN
+		return 4
+	case 0x0005, 0x0006, 0x0007, 0x0014, 0x0040: // DOUBLE, CURRENCY, APPTIME, I8, SYSTIME
+		return 8
+	case 0x0048: // CLSID
+		return 16
+	}
+	return 0
+}
+
+func padTo4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// decodeMAPIString 解码MAPI字符串属性，PT_UNICODE为UTF-16LE，PT_STRING8使用消息的代码页
+func decodeMAPIString(value []byte, baseType uint16, codepage uint32) string {
+	if baseType == mapiTypeUnicode {
+		units := make([]uint16, 0, len(value)/2)
+		for i := 0; i+1 < len(value); i += 2 {
+			units = append(units, binary.LittleEndian.Uint16(value[i:]))
+		}
+		return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+	}
+	return decodeTNEFString(value, codepage)
+}
+
+// decodeTNEFString 按代码页解码以NUL结尾的ANSI字符串
+func decodeTNEFString(value []byte, codepage uint32) string {
+	if i := strings.IndexByte(string(value), 0); i >= 0 {
+		value = value[:i]
+	}
+	if utf8.Valid(value) {
+		return string(value)
+	}
+	return decodeCharset(value, codepageCharset(codepage))
+}
+
+// codepageCharset 把Windows代码页转换为字符集名称，未设置代码页时按GBK处理（与本系统的邮件来源一致）
+func codepageCharset(codepage uint32) string {
+	switch codepage {
+	case 0, 936:
+		return "gbk"
+	case 950:
+		return "big5"
+	case 932:
+		return "shift_jis"
+	case 949:
+		return "euc-kr"
+	case 54936:
+		return "gb18030"
+	case 65001:
+		return "utf-8"
+	}
+	return fmt.Sprintf("windows-%d", codepage)
+}
@@ -0,0 +1,98 @@
+package mailclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// tnefAttr 按TNEF格式编码一个属性
+func tnefAttr(level byte, id uint32, value []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(level)
+	binary.Write(&buf, binary.LittleEndian, id)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(value)))
+	buf.Write(value)
+	var sum uint16
+	for _, b := range value {
+		sum += uint16(b)
+	}
+	binary.Write(&buf, binary.LittleEndian, sum)
+	return buf.Bytes()
+}
+
+// mapiUnicodeProp 编码一个单值PT_UNICODE的MAPI属性
+func mapiUnicodeProp(id uint16, value string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint16(mapiTypeUnicode))
+	binary.Write(&buf, binary.LittleEndian, id)
+	encoded := utf16.Encode([]rune(value + "\x00"))
+	binary.Write(&buf, binary.LittleEndian, uint32(1))
+	binary.Write(&buf, binary.LittleEndian, uint32(len(encoded)*2))
+	binary.Write(&buf, binary.LittleEndian, encoded)
+	buf.Write(make([]byte, padTo4(len(encoded)*2)-len(encoded)*2))
+	return buf.Bytes()
+}
+
+func TestDecodeTNEF(t *testing.T) {
+	var props bytes.Buffer
+	binary.Write(&props, binary.LittleEndian, uint32(3))
+	// PT_LONG的附件方法属性，验证定长属性被正确跳过
+	binary.Write(&props, binary.LittleEndian, uint16(0x0003))
+	binary.Write(&props, binary.LittleEndian, uint16(0x3705))
+	binary.Write(&props, binary.LittleEndian, uint32(1))
+	props.Write(mapiUnicodeProp(mapiAttachLongFilename, "装箱单 2024.xlsx"))
+	props.Write(mapiUnicodeProp(mapiAttachMimeTag, "application/vnd.ms-excel"))
+
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, uint32(tnefSignature))
+	binary.Write(&data, binary.LittleEndian, uint16(0x1234))
+	data.Write(tnefAttr(tnefLevelMessage, attOemCodepage, []byte{0xA8, 0x03, 0, 0, 0, 0, 0, 0}))
+	// 第一个附件只有8.3文件名（GBK编码）
+	data.Write(tnefAttr(tnefLevelAttachment, attAttachRendData, make([]byte, 14)))
+	data.Write(tnefAttr(tnefLevelAttachment, attAttachTitle, []byte{0xB7, 0xA2, 0xC6, 0xB1, '.', 'p', 'd', 'f', 0}))
+	data.Write(tnefAttr(tnefLevelAttachment, attAttachData, []byte("%PDF-1.4")))
+	// 第二个附件的长文件名在MAPI属性中
+	data.Write(tnefAttr(tnefLevelAttachment, attAttachRendData, make([]byte, 14)))
+	data.Write(tnefAttr(tnefLevelAttachment, attAttachTitle, []byte("PACKIN~1.XLS\x00")))
+	data.Write(tnefAttr(tnefLevelAttachment, attAttachData, []byte("xls")))
+	data.Write(tnefAttr(tnefLevelAttachment, attAttachment, props.Bytes()))
+
+	attachments, err := DecodeTNEF(data.Bytes())
+	if err != nil {
+		t.Fatalf("解析TNEF失败: %v", err)
+	}
+	if len(attachments) != 2 {
+		t.Fatalf("应解出2个附件，实际 %d: %+v", len(attachments), attachments)
+	}
+	if attachments[0].Filename != "发票.pdf" || string(attachments[0].Data) != "%PDF-1.4" {
+		t.Errorf("第一个附件解析错误: %+v", attachments[0])
+	}
+	if attachments[1].Filename != "装箱单 2024.xlsx" || attachments[1].MimeType != "application/vnd.ms-excel" || string(attachments[1].Data) != "xls" {
+		t.Errorf("第二个附件解析错误: %+v", attachments[1])
+	}
+
+	// MAPI属性被截断时仍返回已解出的附件，文件名退回8.3格式
+	truncated := data.Bytes()[:data.Len()-10]
+	attachments, err = DecodeTNEF(truncated)
+	if err == nil || len(attachments) != 2 || attachments[1].Filename != "PACKIN~1.XLS" {
+		t.Errorf("截断数据应返回错误和已解出的附件，实际 %+v, %v", attachments, err)
+	}
+
+	if _, err := DecodeTNEF([]byte("not tnef")); err == nil {
+		t.Error("非TNEF数据应返回错误")
+	}
+}
+
+func TestIsTNEF(t *testing.T) {
+	if !IsTNEF("application/ms-tnef", "", nil) || !IsTNEF("", "WINMAIL.DAT", nil) {
+		t.Error("应识别TNEF媒体类型和winmail.dat文件名")
+	}
+	if !IsTNEF("application/octet-stream", "att.dat", []byte{0x78, 0x9F, 0x3E, 0x22, 0, 0}) {
+		t.Error("应根据签名识别TNEF")
+	}
+	if IsTNEF("application/pdf", "a.pdf", []byte("%PDF")) {
+		t.Error("普通附件不应识别为TNEF")
+	}
+}
@@ -0,0 +1,310 @@
+// Package thread 按 JWZ 算法（https://www.jwz.org/doc/threading.html）把邮件组织成会话
+package thread
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message 参与会话计算的邮件
+type Message struct {
+	ID         uint      // 调用方的记录ID
+	MessageID  string    // Message-ID（不含尖括号），为空时只能按主题归并
+	References []string  // References中的Message-ID，最早的在前；In-Reply-To应追加在末尾
+	Subject    string    // 原始主题
+	Date       time.Time // 发送时间，用于同一层邮件排序
+}
+
+// Container JWZ算法中的容器，Message为空表示只被引用、尚未收到的邮件
+type Container struct {
+	Message  *Message
+	Parent   *Container
+	Children []*Container
+	id       string
+}
+
+// replyPrefixPattern 匹配主题开头的回复/转发前缀，包括常见的中文前缀和 Re[2]: 形式
+var replyPrefixPattern = regexp.MustCompile(`(?i)^\s*(re|fw|fwd|aw|wg|sv|vs|回复|答复|转发|回覆|轉寄)\s*(\[\d+\]|\(\d+\))?\s*[:：]\s*`)
+
+// mailingListTagPattern 匹配主题开头的邮件列表标签，如 [list-name]
+var mailingListTagPattern = regexp.MustCompile(`^\s*\[[^\]]{1,40}\]\s*`)
+
+// NormalizeSubject 去掉主题中的回复/转发前缀和列表标签并统一大小写、空白，isReply 表示原主题带有回复/转发前缀
+func NormalizeSubject(subject string) (normalized string, isReply bool) {
+	s := subject
+	for {
+		if loc := replyPrefixPattern.FindStringIndex(s); loc != nil {
+			s = s[loc[1]:]
+			isReply = true
+			continue
+		}
+		if loc := mailingListTagPattern.FindStringIndex(s); loc != nil && loc[1] < len(s) {
+			s = s[loc[1]:]
+			continue
+		}
+		break
+	}
+	return strings.ToLower(strings.Join(strings.Fields(s), " ")), isReply
+}
+
+// Build 对邮件执行JWZ算法，返回会话树的根节点，按各会话最早邮件的时间排序
+// groupBySubject 为true时把去掉了References等邮件头的回复按规范化主题挂到同主题的会话下
+func Build(messages []*Message, groupBySubject bool) []*Container {
+	idTable := make(map[string]*Container)
+	getContainer := func(id string) *Container {
+		c, ok := idTable[id]
+		if !ok {
+			c = &Container{id: id}
+			idTable[id] = c
+		}
+		return c
+	}
+
+	// 第一步：为每封邮件及其引用的邮件建立容器，并按References建立父子关系
+	for i, msg := range messages {
+		id := msg.MessageID
+		if id == "" || (idTable[id] != nil && idTable[id].Message != nil) {
+			// 没有Message-ID或Message-ID重复时使用唯一的占位ID
+			id = fmt.Sprintf("\x00%d", i)
+		}
+		container := getContainer(id)
+		container.Message = msg
+
+		var prev *Container
+		for _, ref := range msg.References {
+			if ref == "" || ref == msg.MessageID {
+				continue
+			}
+			refContainer := getContainer(ref)
+			if prev != nil && refContainer.Parent == nil && refContainer != prev && !reachable(refContainer, prev) {
+				link(prev, refContainer)
+			}
+			prev = refContainer
+		}
+
+		// 邮件的父节点总是References中的最后一个，覆盖之前根据其他邮件推断的关系
+		if container.Parent != nil {
+			unlink(container)
+		}
+		if prev != nil && prev != container && !reachable(container, prev) {
+			link(prev, container)
+		}
+	}
+
+	// 第二步：找出根节点，第三步：删除空容器
+	var roots []*Container
+	for _, c := range idTable {
+		if c.Parent == nil {
+			roots = append(roots, c)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].id < roots[j].id })
+	roots = pruneEmpty(roots, true)
+
+	// 第五步：按主题归并去掉了邮件头的回复
+	if groupBySubject {
+		roots = groupRootsBySubject(roots)
+	}
+
+	for _, root := range roots {
+		sortChildren(root)
+	}
+	sort.SliceStable(roots, func(i, j int) bool { return earliest(roots[i]).Before(earliest(roots[j])) })
+	return roots
+}
+
+// Group 返回每个会话包含的邮件，会话内按树的先序排列
+func Group(messages []*Message, groupBySubject bool) [][]*Message {
+	var groups [][]*Message
+	for _, root := range Build(messages, groupBySubject) {
+		var group []*Message
+		root.Walk(func(c *Container, depth int) {
+			if c.Message != nil {
+				group = append(group, c.Message)
+			}
+		})
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// Walk 先序遍历会话树，depth为相对于当前节点的层数
+func (c *Container) Walk(fn func(c *Container, depth int)) {
+	var walk func(node *Container, depth int)
+	walk = func(node *Container, depth int) {
+		fn(node, depth)
+		for _, child := range node.Children {
+			walk(child, depth+1)
+		}
+	}
+	walk(c, 0)
+}
+
+func link(parent, child *Container) {
+	child.Parent = parent
+	parent.Children = append(parent.Children, child)
+}
+
+func unlink(child *Container) {
+	parent := child.Parent
+	for i, c := range parent.Children {
+		if c == child {
+			parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+			break
+		}
+	}
+	child.Parent = nil
+}
+
+// reachable 判断 target 是否在 from 的子树中，用于防止建立循环引用
+func reachable(from, target *Container) bool {
+	if from == target {
+		return true
+	}
+	for _, child := range from.Children {
+		if reachable(child, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneEmpty 删除没有邮件的容器：没有子节点的直接删除，有子节点的把子节点提升一层
+// 根节点上的空容器只有一个子节点时才提升，多个子节点时保留以便把它们归为同一会话
+func pruneEmpty(containers []*Container, isRoot bool) []*Container {
+	var result []*Container
+	for _, c := range containers {
+		c.Children = pruneEmpty(c.Children, false)
+		if c.Message != nil {
+			result = append(result, c)
+			continue
+		}
+		switch {
+		case len(c.Children) == 0:
+			continue
+		case !isRoot || len(c.Children) == 1:
+			for _, child := range c.Children {
+				child.Parent = c.Parent
+			}
+			result = append(result, c.Children...)
+		default:
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// rootSubject 取根节点的主题，空容器取第一个子节点的主题
+func rootSubject(c *Container) (string, bool) {
+	if c.Message != nil {
+		return NormalizeSubject(c.Message.Subject)
+	}
+	for _, child := range c.Children {
+		if child.Message != nil {
+			return NormalizeSubject(child.Message.Subject)
+		}
+	}
+	return "", false
+}
+
+// SubjectOnlyReply 邮件带有回复/转发前缀但没有References和In-Reply-To，只能按主题找到所回复的邮件
+func SubjectOnlyReply(msg *Message) bool {
+	if msg == nil || len(msg.References) > 0 {
+		return false
+	}
+	_, isReply := NormalizeSubject(msg.Subject)
+	return isReply
+}
+
+// groupRootsBySubject 把去掉了回复邮件头的回复按规范化主题挂到同主题的根节点下
+// 两个都不是这种回复的根节点即使主题相同也不合并，避免定期发送的同主题通知（如 Arrival Notice）连成一个会话
+func groupRootsBySubject(roots []*Container) []*Container {
+	bySubject := make(map[string][]*Container)
+	for _, c := range roots {
+		if subject, _ := rootSubject(c); subject != "" {
+			bySubject[subject] = append(bySubject[subject], c)
+		}
+	}
+
+	var result []*Container
+	for _, c := range roots {
+		if !SubjectOnlyReply(c.Message) {
+			result = append(result, c)
+			continue
+		}
+		subject, _ := rootSubject(c)
+		if parent := subjectParent(c, bySubject[subject]); parent != nil {
+			link(parent, c)
+		} else {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// subjectParent 为只能按主题归并的回复选择父节点：优先选在它之前最近的同主题根节点，
+// 同主题的根节点都是这种回复时挂到最早的一封下面，最早的一封自己作为会话的根
+func subjectParent(reply *Container, candidates []*Container) *Container {
+	var before, after, firstReply *Container
+	date := reply.Message.Date
+	for _, c := range candidates {
+		if c == reply {
+			continue
+		}
+		if SubjectOnlyReply(c.Message) {
+			if rootBefore(c, reply) && (firstReply == nil || rootBefore(c, firstReply)) {
+				firstReply = c
+			}
+			continue
+		}
+		if t := earliest(c); date.IsZero() || !t.After(date) {
+			if before == nil || rootBefore(before, c) {
+				before = c
+			}
+		} else if after == nil || rootBefore(c, after) {
+			after = c
+		}
+	}
+	switch {
+	case before != nil:
+		return before
+	case after != nil:
+		return after
+	}
+	return firstReply
+}
+
+// rootBefore 按最早的邮件时间比较两个根节点，时间相同时按ID比较，保证顺序确定
+func rootBefore(a, b *Container) bool {
+	ta, tb := earliest(a), earliest(b)
+	if !ta.Equal(tb) {
+		return ta.Before(tb)
+	}
+	return a.id < b.id
+}
+
+// earliest 返回子树中最早的邮件时间
+func earliest(c *Container) time.Time {
+	var t time.Time
+	c.Walk(func(node *Container, depth int) {
+		if node.Message != nil && !node.Message.Date.IsZero() && (t.IsZero() || node.Message.Date.Before(t)) {
+			t = node.Message.Date
+		}
+	})
+	return t
+}
+
+// sortChildren 按时间排列每一层的子节点
+func sortChildren(c *Container) {
+	sort.SliceStable(c.Children, func(i, j int) bool {
+		return earliest(c.Children[i]).Before(earliest(c.Children[j]))
+	})
+	for _, child := range c.Children {
+		sortChildren(child)
+	}
+}
@@ -0,0 +1,146 @@
+package thread
+
+import (
+	"testing"
+	"time"
+)
+
+func groupIDs(groups [][]*Message) [][]uint {
+	var result [][]uint
+	for _, group := range groups {
+		var ids []uint
+		for _, msg := range group {
+			ids = append(ids, msg.ID)
+		}
+		result = append(result, ids)
+	}
+	return result
+}
+
+func TestGroupByReferences(t *testing.T) {
+	base := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	messages := []*Message{
+		// 回复先于原邮件到达，中间缺一封
+		{ID: 3, MessageID: "c@x", References: []string{"a@x", "b@x"}, Subject: "Re: Re: 订舱 SO123", Date: base.Add(2 * time.Hour)},
+		{ID: 1, MessageID: "a@x", Subject: "订舱 SO123", Date: base},
+		{ID: 4, MessageID: "d@x", References: []string{"a@x"}, Subject: "回复：订舱 SO123", Date: base.Add(time.Hour)},
+		{ID: 5, MessageID: "e@x", Subject: "Invoice", Date: base.Add(3 * time.Hour)},
+	}
+
+	got := groupIDs(Group(messages, false))
+	if len(got) != 2 {
+		t.Fatalf("应分为2个会话，实际: %v", got)
+	}
+	// a → (d, b(缺失) → c)，同层按时间排序
+	want := []uint{1, 4, 3}
+	for i, id := range want {
+		if got[0][i] != id {
+			t.Fatalf("会话顺序错误: %v", got)
+		}
+	}
+	if len(got[1]) != 1 || got[1][0] != 5 {
+		t.Errorf("无关邮件应单独成为会话: %v", got)
+	}
+
+	roots := Build(messages, false)
+	var depths []int
+	roots[0].Walk(func(c *Container, depth int) {
+		if c.Message != nil && c.Message.ID == 3 {
+			depths = append(depths, depth)
+		}
+	})
+	if len(depths) != 1 || depths[0] != 1 {
+		t.Errorf("缺失的中间邮件应被剪除，c应直接挂在a下: %v", depths)
+	}
+}
+
+func TestGroupBySubjectFallback(t *testing.T) {
+	messages := []*Message{
+		{ID: 1, MessageID: "a@x", Subject: "[ops] Booking 889"},
+		// 客户端去掉了References和In-Reply-To
+		{ID: 2, MessageID: "b@x", Subject: "RE: booking  889"},
+		{ID: 3, Subject: "Fwd: Booking 889"},
+		{ID: 4, MessageID: "d@x", Subject: "Other"},
+	}
+	if got := Group(messages, false); len(got) != 4 {
+		t.Errorf("不按主题合并时应为4个会话: %v", groupIDs(got))
+	}
+	got := groupIDs(Group(messages, true))
+	if len(got) != 2 || len(got[0])+len(got[1]) != 4 {
+		t.Fatalf("按主题合并后应为2个会话: %v", got)
+	}
+	for _, group := range got {
+		if len(group) == 3 && group[0] != 1 {
+			t.Errorf("原邮件应为会话的根: %v", got)
+		}
+	}
+}
+
+func TestGroupBySubjectKeepsNotificationsApart(t *testing.T) {
+	base := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	messages := []*Message{
+		// 每周发送的同主题通知，彼此没有回复关系
+		{ID: 1, MessageID: "n1@carrier.com", Subject: "Arrival Notice", Date: base},
+		{ID: 2, MessageID: "n2@carrier.com", Subject: "Arrival Notice", Date: base.Add(7 * 24 * time.Hour)},
+		{ID: 3, MessageID: "n3@carrier.com", Subject: "ARRIVAL NOTICE", Date: base.Add(14 * 24 * time.Hour)},
+	}
+	if got := groupIDs(Group(messages, true)); len(got) != 3 {
+		t.Fatalf("同主题的通知不应合并: %v", got)
+	}
+
+	// 去掉了邮件头的回复挂到在它之前最近的一封通知下
+	messages = append(messages, &Message{ID: 4, MessageID: "r@example.com", Subject: "Re: Arrival Notice", Date: base.Add(8 * 24 * time.Hour)})
+	got := groupIDs(Group(messages, true))
+	if len(got) != 3 {
+		t.Fatalf("回复应归入已有会话: %v", got)
+	}
+	if len(got[1]) != 2 || got[1][0] != 2 || got[1][1] != 4 {
+		t.Errorf("回复应挂在第二封通知下: %v", got)
+	}
+}
+
+func TestGroupBySubjectRepliesWithoutOriginal(t *testing.T) {
+	base := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	messages := []*Message{
+		{ID: 1, MessageID: "b@x", Subject: "RE: Booking 889", Date: base.Add(time.Hour)},
+		{ID: 2, MessageID: "a@x", Subject: "Re: Booking 889", Date: base},
+		{ID: 3, MessageID: "c@x", Subject: "Re: Booking 889", Date: base.Add(2 * time.Hour)},
+	}
+	got := groupIDs(Group(messages, true))
+	if len(got) != 1 || len(got[0]) != 3 || got[0][0] != 2 {
+		t.Errorf("没有原邮件时回复应归入最早的一封: %v", got)
+	}
+}
+
+func TestBuildIgnoresReferenceLoops(t *testing.T) {
+	messages := []*Message{
+		{ID: 1, MessageID: "a@x", References: []string{"b@x"}},
+		{ID: 2, MessageID: "b@x", References: []string{"a@x"}},
+		{ID: 3, MessageID: "a@x"}, // 重复的Message-ID
+	}
+	groups := Group(messages, false)
+	total := 0
+	for _, group := range groups {
+		total += len(group)
+	}
+	if total != 3 {
+		t.Errorf("循环引用和重复ID不应丢失邮件: %v", groupIDs(groups))
+	}
+}
+
+func TestNormalizeSubject(t *testing.T) {
+	cases := map[string]string{
+		"Re: Fwd: 订舱":         "订舱",
+		"回复：转发: 报价":           "报价",
+		"RE[2]: [list] Hello": "hello",
+		"[only-tag]":          "[only-tag]",
+	}
+	for input, want := range cases {
+		if got, _ := NormalizeSubject(input); got != want {
+			t.Errorf("NormalizeSubject(%q) = %q, 期望 %q", input, got, want)
+		}
+	}
+	if _, isReply := NormalizeSubject("Booking"); isReply {
+		t.Error("不带前缀的主题不应识别为回复")
+	}
+}
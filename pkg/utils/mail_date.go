@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// mailDateLayouts net/mail无法解析时依次尝试的日期格式，覆盖常见的不规范客户端
+var mailDateLayouts = []string{
+	"Mon, 2 Jan 2006 15:04:05 -0700 MST",
+	"Mon, 2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04:05",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 January 2006 15:04:05 -0700",
+	"Monday, 2 Jan 2006 15:04:05 -0700",
+	"Monday, January 2, 2006 15:04:05 -0700",
+	"Mon Jan 2 15:04:05 2006",
+	"Mon Jan 2 15:04:05 MST 2006",
+	"Mon Jan 2 15:04:05 -0700 2006",
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006/01/02 15:04:05 -0700",
+	"2006/01/02 15:04:05",
+}
+
+var (
+	// dateCommentPattern 日期后的注释，如 (CST)、(UTC)
+	dateCommentPattern = regexp.MustCompile(`\([^)]*\)`)
+	// dateOffsetZonePattern GMT+8、UTC+08:00 这类写法的时区
+	dateOffsetZonePattern = regexp.MustCompile(`(?i)\b(?:GMT|UTC)\s*([+-])(\d{1,2})(?::?(\d{2}))?\b`)
+	// dateColonOffsetPattern 时间后面带冒号的时区偏移，如 15:04:05 +08:00
+	dateColonOffsetPattern = regexp.MustCompile(`(\d{2}:\d{2}(?::\d{2})?\s+[+-]\d{2}):(\d{2})\b`)
+)
+
+// ParseMailDate 宽松解析邮件头中的日期，返回UTC时间
+// 去掉注释、规范时区写法后先按RFC 5322解析，失败时尝试常见的不规范格式；
+// 早于1970年或晚于当前时间一年以上的日期视为无效（包括ENVELOPE解析失败时的零值日期）
+func ParseMailDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	// GMT+8 这类时区需要先改写，net/mail会忽略GMT后面的偏移
+	value = dateCommentPattern.ReplaceAllString(value, " ")
+	value = dateOffsetZonePattern.ReplaceAllStringFunc(value, func(zone string) string {
+		m := dateOffsetZonePattern.FindStringSubmatch(zone)
+		hours, minutes := m[2], m[3]
+		if len(hours) == 1 {
+			hours = "0" + hours
+		}
+		if minutes == "" {
+			minutes = "00"
+		}
+		return m[1] + hours + minutes
+	})
+	value = dateColonOffsetPattern.ReplaceAllString(value, "$1$2")
+	value = strings.Join(strings.Fields(strings.TrimRight(value, ". ")), " ")
+	// 个别客户端星期后面没有逗号，如 Mon 2 Jan 2006
+	if len(value) > 4 && value[3] == ' ' && value[4] >= '0' && value[4] <= '9' && isWeekdayAbbr(value[:3]) {
+		value = value[:3] + "," + value[3:]
+	}
+
+	if t, err := mail.ParseDate(value); err == nil {
+		return NormalizeMailDate(t)
+	}
+	for _, layout := range mailDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return NormalizeMailDate(t)
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseReceivedDate 解析 Received 邮件头中分号后面的投递时间
+func ParseReceivedDate(received string) (time.Time, bool) {
+	i := strings.LastIndexByte(received, ';')
+	if i < 0 {
+		return time.Time{}, false
+	}
+	return ParseMailDate(received[i+1:])
+}
+
+// NormalizeMailDate 检查日期是否在合理范围内并转换为UTC
+func NormalizeMailDate(t time.Time) (time.Time, bool) {
+	if t.Year() < 1970 || t.After(time.Now().AddDate(1, 0, 0)) {
+		return time.Time{}, false
+	}
+	return t.UTC(), true
+}
+
+// isWeekdayAbbr 判断是否为英文星期缩写
+func isWeekdayAbbr(s string) bool {
+	switch strings.ToLower(s) {
+	case "mon", "tue", "wed", "thu", "fri", "sat", "sun":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMailDate(t *testing.T) {
+	want := time.Date(2024, 3, 5, 6, 7, 8, 0, time.UTC)
+	cases := []string{
+		"Tue, 05 Mar 2024 14:07:08 +0800",
+		"Tue, 5 Mar 2024 14:07:08 +0800 (CST)",
+		"5 Mar 2024 06:07:08 GMT",
+		"Tue 5 Mar 2024 14:07:08 +0800",
+		"Tue, 05 Mar 2024 14:07:08 GMT+8",
+		"Tue, 05 Mar 2024 14:07:08 UTC+08:00",
+		"Tue, 05 Mar 24 14:07:08 +0800",
+		"Tue Mar 5 06:07:08 2024",
+		"2024-03-05 14:07:08 +08:00",
+		"2024-03-05T14:07:08+08:00",
+		"2024-03-05 06:07:08",
+	}
+	for _, value := range cases {
+		got, ok := ParseMailDate(value)
+		if !ok {
+			t.Errorf("%q 应能解析", value)
+			continue
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("%q 解析为 %v，应为 %v", value, got, want)
+		}
+	}
+
+	for _, value := range []string{"", "not a date", "Mon, 01 Jan 0001 00:00:00 +0000", "Fri, 01 Jan 2300 00:00:00 +0000"} {
+		if got, ok := ParseMailDate(value); ok {
+			t.Errorf("%q 应解析失败，实际 %v", value, got)
+		}
+	}
+}
+
+func TestParseReceivedDate(t *testing.T) {
+	received := "from mx.example.com (mx.example.com [1.2.3.4])\r\n\tby mail.example.org with ESMTPS id abc;\r\n\tTue, 5 Mar 2024 14:07:08 +0800 (CST)"
+	got, ok := ParseReceivedDate(received)
+	if !ok || !got.Equal(time.Date(2024, 3, 5, 6, 7, 8, 0, time.UTC)) {
+		t.Errorf("Received时间解析错误: %v, %v", got, ok)
+	}
+	if _, ok := ParseReceivedDate("from a by b"); ok {
+		t.Error("没有分号时应解析失败")
+	}
+}
+
+func TestUTCTimeValue(t *testing.T) {
+	local := time.FixedZone("CST", 8*3600)
+	v, err := NewUTCTime(time.Date(2024, 3, 5, 14, 7, 8, 0, local)).Value()
+	if err != nil || v != "2024-03-05 06:07:08" {
+		t.Errorf("写入值错误: %v, %v", v, err)
+	}
+	if v, _ := (UTCTime{}).Value(); v != nil {
+		t.Errorf("零值应写入NULL，实际 %v", v)
+	}
+
+	var scanned UTCTime
+	if err := scanned.Scan(time.Date(2024, 3, 5, 6, 7, 8, 0, local)); err != nil {
+		t.Fatal(err)
+	}
+	if !scanned.Equal(time.Date(2024, 3, 5, 6, 7, 8, 0, time.UTC)) {
+		t.Errorf("读取值错误: %v", scanned.Time)
+	}
+	if err := scanned.Scan(nil); err != nil || !scanned.IsZero() {
+		t.Errorf("NULL应读取为零值: %v, %v", scanned.Time, err)
+	}
+}
@@ -105,6 +105,33 @@ func (u *OSSUploader) UploadFile(reader io.Reader, fileName string, folder strin
 	return fileURL, objectKey, nil
 }
 
+// UploadObject 按指定的对象键上传文件，不追加时间戳，已存在时覆盖
+func (u *OSSUploader) UploadObject(reader io.Reader, objectKey string) (string, error) {
+	err := u.bucket.PutObject(objectKey, reader)
+	if err != nil {
+		return "", fmt.Errorf("上传文件到OSS失败: %v", err)
+	}
+
+	// 返回文件URL
+	fileURL := fmt.Sprintf("%s/%s", u.config.Domain, objectKey)
+	return fileURL, nil
+}
+
+// GetObject 下载OSS中的文件内容
+func (u *OSSUploader) GetObject(objectKey string) ([]byte, error) {
+	body, err := u.bucket.GetObject(objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("下载OSS文件失败: %v", err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("读取OSS文件失败: %v", err)
+	}
+	return data, nil
+}
+
 // DeleteFile 删除OSS中的文件
 func (u *OSSUploader) DeleteFile(objectKey string) error {
 	err := u.bucket.DeleteObject(objectKey)
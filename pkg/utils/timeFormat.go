@@ -63,3 +63,58 @@ func (t JsonTime) WeekTime() string {
 	}
 	return t.Format("2006-01-02") + " " + weekMap[weekDay] + " " + t.Format("15:04")
 }
+
+// UTCTime 以UTC保存到DATETIME字段的时间，不受数据库连接 loc=Local 的时区转换影响；零值写入NULL
+type UTCTime struct {
+	time.Time
+}
+
+// NewUTCTime 创建UTC时间，零值返回零值
+func NewUTCTime(t time.Time) UTCTime {
+	if t.IsZero() {
+		return UTCTime{}
+	}
+	return UTCTime{Time: t.UTC()}
+}
+
+func (t UTCTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.UTC().Format(time.RFC3339) + `"`), nil
+}
+
+// Value 按UTC格式化为字符串写入，避免驱动按连接时区转换
+func (t UTCTime) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.UTC().Format(Format), nil
+}
+
+// Scan 驱动按连接时区解析出的时间，其字面值就是UTC时间
+func (t *UTCTime) Scan(v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		*t = UTCTime{}
+	case time.Time:
+		*t = UTCTime{Time: time.Date(value.Year(), value.Month(), value.Day(),
+			value.Hour(), value.Minute(), value.Second(), value.Nanosecond(), time.UTC)}
+	case []byte:
+		return t.scanString(string(value))
+	case string:
+		return t.scanString(value)
+	default:
+		return fmt.Errorf("can not convert %v to timestamp", v)
+	}
+	return nil
+}
+
+func (t *UTCTime) scanString(value string) error {
+	parsed, err := time.ParseInLocation(Format, value, time.UTC)
+	if err != nil {
+		return err
+	}
+	*t = UTCTime{Time: parsed}
+	return nil
+}